@@ -26,7 +26,14 @@ set -o pipefail
 resty \
   -I ./rootfs/etc/nginx/lua \
   --shdict "configuration_data 5M" \
+  --shdict "tcp_udp_configuration_data 5M" \
   --shdict "certificate_data 16M" \
   --shdict "balancer_ewma 1M" \
   --shdict "balancer_ewma_last_touched_at 1M" \
+  --shdict "global_throttle_cache 1M" \
+  --shdict "external_auth 1M" \
+  --shdict "healthcheck 1M" \
+  --shdict "outlier_detection 1M" \
+  --shdict "circuit_breaker 1M" \
+  --shdict "retry_budget 1M" \
   ./rootfs/etc/nginx/lua/test/run.lua ${BUSTED_ARGS} ./rootfs/etc/nginx/lua/test/
@@ -20,9 +20,20 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/controller/history"
+	"k8s.io/ingress-nginx/internal/ingress/controller/process"
 	"k8s.io/ingress-nginx/internal/nginx"
 )
 
@@ -30,6 +41,9 @@ const (
 	backendsPath = "/configuration/backends"
 	generalPath  = "/configuration/general"
 	certsPath    = "/configuration/certs"
+	desiredPath  = "/configuration/desired"
+	historyPath  = "/configuration/history"
+	reloadsPath  = "/configuration/reloads"
 )
 
 func main() {
@@ -72,6 +86,20 @@ func main() {
 	}
 	backendsCmd.AddCommand(backendsGetCmd)
 
+	backendsHealthCmd := &cobra.Command{
+		Use:   "health [backend name]",
+		Short: "Output the state of the active health checks of the endpoints",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			backendsHealth(name)
+		},
+	}
+	backendsCmd.AddCommand(backendsHealthCmd)
+
 	certCmd := &cobra.Command{
 		Use:   "certs",
 		Short: "Inspect dynamic SSL certificates",
@@ -108,6 +136,90 @@ func main() {
 	}
 	rootCmd.AddCommand(confCmd)
 
+	var healthPort int
+	diffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare the configuration the controller would generate with the running configuration",
+		Run: func(cmd *cobra.Command, args []string) {
+			diff(healthPort)
+		},
+	}
+	diffCmd.Flags().IntVar(&healthPort, "port", 10254, "Port of the health check endpoint of the controller")
+	rootCmd.AddCommand(diffCmd)
+
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Inspect the last configurations applied to nginx",
+	}
+	historyCmd.PersistentFlags().IntVar(&healthPort, "port", 10254, "Port of the health check endpoint of the controller")
+	rootCmd.AddCommand(historyCmd)
+
+	historyListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Output the list of revisions",
+		Run: func(cmd *cobra.Command, args []string) {
+			historyList(healthPort)
+		},
+	}
+	historyCmd.AddCommand(historyListCmd)
+
+	var onlyConf bool
+	historyShowCmd := &cobra.Command{
+		Use:   "show [revision]",
+		Short: "Output the configuration of a revision as JSON",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			historyShow(healthPort, args[0], onlyConf)
+		},
+	}
+	historyShowCmd.Flags().BoolVar(&onlyConf, "conf", false, "Output only the nginx.conf file of the revision")
+	historyCmd.AddCommand(historyShowCmd)
+
+	historyDiffCmd := &cobra.Command{
+		Use:   "diff [revision] [revision]",
+		Short: "Output the differences between two revisions",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			historyDiff(healthPort, args[0], args[1])
+		},
+	}
+	historyCmd.AddCommand(historyDiffCmd)
+
+	var byChecksum bool
+	historyPinCmd := &cobra.Command{
+		Use:   "pin [revision]",
+		Short: "Apply a revision and ignore any change until unpinned",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			param := "revision"
+			if byChecksum {
+				param = "checksum"
+			}
+			historyPost(healthPort, historyPath+"/pin?"+param+"="+url.QueryEscape(args[0]))
+		},
+	}
+	historyPinCmd.Flags().BoolVar(&byChecksum, "checksum", false, "Identify the revision by its checksum instead of its ID")
+	historyCmd.AddCommand(historyPinCmd)
+
+	historyUnpinCmd := &cobra.Command{
+		Use:   "unpin",
+		Short: "Apply the current configuration again",
+		Run: func(cmd *cobra.Command, args []string) {
+			historyPost(healthPort, historyPath+"/unpin")
+		},
+	}
+	historyCmd.AddCommand(historyUnpinCmd)
+
+	reloadsCmd := &cobra.Command{
+		Use:   "reloads",
+		Short: "Show the last reloads of nginx and the generations of workers still running",
+		Run: func(cmd *cobra.Command, args []string) {
+			reloads(healthPort)
+		},
+	}
+	reloadsCmd.Flags().IntVar(&healthPort, "port", 10254, "Port of the health check endpoint of the controller")
+	rootCmd.AddCommand(reloadsCmd)
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -191,6 +303,63 @@ func backendsGet(name string) {
 	fmt.Println("A backend of this name was not found.")
 }
 
+func backendsHealth(name string) {
+	health, requestErr := nginx.ReadBackendsHealth()
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+
+	if name != "" {
+		if _, ok := health[name]; !ok {
+			fmt.Println("A backend of this name with active health checks was not found.")
+			return
+		}
+	}
+
+	backends := make([]string, 0, len(health))
+	for backend := range health {
+		if name == "" || backend == name {
+			backends = append(backends, backend)
+		}
+	}
+	sort.Strings(backends)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKEND\tENDPOINT\tSTATE\tFAILURES\tSUCCESSES\tLAST CHECK\tLAST RESULT")
+	for _, backend := range backends {
+		endpoints := make([]string, 0, len(health[backend]))
+		for endpoint := range health[backend] {
+			endpoints = append(endpoints, endpoint)
+		}
+		sort.Strings(endpoints)
+
+		for _, endpoint := range endpoints {
+			eh := health[backend][endpoint]
+
+			state := "healthy"
+			if !eh.Healthy {
+				state = "unhealthy"
+			}
+
+			lastCheck := "-"
+			if eh.CheckedAt > 0 {
+				lastCheck = time.Unix(int64(eh.CheckedAt), 0).Format(time.RFC3339)
+			}
+
+			result := "-"
+			if eh.Error != "" {
+				result = eh.Error
+			} else if eh.Status != 0 {
+				result = strconv.Itoa(eh.Status)
+			}
+
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", backend, endpoint, state, eh.Failures, eh.Successes, lastCheck, result)
+		}
+	}
+	w.Flush()
+}
+
 func certGet(host string) {
 	statusCode, body, requestErr := nginx.NewGetStatusRequest(certsPath + "?hostname=" + host)
 	if requestErr != nil {
@@ -240,3 +409,185 @@ func readNginxConf() {
 
 	fmt.Println(conf)
 }
+
+func diff(port int) {
+	var desired ingress.DynamicConfiguration
+	requestErr := controllerGet(port, desiredPath, &desired)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+
+	hostnames := make([]string, 0, len(desired.Certificates))
+	for hostname := range desired.Certificates {
+		hostnames = append(hostnames, hostname)
+	}
+
+	running, readErr := nginx.ReadRunningConfiguration(hostnames)
+	if readErr != nil {
+		fmt.Println(readErr)
+		return
+	}
+
+	drift, diffErr := nginx.Diff(&desired, running)
+	if diffErr != nil {
+		fmt.Println(diffErr)
+		return
+	}
+
+	printed, _ := json.MarshalIndent(drift, "", "  ")
+	fmt.Println(string(printed))
+}
+
+// controllerGet decodes the JSON returned by the controller for a path
+func controllerGet(port int, path string, v interface{}) error {
+	res, err := http.Get(fmt.Sprintf("http://127.0.0.1:%v%v", port, path))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("controller returned code %v: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func historyList(port int) {
+	var summary history.Summary
+	requestErr := controllerGet(port, historyPath, &summary)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tTIMESTAMP\tCHECKSUM\tRELOAD\tTRIGGERS")
+	for _, rev := range summary.Revisions {
+		id := strconv.Itoa(rev.ID)
+		if rev.ID == summary.Pinned {
+			id += " (pinned)"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", id, rev.Timestamp.Format(time.RFC3339), rev.Checksum, rev.Reload, strings.Join(rev.Triggers, ", "))
+	}
+	w.Flush()
+}
+
+func getRevision(port int, id string) (*history.Revision, error) {
+	rev := &history.Revision{}
+	err := controllerGet(port, historyPath+"?revision="+url.QueryEscape(id), rev)
+	if err != nil {
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+func historyShow(port int, id string, onlyConf bool) {
+	rev, requestErr := getRevision(port, id)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+
+	if onlyConf {
+		fmt.Print(rev.NginxConf)
+		return
+	}
+
+	printed, _ := json.MarshalIndent(rev, "", "  ")
+	fmt.Println(string(printed))
+}
+
+func historyDiff(port int, a, b string) {
+	revA, requestErr := getRevision(port, a)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+
+	revB, requestErr := getRevision(port, b)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+
+	texts := []struct {
+		name string
+		a, b string
+	}{
+		{"nginx.conf", revA.NginxConf, revB.NginxConf},
+		{"backends", indent(revA.Backends), indent(revB.Backends)},
+		{"streams", indent(revA.Streams), indent(revB.Streams)},
+	}
+
+	for _, t := range texts {
+		out, diffErr := nginx.UnifiedDiff(t.a, t.b, fmt.Sprintf("%v@%v", t.name, a), fmt.Sprintf("%v@%v", t.name, b))
+		if diffErr != nil {
+			fmt.Println(diffErr)
+			return
+		}
+
+		fmt.Print(out)
+	}
+}
+
+func indent(data []byte) string {
+	var prettyBuffer bytes.Buffer
+	err := json.Indent(&prettyBuffer, data, "", "  ")
+	if err != nil {
+		return string(data)
+	}
+
+	return prettyBuffer.String() + "\n"
+}
+
+func historyPost(port int, path string) {
+	res, err := http.Post(fmt.Sprintf("http://127.0.0.1:%v%v", port, path), "application/json", nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusAccepted {
+		fmt.Printf("Controller returned code %v: %s\n", res.StatusCode, bytes.TrimSpace(body))
+		return
+	}
+
+	fmt.Println("OK")
+}
+
+func reloads(port int) {
+	var status process.ReloadStatus
+	requestErr := controllerGet(port, reloadsPath, &status)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RELOAD\tDURATION\tREADY\tTRIGGERS")
+	for _, reload := range status.Reloads {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", reload.Time.Format(time.RFC3339), reload.Duration.Round(time.Millisecond), reload.Ready, strings.Join(reload.Triggers, ", "))
+	}
+	w.Flush()
+
+	fmt.Println()
+
+	now := time.Now()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GENERATION\tAGE\tSTATE\tWORKERS\tCONNECTIONS\tMEMORY")
+	for _, g := range status.Generations {
+		state := "current"
+		if g.ShuttingDown {
+			state = "shutting down"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%vMiB\n", g.StartTime.Format(time.RFC3339), now.Sub(g.StartTime).Round(time.Second),
+			state, len(g.Workers), g.Connections, g.ResidentMemory/1024/1024)
+	}
+	w.Flush()
+}
@@ -20,17 +20,20 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
 
-	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/controller"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
 	ing_net "k8s.io/ingress-nginx/internal/net"
 	"k8s.io/ingress-nginx/internal/nginx"
 )
@@ -64,7 +67,13 @@ All ingress classes are satisfied if this parameter is left empty.`)
 			`Service fronting the Ingress controller.
 Takes the form "namespace/name". When used together with update-status, the
 controller mirrors the address of this service's endpoints to the load-balancer
-status of all Ingress objects it satisfies.`)
+status of all Ingress objects it satisfies. The placeholders {class} and {shard}
+are replaced by the ingress class and the shard index.`)
+
+		publishInternalSvc = flags.String("publish-internal-service", "",
+			`Service whose address is set as the load-balancer status of the Ingress objects with the
+status-address annotation set to internal. Takes the form "namespace/name" and accepts the
+placeholders of publish-service. Defaults to the cluster IP of publish-service.`)
 
 		tcpConfigMapName = flags.String("tcp-services-configmap", "",
 			`Name of the ConfigMap containing the definition of the TCP services to expose.
@@ -81,11 +90,16 @@ either be a port name or number.`)
 		resyncPeriod = flags.Duration("sync-period", 0,
 			`Period at which the controller forces the repopulation of its local object stores. Disabled by default.`)
 
-		watchNamespace = flags.String("watch-namespace", apiv1.NamespaceAll,
-			`Namespace the controller watches for updates to Kubernetes objects.
+		watchNamespaces = flags.StringSlice("watch-namespace", []string{},
+			`Namespaces the controller watches for updates to Kubernetes objects, separated by commas.
 This includes Ingresses, Services and all configuration resources. All
 namespaces are watched if this parameter is left empty.`)
 
+		watchNamespaceSelector = flags.String("watch-namespace-selector", "",
+			`Label selector of the namespaces the controller watches for updates to Ingresses.
+Namespaces gaining or losing the labels are added or removed without restart.
+Can be combined with watch-namespace.`)
+
 		profiling = flags.Bool("profiling", true,
 			`Enable profiling via web interface host:port/debug/pprof/`)
 
@@ -116,6 +130,14 @@ different namespace than their own. May be used together with watch-namespace.`)
 			`Update the load-balancer status of Ingress objects when the controller shuts down.
 Requires the update-status parameter.`)
 
+		shutdownDelay = flags.Duration("shutdown-delay", 0,
+			`Time to wait once the controller receives the SIGTERM signal before NGINX stops accepting connections.
+The health check fails while NGINX keeps serving, to let the load balancers deregister the pod.`)
+
+		shutdownTimeout = flags.Duration("shutdown-timeout", 5*time.Minute,
+			`Maximum time to wait for the in-flight requests and the long-lived connections to complete once NGINX stops accepting connections.
+The connections still open are closed when it expires. Zero waits forever.`)
+
 		useNodeInternalIP = flags.Bool("report-node-internal-ip-address", false,
 			`Set the load-balancer status of Ingress objects to internal Node addresses instead of external.
 Requires the update-status parameter.`)
@@ -137,10 +159,36 @@ extension for this to succeed.`)
 		syncRateLimit = flags.Float32("sync-rate-limit", 0.3,
 			`Define the sync frequency upper limit`)
 
+		reloadQuietPeriod = flags.Duration("reload-quiet-period", 0,
+			`Time without changes to wait before reloading NGINX, to apply several changes with a single reload.
+Changes that do not require a reload are applied immediately. Zero disables the batching.`)
+		reloadMaxDelay = flags.Duration("reload-max-delay", 30*time.Second,
+			`Maximum time a reload can be delayed by the reload-quiet-period. Zero does not limit the delay.`)
+
+		enableServerFragments = flags.Bool("enable-server-fragments", false,
+			`Render the server of each host in its own file included by nginx.conf, rendering again only the servers
+changed by each synchronization. Reduces the CPU usage of the synchronizations with many hosts.`)
+
+		shardCount = flags.Int("shard-count", 1,
+			`Number of controller deployments sharing the ingress class. Each host is served by the deployment
+of the shard of its hash.`)
+		shardIndex = flags.Int("shard-index", 0,
+			`Shard of the hosts served by this controller, from 0 to shard-count minus one.`)
+
+		configHistorySize = flags.Int("config-history-size", 10,
+			`Number of NGINX configurations kept in memory to inspect or pin them. Zero disables the history.`)
+
 		publishStatusAddress = flags.String("publish-status-address", "",
 			`Customized address to set as the load-balancer status of Ingress objects this controller satisfies.
 Requires the update-status parameter.`)
 
+		publishInternalStatusAddress = flags.String("publish-internal-status-address", "",
+			`Customized address to set as the load-balancer status of the Ingress objects with the
+status-address annotation set to internal.`)
+
+		statusUpdateRateLimit = flags.Float32("status-update-rate-limit", 10,
+			`Maximum number of Ingress status updates per second sent to the API server.`)
+
 		enableDynamicCertificates = flags.Bool("enable-dynamic-certificates", true,
 			`Dynamically update SSL certificates instead of reloading NGINX. Feature backed by OpenResty Lua libraries.`)
 
@@ -148,6 +196,8 @@ Requires the update-status parameter.`)
 			`Enables the collection of NGINX metrics`)
 		metricsPerHost = flags.Bool("metrics-per-host", true,
 			`Export metrics per-host`)
+		metricsPerUpstreamEndpoint = flags.Bool("metrics-per-upstream-endpoint", false,
+			`Export the response time of every endpoint of the upstreams. The number of metrics grows with the number of endpoints.`)
 
 		httpPort      = flags.Int("http-port", 80, `Port to use for servicing HTTP traffic.`)
 		httpsPort     = flags.Int("https-port", 443, `Port to use for servicing HTTPS traffic.`)
@@ -221,10 +271,46 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 		klog.Warningf("SSL certificate chain completion is disabled (--enable-ssl-chain-completion=false)")
 	}
 
+	if *shutdownDelay < 0 || *shutdownTimeout < 0 {
+		return false, nil, fmt.Errorf("flags --shutdown-delay and --shutdown-timeout must be zero or greater")
+	}
+
+	if *reloadQuietPeriod < 0 || *reloadMaxDelay < 0 {
+		return false, nil, fmt.Errorf("flags --reload-quiet-period and --reload-max-delay must be zero or greater")
+	}
+
+	if *shardCount < 1 || *shardIndex < 0 || *shardIndex >= *shardCount {
+		return false, nil, fmt.Errorf("flag --shard-index must be between zero and --shard-count minus one")
+	}
+
+	if *configHistorySize < 0 {
+		return false, nil, fmt.Errorf("flag --config-history-size must be zero or greater")
+	}
+
+	var namespaceSelector labels.Selector
+	if *watchNamespaceSelector != "" {
+		selector, err := labels.Parse(*watchNamespaceSelector)
+		if err != nil {
+			return false, nil, fmt.Errorf("invalid flag --watch-namespace-selector: %v", err)
+		}
+		namespaceSelector = selector
+	}
+
 	if *publishSvc != "" && *publishStatusAddress != "" {
 		return false, nil, fmt.Errorf("flags --publish-service and --publish-status-address are mutually exclusive")
 	}
 
+	if *publishInternalSvc != "" && *publishInternalStatusAddress != "" {
+		return false, nil, fmt.Errorf("flags --publish-internal-service and --publish-internal-status-address are mutually exclusive")
+	}
+
+	if *statusUpdateRateLimit <= 0 {
+		return false, nil, fmt.Errorf("flag --status-update-rate-limit must be greater than zero")
+	}
+
+	// a publish service for each ingress class or shard of a deployment
+	publishReplacer := strings.NewReplacer("{class}", class.IngressClass, "{shard}", strconv.Itoa(*shardIndex))
+
 	nginx.HealthPath = *defHealthzURL
 
 	if *defHealthCheckTimeout > 0 {
@@ -245,16 +331,32 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 		EnableSSLPassthrough:   *enableSSLPassthrough,
 		ResyncPeriod:           *resyncPeriod,
 		DefaultService:         *defaultSvc,
-		Namespace:              *watchNamespace,
+		Namespaces:             *watchNamespaces,
+		NamespaceSelector:      namespaceSelector,
 		ConfigMapName:          *configMap,
 		TCPConfigMapName:       *tcpConfigMapName,
 		UDPConfigMapName:       *udpConfigMapName,
 		DefaultSSLCertificate:  *defSSLCertificate,
-		PublishService:         *publishSvc,
+		PublishService:         publishReplacer.Replace(*publishSvc),
 		PublishStatusAddress:   *publishStatusAddress,
 		UpdateStatusOnShutdown: *updateStatusOnShutdown,
+		ShutdownDelay:          *shutdownDelay,
+		ShutdownTimeout:        *shutdownTimeout,
 		UseNodeInternalIP:      *useNodeInternalIP,
 		SyncRateLimit:          *syncRateLimit,
+		ReloadQuietPeriod:      *reloadQuietPeriod,
+		ReloadMaxDelay:         *reloadMaxDelay,
+		EnableServerFragments:  *enableServerFragments,
+		ConfigHistorySize:      *configHistorySize,
+		StatusUpdateRateLimit:  *statusUpdateRateLimit,
+
+		PublishInternalService:       publishReplacer.Replace(*publishInternalSvc),
+		PublishInternalStatusAddress: *publishInternalStatusAddress,
+		MetricsPerUpstreamEndpoint:   *metricsPerUpstreamEndpoint,
+		Shard: store.Shard{
+			Index: *shardIndex,
+			Count: *shardCount,
+		},
 		ListenPorts: &ngx_config.ListenPorts{
 			Default:  *defServerPort,
 			Health:   *healthzPort,
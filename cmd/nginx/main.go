@@ -20,10 +20,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -91,10 +93,10 @@ func main() {
 		klog.Infof("Validated %v as the default backend.", conf.DefaultService)
 	}
 
-	if conf.Namespace != "" {
-		_, err = kubeClient.CoreV1().Namespaces().Get(conf.Namespace, metav1.GetOptions{})
+	for _, namespace := range conf.Namespaces {
+		_, err = kubeClient.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
 		if err != nil {
-			klog.Fatalf("No namespace with name %v found: %v", conf.Namespace, err)
+			klog.Fatalf("No namespace with name %v found: %v", namespace, err)
 		}
 	}
 
@@ -118,7 +120,7 @@ func main() {
 
 	mc := metric.NewDummyCollector()
 	if conf.EnableMetrics {
-		mc, err = metric.NewCollector(conf.MetricsPerHost, reg)
+		mc, err = metric.NewCollector(conf.MetricsPerHost, conf.MetricsPerUpstreamEndpoint, reg)
 		if err != nil {
 			klog.Fatalf("Error creating prometheus collector:  %v", err)
 		}
@@ -139,6 +141,9 @@ func main() {
 	registerHealthz(ngx, mux)
 	registerMetrics(reg, mux)
 	registerHandlers(mux)
+	registerDesiredConfiguration(ngx, mux)
+	registerHistory(ngx, mux)
+	registerReloads(ngx, mux)
 
 	go startHTTPServer(conf.ListenPorts.Health, mux)
 
@@ -256,6 +261,115 @@ func registerHealthz(ic *controller.NGINXController, mux *http.ServeMux) {
 	)
 }
 
+// localOnly rejects the requests not coming from the same pod
+func localOnly(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		f(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// registerDesiredConfiguration exposes the configuration the controller would
+// send to NGINX, used by the dbg tool to detect drift
+func registerDesiredConfiguration(ic *controller.NGINXController, mux *http.ServeMux) {
+	mux.HandleFunc("/configuration/desired", localOnly(func(w http.ResponseWriter, r *http.Request) {
+		desired, err := ic.DesiredConfiguration()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, desired)
+	}))
+}
+
+// registerHistory exposes the last configurations applied to NGINX and
+// allows to pin one of them
+func registerHistory(ic *controller.NGINXController, mux *http.ServeMux) {
+	mux.HandleFunc("/configuration/history", localOnly(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("revision") == "" {
+			writeJSON(w, ic.Revisions())
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("revision"))
+		if err != nil {
+			http.Error(w, "invalid revision", http.StatusBadRequest)
+			return
+		}
+
+		rev, err := ic.Revision(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, rev)
+	}))
+
+	mux.HandleFunc("/configuration/history/pin", localOnly(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var err error
+		if checksum := r.URL.Query().Get("checksum"); checksum != "" {
+			// the checksum identifies the same revision in every pod
+			err = ic.PinRevisionChecksum(checksum)
+		} else {
+			var id int
+			id, err = strconv.Atoi(r.URL.Query().Get("revision"))
+			if err != nil {
+				http.Error(w, "invalid revision", http.StatusBadRequest)
+				return
+			}
+
+			err = ic.PinRevision(id)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	mux.HandleFunc("/configuration/history/unpin", localOnly(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ic.UnpinRevision()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+// registerReloads exposes the last reloads of NGINX and the generations of
+// workers still running
+func registerReloads(ic *controller.NGINXController, mux *http.ServeMux) {
+	mux.HandleFunc("/configuration/reloads", localOnly(func(w http.ResponseWriter, r *http.Request) {
+		status, err := ic.Reloads()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, status)
+	}))
+}
+
 func registerMetrics(reg *prometheus.Registry, mux *http.ServeMux) {
 	mux.Handle(
 		"/metrics",
@@ -0,0 +1,237 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"k8s.io/ingress-nginx/cmd/plugin/kubectl"
+	"k8s.io/ingress-nginx/cmd/plugin/request"
+	"k8s.io/ingress-nginx/cmd/plugin/util"
+	"k8s.io/ingress-nginx/internal/nginx"
+)
+
+// exitCodeDrift is the exit code used when at least one pod is not in sync,
+// distinct from the exit code of the other errors
+const exitCodeDrift = 2
+
+// DriftError is returned when at least one pod is not in sync
+type DriftError struct {
+	// Pods is the number of pods not in sync
+	Pods int
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("%v pods are not in sync", e.Pods)
+}
+
+// ExitCode returns the exit code of the plugin for the error
+func (e *DriftError) ExitCode() int {
+	return exitCodeDrift
+}
+
+// CreateCommand creates and returns this cobra subcommand
+func CreateCommand(flags *genericclioptions.ConfigFlags) *cobra.Command {
+	var pod, deployment *string
+	var output string
+	var port int
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare the configuration the ingress-nginx pods would generate with the configuration they are running",
+		Long: `Compare the configuration the ingress-nginx pods would generate with the configuration they are running.
+
+The command exits with code 2 when at least one pod is not in sync, and 1 on errors.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid --output value %v. Expected one of: text, json", output)
+			}
+
+			results, err := diff(flags, *pod, *deployment, port)
+			if err != nil {
+				return err
+			}
+
+			if output == "json" {
+				err = printJSON(results)
+			} else {
+				printText(results)
+			}
+			if err != nil {
+				return err
+			}
+
+			drift := 0
+			for _, r := range results {
+				if !r.inSync() {
+					drift++
+				}
+			}
+			if drift > 0 {
+				// the pods not in sync are already part of the output
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &DriftError{Pods: drift}
+			}
+
+			return nil
+		},
+	}
+
+	pod = util.AddPodFlag(cmd)
+	deployment = util.AddDeploymentFlag(cmd)
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format. One of: text, json")
+	cmd.Flags().IntVar(&port, "health-port", 10254, "Port of the health check endpoint of the ingress-nginx pods")
+
+	return cmd
+}
+
+// result contains the differences found in one pod
+type result struct {
+	Pod   string       `json:"pod"`
+	Drift *nginx.Drift `json:"drift,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+func (r result) inSync() bool {
+	return r.Error == "" && r.Drift != nil && r.Drift.InSync()
+}
+
+func diff(flags *genericclioptions.ConfigFlags, podName string, deployment string, port int) ([]result, error) {
+	var pods []apiv1.Pod
+	if podName != "" {
+		pod, err := request.GetNamedPod(flags, podName)
+		if err != nil {
+			return nil, err
+		}
+		pods = []apiv1.Pod{pod}
+	} else {
+		var err error
+		pods, err = request.GetDeploymentPods(flags, deployment)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]result, 0, len(pods))
+	for i := range pods {
+		r := result{Pod: pods[i].Name}
+
+		out, err := kubectl.PodExecString(flags, &pods[i], []string{"/dbg", "diff", fmt.Sprintf("--port=%v", port)})
+		if err != nil {
+			r.Error = err.Error()
+			results = append(results, r)
+			continue
+		}
+
+		drift := &nginx.Drift{}
+		err = json.Unmarshal([]byte(out), drift)
+		if err != nil {
+			// dbg prints errors as plain text
+			r.Error = strings.TrimSpace(out)
+		} else {
+			r.Drift = drift
+		}
+
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+func printJSON(results []result) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+func printText(results []result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POD\tSTATUS\tBACKENDS\tSTREAMS\tCERTIFICATES\tGENERAL\tNGINX.CONF")
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(w, "%v\terror\t-\t-\t-\t-\t-\n", r.Pod)
+		case r.inSync():
+			fmt.Fprintf(w, "%v\tin sync\t0\t0\t0\t0\tequal\n", r.Pod)
+		default:
+			conf := "equal"
+			if r.Drift.NginxConf != "" {
+				conf = "different"
+			}
+			fmt.Fprintf(w, "%v\tdrift\t%v\t%v\t%v\t%v\t%v\n", r.Pod,
+				len(r.Drift.Backends), len(r.Drift.Streams), len(r.Drift.Certificates), len(r.Drift.General), conf)
+		}
+	}
+	w.Flush()
+
+	for _, r := range results {
+		if r.inSync() {
+			continue
+		}
+
+		fmt.Printf("\n%v:\n", r.Pod)
+		if r.Error != "" {
+			fmt.Printf("  error: %v\n", r.Error)
+			continue
+		}
+
+		for _, b := range r.Drift.Backends {
+			fmt.Printf("  backend %v\n", describe(b))
+		}
+		for _, s := range r.Drift.Streams {
+			fmt.Printf("  stream %v\n", describe(s))
+		}
+		for _, c := range r.Drift.Certificates {
+			fmt.Printf("  certificate %v %v\n", c.Hostname, c.Reason)
+		}
+		if len(r.Drift.General) > 0 {
+			fmt.Printf("  general configuration changed: %v\n", strings.Join(r.Drift.General, ", "))
+		}
+		if r.Drift.NginxConf != "" {
+			fmt.Println("  nginx.conf is different, use --output json to see the diff")
+		}
+	}
+}
+
+func describe(b nginx.BackendDrift) string {
+	details := make([]string, 0)
+	if len(b.Fields) > 0 {
+		details = append(details, fmt.Sprintf("fields %v", strings.Join(b.Fields, ", ")))
+	}
+	if len(b.MissingEndpoints) > 0 {
+		details = append(details, fmt.Sprintf("missing endpoints %v", strings.Join(b.MissingEndpoints, ", ")))
+	}
+	if len(b.UnexpectedEndpoints) > 0 {
+		details = append(details, fmt.Sprintf("unexpected endpoints %v", strings.Join(b.UnexpectedEndpoints, ", ")))
+	}
+
+	if len(details) == 0 {
+		return fmt.Sprintf("%v %v", b.Name, b.Reason)
+	}
+
+	return fmt.Sprintf("%v %v: %v", b.Name, b.Reason, strings.Join(details, "; "))
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"k8s.io/ingress-nginx/cmd/plugin/kubectl"
+	"k8s.io/ingress-nginx/cmd/plugin/request"
+	"k8s.io/ingress-nginx/cmd/plugin/util"
+)
+
+// arguments maps the subcommands to the number of arguments they expect
+var arguments = map[string]int{
+	"list":  0,
+	"show":  1,
+	"diff":  2,
+	"pin":   1,
+	"unpin": 0,
+}
+
+// CreateCommand creates and returns this cobra subcommand
+func CreateCommand(flags *genericclioptions.ConfigFlags) *cobra.Command {
+	var pod, deployment *string
+	var onlyConf bool
+	var port int
+
+	cmd := &cobra.Command{
+		Use:   "history [list | show REVISION | diff REVISION REVISION | pin CHECKSUM | unpin]",
+		Short: "Inspect or pin the last configurations applied to an ingress-nginx instance",
+		Long: `Inspect or pin the last configurations applied to an ingress-nginx instance.
+
+list, show and diff inspect the revisions of a single pod. pin applies the
+revision with the given checksum to every pod of the deployment, or to the pod
+given with --pod, and unpin releases them. The pin is kept in memory: a pod
+restarted or created after the pin applies the current configuration of the
+cluster.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"list"}
+			}
+
+			expected, ok := arguments[args[0]]
+			if !ok {
+				return fmt.Errorf("unknown subcommand %v", args[0])
+			}
+			if len(args)-1 != expected {
+				return fmt.Errorf("%v expects %v argument(s)", args[0], expected)
+			}
+
+			command := append([]string{"/dbg", "history"}, args...)
+			command = append(command, fmt.Sprintf("--port=%v", port))
+			if onlyConf && args[0] == "show" {
+				command = append(command, "--conf")
+			}
+
+			if args[0] == "pin" || args[0] == "unpin" {
+				if args[0] == "pin" {
+					// the IDs of the revisions are different in every pod
+					command = append(command, "--checksum")
+				}
+
+				return pinAll(flags, *pod, *deployment, command)
+			}
+
+			return history(flags, *pod, *deployment, command)
+		},
+	}
+
+	pod = util.AddPodFlag(cmd)
+	deployment = util.AddDeploymentFlag(cmd)
+	cmd.Flags().BoolVar(&onlyConf, "conf", false, "Output only the nginx.conf file of the revision shown")
+	cmd.Flags().IntVar(&port, "health-port", 10254, "Port of the health check endpoint of the ingress-nginx pod")
+
+	return cmd
+}
+
+func history(flags *genericclioptions.ConfigFlags, podName string, deployment string, command []string) error {
+	pod, err := request.ChoosePod(flags, podName, deployment)
+	if err != nil {
+		return err
+	}
+
+	out, err := kubectl.PodExecString(flags, &pod, command)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(out)
+	return nil
+}
+
+// pinAll runs the command pinning or unpinning a revision in every pod of
+// the deployment, or only in the given pod
+func pinAll(flags *genericclioptions.ConfigFlags, podName string, deployment string, command []string) error {
+	var pods []apiv1.Pod
+	if podName != "" {
+		pod, err := request.GetNamedPod(flags, podName)
+		if err != nil {
+			return err
+		}
+		pods = []apiv1.Pod{pod}
+	} else {
+		var err error
+		pods, err = request.GetDeploymentPods(flags, deployment)
+		if err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for i := range pods {
+		out, err := kubectl.PodExecString(flags, &pods[i], command)
+		out = strings.TrimSpace(out)
+		if err == nil && out != "OK" {
+			err = errors.New(out)
+		}
+
+		if err != nil {
+			failed++
+			fmt.Printf("%v: %v\n", pods[i].Name, err)
+			continue
+		}
+
+		fmt.Printf("%v: %v\n", pods[i].Name, out)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v pods failed", failed, len(pods))
+	}
+
+	return nil
+}
@@ -18,6 +18,9 @@ package lint
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
 
 	"github.com/spf13/cobra"
 
@@ -29,33 +32,64 @@ import (
 	"k8s.io/ingress-nginx/cmd/plugin/lints"
 	"k8s.io/ingress-nginx/cmd/plugin/request"
 	"k8s.io/ingress-nginx/cmd/plugin/util"
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
 	"k8s.io/ingress-nginx/version"
 )
 
+// exitCodeProblems is the exit code used when at least one lint failed,
+// distinct from the exit code of the other errors
+const exitCodeProblems = 2
+
+// exitCodesHelp documents the exit codes in the help of the commands
+const exitCodesHelp = `When linting manifests with --filename, the command exits with code 2 when
+a problem is detected, and 1 on errors.`
+
+// ProblemsError is returned when the lints detect problems in the manifests
+type ProblemsError struct {
+	// Resources is the number of resources with problems
+	Resources int
+}
+
+func (e *ProblemsError) Error() string {
+	return fmt.Sprintf("problems detected in %v resources", e.Resources)
+}
+
+// ExitCode returns the exit code of the plugin for the error
+func (e *ProblemsError) ExitCode() int {
+	return exitCodeProblems
+}
+
+// silenceProblems prevents cobra from printing the usage and the error when
+// the lints detect problems, as they are already part of the output
+func silenceProblems(cmd *cobra.Command, err error) error {
+	if _, ok := err.(*ProblemsError); ok {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+	}
+
+	return err
+}
+
+type check struct {
+	kind string
+	f    func(opts lintOptions) ([]result, error)
+}
+
 // CreateCommand creates and returns this cobra subcommand
 func CreateCommand(flags *genericclioptions.ConfigFlags) *cobra.Command {
 	var opts *lintOptions
 	cmd := &cobra.Command{
 		Use:   "lint",
 		Short: "Inspect kubernetes resources for possible issues",
+		Long:  "Inspect kubernetes resources for possible issues.\n\n" + exitCodesHelp,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			err := opts.Validate()
 			if err != nil {
 				return err
 			}
 
-			fmt.Println("Checking ingresses...")
-			err = ingresses(*opts)
-			if err != nil {
-				util.PrintError(err)
-			}
-			fmt.Println("Checking deployments...")
-			err = deployments(*opts)
-			if err != nil {
-				util.PrintError(err)
-			}
-
-			return nil
+			return silenceProblems(cmd, run(*opts, check{"ingresses", ingresses}, check{"deployments", deployments}))
 		},
 	}
 
@@ -67,19 +101,20 @@ func CreateCommand(flags *genericclioptions.ConfigFlags) *cobra.Command {
 	return cmd
 }
 
-func createSubcommand(flags *genericclioptions.ConfigFlags, names []string, short string, f func(opts lintOptions) error) *cobra.Command {
+func createSubcommand(flags *genericclioptions.ConfigFlags, names []string, short string, f func(opts lintOptions) ([]result, error)) *cobra.Command {
 	var opts *lintOptions
 	cmd := &cobra.Command{
 		Use:     names[0],
 		Aliases: names[1:],
 		Short:   short,
+		Long:    short + ".\n\n" + exitCodesHelp,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			err := opts.Validate()
 			if err != nil {
 				return err
 			}
-			util.PrintError(f(*opts))
-			return nil
+
+			return silenceProblems(cmd, run(*opts, check{names[0], f}))
 		},
 	}
 
@@ -97,6 +132,8 @@ func addCommonOptions(flags *genericclioptions.ConfigFlags, cmd *cobra.Command)
 	cmd.Flags().BoolVarP(&out.verbose, "verbose", "v", false, "Show extra information about the lints")
 	cmd.Flags().StringVarP(&out.versionFrom, "from-version", "f", "0.0.0", "Use lints added for versions starting with this one")
 	cmd.Flags().StringVarP(&out.versionTo, "to-version", "t", version.RELEASE, "Use lints added for versions up to and including this one")
+	cmd.Flags().StringSliceVar(&out.filenames, "filename", []string{}, "Check the resources defined in these manifest files instead of the ones in the cluster. Use - to read from stdin")
+	cmd.Flags().StringVarP(&out.output, "output", "o", textOutput, fmt.Sprintf("Output format. One of: %v", outputFormats))
 
 	return &out
 }
@@ -108,6 +145,11 @@ type lintOptions struct {
 	verbose       bool
 	versionFrom   string
 	versionTo     string
+	filenames     []string
+	output        string
+
+	// manifests caches the objects read from filenames
+	manifests *manifests
 }
 
 func (opts *lintOptions) Validate() error {
@@ -121,17 +163,103 @@ func (opts *lintOptions) Validate() error {
 		return err
 	}
 
+	if !isValidOutput(opts.output) {
+		return fmt.Errorf("unsupported output format %v. Expected one of: %v", opts.output, outputFormats)
+	}
+
+	if len(opts.filenames) > 0 {
+		opts.manifests, err = readManifests(opts.filenames, util.GetNamespace(opts.flags))
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 type lint interface {
-	Check(obj kmeta.Object) bool
+	Problems(obj kmeta.Object, c *lints.Cluster) []string
+	ID() string
 	Message() string
 	Link() string
 	Version() string
 }
 
-func checkObjectArray(lints []lint, objects []kmeta.Object, opts lintOptions) {
+// problem is a single issue detected in a resource
+type problem struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	Link    string `json:"link,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// result contains the problems detected in a resource
+type result struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Source    string    `json:"source,omitempty"`
+	Problems  []problem `json:"problems"`
+}
+
+func (r result) displayName(opts lintOptions) string {
+	if opts.allNamespaces || opts.manifests != nil {
+		return r.Namespace + "/" + r.Name
+	}
+
+	return r.Name
+}
+
+func run(opts lintOptions, checks ...check) error {
+	results := make([]result, 0)
+	for _, c := range checks {
+		if opts.output == textOutput && len(checks) > 1 {
+			fmt.Printf("Checking %v...\n", c.kind)
+		}
+
+		r, err := c.f(opts)
+		if err != nil {
+			if opts.output != textOutput {
+				return err
+			}
+
+			util.PrintError(err)
+			continue
+		}
+
+		if opts.output == textOutput {
+			printText(r, opts)
+		}
+		results = append(results, r...)
+	}
+
+	if opts.output != textOutput {
+		err := printResults(results, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// checking a cluster reports the problems without failing
+	if opts.manifests == nil {
+		return nil
+	}
+
+	resources := 0
+	for _, r := range results {
+		if len(r.Problems) > 0 {
+			resources++
+		}
+	}
+
+	if resources > 0 {
+		return &ProblemsError{Resources: resources}
+	}
+
+	return nil
+}
+
+func checkObjectArray(kind string, lints []lint, objects []kmeta.Object, cluster *lints.Cluster, opts lintOptions) []result {
 	usedLints := make([]lint, 0)
 	for _, lint := range lints {
 		lintVersion := lint.Version()
@@ -143,28 +271,44 @@ func checkObjectArray(lints []lint, objects []kmeta.Object, opts lintOptions) {
 		}
 	}
 
+	results := make([]result, 0, len(objects))
 	for _, obj := range objects {
-		objName := obj.GetName()
-		if opts.allNamespaces {
-			objName = obj.GetNamespace() + "/" + obj.GetName()
+		r := result{
+			Kind:      kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			Source:    opts.manifests.source(kind, obj),
+			Problems:  make([]problem, 0),
 		}
 
-		failedLints := make([]lint, 0)
 		for _, lint := range usedLints {
-			if lint.Check(obj) {
-				failedLints = append(failedLints, lint)
+			for _, message := range lint.Problems(obj, cluster) {
+				r.Problems = append(r.Problems, problem{
+					ID:      lint.ID(),
+					Message: message,
+					Link:    lint.Link(),
+					Version: lint.Version(),
+				})
 			}
 		}
 
-		if len(failedLints) != 0 {
-			fmt.Printf("✗ %v\n", objName)
-			for _, lint := range failedLints {
-				fmt.Printf("  - %v\n", lint.Message())
-				if opts.verbose && lint.Version() != "" {
-					fmt.Printf("      Lint added for version %v\n", lint.Version())
+		results = append(results, r)
+	}
+
+	return results
+}
+
+func printText(results []result, opts lintOptions) {
+	for _, r := range results {
+		if len(r.Problems) != 0 {
+			fmt.Printf("✗ %v\n", r.displayName(opts))
+			for _, p := range r.Problems {
+				fmt.Printf("  - %v\n", p.Message)
+				if opts.verbose && p.Version != "" {
+					fmt.Printf("      Lint added for version %v\n", p.Version)
 				}
-				if opts.verbose && lint.Link() != "" {
-					fmt.Printf("      %v\n", lint.Link())
+				if opts.verbose && p.Link != "" {
+					fmt.Printf("      %v\n", p.Link)
 				}
 			}
 			fmt.Println("")
@@ -172,21 +316,23 @@ func checkObjectArray(lints []lint, objects []kmeta.Object, opts lintOptions) {
 		}
 
 		if opts.showAll {
-			fmt.Printf("✓ %v\n", objName)
+			fmt.Printf("✓ %v\n", r.displayName(opts))
 		}
 	}
 }
 
-func ingresses(opts lintOptions) error {
+func ingresses(opts lintOptions) ([]result, error) {
 	var ings []networking.Ingress
 	var err error
-	if opts.allNamespaces {
+	if opts.manifests != nil {
+		ings = opts.manifests.ingresses
+	} else if opts.allNamespaces {
 		ings, err = request.GetIngressDefinitions(opts.flags, "")
 	} else {
 		ings, err = request.GetIngressDefinitions(opts.flags, util.GetNamespace(opts.flags))
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var iLints []lints.IngressLint = lints.GetIngressLints()
@@ -200,20 +346,60 @@ func ingresses(opts lintOptions) error {
 		objects = append(objects, &ings[i])
 	}
 
-	checkObjectArray(genericLints, objects, opts)
-	return nil
+	if opts.manifests == nil {
+		cluster, errs := getCluster(opts)
+		for _, err := range errs {
+			// stderr keeps the machine-readable outputs valid
+			fmt.Fprintf(os.Stderr, "Warning: %v. The lints depending on these objects are skipped.\n", err)
+		}
+
+		return checkObjectArray("Ingress", genericLints, objects, cluster, opts), nil
+	}
+
+	results := checkObjectArray("Ingress", genericLints, objects, nil, opts)
+
+	// the annotation parsers require the referenced secrets, configmaps and
+	// services, which are only known when the manifests are linted offline
+	authDirectory, err := ioutil.TempDir("", "ingress-nginx-lint")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(authDirectory)
+	auth.AuthDirectory = authDirectory
+
+	extractor := annotations.NewAnnotationExtractor(opts.manifests)
+	for i := range ings {
+		errs := extractor.Validate(&ings[i])
+
+		names := make([]string, 0, len(errs))
+		for name := range errs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			results[i].Problems = append(results[i].Problems, problem{
+				ID:      fmt.Sprintf("invalid-annotation-%v", name),
+				Message: fmt.Sprintf("Invalid %v annotation: %v", name, errs[name]),
+			})
+		}
+	}
+
+	return results, nil
 }
 
-func deployments(opts lintOptions) error {
+func deployments(opts lintOptions) ([]result, error) {
 	var deps []appsv1.Deployment
 	var err error
-	if opts.allNamespaces {
+	if opts.manifests != nil {
+		deps = opts.manifests.deployments
+	} else if opts.allNamespaces {
 		deps, err = request.GetDeployments(opts.flags, "")
 	} else {
 		deps, err = request.GetDeployments(opts.flags, util.GetNamespace(opts.flags))
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var iLints []lints.DeploymentLint = lints.GetDeploymentLints()
@@ -227,6 +413,55 @@ func deployments(opts lintOptions) error {
 		objects = append(objects, &deps[i])
 	}
 
-	checkObjectArray(genericLints, objects, opts)
-	return nil
+	return checkObjectArray("Deployment", genericLints, objects, nil, opts), nil
+}
+
+// getCluster retrieves the objects used by the lints that cross-reference
+// the state of the cluster. Objects that cannot be retrieved, for instance
+// due to missing permissions, disable the lints that depend on them, and
+// the errors are returned to report them.
+func getCluster(opts lintOptions) (*lints.Cluster, []error) {
+	namespace := ""
+	if !opts.allNamespaces {
+		namespace = util.GetNamespace(opts.flags)
+	}
+
+	errs := make([]error, 0)
+
+	services, err := request.GetServices(opts.flags, namespace)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("cannot list services: %v", err))
+		services = nil
+	}
+
+	endpoints, err := request.GetEndpoints(opts.flags, namespace)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("cannot list endpoints: %v", err))
+		endpoints = nil
+	}
+
+	secrets, err := request.GetSecrets(opts.flags, namespace)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("cannot list secrets: %v", err))
+		secrets = nil
+	}
+
+	// canaries and their primary ingress can live in different namespaces
+	ings, err := request.GetIngressDefinitions(opts.flags, "")
+	if err != nil {
+		ings, err = request.GetIngressDefinitions(opts.flags, namespace)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot list ingresses: %v", err))
+			ings = nil
+		}
+	}
+
+	// ingress-nginx usually runs in a different namespace than the ingresses
+	deps, err := request.GetDeployments(opts.flags, "")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("cannot list deployments: %v", err))
+		deps = nil
+	}
+
+	return lints.NewCluster(services, endpoints, secrets, ings, deps), errs
 }
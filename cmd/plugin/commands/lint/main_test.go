@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRunWithManifests(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"valid.yaml": ingressManifest,
+		"invalid.yaml": `
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: invalid
+  annotations:
+    nginx.ingress.kubernetes.io/secure-backends: "true"
+spec:
+  backend:
+    serviceName: demo
+    servicePort: 80
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	m, err := readManifests([]string{dir}, "default")
+	if err != nil {
+		t.Fatalf("unexpected error reading manifests: %v", err)
+	}
+
+	opts := lintOptions{
+		versionFrom: "0.0.0",
+		versionTo:   "99.0.0",
+		output:      jsonOutput,
+		manifests:   m,
+	}
+
+	err = run(opts, check{"ingresses", ingresses})
+	problems, ok := err.(*ProblemsError)
+	if !ok {
+		t.Fatalf("expected a ProblemsError but returned %v", err)
+	}
+
+	// the exit code of the problems is distinct from the generic error code 1
+	if problems.Resources != 1 || problems.ExitCode() != 2 {
+		t.Errorf("unexpected error %+v", problems)
+	}
+
+	opts.manifests.ingresses = opts.manifests.ingresses[:0]
+	if err := run(opts, check{"ingresses", ingresses}); err != nil {
+		t.Errorf("unexpected error without problems: %v", err)
+	}
+}
+
+func TestRunWithoutManifests(t *testing.T) {
+	failing := func(opts lintOptions) ([]result, error) {
+		return []result{{Kind: "Ingress", Name: "a", Problems: []problem{{ID: "lint"}}}}, nil
+	}
+
+	// checking a cluster reports the problems without failing
+	opts := lintOptions{output: jsonOutput}
+	if err := run(opts, check{"ingresses", failing}); err != nil {
+		t.Errorf("unexpected error checking a cluster: %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	networking "k8s.io/api/networking/v1beta1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/defaults"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// stdinFilename is the filename used to read manifests from stdin
+const stdinFilename = "-"
+
+// manifests contains the objects read from manifest files, such as the
+// output of helm template. It also implements resolver.Resolver so the
+// annotation parsers can be used without a cluster.
+type manifests struct {
+	ingresses   []networking.Ingress
+	deployments []appsv1.Deployment
+
+	secrets    map[string]*apiv1.Secret
+	configMaps map[string]*apiv1.ConfigMap
+	services   map[string]*apiv1.Service
+
+	// sources maps kind/namespace/name to the file the object was read from
+	sources map[string]string
+
+	namespace string
+}
+
+// readManifests decodes all the objects contained in the given files or
+// directories. Objects without namespace are placed in the given namespace.
+// Kinds not relevant for the lints are ignored.
+func readManifests(filenames []string, namespace string) (*manifests, error) {
+	m := &manifests{
+		ingresses:   make([]networking.Ingress, 0),
+		deployments: make([]appsv1.Deployment, 0),
+		secrets:     make(map[string]*apiv1.Secret),
+		configMaps:  make(map[string]*apiv1.ConfigMap),
+		services:    make(map[string]*apiv1.Service),
+		sources:     make(map[string]string),
+		namespace:   namespace,
+	}
+
+	for _, filename := range filenames {
+		if filename == stdinFilename {
+			err := m.read(os.Stdin, "stdin")
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err := filepath.Walk(filename, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			// only filter by extension files found walking a directory
+			if path != filename && !isManifestFile(path) {
+				return nil
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			return m.read(f, path)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func isManifestFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+
+	return false
+}
+
+func (m *manifests) read(r io.Reader, source string) error {
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw runtime.RawExtension
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %v: %v", source, err)
+		}
+
+		err = m.add(raw.Raw, source)
+		if err != nil {
+			return fmt.Errorf("error reading %v: %v", source, err)
+		}
+	}
+}
+
+func (m *manifests) add(data []byte, source string) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch o := obj.(type) {
+	case *apiv1.List:
+		for _, item := range o.Items {
+			err := m.add(item.Raw, source)
+			if err != nil {
+				return err
+			}
+		}
+	case *networking.Ingress:
+		m.defaultNamespace(o)
+		m.ingresses = append(m.ingresses, *o)
+		m.sources[sourceKey("Ingress", o)] = source
+	case *extensions.Ingress:
+		// both API groups share the same definition of an Ingress
+		ing := &networking.Ingress{}
+		b, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(b, ing)
+		if err != nil {
+			return err
+		}
+
+		m.defaultNamespace(ing)
+		m.ingresses = append(m.ingresses, *ing)
+		m.sources[sourceKey("Ingress", ing)] = source
+	case *appsv1.Deployment:
+		m.defaultNamespace(o)
+		m.deployments = append(m.deployments, *o)
+		m.sources[sourceKey("Deployment", o)] = source
+	case *apiv1.Secret:
+		m.defaultNamespace(o)
+		// the API server merges stringData into data when the secret is created
+		for k, v := range o.StringData {
+			if o.Data == nil {
+				o.Data = make(map[string][]byte)
+			}
+			o.Data[k] = []byte(v)
+		}
+		m.secrets[o.Namespace+"/"+o.Name] = o
+	case *apiv1.ConfigMap:
+		m.defaultNamespace(o)
+		m.configMaps[o.Namespace+"/"+o.Name] = o
+	case *apiv1.Service:
+		m.defaultNamespace(o)
+		m.services[o.Namespace+"/"+o.Name] = o
+	}
+
+	return nil
+}
+
+func (m *manifests) defaultNamespace(obj kmeta.Object) {
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(m.namespace)
+	}
+}
+
+// source returns the file an object was read from, or the empty string
+// if the object was not read from a manifest
+func (m *manifests) source(kind string, obj kmeta.Object) string {
+	if m == nil {
+		return ""
+	}
+
+	return m.sources[sourceKey(kind, obj)]
+}
+
+func sourceKey(kind string, obj kmeta.Object) string {
+	return fmt.Sprintf("%v/%v/%v", kind, obj.GetNamespace(), obj.GetName())
+}
+
+// GetDefaultBackend returns the default configuration of the controller
+func (m *manifests) GetDefaultBackend() defaults.Backend {
+	return config.NewDefault().Backend
+}
+
+// GetConfigMap searches for a configmap in the manifests using the format namespace/name
+func (m *manifests) GetConfigMap(name string) (*apiv1.ConfigMap, error) {
+	cm, ok := m.configMaps[name]
+	if !ok {
+		return nil, fmt.Errorf("configmap %v not found in the manifests", name)
+	}
+
+	return cm, nil
+}
+
+// GetSecret searches for a secret in the manifests using the format namespace/name
+func (m *manifests) GetSecret(name string) (*apiv1.Secret, error) {
+	secret, ok := m.secrets[name]
+	if !ok {
+		return nil, fmt.Errorf("secret %v not found in the manifests", name)
+	}
+
+	return secret, nil
+}
+
+// GetAuthCertificate checks the secret contains a CA certificate. The
+// certificate is not written to disk.
+func (m *manifests) GetAuthCertificate(name string) (*resolver.AuthSSLCert, error) {
+	secret, err := m.GetSecret(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := secret.Data["ca.crt"]; !ok {
+		return nil, fmt.Errorf("secret %v does not contain a ca.crt key", name)
+	}
+
+	return &resolver.AuthSSLCert{
+		Secret: name,
+	}, nil
+}
+
+// GetService searches for a service in the manifests using the format namespace/name
+func (m *manifests) GetService(name string) (*apiv1.Service, error) {
+	svc, ok := m.services[name]
+	if !ok {
+		return nil, fmt.Errorf("service %v not found in the manifests", name)
+	}
+
+	return svc, nil
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const ingressManifest = `
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: demo
+  annotations:
+    nginx.ingress.kubernetes.io/auth-tls-secret: default/ca
+spec:
+  backend:
+    serviceName: demo
+    servicePort: 80
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: ca
+stringData:
+  ca.crt: certificate
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: headers
+  namespace: ingress-nginx
+data:
+  X-Header: value
+`
+
+const listManifest = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {
+      "apiVersion": "extensions/v1beta1",
+      "kind": "Ingress",
+      "metadata": {"name": "legacy", "namespace": "other"},
+      "spec": {"backend": {"serviceName": "legacy", "servicePort": 80}}
+    },
+    {
+      "apiVersion": "apps/v1",
+      "kind": "Deployment",
+      "metadata": {"name": "controller", "namespace": "ingress-nginx"},
+      "spec": {"selector": {}, "template": {"spec": {"containers": []}}}
+    },
+    {
+      "apiVersion": "v1",
+      "kind": "Service",
+      "metadata": {"name": "legacy", "namespace": "other"}
+    },
+    {
+      "apiVersion": "example.com/v1",
+      "kind": "Unknown",
+      "metadata": {"name": "ignored"}
+    }
+  ]
+}`
+
+func writeManifests(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatalf("unexpected error creating directory: %v", err)
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("unexpected error creating directory: %v", err)
+		}
+
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("unexpected error writing %v: %v", name, err)
+		}
+	}
+
+	return dir
+}
+
+func TestReadManifests(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"ingress.yaml":     ingressManifest,
+		"nested/list.json": listManifest,
+		"README.md":        "not a manifest",
+	})
+	defer os.RemoveAll(dir)
+
+	m, err := readManifests([]string{dir}, "default")
+	if err != nil {
+		t.Fatalf("unexpected error reading manifests: %v", err)
+	}
+
+	if len(m.ingresses) != 2 {
+		t.Fatalf("expected 2 ingresses but found %v", len(m.ingresses))
+	}
+
+	if len(m.deployments) != 1 {
+		t.Errorf("expected 1 deployment but found %v", len(m.deployments))
+	}
+
+	for _, ing := range m.ingresses {
+		expected := map[string]string{"demo": "default", "legacy": "other"}[ing.Name]
+		if ing.Namespace != expected {
+			t.Errorf("expected ingress %v in namespace %v but found %v", ing.Name, expected, ing.Namespace)
+		}
+
+		source := m.source("Ingress", &ing)
+		if filepath.Dir(source) != dir && filepath.Dir(source) != filepath.Join(dir, "nested") {
+			t.Errorf("unexpected source %v of ingress %v", source, ing.Name)
+		}
+	}
+
+	if m.source("Ingress", &m.ingresses[0]) == m.source("Ingress", &m.ingresses[1]) {
+		t.Errorf("expected the source file of each ingress")
+	}
+}
+
+func TestReadManifestsFile(t *testing.T) {
+	// a file given explicitly is read regardless of its extension
+	dir := writeManifests(t, map[string]string{"manifests.txt": ingressManifest})
+	defer os.RemoveAll(dir)
+
+	m, err := readManifests([]string{filepath.Join(dir, "manifests.txt")}, "default")
+	if err != nil {
+		t.Fatalf("unexpected error reading manifests: %v", err)
+	}
+
+	if len(m.ingresses) != 1 {
+		t.Errorf("expected 1 ingress but found %v", len(m.ingresses))
+	}
+}
+
+func TestReadManifestsInvalid(t *testing.T) {
+	dir := writeManifests(t, map[string]string{"invalid.yaml": "kind: Ingress\napiVersion: networking.k8s.io/v1beta1\nspec: [\n"})
+	defer os.RemoveAll(dir)
+
+	if _, err := readManifests([]string{dir}, "default"); err == nil {
+		t.Errorf("expected an error reading an invalid manifest")
+	}
+
+	if _, err := readManifests([]string{filepath.Join(dir, "missing.yaml")}, "default"); err == nil {
+		t.Errorf("expected an error reading a missing file")
+	}
+}
+
+func TestManifestsResolver(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"ingress.yaml": ingressManifest,
+		"list.json":    listManifest,
+	})
+	defer os.RemoveAll(dir)
+
+	m, err := readManifests([]string{dir}, "default")
+	if err != nil {
+		t.Fatalf("unexpected error reading manifests: %v", err)
+	}
+
+	secret, err := m.GetSecret("default/ca")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(secret.Data["ca.crt"]) != "certificate" {
+		t.Errorf("expected the stringData of the secret in its data but found %v", secret.Data)
+	}
+
+	if _, err := m.GetAuthCertificate("default/ca"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := m.GetConfigMap("ingress-nginx/headers"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := m.GetService("other/legacy"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for name, f := range map[string]func(string) error{
+		"secret":           func(n string) error { _, err := m.GetSecret(n); return err },
+		"auth certificate": func(n string) error { _, err := m.GetAuthCertificate(n); return err },
+		"configmap":        func(n string) error { _, err := m.GetConfigMap(n); return err },
+		"service":          func(n string) error { _, err := m.GetService(n); return err },
+	} {
+		if err := f("default/missing"); err == nil {
+			t.Errorf("expected an error getting a missing %v", name)
+		}
+	}
+}
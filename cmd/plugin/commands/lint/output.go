@@ -0,0 +1,256 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/ingress-nginx/version"
+)
+
+// Supported output formats
+const (
+	textOutput  = "text"
+	jsonOutput  = "json"
+	sarifOutput = "sarif"
+	junitOutput = "junit"
+)
+
+var outputFormats = []string{textOutput, jsonOutput, sarifOutput, junitOutput}
+
+func isValidOutput(output string) bool {
+	for _, o := range outputFormats {
+		if o == output {
+			return true
+		}
+	}
+
+	return false
+}
+
+// printResults writes the results to stdout using a machine-readable format
+func printResults(results []result, opts lintOptions) error {
+	var out interface{}
+	switch opts.output {
+	case jsonOutput:
+		out = results
+	case sarifOutput:
+		out = toSARIF(results)
+	case junitOutput:
+		b, err := xml.MarshalIndent(toJUnit(results, opts), "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%v%s\n", xml.Header, b)
+		return nil
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// SARIF 2.1.0 log, limited to the properties used by the lints
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID      string       `json:"id"`
+	HelpURI string       `json:"helpUri,omitempty"`
+	Short   sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+func toSARIF(results []result) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "ingress-nginx lint",
+				Version:        version.RELEASE,
+				InformationURI: "https://kubernetes.github.io/ingress-nginx/kubectl-plugin/",
+				Rules:          make([]sarifRule, 0),
+			},
+		},
+		Results: make([]sarifResult, 0),
+	}
+
+	rules := make(map[string]bool)
+	for _, r := range results {
+		for _, p := range r.Problems {
+			if !rules[p.ID] {
+				rules[p.ID] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:      p.ID,
+					HelpURI: p.Link,
+					Short:   sarifMessage{Text: p.Message},
+				})
+			}
+
+			location := sarifLocation{
+				LogicalLocations: []sarifLogicalLocation{{
+					FullyQualifiedName: fmt.Sprintf("%v/%v/%v", r.Kind, r.Namespace, r.Name),
+					Kind:               "resource",
+				}},
+			}
+			if r.Source != "" {
+				location.PhysicalLocation = &sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: r.Source},
+				}
+			}
+
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    p.ID,
+				Level:     "error",
+				Message:   sarifMessage{Text: fmt.Sprintf("%v %v/%v: %v", r.Kind, r.Namespace, r.Name, p.Message)},
+				Locations: []sarifLocation{location},
+			})
+		}
+	}
+
+	return sarifLog{
+		Schema:  "https://schemastore.azurewebsites.net/schemas/json/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
+
+// JUnit XML report, with one test suite per kind and one test case per resource
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func toJUnit(results []result, opts lintOptions) junitTestSuites {
+	out := junitTestSuites{
+		Suites: make([]junitTestSuite, 0),
+	}
+
+	suites := make(map[string]int)
+	for _, r := range results {
+		i, ok := suites[r.Kind]
+		if !ok {
+			i = len(out.Suites)
+			suites[r.Kind] = i
+			out.Suites = append(out.Suites, junitTestSuite{Name: r.Kind})
+		}
+
+		tc := junitTestCase{
+			Name:      r.displayName(opts),
+			ClassName: r.Kind,
+			File:      r.Source,
+		}
+
+		if len(r.Problems) > 0 {
+			ids := make([]string, 0, len(r.Problems))
+			messages := make([]string, 0, len(r.Problems))
+			for _, p := range r.Problems {
+				ids = append(ids, p.ID)
+				messages = append(messages, fmt.Sprintf("%v: %v", p.ID, p.Message))
+			}
+
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%v problem(s) detected", len(r.Problems)),
+				Type:    strings.Join(ids, ","),
+				Text:    strings.Join(messages, "\n"),
+			}
+
+			out.Suites[i].Failures++
+			out.Failures++
+		}
+
+		out.Suites[i].Tests++
+		out.Suites[i].Cases = append(out.Suites[i].Cases, tc)
+		out.Tests++
+	}
+
+	return out
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"testing"
+)
+
+func testResults() []result {
+	return []result{
+		{
+			Kind:      "Ingress",
+			Namespace: "default",
+			Name:      "a",
+			Source:    "manifests/a.yaml",
+			Problems: []problem{
+				{ID: "missing-service", Message: "Uses a service that does not exist", Link: "https://example.com/1"},
+				{ID: "invalid-annotation-CustomHTTPErrors", Message: "Invalid CustomHTTPErrors annotation"},
+			},
+		},
+		{
+			Kind:      "Ingress",
+			Namespace: "default",
+			Name:      "b",
+			Problems: []problem{
+				{ID: "missing-service", Message: "Uses a service that does not exist", Link: "https://example.com/1"},
+			},
+		},
+		{
+			Kind:      "Deployment",
+			Namespace: "ingress-nginx",
+			Name:      "controller",
+			Problems:  []problem{},
+		},
+	}
+}
+
+func TestToSARIF(t *testing.T) {
+	log := toSARIF(testResults())
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected SARIF log %+v", log)
+	}
+
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Errorf("expected a rule per lint but found %+v", run.Tool.Driver.Rules)
+	}
+
+	if run.Tool.Driver.Rules[0].ID != "missing-service" || run.Tool.Driver.Rules[0].HelpURI != "https://example.com/1" {
+		t.Errorf("unexpected rule %+v", run.Tool.Driver.Rules[0])
+	}
+
+	if len(run.Results) != 3 {
+		t.Fatalf("expected a result per problem but found %v", len(run.Results))
+	}
+
+	first := run.Results[0]
+	if first.RuleID != "missing-service" || first.Level != "error" {
+		t.Errorf("unexpected result %+v", first)
+	}
+
+	if first.Locations[0].PhysicalLocation == nil || first.Locations[0].PhysicalLocation.ArtifactLocation.URI != "manifests/a.yaml" {
+		t.Errorf("expected the manifest file as location but found %+v", first.Locations[0])
+	}
+
+	if first.Locations[0].LogicalLocations[0].FullyQualifiedName != "Ingress/default/a" {
+		t.Errorf("unexpected logical location %+v", first.Locations[0].LogicalLocations)
+	}
+
+	if run.Results[2].Locations[0].PhysicalLocation != nil {
+		t.Errorf("expected no physical location for a resource of the cluster")
+	}
+}
+
+func TestToSARIFWithoutProblems(t *testing.T) {
+	log := toSARIF([]result{})
+
+	if len(log.Runs[0].Results) != 0 || len(log.Runs[0].Tool.Driver.Rules) != 0 {
+		t.Errorf("expected no results and no rules but found %+v", log.Runs[0])
+	}
+}
+
+func TestToJUnit(t *testing.T) {
+	out := toJUnit(testResults(), lintOptions{allNamespaces: true})
+
+	if out.Tests != 3 || out.Failures != 2 {
+		t.Errorf("expected 3 tests and 2 failures but found %v and %v", out.Tests, out.Failures)
+	}
+
+	if len(out.Suites) != 2 {
+		t.Fatalf("expected a suite per kind but found %v", len(out.Suites))
+	}
+
+	ingresses := out.Suites[0]
+	if ingresses.Name != "Ingress" || ingresses.Tests != 2 || ingresses.Failures != 2 {
+		t.Errorf("unexpected suite %+v", ingresses)
+	}
+
+	tc := ingresses.Cases[0]
+	if tc.Name != "default/a" || tc.File != "manifests/a.yaml" || tc.Failure == nil {
+		t.Fatalf("unexpected test case %+v", tc)
+	}
+
+	if tc.Failure.Type != "missing-service,invalid-annotation-CustomHTTPErrors" {
+		t.Errorf("unexpected failure type %v", tc.Failure.Type)
+	}
+
+	deployments := out.Suites[1]
+	if deployments.Name != "Deployment" || deployments.Failures != 0 || deployments.Cases[0].Failure != nil {
+		t.Errorf("unexpected suite %+v", deployments)
+	}
+}
+
+func TestIsValidOutput(t *testing.T) {
+	for _, output := range outputFormats {
+		if !isValidOutput(output) {
+			t.Errorf("expected %v to be a valid output", output)
+		}
+	}
+
+	if isValidOutput("yaml") {
+		t.Errorf("expected yaml to be an invalid output")
+	}
+}
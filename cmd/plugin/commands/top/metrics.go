@@ -0,0 +1,192 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package top
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	requestDurationMetric  = "nginx_ingress_controller_request_duration_seconds"
+	endpointDurationMetric = "nginx_ingress_controller_upstream_endpoint_response_duration_seconds"
+	connectionsMetric      = "nginx_ingress_controller_nginx_process_connections"
+)
+
+// series contains the cumulative values of the request histograms
+// sharing the same grouping key
+type series struct {
+	requests float64
+	errors4x float64
+	errors5x float64
+	// buckets maps the upper bound of each bucket to its cumulative count
+	buckets map[float64]float64
+}
+
+func newSeries() *series {
+	return &series{
+		buckets: make(map[float64]float64),
+	}
+}
+
+// sub returns the increase of the values since a previous sample.
+// Series without a previous sample have no reference to compare with
+// and do not increase. Counter resets, for instance after a restart,
+// are handled using the current value as the increase.
+func (s *series) sub(prev *series) *series {
+	if prev == nil {
+		return newSeries()
+	}
+	if s.requests < prev.requests {
+		return s
+	}
+
+	out := &series{
+		requests: s.requests - prev.requests,
+		errors4x: s.errors4x - prev.errors4x,
+		errors5x: s.errors5x - prev.errors5x,
+		buckets:  make(map[float64]float64),
+	}
+	for le, count := range s.buckets {
+		out.buckets[le] = count - prev.buckets[le]
+	}
+	return out
+}
+
+func (s *series) add(other *series) {
+	s.requests += other.requests
+	s.errors4x += other.errors4x
+	s.errors5x += other.errors5x
+	for le, count := range other.buckets {
+		s.buckets[le] += count
+	}
+}
+
+// quantile estimates a quantile from the histogram buckets using linear
+// interpolation, like the histogram_quantile function of Prometheus
+func (s *series) quantile(q float64) float64 {
+	if s.requests == 0 || len(s.buckets) == 0 {
+		return math.NaN()
+	}
+
+	bounds := make([]float64, 0, len(s.buckets))
+	for le := range s.buckets {
+		bounds = append(bounds, le)
+	}
+	sort.Float64s(bounds)
+
+	rank := q * s.requests
+	lower, lowerCount := 0.0, 0.0
+	for _, le := range bounds {
+		count := s.buckets[le]
+		if count >= rank {
+			if math.IsInf(le, 1) {
+				// the quantile is above the highest finite bucket
+				return lower
+			}
+			if count == lowerCount {
+				return le
+			}
+			return lower + (le-lower)*(rank-lowerCount)/(count-lowerCount)
+		}
+		lower, lowerCount = le, count
+	}
+
+	return lower
+}
+
+// sample contains the metrics of one controller pod
+type sample struct {
+	connections float64
+	series      map[string]*series
+}
+
+// parseSample extracts the histogram of the grouping metric, grouped by
+// its labels, from the output of the metrics endpoint
+func parseSample(data []byte, groupBy grouping) (*sample, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sample{
+		series: make(map[string]*series),
+	}
+
+	if family, ok := families[connectionsMetric]; ok {
+		for _, m := range family.GetMetric() {
+			if labelValue(m, "state") == "active" {
+				s.connections += m.GetGauge().GetValue()
+			}
+		}
+	}
+
+	family, ok := families[groupBy.metric]
+	if !ok {
+		return s, nil
+	}
+
+	for _, m := range family.GetMetric() {
+		values := make([]string, 0, len(groupBy.labels))
+		for _, label := range groupBy.labels {
+			values = append(values, labelValue(m, label))
+		}
+		key := strings.Join(values, "/")
+
+		h := m.GetHistogram()
+		ser, ok := s.series[key]
+		if !ok {
+			ser = newSeries()
+			s.series[key] = ser
+		}
+
+		count := float64(h.GetSampleCount())
+		ser.requests += count
+		switch {
+		case strings.HasPrefix(labelValue(m, "status"), "4"):
+			ser.errors4x += count
+		case strings.HasPrefix(labelValue(m, "status"), "5"):
+			ser.errors5x += count
+		}
+
+		hasInf := false
+		for _, b := range h.GetBucket() {
+			ser.buckets[b.GetUpperBound()] += float64(b.GetCumulativeCount())
+			hasInf = hasInf || math.IsInf(b.GetUpperBound(), 1)
+		}
+		if !hasInf {
+			ser.buckets[math.Inf(1)] += count
+		}
+	}
+
+	return s, nil
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, label := range m.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return "-"
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package top
+
+import (
+	"math"
+	"testing"
+)
+
+const testMetrics = `
+# HELP nginx_ingress_controller_nginx_process_connections current number of client connections with state {active, reading, writing, waiting}
+# TYPE nginx_ingress_controller_nginx_process_connections gauge
+nginx_ingress_controller_nginx_process_connections{controller_class="nginx",controller_namespace="ingress-nginx",controller_pod="pod-a",state="active"} 7
+nginx_ingress_controller_nginx_process_connections{controller_class="nginx",controller_namespace="ingress-nginx",controller_pod="pod-a",state="waiting"} 3
+# HELP nginx_ingress_controller_request_duration_seconds The request processing time in milliseconds
+# TYPE nginx_ingress_controller_request_duration_seconds histogram
+nginx_ingress_controller_request_duration_seconds_bucket{controller_pod="pod-a",ingress="api",namespace="default",path="/",service="api",status="200",le="0.1"} 6
+nginx_ingress_controller_request_duration_seconds_bucket{controller_pod="pod-a",ingress="api",namespace="default",path="/",service="api",status="200",le="1"} 8
+nginx_ingress_controller_request_duration_seconds_bucket{controller_pod="pod-a",ingress="api",namespace="default",path="/",service="api",status="200",le="+Inf"} 8
+nginx_ingress_controller_request_duration_seconds_sum{controller_pod="pod-a",ingress="api",namespace="default",path="/",service="api",status="200"} 1.2
+nginx_ingress_controller_request_duration_seconds_count{controller_pod="pod-a",ingress="api",namespace="default",path="/",service="api",status="200"} 8
+nginx_ingress_controller_request_duration_seconds_bucket{controller_pod="pod-a",ingress="api",namespace="default",path="/v2",service="api",status="503",le="0.1"} 0
+nginx_ingress_controller_request_duration_seconds_bucket{controller_pod="pod-a",ingress="api",namespace="default",path="/v2",service="api",status="503",le="1"} 2
+nginx_ingress_controller_request_duration_seconds_bucket{controller_pod="pod-a",ingress="api",namespace="default",path="/v2",service="api",status="503",le="+Inf"} 2
+nginx_ingress_controller_request_duration_seconds_sum{controller_pod="pod-a",ingress="api",namespace="default",path="/v2",service="api",status="503"} 1
+nginx_ingress_controller_request_duration_seconds_count{controller_pod="pod-a",ingress="api",namespace="default",path="/v2",service="api",status="503"} 2
+# HELP nginx_ingress_controller_upstream_endpoint_response_duration_seconds The time spent on receiving the response from each endpoint of the upstream
+# TYPE nginx_ingress_controller_upstream_endpoint_response_duration_seconds histogram
+nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_pod="pod-a",endpoint="10.0.0.1:8080",ingress="api",namespace="default",service="api",status="404",le="0.1"} 4
+nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_pod="pod-a",endpoint="10.0.0.1:8080",ingress="api",namespace="default",service="api",status="404",le="+Inf"} 4
+nginx_ingress_controller_upstream_endpoint_response_duration_seconds_sum{controller_pod="pod-a",endpoint="10.0.0.1:8080",ingress="api",namespace="default",service="api",status="404"} 0.2
+nginx_ingress_controller_upstream_endpoint_response_duration_seconds_count{controller_pod="pod-a",endpoint="10.0.0.1:8080",ingress="api",namespace="default",service="api",status="404"} 4
+`
+
+func newTestSeries(requests, errors4x, errors5x float64, buckets map[float64]float64) *series {
+	return &series{
+		requests: requests,
+		errors4x: errors4x,
+		errors5x: errors5x,
+		buckets:  buckets,
+	}
+}
+
+func TestSeriesSub(t *testing.T) {
+	cur := newTestSeries(10, 2, 1, map[float64]float64{0.1: 6, math.Inf(1): 10})
+
+	testCases := []struct {
+		name     string
+		prev     *series
+		requests float64
+		errors5x float64
+		buckets  map[float64]float64
+	}{
+		{
+			name:     "without previous sample",
+			requests: 0,
+			errors5x: 0,
+			buckets:  map[float64]float64{},
+		},
+		{
+			name:     "with previous sample",
+			prev:     newTestSeries(4, 1, 0, map[float64]float64{0.1: 3, math.Inf(1): 4}),
+			requests: 6,
+			errors5x: 1,
+			buckets:  map[float64]float64{0.1: 3, math.Inf(1): 6},
+		},
+		{
+			name:     "after a counter reset",
+			prev:     newTestSeries(40, 1, 0, map[float64]float64{0.1: 30, math.Inf(1): 40}),
+			requests: 10,
+			errors5x: 1,
+			buckets:  map[float64]float64{0.1: 6, math.Inf(1): 10},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := cur.sub(tc.prev)
+			if out.requests != tc.requests || out.errors5x != tc.errors5x {
+				t.Errorf("expected %v requests and %v 5xx errors but got %v and %v", tc.requests, tc.errors5x, out.requests, out.errors5x)
+			}
+			if len(out.buckets) != len(tc.buckets) {
+				t.Fatalf("expected buckets %v but got %v", tc.buckets, out.buckets)
+			}
+			for le, count := range tc.buckets {
+				if out.buckets[le] != count {
+					t.Errorf("expected buckets %v but got %v", tc.buckets, out.buckets)
+				}
+			}
+		})
+	}
+}
+
+func TestSeriesQuantile(t *testing.T) {
+	s := newTestSeries(10, 0, 0, map[float64]float64{0.1: 5, 0.5: 9, 1: 9, math.Inf(1): 10})
+
+	testCases := []struct {
+		q        float64
+		expected float64
+	}{
+		// inside the first bucket, interpolated from zero
+		{0.3, 0.06},
+		// on the upper bound of the first bucket
+		{0.5, 0.1},
+		// interpolated inside the second bucket
+		{0.7, 0.3},
+		// above the highest finite bucket
+		{0.99, 1},
+	}
+
+	for _, tc := range testCases {
+		if v := s.quantile(tc.q); math.Abs(v-tc.expected) > 1e-9 {
+			t.Errorf("expected quantile %v to be %v but got %v", tc.q, tc.expected, v)
+		}
+	}
+
+	if v := newSeries().quantile(0.5); !math.IsNaN(v) {
+		t.Errorf("expected NaN for a series without requests but got %v", v)
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	s, err := parseSample([]byte(testMetrics), groupings["ingress"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.connections != 7 {
+		t.Errorf("expected 7 active connections but got %v", s.connections)
+	}
+	if len(s.series) != 1 {
+		t.Fatalf("expected one series but got %v", s.series)
+	}
+
+	ser, ok := s.series["default/api"]
+	if !ok {
+		t.Fatalf("expected a series for default/api but got %v", s.series)
+	}
+	if ser.requests != 10 || ser.errors4x != 0 || ser.errors5x != 2 {
+		t.Errorf("unexpected series %+v", ser)
+	}
+	if ser.buckets[0.1] != 6 || ser.buckets[1] != 10 || ser.buckets[math.Inf(1)] != 10 {
+		t.Errorf("unexpected buckets %v", ser.buckets)
+	}
+}
+
+func TestParseSampleByEndpoint(t *testing.T) {
+	s, err := parseSample([]byte(testMetrics), groupings["endpoint"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ser, ok := s.series["default/api/10.0.0.1:8080"]
+	if !ok || len(s.series) != 1 {
+		t.Fatalf("expected a series for default/api/10.0.0.1:8080 but got %v", s.series)
+	}
+	if ser.requests != 4 || ser.errors4x != 4 {
+		t.Errorf("unexpected series %+v", ser)
+	}
+}
+
+func TestParseSampleInvalid(t *testing.T) {
+	if _, err := parseSample([]byte("not metrics {"), groupings["ingress"]); err == nil {
+		t.Errorf("expected an error parsing invalid metrics")
+	}
+}
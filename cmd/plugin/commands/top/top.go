@@ -0,0 +1,317 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package top
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"k8s.io/ingress-nginx/cmd/plugin/request"
+	"k8s.io/ingress-nginx/cmd/plugin/util"
+)
+
+// grouping contains the histogram and the labels used to build the rows
+type grouping struct {
+	metric string
+	labels []string
+}
+
+// groupings maps the values of --group-by to the metrics and labels
+var groupings = map[string]grouping{
+	"host":     {requestDurationMetric, []string{"host"}},
+	"ingress":  {requestDurationMetric, []string{"namespace", "ingress"}},
+	"service":  {requestDurationMetric, []string{"namespace", "service"}},
+	"path":     {requestDurationMetric, []string{"namespace", "ingress", "path"}},
+	"pod":      {requestDurationMetric, []string{"controller_pod"}},
+	"endpoint": {endpointDurationMetric, []string{"namespace", "service", "endpoint"}},
+}
+
+var sortColumns = []string{"rps", "4xx", "5xx", "p50", "p99", "name"}
+
+type topOptions struct {
+	pod        string
+	deployment string
+	groupBy    string
+	sortBy     string
+	filter     string
+	interval   time.Duration
+	count      int
+	port       int
+	limit      int
+}
+
+// CreateCommand creates and returns this cobra subcommand
+func CreateCommand(flags *genericclioptions.ConfigFlags) *cobra.Command {
+	o := topOptions{}
+	var pod, deployment *string
+
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Show the live traffic of the ingress-nginx pods",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.pod = *pod
+			o.deployment = *deployment
+
+			if _, ok := groupings[o.groupBy]; !ok {
+				return fmt.Errorf("invalid --group-by value %v", o.groupBy)
+			}
+			if !isValidSort(o.sortBy) {
+				return fmt.Errorf("invalid --sort value %v. Expected one of: %v", o.sortBy, sortColumns)
+			}
+			if o.interval < time.Second {
+				return fmt.Errorf("--interval must be at least one second")
+			}
+
+			util.PrintError(top(flags, o))
+			return nil
+		},
+	}
+	pod = util.AddPodFlag(cmd)
+	deployment = util.AddDeploymentFlag(cmd)
+
+	cmd.Flags().StringVar(&o.groupBy, "group-by", "ingress", "Aggregate the traffic by host, ingress, service, path, pod or endpoint. Grouping by host requires --metrics-per-host and grouping by endpoint requires --metrics-per-upstream-endpoint in the controller")
+	cmd.Flags().StringVar(&o.sortBy, "sort", "rps", fmt.Sprintf("Sort the rows by one of: %v", sortColumns))
+	cmd.Flags().StringVar(&o.filter, "filter", "", "Show only the rows matching this regular expression")
+	cmd.Flags().DurationVar(&o.interval, "interval", 2*time.Second, "Time between refreshes")
+	cmd.Flags().IntVar(&o.count, "count", 0, "Number of refreshes before exiting. Zero means no limit")
+	cmd.Flags().IntVar(&o.port, "metrics-port", 10254, "Port of the metrics endpoint of the ingress-nginx pods")
+	cmd.Flags().IntVar(&o.limit, "limit", 0, "Maximum number of rows to show. Zero means no limit")
+
+	return cmd
+}
+
+func isValidSort(column string) bool {
+	for _, c := range sortColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// row contains the traffic of a group during the last interval
+type row struct {
+	name string
+	rps  float64
+	p4xx float64
+	p5xx float64
+	p50  float64
+	p99  float64
+	// connections is only known when the rows are grouped by pod
+	connections float64
+}
+
+func top(flags *genericclioptions.ConfigFlags, o topOptions) error {
+	var filter *regexp.Regexp
+	if o.filter != "" {
+		var err error
+		filter, err = regexp.Compile(o.filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	pods, err := listPods(flags, o)
+	if err != nil {
+		return err
+	}
+
+	prev := collect(flags, pods, o)
+	last := time.Now()
+	for i := 0; o.count == 0 || i < o.count; i++ {
+		time.Sleep(o.interval)
+
+		// the pods are listed again on every refresh to follow the rollouts
+		// and the scaling of the deployment. The previous pods are kept when
+		// they cannot be listed.
+		if current, err := listPods(flags, o); err == nil {
+			pods = current
+		}
+
+		cur := collect(flags, pods, o)
+		now := time.Now()
+
+		rows, connections := aggregate(prev, cur, now.Sub(last).Seconds(), filter)
+		sortRows(rows, o.sortBy)
+		if o.limit > 0 && len(rows) > o.limit {
+			rows = rows[:o.limit]
+		}
+
+		printTable(rows, connections, cur, pods, o)
+
+		prev, last = cur, now
+	}
+
+	return nil
+}
+
+// listPods returns the pod selected by --pod, or the pods of the deployment
+func listPods(flags *genericclioptions.ConfigFlags, o topOptions) ([]apiv1.Pod, error) {
+	if o.pod != "" {
+		pod, err := request.GetNamedPod(flags, o.pod)
+		if err != nil {
+			return nil, err
+		}
+		return []apiv1.Pod{pod}, nil
+	}
+
+	return request.GetDeploymentPods(flags, o.deployment)
+}
+
+// collect retrieves the metrics of every pod. Pods that cannot be reached are
+// not present in the returned map.
+func collect(flags *genericclioptions.ConfigFlags, pods []apiv1.Pod, o topOptions) map[string]*sample {
+	samples := make(map[string]*sample)
+	for _, pod := range pods {
+		data, err := request.GetPodHTTP(flags, pod, o.port, "/metrics")
+		if err != nil {
+			continue
+		}
+
+		s, err := parseSample(data, groupings[o.groupBy])
+		if err != nil {
+			continue
+		}
+
+		samples[pod.Name] = s
+	}
+
+	return samples
+}
+
+// aggregate computes the traffic of every group across all the pods
+func aggregate(prev, cur map[string]*sample, seconds float64, filter *regexp.Regexp) ([]row, float64) {
+	connections := 0.0
+	totals := make(map[string]*series)
+	for pod, s := range cur {
+		connections += s.connections
+
+		p, ok := prev[pod]
+		if !ok {
+			// the first sample of a pod has no reference to compare with
+			continue
+		}
+
+		for key, ser := range s.series {
+			if filter != nil && !filter.MatchString(key) {
+				continue
+			}
+
+			total, ok := totals[key]
+			if !ok {
+				total = newSeries()
+				totals[key] = total
+			}
+			total.add(ser.sub(p.series[key]))
+		}
+	}
+
+	rows := make([]row, 0, len(totals))
+	for key, total := range totals {
+		r := row{
+			name: key,
+			rps:  total.requests / seconds,
+			p50:  total.quantile(0.5),
+			p99:  total.quantile(0.99),
+		}
+		if s, ok := cur[key]; ok {
+			r.connections = s.connections
+		}
+		if total.requests > 0 {
+			r.p4xx = 100 * total.errors4x / total.requests
+			r.p5xx = 100 * total.errors5x / total.requests
+		}
+		rows = append(rows, r)
+	}
+
+	return rows, connections
+}
+
+func sortRows(rows []row, column string) {
+	value := func(r row) float64 {
+		var v float64
+		switch column {
+		case "rps":
+			v = r.rps
+		case "4xx":
+			v = r.p4xx
+		case "5xx":
+			v = r.p5xx
+		case "p50":
+			v = r.p50
+		case "p99":
+			v = r.p99
+		}
+		if math.IsNaN(v) {
+			return -1
+		}
+		return v
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if column == "name" {
+			return rows[i].name < rows[j].name
+		}
+
+		vi, vj := value(rows[i]), value(rows[j])
+		if vi == vj {
+			return rows[i].name < rows[j].name
+		}
+		return vi > vj
+	})
+}
+
+func printTable(rows []row, connections float64, cur map[string]*sample, pods []apiv1.Pod, o topOptions) {
+	// clear the screen and move the cursor to the top left corner
+	fmt.Print("\033[H\033[2J")
+	fmt.Printf("%v  pods: %v/%v  active connections: %.0f  interval: %v\n\n",
+		time.Now().Format("15:04:05"), len(cur), len(pods), connections, o.interval)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%v\tRPS\t4XX%%\t5XX%%\tP50\tP99", strings.ToUpper(o.groupBy))
+	if o.groupBy == "pod" {
+		fmt.Fprint(w, "\tCONNECTIONS")
+	}
+	fmt.Fprintln(w)
+
+	for _, r := range rows {
+		fmt.Fprintf(w, "%v\t%.1f\t%.1f\t%.1f\t%v\t%v", r.name, r.rps, r.p4xx, r.p5xx, latency(r.p50), latency(r.p99))
+		if o.groupBy == "pod" {
+			fmt.Fprintf(w, "\t%.0f", r.connections)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+func latency(seconds float64) string {
+	if math.IsNaN(seconds) {
+		return "-"
+	}
+	return fmt.Sprintf("%.0fms", seconds*1000)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package top
+
+import (
+	"math"
+	"regexp"
+	"testing"
+)
+
+func newTestSample(connections float64, series map[string]*series) *sample {
+	return &sample{
+		connections: connections,
+		series:      series,
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	prev := map[string]*sample{
+		"pod-a": newTestSample(5, map[string]*series{
+			"default/api": newTestSeries(10, 0, 0, map[float64]float64{0.1: 10, math.Inf(1): 10}),
+		}),
+	}
+	cur := map[string]*sample{
+		"pod-a": newTestSample(7, map[string]*series{
+			"default/api": newTestSeries(30, 4, 2, map[float64]float64{0.1: 30, math.Inf(1): 30}),
+			// appeared during the interval, the cumulative value is not an increase
+			"default/web": newTestSeries(1000, 0, 0, map[float64]float64{0.1: 1000, math.Inf(1): 1000}),
+		}),
+		// first sample of the pod, only the connections are counted
+		"pod-b": newTestSample(3, map[string]*series{
+			"default/api": newTestSeries(500, 0, 0, map[float64]float64{0.1: 500, math.Inf(1): 500}),
+		}),
+	}
+
+	rows, connections := aggregate(prev, cur, 2, nil)
+	if connections != 10 {
+		t.Errorf("expected 10 active connections but got %v", connections)
+	}
+
+	sortRows(rows, "name")
+	if len(rows) != 2 {
+		t.Fatalf("expected two rows but got %+v", rows)
+	}
+
+	api := rows[0]
+	if api.name != "default/api" || api.rps != 10 || api.p4xx != 20 || api.p5xx != 10 {
+		t.Errorf("unexpected row %+v", api)
+	}
+	if math.Abs(api.p50-0.05) > 1e-9 {
+		t.Errorf("expected p50 of 50ms but got %v", api.p50)
+	}
+
+	web := rows[1]
+	if web.name != "default/web" || web.rps != 0 || !math.IsNaN(web.p50) {
+		t.Errorf("unexpected row %+v", web)
+	}
+}
+
+func TestAggregateFilter(t *testing.T) {
+	prev := map[string]*sample{
+		"pod-a": newTestSample(0, map[string]*series{
+			"default/api": newTestSeries(10, 0, 0, map[float64]float64{math.Inf(1): 10}),
+			"default/web": newTestSeries(10, 0, 0, map[float64]float64{math.Inf(1): 10}),
+		}),
+	}
+	cur := map[string]*sample{
+		"pod-a": newTestSample(0, map[string]*series{
+			"default/api": newTestSeries(20, 0, 0, map[float64]float64{math.Inf(1): 20}),
+			"default/web": newTestSeries(20, 0, 0, map[float64]float64{math.Inf(1): 20}),
+		}),
+	}
+
+	rows, _ := aggregate(prev, cur, 1, regexp.MustCompile("web"))
+	if len(rows) != 1 || rows[0].name != "default/web" || rows[0].rps != 10 {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+}
+
+func TestSortRows(t *testing.T) {
+	rows := []row{
+		{name: "b", rps: 1, p99: math.NaN()},
+		{name: "a", rps: 1, p99: 0.2},
+		{name: "c", rps: 5, p99: 0.1},
+	}
+
+	sortRows(rows, "rps")
+	if rows[0].name != "c" || rows[1].name != "a" || rows[2].name != "b" {
+		t.Errorf("unexpected order by rps %+v", rows)
+	}
+
+	sortRows(rows, "p99")
+	if rows[0].name != "a" || rows[1].name != "c" || rows[2].name != "b" {
+		t.Errorf("unexpected order by p99 %+v", rows)
+	}
+}
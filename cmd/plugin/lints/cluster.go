@@ -0,0 +1,410 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lints
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/net/ssl"
+)
+
+// Cluster contains the objects used by the lints that cross-reference the
+// state of the cluster. A nil field means the objects are not known, and
+// the lints depending on them are skipped.
+type Cluster struct {
+	// Services indexed by namespace/name
+	Services map[string]apiv1.Service
+	// Endpoints indexed by namespace/name
+	Endpoints map[string]apiv1.Endpoints
+	// Secrets indexed by namespace/name
+	Secrets map[string]apiv1.Secret
+	// Ingresses of the cluster, including the one being checked
+	Ingresses []networking.Ingress
+	// Deployments that may contain ingress-nginx controllers
+	Deployments []appsv1.Deployment
+}
+
+// NewCluster indexes the given objects. Any of the arguments can be nil
+// when the objects could not be retrieved.
+func NewCluster(services []apiv1.Service, endpoints []apiv1.Endpoints, secrets []apiv1.Secret,
+	ingresses []networking.Ingress, deployments []appsv1.Deployment) *Cluster {
+	c := &Cluster{
+		Ingresses:   ingresses,
+		Deployments: deployments,
+	}
+
+	if services != nil {
+		c.Services = make(map[string]apiv1.Service)
+		for _, svc := range services {
+			c.Services[key(svc.Namespace, svc.Name)] = svc
+		}
+	}
+
+	if endpoints != nil {
+		c.Endpoints = make(map[string]apiv1.Endpoints)
+		for _, ep := range endpoints {
+			c.Endpoints[key(ep.Namespace, ep.Name)] = ep
+		}
+	}
+
+	if secrets != nil {
+		c.Secrets = make(map[string]apiv1.Secret)
+		for _, secret := range secrets {
+			c.Secrets[key(secret.Namespace, secret.Name)] = secret
+		}
+	}
+
+	return c
+}
+
+func key(namespace, name string) string {
+	return fmt.Sprintf("%v/%v", namespace, name)
+}
+
+// ingressBackends returns all the backends referenced by an ingress
+func ingressBackends(ing networking.Ingress) []networking.IngressBackend {
+	backends := make([]networking.IngressBackend, 0)
+	if ing.Spec.Backend != nil {
+		backends = append(backends, *ing.Spec.Backend)
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			backends = append(backends, path.Backend)
+		}
+	}
+
+	return backends
+}
+
+// annotationValue returns the value of an annotation regardless of its prefix
+func annotationValue(ing networking.Ingress, name string) (string, bool) {
+	for annotation, val := range ing.Annotations {
+		if strings.HasSuffix(annotation, "/"+name) {
+			return val, true
+		}
+	}
+	return "", false
+}
+
+func annotationIsTrue(ing networking.Ingress, name string) bool {
+	val, ok := annotationValue(ing, name)
+	if !ok {
+		return false
+	}
+
+	b, err := strconv.ParseBool(val)
+	return err == nil && b
+}
+
+func servicePortExists(svc apiv1.Service, port intstr.IntOrString) bool {
+	for _, sp := range svc.Spec.Ports {
+		if port.Type == intstr.Int && sp.Port == port.IntVal {
+			return true
+		}
+		if port.Type == intstr.String && sp.Name == port.StrVal {
+			return true
+		}
+	}
+	return false
+}
+
+func missingService(ing networking.Ingress, c *Cluster) []string {
+	if c.Services == nil {
+		return nil
+	}
+
+	problems := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, backend := range ingressBackends(ing) {
+		name := key(ing.Namespace, backend.ServiceName)
+		if _, ok := c.Services[name]; ok || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		problems = append(problems, fmt.Sprintf("service %v", name))
+	}
+	return problems
+}
+
+func missingServicePort(ing networking.Ingress, c *Cluster) []string {
+	if c.Services == nil {
+		return nil
+	}
+
+	problems := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, backend := range ingressBackends(ing) {
+		name := key(ing.Namespace, backend.ServiceName)
+		svc, ok := c.Services[name]
+		if !ok || svc.Spec.Type == apiv1.ServiceTypeExternalName {
+			continue
+		}
+
+		problem := fmt.Sprintf("port %v of service %v", backend.ServicePort.String(), name)
+		if servicePortExists(svc, backend.ServicePort) || seen[problem] {
+			continue
+		}
+
+		seen[problem] = true
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+func serviceWithoutEndpoints(ing networking.Ingress, c *Cluster) []string {
+	if c.Services == nil || c.Endpoints == nil {
+		return nil
+	}
+
+	problems := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, backend := range ingressBackends(ing) {
+		name := key(ing.Namespace, backend.ServiceName)
+		svc, ok := c.Services[name]
+		if !ok || svc.Spec.Type == apiv1.ServiceTypeExternalName || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		addresses := 0
+		for _, subset := range c.Endpoints[name].Subsets {
+			addresses += len(subset.Addresses)
+		}
+
+		if addresses == 0 {
+			problems = append(problems, fmt.Sprintf("service %v", name))
+		}
+	}
+	return problems
+}
+
+func missingTLSSecret(ing networking.Ingress, c *Cluster) []string {
+	if c.Secrets == nil {
+		return nil
+	}
+
+	problems := make([]string, 0)
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName == "" {
+			continue
+		}
+
+		name := key(ing.Namespace, tls.SecretName)
+		if _, ok := c.Secrets[name]; !ok {
+			problems = append(problems, fmt.Sprintf("secret %v", name))
+		}
+	}
+	return problems
+}
+
+// tlsCertificate parses the certificate contained in a TLS secret
+func tlsCertificate(secret apiv1.Secret) (*x509.Certificate, error) {
+	data, ok := secret.Data[apiv1.TLSCertKey]
+	if !ok {
+		return nil, fmt.Errorf("missing %v key", apiv1.TLSCertKey)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %v", apiv1.TLSCertKey)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// checkTLSCertificates calls f for every TLS section of the ingress using a
+// secret present in the cluster
+func checkTLSCertificates(ing networking.Ingress, c *Cluster, f func(tls networking.IngressTLS, name string, cert *x509.Certificate, err error) []string) []string {
+	if c.Secrets == nil {
+		return nil
+	}
+
+	problems := make([]string, 0)
+	for _, tls := range ing.Spec.TLS {
+		name := key(ing.Namespace, tls.SecretName)
+		secret, ok := c.Secrets[name]
+		if tls.SecretName == "" || !ok {
+			continue
+		}
+
+		cert, err := tlsCertificate(secret)
+		problems = append(problems, f(tls, name, cert, err)...)
+	}
+	return problems
+}
+
+func invalidTLSCertificate(ing networking.Ingress, c *Cluster) []string {
+	return checkTLSCertificates(ing, c, func(tls networking.IngressTLS, name string, cert *x509.Certificate, err error) []string {
+		if err != nil {
+			return []string{fmt.Sprintf("secret %v: %v", name, err)}
+		}
+		return nil
+	})
+}
+
+func expiredTLSCertificate(ing networking.Ingress, c *Cluster) []string {
+	return checkTLSCertificates(ing, c, func(tls networking.IngressTLS, name string, cert *x509.Certificate, err error) []string {
+		if err == nil && time.Now().After(cert.NotAfter) {
+			return []string{fmt.Sprintf("secret %v expired on %v", name, cert.NotAfter.Format(time.RFC3339))}
+		}
+		return nil
+	})
+}
+
+func tlsCertificateHostMismatch(ing networking.Ingress, c *Cluster) []string {
+	return checkTLSCertificates(ing, c, func(tls networking.IngressTLS, name string, cert *x509.Certificate, err error) []string {
+		if err != nil {
+			return nil
+		}
+
+		problems := make([]string, 0)
+		for _, host := range tls.Hosts {
+			// same validation used by the controller, including the Common Name fallback
+			if cert.VerifyHostname(host) == nil || ssl.VerifyHostname(host, cert) == nil {
+				continue
+			}
+
+			problems = append(problems, fmt.Sprintf("secret %v is not valid for %v", name, host))
+		}
+		return problems
+	})
+}
+
+func canaryWithoutPrimary(ing networking.Ingress, c *Cluster) []string {
+	if c.Ingresses == nil || !annotationIsTrue(ing, "canary") {
+		return nil
+	}
+
+	problems := make([]string, 0)
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			if !hasPrimary(ing, rule.Host, path.Path, c.Ingresses) {
+				problems = append(problems, fmt.Sprintf("host %q path %q", rule.Host, path.Path))
+			}
+		}
+	}
+	return problems
+}
+
+func hasPrimary(canary networking.Ingress, host, path string, ingresses []networking.Ingress) bool {
+	for _, ing := range ingresses {
+		if (ing.Namespace == canary.Namespace && ing.Name == canary.Name) || annotationIsTrue(ing, "canary") {
+			continue
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host != host || rule.HTTP == nil {
+				continue
+			}
+
+			for _, p := range rule.HTTP.Paths {
+				if p.Path == path {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func sslPassthroughWithoutFlag(ing networking.Ingress, c *Cluster) []string {
+	if c.Deployments == nil || !annotationIsTrue(ing, "ssl-passthrough") {
+		return nil
+	}
+
+	controllers := make([]string, 0)
+	for _, dep := range c.Deployments {
+		if !isIngressNginxDeployment(dep) {
+			continue
+		}
+
+		for _, arg := range getNginxArgs(dep) {
+			if arg == "--enable-ssl-passthrough" || arg == "--enable-ssl-passthrough=true" {
+				return nil
+			}
+		}
+		controllers = append(controllers, key(dep.Namespace, dep.Name))
+	}
+
+	// without controllers we cannot tell how the flags are configured
+	if len(controllers) == 0 {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("checked %v", strings.Join(controllers, ", "))}
+}
+
+func sessionAffinity(ing networking.Ingress) string {
+	affinity, _ := annotationValue(ing, "affinity")
+	cookie, _ := annotationValue(ing, "session-cookie-name")
+	return fmt.Sprintf("affinity=%q session-cookie-name=%q", affinity, cookie)
+}
+
+func usesBackend(ing networking.Ingress, backend networking.IngressBackend) bool {
+	for _, b := range ingressBackends(ing) {
+		if b.ServiceName == backend.ServiceName && b.ServicePort.String() == backend.ServicePort.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func conflictingSessionAffinity(ing networking.Ingress, c *Cluster) []string {
+	if c.Ingresses == nil {
+		return nil
+	}
+
+	affinity := sessionAffinity(ing)
+	problems := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, backend := range ingressBackends(ing) {
+		for _, other := range c.Ingresses {
+			name := key(other.Namespace, other.Name)
+			if other.Namespace != ing.Namespace || other.Name == ing.Name || seen[name] {
+				continue
+			}
+
+			if !usesBackend(other, backend) || sessionAffinity(other) == affinity {
+				continue
+			}
+
+			seen[name] = true
+			problems = append(problems, fmt.Sprintf("ingress %v uses %v for service %v", name, sessionAffinity(other), backend.ServiceName))
+		}
+	}
+	return problems
+}
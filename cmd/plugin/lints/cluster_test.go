@@ -0,0 +1,311 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lints
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func newIngress(name string, annotations map[string]string, host, path, service string, port intstr.IntOrString) networking.Ingress {
+	return networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			Annotations: annotations,
+		},
+		Spec: networking.IngressSpec{
+			Rules: []networking.IngressRule{{
+				Host: host,
+				IngressRuleValue: networking.IngressRuleValue{
+					HTTP: &networking.HTTPIngressRuleValue{
+						Paths: []networking.HTTPIngressPath{{
+							Path: path,
+							Backend: networking.IngressBackend{
+								ServiceName: service,
+								ServicePort: port,
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func newService(name string, ports ...apiv1.ServicePort) apiv1.Service {
+	return apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       apiv1.ServiceSpec{Ports: ports},
+	}
+}
+
+func newEndpoints(name string, addresses ...string) apiv1.Endpoints {
+	ep := apiv1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+	}
+	if len(addresses) > 0 {
+		subset := apiv1.EndpointSubset{}
+		for _, address := range addresses {
+			subset.Addresses = append(subset.Addresses, apiv1.EndpointAddress{IP: address})
+		}
+		ep.Subsets = []apiv1.EndpointSubset{subset}
+	}
+	return ep
+}
+
+func newTLSSecret(t *testing.T, name string, hosts []string, notAfter time.Time) apiv1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		DNSNames:     hosts,
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %v", err)
+	}
+
+	return apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Data: map[string][]byte{
+			apiv1.TLSCertKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}
+}
+
+func withTLS(ing networking.Ingress, secret string, hosts ...string) networking.Ingress {
+	ing.Spec.TLS = []networking.IngressTLS{{Hosts: hosts, SecretName: secret}}
+	return ing
+}
+
+func newControllerDeployment(name string, args ...string) appsv1.Deployment {
+	return appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ingress-nginx", Name: name},
+		Spec: appsv1.DeploymentSpec{
+			Template: apiv1.PodTemplateSpec{
+				Spec: apiv1.PodSpec{
+					Containers: []apiv1.Container{{
+						Args: append([]string{"/nginx-ingress-controller"}, args...),
+					}},
+				},
+			},
+		},
+	}
+}
+
+type clusterLintCase struct {
+	name     string
+	ing      networking.Ingress
+	cluster  *Cluster
+	expected []string
+}
+
+func runClusterLintCases(t *testing.T, f func(networking.Ingress, *Cluster) []string, cases []clusterLintCase) {
+	for _, tc := range cases {
+		problems := f(tc.ing, tc.cluster)
+		if len(problems) == 0 && len(tc.expected) == 0 {
+			continue
+		}
+
+		if !reflect.DeepEqual(problems, tc.expected) {
+			t.Errorf("%v: returned %q but expected %q", tc.name, problems, tc.expected)
+		}
+	}
+}
+
+var port80 = intstr.FromInt(80)
+
+func TestMissingService(t *testing.T) {
+	ing := newIngress("app", nil, "example.com", "/", "app", port80)
+
+	runClusterLintCases(t, missingService, []clusterLintCase{
+		{"unknown services", ing, NewCluster(nil, nil, nil, nil, nil), nil},
+		{"existing service", ing, NewCluster([]apiv1.Service{newService("app")}, nil, nil, nil, nil), nil},
+		{"missing service", ing, NewCluster([]apiv1.Service{}, nil, nil, nil, nil), []string{"service default/app"}},
+		{"service of another namespace", ing, NewCluster([]apiv1.Service{{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "app"}}}, nil, nil, nil, nil), []string{"service default/app"}},
+	})
+}
+
+func TestMissingServicePort(t *testing.T) {
+	ing := newIngress("app", nil, "example.com", "/", "app", port80)
+	named := newIngress("app", nil, "example.com", "/", "app", intstr.FromString("http"))
+	externalName := newService("app")
+	externalName.Spec.Type = apiv1.ServiceTypeExternalName
+
+	runClusterLintCases(t, missingServicePort, []clusterLintCase{
+		{"unknown services", ing, NewCluster(nil, nil, nil, nil, nil), nil},
+		{"missing service", ing, NewCluster([]apiv1.Service{}, nil, nil, nil, nil), nil},
+		{"existing port", ing, NewCluster([]apiv1.Service{newService("app", apiv1.ServicePort{Port: 80})}, nil, nil, nil, nil), nil},
+		{"existing named port", named, NewCluster([]apiv1.Service{newService("app", apiv1.ServicePort{Name: "http", Port: 8080})}, nil, nil, nil, nil), nil},
+		{"missing port", ing, NewCluster([]apiv1.Service{newService("app", apiv1.ServicePort{Port: 8080})}, nil, nil, nil, nil), []string{"port 80 of service default/app"}},
+		{"missing named port", named, NewCluster([]apiv1.Service{newService("app", apiv1.ServicePort{Port: 80})}, nil, nil, nil, nil), []string{"port http of service default/app"}},
+		{"external name service", ing, NewCluster([]apiv1.Service{externalName}, nil, nil, nil, nil), nil},
+	})
+}
+
+func TestServiceWithoutEndpoints(t *testing.T) {
+	ing := newIngress("app", nil, "example.com", "/", "app", port80)
+	services := []apiv1.Service{newService("app", apiv1.ServicePort{Port: 80})}
+
+	runClusterLintCases(t, serviceWithoutEndpoints, []clusterLintCase{
+		{"unknown endpoints", ing, NewCluster(services, nil, nil, nil, nil), nil},
+		{"with endpoints", ing, NewCluster(services, []apiv1.Endpoints{newEndpoints("app", "10.0.0.1")}, nil, nil, nil), nil},
+		{"empty endpoints", ing, NewCluster(services, []apiv1.Endpoints{newEndpoints("app")}, nil, nil, nil), []string{"service default/app"}},
+		{"missing endpoints", ing, NewCluster(services, []apiv1.Endpoints{}, nil, nil, nil), []string{"service default/app"}},
+		{"missing service", ing, NewCluster([]apiv1.Service{}, []apiv1.Endpoints{}, nil, nil, nil), nil},
+	})
+}
+
+func TestMissingTLSSecret(t *testing.T) {
+	ing := withTLS(newIngress("app", nil, "example.com", "/", "app", port80), "tls", "example.com")
+	secrets := []apiv1.Secret{newTLSSecret(t, "tls", []string{"example.com"}, time.Now().Add(time.Hour))}
+
+	runClusterLintCases(t, missingTLSSecret, []clusterLintCase{
+		{"unknown secrets", ing, NewCluster(nil, nil, nil, nil, nil), nil},
+		{"existing secret", ing, NewCluster(nil, nil, secrets, nil, nil), nil},
+		{"missing secret", ing, NewCluster(nil, nil, []apiv1.Secret{}, nil, nil), []string{"secret default/tls"}},
+		{"without secret name", withTLS(ing, "", "example.com"), NewCluster(nil, nil, []apiv1.Secret{}, nil, nil), nil},
+	})
+}
+
+func TestInvalidTLSCertificate(t *testing.T) {
+	ing := withTLS(newIngress("app", nil, "example.com", "/", "app", port80), "tls", "example.com")
+	valid := newTLSSecret(t, "tls", []string{"example.com"}, time.Now().Add(time.Hour))
+
+	withoutCert := valid
+	withoutCert.Data = map[string][]byte{}
+
+	notPEM := valid
+	notPEM.Data = map[string][]byte{apiv1.TLSCertKey: []byte("not a certificate")}
+
+	runClusterLintCases(t, invalidTLSCertificate, []clusterLintCase{
+		{"unknown secrets", ing, NewCluster(nil, nil, nil, nil, nil), nil},
+		{"valid certificate", ing, NewCluster(nil, nil, []apiv1.Secret{valid}, nil, nil), nil},
+		{"missing secret", ing, NewCluster(nil, nil, []apiv1.Secret{}, nil, nil), nil},
+		{"without certificate", ing, NewCluster(nil, nil, []apiv1.Secret{withoutCert}, nil, nil), []string{"secret default/tls: missing tls.crt key"}},
+		{"not PEM", ing, NewCluster(nil, nil, []apiv1.Secret{notPEM}, nil, nil), []string{"secret default/tls: no PEM data found in tls.crt"}},
+	})
+}
+
+func TestExpiredTLSCertificate(t *testing.T) {
+	ing := withTLS(newIngress("app", nil, "example.com", "/", "app", port80), "tls", "example.com")
+	expiration := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	runClusterLintCases(t, expiredTLSCertificate, []clusterLintCase{
+		{"unknown secrets", ing, NewCluster(nil, nil, nil, nil, nil), nil},
+		{"valid certificate", ing, NewCluster(nil, nil, []apiv1.Secret{newTLSSecret(t, "tls", []string{"example.com"}, time.Now().Add(time.Hour))}, nil, nil), nil},
+		{"expired certificate", ing, NewCluster(nil, nil, []apiv1.Secret{newTLSSecret(t, "tls", []string{"example.com"}, expiration)}, nil, nil), []string{"secret default/tls expired on " + expiration.Format(time.RFC3339)}},
+	})
+}
+
+func TestTLSCertificateHostMismatch(t *testing.T) {
+	ing := withTLS(newIngress("app", nil, "example.com", "/", "app", port80), "tls", "example.com", "other.com")
+	notAfter := time.Now().Add(time.Hour)
+
+	runClusterLintCases(t, tlsCertificateHostMismatch, []clusterLintCase{
+		{"unknown secrets", ing, NewCluster(nil, nil, nil, nil, nil), nil},
+		{"matching hosts", ing, NewCluster(nil, nil, []apiv1.Secret{newTLSSecret(t, "tls", []string{"example.com", "other.com"}, notAfter)}, nil, nil), nil},
+		{"wildcard", withTLS(ing, "tls", "www.example.com"), NewCluster(nil, nil, []apiv1.Secret{newTLSSecret(t, "tls", []string{"*.example.com"}, notAfter)}, nil, nil), nil},
+		{"mismatched host", ing, NewCluster(nil, nil, []apiv1.Secret{newTLSSecret(t, "tls", []string{"example.com"}, notAfter)}, nil, nil), []string{"secret default/tls is not valid for other.com"}},
+	})
+}
+
+func TestCanaryWithoutPrimary(t *testing.T) {
+	canaryAnnotations := map[string]string{"nginx.ingress.kubernetes.io/canary": "true"}
+	canary := newIngress("canary", canaryAnnotations, "example.com", "/", "app-canary", port80)
+	primary := newIngress("app", nil, "example.com", "/", "app", port80)
+	otherPath := newIngress("app", nil, "example.com", "/other", "app", port80)
+	otherCanary := newIngress("other-canary", canaryAnnotations, "example.com", "/", "app", port80)
+
+	runClusterLintCases(t, canaryWithoutPrimary, []clusterLintCase{
+		{"unknown ingresses", canary, NewCluster(nil, nil, nil, nil, nil), nil},
+		{"not a canary", primary, NewCluster(nil, nil, nil, []networking.Ingress{primary}, nil), nil},
+		{"with primary", canary, NewCluster(nil, nil, nil, []networking.Ingress{canary, primary}, nil), nil},
+		{"primary of another path", canary, NewCluster(nil, nil, nil, []networking.Ingress{canary, otherPath}, nil), []string{`host "example.com" path "/"`}},
+		{"only canaries", canary, NewCluster(nil, nil, nil, []networking.Ingress{canary, otherCanary}, nil), []string{`host "example.com" path "/"`}},
+	})
+}
+
+func TestSSLPassthroughWithoutFlag(t *testing.T) {
+	ing := newIngress("app", map[string]string{"nginx.ingress.kubernetes.io/ssl-passthrough": "true"}, "example.com", "/", "app", port80)
+	withoutAnnotation := newIngress("app", nil, "example.com", "/", "app", port80)
+	other := appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}}
+
+	runClusterLintCases(t, sslPassthroughWithoutFlag, []clusterLintCase{
+		{"unknown deployments", ing, NewCluster(nil, nil, nil, nil, nil), nil},
+		{"without annotation", withoutAnnotation, NewCluster(nil, nil, nil, nil, []appsv1.Deployment{newControllerDeployment("controller")}), nil},
+		{"without controllers", ing, NewCluster(nil, nil, nil, nil, []appsv1.Deployment{other}), nil},
+		{"with flag", ing, NewCluster(nil, nil, nil, nil, []appsv1.Deployment{newControllerDeployment("controller", "--enable-ssl-passthrough")}), nil},
+		{"with flag value", ing, NewCluster(nil, nil, nil, nil, []appsv1.Deployment{newControllerDeployment("controller", "--enable-ssl-passthrough=true")}), nil},
+		{"without flag", ing, NewCluster(nil, nil, nil, nil, []appsv1.Deployment{newControllerDeployment("controller"), newControllerDeployment("other")}), []string{"checked ingress-nginx/controller, ingress-nginx/other"}},
+	})
+}
+
+func TestConflictingSessionAffinity(t *testing.T) {
+	cookie := map[string]string{"nginx.ingress.kubernetes.io/affinity": "cookie"}
+	ing := newIngress("app", cookie, "example.com", "/", "app", port80)
+	same := newIngress("same", cookie, "other.com", "/", "app", port80)
+	different := newIngress("different", nil, "other.com", "/", "app", port80)
+	otherBackend := newIngress("other-backend", nil, "other.com", "/", "other", port80)
+
+	otherNamespace := different
+	otherNamespace.Namespace = "other"
+
+	runClusterLintCases(t, conflictingSessionAffinity, []clusterLintCase{
+		{"unknown ingresses", ing, NewCluster(nil, nil, nil, nil, nil), nil},
+		{"same affinity", ing, NewCluster(nil, nil, nil, []networking.Ingress{ing, same}, nil), nil},
+		{"other backend", ing, NewCluster(nil, nil, nil, []networking.Ingress{ing, otherBackend}, nil), nil},
+		{"other namespace", ing, NewCluster(nil, nil, nil, []networking.Ingress{ing, otherNamespace}, nil), nil},
+		{"different affinity", ing, NewCluster(nil, nil, nil, []networking.Ingress{ing, different}, nil), []string{`ingress default/different uses affinity="" session-cookie-name="" for service app`}},
+	})
+}
+
+func TestClusterLintsWithoutCluster(t *testing.T) {
+	ing := newIngress("app", nil, "example.com", "/", "app", port80)
+
+	for _, lint := range GetIngressLints() {
+		if lint.cluster == nil {
+			continue
+		}
+
+		if problems := lint.Problems(&ing, nil); len(problems) != 0 {
+			t.Errorf("%v: expected the lint to be skipped without cluster but returned %v", lint.ID(), problems)
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 // DeploymentLint is a validation for a deployment
 type DeploymentLint struct {
+	id      string
 	message string
 	version string
 	issue   int
@@ -39,6 +40,19 @@ func (lint DeploymentLint) Check(obj kmeta.Object) bool {
 	return lint.f(*cmp)
 }
 
+// Problems returns a message for every issue detected by the lint
+func (lint DeploymentLint) Problems(obj kmeta.Object, c *Cluster) []string {
+	if lint.Check(obj) {
+		return []string{lint.message}
+	}
+	return nil
+}
+
+// ID is a short, stable identifier of the lint
+func (lint DeploymentLint) ID() string {
+	return lint.id
+}
+
 // Message is a description of the lint
 func (lint DeploymentLint) Message() string {
 	return lint.message
@@ -68,6 +82,7 @@ func GetDeploymentLints() []DeploymentLint {
 
 func removedFlag(flag string, issueNumber int, version string) DeploymentLint {
 	return DeploymentLint{
+		id:      fmt.Sprintf("removed-flag-%v", flag),
 		message: fmt.Sprintf("Uses removed config flag --%v", flag),
 		issue:   issueNumber,
 		version: version,
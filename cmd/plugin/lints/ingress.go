@@ -27,16 +27,46 @@ import (
 
 // IngressLint is a validation for an ingress
 type IngressLint struct {
+	id      string
 	message string
 	issue   int
 	version string
 	f       func(ing networking.Ingress) bool
+	// cluster is used instead of f by lints that need other objects of the
+	// cluster. It returns a description of every issue detected.
+	cluster func(ing networking.Ingress, c *Cluster) []string
 }
 
 // Check returns true if the lint detects an issue
 func (lint IngressLint) Check(obj kmeta.Object) bool {
+	return len(lint.Problems(obj, nil)) > 0
+}
+
+// Problems returns a message for every issue detected by the lint. Lints that
+// cross-reference other objects are skipped when the cluster is nil.
+func (lint IngressLint) Problems(obj kmeta.Object, c *Cluster) []string {
 	ing := obj.(*networking.Ingress)
-	return lint.f(*ing)
+	if lint.f != nil {
+		if lint.f(*ing) {
+			return []string{lint.message}
+		}
+		return nil
+	}
+
+	if c == nil {
+		return nil
+	}
+
+	problems := make([]string, 0)
+	for _, detail := range lint.cluster(*ing, c) {
+		problems = append(problems, fmt.Sprintf("%v: %v", lint.message, detail))
+	}
+	return problems
+}
+
+// ID is a short, stable identifier of the lint
+func (lint IngressLint) ID() string {
+	return lint.id
 }
 
 // Message is a description of the lint
@@ -67,29 +97,84 @@ func GetIngressLints() []IngressLint {
 		removedAnnotation("base-url-scheme", 3174, "0.22.0"),
 		removedAnnotation("session-cookie-hash", 3743, "0.24.0"),
 		{
+			id:      "rewrite-target-without-capture-group",
 			message: "The rewrite-target annotation value does not reference a capture group",
 			issue:   3174,
 			version: "0.22.0",
 			f:       rewriteTargetWithoutCaptureGroup,
 		},
 		{
+			id:      "nginx-org-annotation-prefix",
 			message: "Contains an annotation with the prefix 'nginx.org'. This is a prefix for https://github.com/nginxinc/kubernetes-ingress",
 			f:       annotationPrefixIsNginxOrg,
 		},
 		{
+			id:      "nginx-com-annotation-prefix",
 			message: "Contains an annotation with the prefix 'nginx.com'. This is a prefix for https://github.com/nginxinc/kubernetes-ingress",
 			f:       annotationPrefixIsNginxCom,
 		},
 		{
+			id:      "x-forwarded-prefix-is-bool",
 			message: "The x-forwarded-prefix annotation value is a boolean instead of a string",
 			issue:   3786,
 			version: "0.24.0",
 			f:       xForwardedPrefixIsBool,
 		},
 		{
+			id:      "satisfy-directive-in-snippet",
 			message: "Contains an configuration-snippet that contains a Satisfy directive.\nPlease use https://kubernetes.github.io/ingress-nginx/user-guide/nginx-configuration/annotations/#satisfy",
 			f:       satisfyDirective,
 		},
+		{
+			id:      "missing-service",
+			message: "Uses a service that does not exist",
+			cluster: missingService,
+		},
+		{
+			id:      "missing-service-port",
+			message: "Uses a service port that does not exist",
+			cluster: missingServicePort,
+		},
+		{
+			id:      "service-without-endpoints",
+			message: "Uses a service without endpoints",
+			cluster: serviceWithoutEndpoints,
+		},
+		{
+			id:      "missing-tls-secret",
+			message: "Uses a TLS secret that does not exist",
+			cluster: missingTLSSecret,
+		},
+		{
+			id:      "invalid-tls-certificate",
+			message: "Uses a TLS secret without a valid certificate",
+			cluster: invalidTLSCertificate,
+		},
+		{
+			id:      "expired-tls-certificate",
+			message: "Uses an expired TLS certificate",
+			cluster: expiredTLSCertificate,
+		},
+		{
+			id:      "tls-certificate-host-mismatch",
+			message: "Uses a TLS certificate that is not valid for the host",
+			cluster: tlsCertificateHostMismatch,
+		},
+		{
+			id:      "canary-without-primary",
+			message: "Is a canary without a primary ingress for the same host and path",
+			cluster: canaryWithoutPrimary,
+		},
+		{
+			id:      "ssl-passthrough-disabled",
+			message: "Uses the ssl-passthrough annotation but no ingress-nginx controller runs with --enable-ssl-passthrough",
+			cluster: sslPassthroughWithoutFlag,
+		},
+		{
+			id:      "conflicting-session-affinity",
+			message: "Configures a session affinity different from other ingresses using the same backend",
+			cluster: conflictingSessionAffinity,
+		},
 	}
 }
 
@@ -131,6 +216,7 @@ func rewriteTargetWithoutCaptureGroup(ing networking.Ingress) bool {
 
 func removedAnnotation(annotationName string, issueNumber int, version string) IngressLint {
 	return IngressLint{
+		id:      fmt.Sprintf("removed-annotation-%v", annotationName),
 		message: fmt.Sprintf("Contains the removed %v annotation.", annotationName),
 		issue:   issueNumber,
 		version: version,
@@ -31,13 +31,16 @@ import (
 	"k8s.io/ingress-nginx/cmd/plugin/commands/backends"
 	"k8s.io/ingress-nginx/cmd/plugin/commands/certs"
 	"k8s.io/ingress-nginx/cmd/plugin/commands/conf"
+	"k8s.io/ingress-nginx/cmd/plugin/commands/diff"
 	"k8s.io/ingress-nginx/cmd/plugin/commands/exec"
 	"k8s.io/ingress-nginx/cmd/plugin/commands/general"
+	"k8s.io/ingress-nginx/cmd/plugin/commands/history"
 	"k8s.io/ingress-nginx/cmd/plugin/commands/info"
 	"k8s.io/ingress-nginx/cmd/plugin/commands/ingresses"
 	"k8s.io/ingress-nginx/cmd/plugin/commands/lint"
 	"k8s.io/ingress-nginx/cmd/plugin/commands/logs"
 	"k8s.io/ingress-nginx/cmd/plugin/commands/ssh"
+	"k8s.io/ingress-nginx/cmd/plugin/commands/top"
 )
 
 func main() {
@@ -60,8 +63,18 @@ func main() {
 	rootCmd.AddCommand(exec.CreateCommand(flags))
 	rootCmd.AddCommand(ssh.CreateCommand(flags))
 	rootCmd.AddCommand(lint.CreateCommand(flags))
+	rootCmd.AddCommand(top.CreateCommand(flags))
+	rootCmd.AddCommand(diff.CreateCommand(flags))
+	rootCmd.AddCommand(history.CreateCommand(flags))
 
 	if err := rootCmd.Execute(); err != nil {
+		if problems, ok := err.(*lint.ProblemsError); ok {
+			os.Exit(problems.ExitCode())
+		}
+		if drift, ok := err.(*diff.DriftError); ok {
+			os.Exit(drift.ExitCode())
+		}
+
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -18,11 +18,13 @@ package request
 
 import (
 	"fmt"
+	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -70,6 +72,42 @@ func GetDeploymentPod(flags *genericclioptions.ConfigFlags, deployment string) (
 	return ings[0], nil
 }
 
+// GetDeploymentPods returns the pods of the given deployment
+func GetDeploymentPods(flags *genericclioptions.ConfigFlags, deployment string) ([]apiv1.Pod, error) {
+	pods, err := getDeploymentPods(flags, deployment)
+	if err != nil {
+		return pods, err
+	}
+
+	if len(pods) == 0 {
+		return pods, fmt.Errorf("no pods for deployment %v found in namespace %v", deployment, util.GetNamespace(flags))
+	}
+
+	return pods, nil
+}
+
+// GetPodHTTP makes a GET request to a port of the pod through the API server proxy
+// and returns the body of the response
+func GetPodHTTP(flags *genericclioptions.ConfigFlags, pod apiv1.Pod, port int, path string) ([]byte, error) {
+	rawConfig, err := flags.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := corev1.NewForConfig(rawConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.RESTClient().Get().
+		Namespace(pod.Namespace).
+		Resource("pods").
+		SubResource("proxy").
+		Name(net.JoinSchemeNamePort("http", pod.Name, strconv.Itoa(port))).
+		Suffix(path).
+		DoRaw()
+}
+
 // GetDeployments returns an array of Deployments
 func GetDeployments(flags *genericclioptions.ConfigFlags, namespace string) ([]appsv1.Deployment, error) {
 	rawConfig, err := flags.ToRESTConfig()
@@ -144,6 +182,11 @@ func GetEndpointsByName(flags *genericclioptions.ConfigFlags, namespace string,
 	return nil, nil
 }
 
+// GetEndpoints returns an array of Endpoints
+func GetEndpoints(flags *genericclioptions.ConfigFlags, namespace string) ([]apiv1.Endpoints, error) {
+	return getEndpoints(flags, namespace)
+}
+
 var endpointsCache = make(map[string]*[]apiv1.Endpoints)
 
 func getEndpoints(flags *genericclioptions.ConfigFlags, namespace string) ([]apiv1.Endpoints, error) {
@@ -263,8 +306,11 @@ func getDeploymentPods(flags *genericclioptions.ConfigFlags, deployment string)
 }
 
 func getServices(flags *genericclioptions.ConfigFlags) ([]apiv1.Service, error) {
-	namespace := util.GetNamespace(flags)
+	return GetServices(flags, util.GetNamespace(flags))
+}
 
+// GetServices returns an array of Services
+func GetServices(flags *genericclioptions.ConfigFlags, namespace string) ([]apiv1.Service, error) {
 	rawConfig, err := flags.ToRESTConfig()
 	if err != nil {
 		return make([]apiv1.Service, 0), err
@@ -281,5 +327,24 @@ func getServices(flags *genericclioptions.ConfigFlags) ([]apiv1.Service, error)
 	}
 
 	return services.Items, nil
+}
+
+// GetSecrets returns an array of Secrets
+func GetSecrets(flags *genericclioptions.ConfigFlags, namespace string) ([]apiv1.Secret, error) {
+	rawConfig, err := flags.ToRESTConfig()
+	if err != nil {
+		return make([]apiv1.Secret, 0), err
+	}
+
+	api, err := corev1.NewForConfig(rawConfig)
+	if err != nil {
+		return make([]apiv1.Secret, 0), err
+	}
+
+	secrets, err := api.Secrets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return make([]apiv1.Secret, 0), err
+	}
 
+	return secrets.Items, nil
 }
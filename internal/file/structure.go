@@ -26,11 +26,16 @@ const (
 	// The name of each file is <namespace>-<secret name>.pem. The content is the concatenated
 	// certificate and key.
 	DefaultSSLDirectory = "/etc/ingress-controller/ssl"
+
+	// ServersDirectory defines the location of the files of the servers
+	// included by nginx.conf when the server fragments are enabled.
+	ServersDirectory = "/etc/ingress-controller/servers"
 )
 
 var (
 	directories = []string{
 		DefaultSSLDirectory,
 		AuthDirectory,
+		ServersDirectory,
 	}
 )
@@ -29,16 +29,22 @@ import (
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/alias"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authjwt"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authoidc"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreqglobal"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/backendprotocol"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/circuitbreaker"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/clientbodybuffersize"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/connection"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/customhttperrors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/defaultbackend"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/fastcgi"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/globalratelimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/headers"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/healthcheck"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/http2pushpreload"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
@@ -46,11 +52,13 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/luarestywaf"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/mirror"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/outlierdetection"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/portinredirect"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/redirect"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/retrypolicy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/satisfy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/secureupstream"
@@ -59,6 +67,7 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sessionaffinity"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/snippet"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sslpassthrough"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/statusaddress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhashby"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamvhost"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/xforwardedprefix"
@@ -88,11 +97,19 @@ type Ingress struct {
 	Denied             *string
 	ExternalAuth       authreq.Config
 	EnableGlobalAuth   bool
+	Headers            headers.Config
+	HealthCheck        healthcheck.Config
+	OutlierDetection   outlierdetection.Config
+	CircuitBreaker     circuitbreaker.Config
+	JWTAuth            authjwt.Config
+	OIDCAuth           authoidc.Config
 	HTTP2PushPreload   bool
 	Proxy              proxy.Config
 	RateLimit          ratelimit.Config
+	GlobalRateLimit    globalratelimit.Config
 	Redirect           redirect.Config
 	Rewrite            rewrite.Config
+	RetryPolicy        retrypolicy.Config
 	Satisfy            string
 	SecureUpstream     secureupstream.Config
 	ServerSnippet      string
@@ -111,6 +128,7 @@ type Ingress struct {
 	InfluxDB           influxdb.Config
 	ModSecurity        modsecurity.Config
 	Mirror             mirror.Config
+	StatusAddress      statusaddress.Config
 }
 
 // Extractor defines the annotation parsers to be used in the extraction of annotations
@@ -135,9 +153,12 @@ func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
 			"FastCGI":              fastcgi.NewParser(cfg),
 			"ExternalAuth":         authreq.NewParser(cfg),
 			"EnableGlobalAuth":     authreqglobal.NewParser(cfg),
+			"JWTAuth":              authjwt.NewParser(cfg),
+			"OIDCAuth":             authoidc.NewParser(cfg),
 			"HTTP2PushPreload":     http2pushpreload.NewParser(cfg),
 			"Proxy":                proxy.NewParser(cfg),
 			"RateLimit":            ratelimit.NewParser(cfg),
+			"GlobalRateLimit":      globalratelimit.NewParser(cfg),
 			"Redirect":             redirect.NewParser(cfg),
 			"Rewrite":              rewrite.NewParser(cfg),
 			"Satisfy":              satisfy.NewParser(cfg),
@@ -159,6 +180,12 @@ func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
 			"BackendProtocol":      backendprotocol.NewParser(cfg),
 			"ModSecurity":          modsecurity.NewParser(cfg),
 			"Mirror":               mirror.NewParser(cfg),
+			"Headers":              headers.NewParser(cfg),
+			"HealthCheck":          healthcheck.NewParser(cfg),
+			"OutlierDetection":     outlierdetection.NewParser(cfg),
+			"CircuitBreaker":       circuitbreaker.NewParser(cfg),
+			"RetryPolicy":          retrypolicy.NewParser(cfg),
+			"StatusAddress":        statusaddress.NewParser(cfg),
 		},
 	}
 }
@@ -213,3 +240,20 @@ func (e Extractor) Extract(ing *networking.Ingress) *Ingress {
 
 	return pia
 }
+
+// Validate runs every annotation parser against an Ingress and returns the
+// errors reported by each of them, keyed by the name of the parser.
+// Missing annotations are not considered an error.
+func (e Extractor) Validate(ing *networking.Ingress) map[string]error {
+	errs := make(map[string]error)
+	for name, annotationParser := range e.annotations {
+		_, err := annotationParser.Parse(ing)
+		if err == nil || errors.IsMissingAnnotations(err) {
+			continue
+		}
+
+		errs[name] = err
+	}
+
+	return errs
+}
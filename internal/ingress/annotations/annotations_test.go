@@ -301,6 +301,37 @@ func TestCustomHTTPErrors(t *testing.T) {
 	}
 }
 
+func TestValidate(t *testing.T) {
+	ec := NewAnnotationExtractor(mockCfg{})
+
+	fooAnns := []struct {
+		annotations map[string]string
+		er          []string
+	}{
+		{nil, []string{}},
+		{map[string]string{annotationCustomHTTPErrors: "404,415"}, []string{}},
+		{map[string]string{annotationCustomHTTPErrors: "40x"}, []string{"CustomHTTPErrors"}},
+		{map[string]string{parser.GetAnnotationWithPrefix("whitelist-source-range"): "1.1.1.1/40"}, []string{"Whitelist"}},
+	}
+
+	for _, foo := range fooAnns {
+		ing := buildIngress()
+		ing.SetAnnotations(foo.annotations)
+		errs := ec.Validate(ing)
+
+		if len(errs) != len(foo.er) {
+			t.Errorf("Returned %v but expected errors for %v", errs, foo.er)
+			continue
+		}
+
+		for _, name := range foo.er {
+			if errs[name] == nil {
+				t.Errorf("Expected an error for %v but got none", name)
+			}
+		}
+	}
+}
+
 /*
 func TestMergeLocationAnnotations(t *testing.T) {
 	// initial parameters
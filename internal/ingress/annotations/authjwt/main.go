@@ -0,0 +1,273 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authjwt
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/jwks"
+	"k8s.io/ingress-nginx/internal/sets"
+)
+
+var headerRegex = regexp.MustCompile(`^[a-zA-Z\d\-_]+$`)
+
+// Config contains the configuration used to validate JSON Web Tokens
+// sent by the clients in the Authorization header
+type Config struct {
+	// KeySet identifies the keys used to verify the signature of the tokens
+	KeySet string `json:"keySet"`
+	// Secret contains the namespace and name of the Secret with the keys
+	Secret string `json:"secret,omitempty"`
+	// JWKSURL is the URL of a JSON Web Key Set with the keys
+	JWKSURL string `json:"jwksURL,omitempty"`
+	// Issuer is the value expected in the iss claim
+	Issuer string `json:"issuer,omitempty"`
+	// Audiences contains the values accepted in the aud claim
+	Audiences []string `json:"audiences,omitempty"`
+	// RequiredClaims contains the claims the tokens must contain. An
+	// empty value only requires the presence of the claim.
+	RequiredClaims map[string]string `json:"requiredClaims,omitempty"`
+	// ClockSkew is the number of seconds tolerated when checking the
+	// exp and nbf claims
+	ClockSkew int `json:"clockSkew"`
+	// ClaimHeaders maps claims to the headers sent to the upstream
+	ClaimHeaders map[string]string `json:"claimHeaders,omitempty"`
+}
+
+// Enabled returns true if the tokens must be validated
+func (c *Config) Enabled() bool {
+	return c.KeySet != ""
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.KeySet != c2.KeySet {
+		return false
+	}
+	if c1.Secret != c2.Secret {
+		return false
+	}
+	if c1.JWKSURL != c2.JWKSURL {
+		return false
+	}
+	if c1.Issuer != c2.Issuer {
+		return false
+	}
+	if len(c1.Audiences) != len(c2.Audiences) {
+		return false
+	}
+	if !sets.StringElementsMatch(c1.Audiences, c2.Audiences) {
+		return false
+	}
+	if !stringMapEqual(c1.RequiredClaims, c2.RequiredClaims) {
+		return false
+	}
+	if c1.ClockSkew != c2.ClockSkew {
+		return false
+	}
+
+	return stringMapEqual(c1.ClaimHeaders, c2.ClaimHeaders)
+}
+
+func stringMapEqual(m1, m2 map[string]string) bool {
+	if len(m1) != len(m2) {
+		return false
+	}
+	for k, v := range m1 {
+		if v2, ok := m2[k]; !ok || v != v2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// secretKeySet returns the identifier of the keys contained in a Secret
+func secretKeySet(secret string) string {
+	return fmt.Sprintf("secret/%v", secret)
+}
+
+// URLKeySet returns the identifier of the keys of a remote JSON Web Key Set
+func URLKeySet(url string) string {
+	return fmt.Sprintf("url/%v", url)
+}
+
+type authJWT struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new JWT authentication annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return authJWT{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// validate JSON Web Tokens. An invalid configuration denies the access
+// to the location instead of disabling the authentication.
+func (a authJWT) Parse(ing *networking.Ingress) (interface{}, error) {
+	secret, _ := parser.GetStringAnnotation("auth-jwt-secret", ing)
+	jwksURL, _ := parser.GetStringAnnotation("auth-jwt-jwks-url", ing)
+
+	if secret == "" && jwksURL == "" {
+		return &Config{}, ing_errors.ErrMissingAnnotations
+	}
+
+	if secret != "" && jwksURL != "" {
+		return &Config{}, ing_errors.NewLocationDenied("only one of auth-jwt-secret and auth-jwt-jwks-url can be used")
+	}
+
+	config := &Config{}
+
+	if secret != "" {
+		ns, name, err := cache.SplitMetaNamespaceKey(secret)
+		if err != nil {
+			return &Config{}, ing_errors.LocationDenied{
+				Reason: errors.Wrap(err, "error reading secret name from annotation"),
+			}
+		}
+		if ns == "" {
+			ns = ing.GetNamespace()
+		}
+		config.Secret = fmt.Sprintf("%v/%v", ns, name)
+
+		s, err := a.r.GetSecret(config.Secret)
+		if err != nil {
+			return &Config{}, ing_errors.LocationDenied{
+				Reason: errors.Wrapf(err, "unexpected error reading secret %v", config.Secret),
+			}
+		}
+
+		_, err = jwks.FromSecret(s.Data)
+		if err != nil {
+			return &Config{}, ing_errors.LocationDenied{
+				Reason: errors.Wrapf(err, "invalid keys in secret %v", config.Secret),
+			}
+		}
+
+		config.KeySet = secretKeySet(config.Secret)
+	} else {
+		u, err := url.Parse(jwksURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid JSON Web Key Set URL %q", jwksURL))
+		}
+
+		config.JWKSURL = jwksURL
+		config.KeySet = URLKeySet(jwksURL)
+	}
+
+	config.Issuer, _ = parser.GetStringAnnotation("auth-jwt-issuer", ing)
+
+	audiences, _ := parser.GetStringAnnotation("auth-jwt-audience", ing)
+	config.Audiences = SplitList(audiences)
+
+	var err error
+	claims, _ := parser.GetStringAnnotation("auth-jwt-required-claims", ing)
+	config.RequiredClaims, err = ParseRequiredClaims(claims)
+	if err != nil {
+		return &Config{}, ing_errors.NewLocationDenied(err.Error())
+	}
+
+	skew, err := parser.GetIntAnnotation("auth-jwt-clock-skew", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, ing_errors.NewLocationDenied("invalid auth-jwt-clock-skew value")
+	}
+	if skew < 0 {
+		return &Config{}, ing_errors.NewLocationDenied("auth-jwt-clock-skew must be a positive number of seconds")
+	}
+	config.ClockSkew = skew
+
+	headers, _ := parser.GetStringAnnotation("auth-jwt-claim-headers", ing)
+	config.ClaimHeaders, err = ParseClaimHeaders(headers)
+	if err != nil {
+		return &Config{}, ing_errors.NewLocationDenied(err.Error())
+	}
+
+	return config, nil
+}
+
+// ParseRequiredClaims parses a comma separated list of claims like
+// email,role=admin. An empty value only requires the presence of the claim.
+func ParseRequiredClaims(s string) (map[string]string, error) {
+	claims := map[string]string{}
+	for _, claim := range SplitList(s) {
+		parts := strings.SplitN(claim, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if name == "" {
+			return nil, fmt.Errorf("invalid required claim %q", claim)
+		}
+
+		value := ""
+		if len(parts) == 2 {
+			value = strings.TrimSpace(parts[1])
+		}
+		claims[name] = value
+	}
+
+	return claims, nil
+}
+
+// ParseClaimHeaders parses a comma separated list of claims and the
+// headers used to send them to the upstream, like sub:X-User
+func ParseClaimHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, h := range SplitList(s) {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid claim header %q, the format is claim:Header", h)
+		}
+
+		claim := strings.TrimSpace(parts[0])
+		header := strings.TrimSpace(parts[1])
+		if claim == "" || !headerRegex.MatchString(header) {
+			return nil, fmt.Errorf("invalid claim header %q", h)
+		}
+		headers[claim] = header
+	}
+
+	return headers, nil
+}
+
+// SplitList returns the sorted non empty values of a comma separated list
+func SplitList(s string) []string {
+	values := []string{}
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authjwt
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress() *networking.Ingress {
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{
+			Backend: &networking.IngressBackend{
+				ServiceName: "default-backend",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	}
+}
+
+type mockSecret struct {
+	resolver.Mock
+}
+
+func (m mockSecret) GetSecret(name string) (*api.Secret, error) {
+	switch name {
+	case "default/jwt-keys":
+		return &api.Secret{
+			Data: map[string][]byte{"jwks.json": []byte(`{"keys": [{"kty": "oct", "kid": "a", "k": "c2VjcmV0"}]}`)},
+		}, nil
+	case "default/invalid-keys":
+		return &api.Secret{
+			Data: map[string][]byte{"key.pem": []byte("invalid")},
+		}, nil
+	}
+
+	return nil, errors.Errorf("there is no secret with name %v", name)
+}
+
+func TestParse(t *testing.T) {
+	ap := parser.GetAnnotationWithPrefix
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		denied      bool
+	}{
+		{map[string]string{}, &Config{}, false},
+		{map[string]string{ap("auth-jwt-secret"): "jwt-keys"}, &Config{
+			KeySet:         "secret/default/jwt-keys",
+			Secret:         "default/jwt-keys",
+			Audiences:      []string{},
+			RequiredClaims: map[string]string{},
+			ClaimHeaders:   map[string]string{},
+		}, false},
+		{map[string]string{
+			ap("auth-jwt-jwks-url"):        "https://auth.example.com/.well-known/jwks.json",
+			ap("auth-jwt-issuer"):          "https://auth.example.com",
+			ap("auth-jwt-audience"):        "web, api",
+			ap("auth-jwt-required-claims"): "email,role=admin",
+			ap("auth-jwt-clock-skew"):      "30",
+			ap("auth-jwt-claim-headers"):   "sub:X-User,email:X-Email",
+		}, &Config{
+			KeySet:         "url/https://auth.example.com/.well-known/jwks.json",
+			JWKSURL:        "https://auth.example.com/.well-known/jwks.json",
+			Issuer:         "https://auth.example.com",
+			Audiences:      []string{"api", "web"},
+			RequiredClaims: map[string]string{"email": "", "role": "admin"},
+			ClockSkew:      30,
+			ClaimHeaders:   map[string]string{"sub": "X-User", "email": "X-Email"},
+		}, false},
+		{map[string]string{ap("auth-jwt-secret"): "jwt-keys", ap("auth-jwt-jwks-url"): "https://auth.example.com/jwks.json"}, nil, true},
+		{map[string]string{ap("auth-jwt-secret"): "missing"}, nil, true},
+		{map[string]string{ap("auth-jwt-secret"): "invalid-keys"}, nil, true},
+		{map[string]string{ap("auth-jwt-jwks-url"): "file:///etc/jwks.json"}, nil, true},
+		{map[string]string{ap("auth-jwt-secret"): "jwt-keys", ap("auth-jwt-clock-skew"): "-1"}, nil, true},
+		{map[string]string{ap("auth-jwt-secret"): "jwt-keys", ap("auth-jwt-claim-headers"): "sub"}, nil, true},
+		{map[string]string{ap("auth-jwt-secret"): "jwt-keys", ap("auth-jwt-claim-headers"): "sub:X User"}, nil, true},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		ing.SetAnnotations(tc.annotations)
+
+		i, err := NewParser(mockSecret{}).Parse(ing)
+		if tc.denied {
+			if !ing_errors.IsLocationDenied(err) {
+				t.Errorf("expected the location to be denied with %v but returned %v", tc.annotations, err)
+			}
+			continue
+		}
+		if err != nil && !ing_errors.IsMissingAnnotations(err) {
+			t.Errorf("unexpected error with %v: %v", tc.annotations, err)
+		}
+
+		config := i.(*Config)
+		if !config.Equal(tc.expected) {
+			t.Errorf("expected %+v but returned %+v", tc.expected, config)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authoidc
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authjwt"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/oidc"
+	"k8s.io/ingress-nginx/internal/sets"
+)
+
+const (
+	defaultScopes          = "openid profile email"
+	defaultRedirectPath    = "/oauth2/callback"
+	defaultLogoutPath      = "/oauth2/logout"
+	defaultGroupsClaim     = "groups"
+	defaultSessionLifetime = 24 * time.Hour
+)
+
+var pathRegex = regexp.MustCompile(`^/[a-zA-Z0-9\-_./]*$`)
+
+// Config contains the configuration used to authenticate users with
+// an OpenID Connect provider
+type Config struct {
+	// Issuer is the URL of the provider
+	Issuer string `json:"issuer"`
+	// Secret contains the namespace and name of the Secret with the
+	// credentials of the client
+	Secret string `json:"secret"`
+	// Scopes contains the scopes requested to the provider
+	Scopes []string `json:"scopes,omitempty"`
+	// RedirectPath is the path receiving the authorization code
+	RedirectPath string `json:"redirectPath"`
+	// LogoutPath is the path removing the session of the user
+	LogoutPath string `json:"logoutPath"`
+	// PostLogoutRedirectURI is the URL the users are redirected to after
+	// the logout
+	PostLogoutRedirectURI string `json:"postLogoutRedirectURI,omitempty"`
+	// GroupsClaim is the claim of the ID token containing the groups
+	GroupsClaim string `json:"groupsClaim"`
+	// AllowedGroups contains the groups allowed to access the location.
+	// An empty list allows every authenticated user.
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
+	// RequiredClaims contains the claims the ID tokens must contain. An
+	// empty value only requires the presence of the claim.
+	RequiredClaims map[string]string `json:"requiredClaims,omitempty"`
+	// ClaimHeaders maps claims to the headers sent to the upstream
+	ClaimHeaders map[string]string `json:"claimHeaders,omitempty"`
+	// SessionLifetime is the number of seconds after which the users
+	// must log in again
+	SessionLifetime int `json:"sessionLifetime"`
+	// CookieName is the name of the session cookie
+	CookieName string `json:"cookieName"`
+}
+
+// Enabled returns true if the users must be authenticated
+func (c *Config) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Issuer != c2.Issuer {
+		return false
+	}
+	if c1.Secret != c2.Secret {
+		return false
+	}
+	if strings.Join(c1.Scopes, " ") != strings.Join(c2.Scopes, " ") {
+		return false
+	}
+	if c1.RedirectPath != c2.RedirectPath {
+		return false
+	}
+	if c1.LogoutPath != c2.LogoutPath {
+		return false
+	}
+	if c1.PostLogoutRedirectURI != c2.PostLogoutRedirectURI {
+		return false
+	}
+	if c1.GroupsClaim != c2.GroupsClaim {
+		return false
+	}
+	if len(c1.AllowedGroups) != len(c2.AllowedGroups) {
+		return false
+	}
+	if !sets.StringElementsMatch(c1.AllowedGroups, c2.AllowedGroups) {
+		return false
+	}
+	if !stringMapEqual(c1.RequiredClaims, c2.RequiredClaims) {
+		return false
+	}
+	if !stringMapEqual(c1.ClaimHeaders, c2.ClaimHeaders) {
+		return false
+	}
+	if c1.SessionLifetime != c2.SessionLifetime {
+		return false
+	}
+
+	return c1.CookieName == c2.CookieName
+}
+
+func stringMapEqual(m1, m2 map[string]string) bool {
+	if len(m1) != len(m2) {
+		return false
+	}
+	for k, v := range m1 {
+		if v2, ok := m2[k]; !ok || v != v2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+type authOIDC struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new OpenID Connect authentication annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return authOIDC{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// authenticate users with an OpenID Connect provider. An invalid
+// configuration denies the access to the location instead of disabling
+// the authentication.
+func (a authOIDC) Parse(ing *networking.Ingress) (interface{}, error) {
+	issuer, err := parser.GetStringAnnotation("auth-oidc-issuer", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	if !oidc.IsValidIssuer(issuer) {
+		return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid OpenID Connect issuer %q, an https URL is required", issuer))
+	}
+
+	config := &Config{
+		Issuer: issuer,
+	}
+
+	s, err := parser.GetStringAnnotation("auth-oidc-secret", ing)
+	if err != nil {
+		return &Config{}, ing_errors.NewLocationDenied("the auth-oidc-secret annotation is required")
+	}
+
+	ns, name, err := cache.SplitMetaNamespaceKey(s)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDenied{
+			Reason: errors.Wrap(err, "error reading secret name from annotation"),
+		}
+	}
+	if ns == "" {
+		ns = ing.GetNamespace()
+	}
+	config.Secret = fmt.Sprintf("%v/%v", ns, name)
+
+	secret, err := a.r.GetSecret(config.Secret)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDenied{
+			Reason: errors.Wrapf(err, "unexpected error reading secret %v", config.Secret),
+		}
+	}
+
+	_, err = oidc.ClientFromSecret(secret.Data)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDenied{
+			Reason: errors.Wrapf(err, "invalid OpenID Connect client in secret %v", config.Secret),
+		}
+	}
+
+	scopes, err := parser.GetStringAnnotation("auth-oidc-scopes", ing)
+	if err != nil {
+		scopes = defaultScopes
+	}
+	config.Scopes = parseScopes(scopes)
+
+	config.RedirectPath, err = parser.GetStringAnnotation("auth-oidc-redirect-path", ing)
+	if err != nil {
+		config.RedirectPath = defaultRedirectPath
+	}
+
+	config.LogoutPath, err = parser.GetStringAnnotation("auth-oidc-logout-path", ing)
+	if err != nil {
+		config.LogoutPath = defaultLogoutPath
+	}
+
+	if !pathRegex.MatchString(config.RedirectPath) || !pathRegex.MatchString(config.LogoutPath) ||
+		config.RedirectPath == config.LogoutPath {
+		return &Config{}, ing_errors.NewLocationDenied("invalid OpenID Connect redirect or logout path")
+	}
+
+	config.PostLogoutRedirectURI, _ = parser.GetStringAnnotation("auth-oidc-post-logout-redirect-uri", ing)
+	if config.PostLogoutRedirectURI != "" && !isAbsoluteURL(config.PostLogoutRedirectURI) {
+		return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid post logout redirect URI %q", config.PostLogoutRedirectURI))
+	}
+
+	config.GroupsClaim, err = parser.GetStringAnnotation("auth-oidc-groups-claim", ing)
+	if err != nil {
+		config.GroupsClaim = defaultGroupsClaim
+	}
+
+	groups, _ := parser.GetStringAnnotation("auth-oidc-allowed-groups", ing)
+	config.AllowedGroups = authjwt.SplitList(groups)
+
+	claims, _ := parser.GetStringAnnotation("auth-oidc-required-claims", ing)
+	config.RequiredClaims, err = authjwt.ParseRequiredClaims(claims)
+	if err != nil {
+		return &Config{}, ing_errors.NewLocationDenied(err.Error())
+	}
+
+	headers, _ := parser.GetStringAnnotation("auth-oidc-claim-headers", ing)
+	config.ClaimHeaders, err = authjwt.ParseClaimHeaders(headers)
+	if err != nil {
+		return &Config{}, ing_errors.NewLocationDenied(err.Error())
+	}
+
+	lifetime := defaultSessionLifetime
+	val, err := parser.GetStringAnnotation("auth-oidc-session-lifetime", ing)
+	if err == nil {
+		lifetime, err = time.ParseDuration(val)
+		if err != nil || lifetime < time.Minute {
+			return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid session lifetime %q", val))
+		}
+	}
+	config.SessionLifetime = int(lifetime / time.Second)
+
+	config.CookieName = fmt.Sprintf("_oidc_%x", sha1.Sum([]byte(config.Issuer+"|"+config.Secret)))[:14]
+
+	return config, nil
+}
+
+// parseScopes returns the scopes of a space or comma separated list,
+// always including the openid scope
+func parseScopes(s string) []string {
+	scopes := []string{"openid"}
+	seen := map[string]bool{"openid": true}
+	for _, scope := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		if !seen[scope] {
+			scopes = append(scopes, scope)
+			seen[scope] = true
+		}
+	}
+
+	return scopes
+}
+
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
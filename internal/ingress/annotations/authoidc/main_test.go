@@ -0,0 +1,171 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authoidc
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress() *networking.Ingress {
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{
+			Backend: &networking.IngressBackend{
+				ServiceName: "default-backend",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	}
+}
+
+type mockSecret struct {
+	resolver.Mock
+}
+
+func (m mockSecret) GetSecret(name string) (*api.Secret, error) {
+	switch name {
+	case "default/oidc-client":
+		return &api.Secret{
+			Data: map[string][]byte{"client-id": []byte("app"), "client-secret": []byte("secret")},
+		}, nil
+	case "default/invalid-client":
+		return &api.Secret{
+			Data: map[string][]byte{"client-id": []byte("app")},
+		}, nil
+	}
+
+	return nil, errors.Errorf("there is no secret with name %v", name)
+}
+
+func TestParse(t *testing.T) {
+	ap := parser.GetAnnotationWithPrefix
+	issuer := "https://auth.example.com"
+
+	ing := buildIngress()
+	ing.SetAnnotations(map[string]string{
+		ap("auth-oidc-issuer"): issuer,
+		ap("auth-oidc-secret"): "oidc-client",
+	})
+
+	i, err := NewParser(mockSecret{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config := i.(*Config)
+	expected := &Config{
+		Issuer:          issuer,
+		Secret:          "default/oidc-client",
+		Scopes:          []string{"openid", "profile", "email"},
+		RedirectPath:    "/oauth2/callback",
+		LogoutPath:      "/oauth2/logout",
+		GroupsClaim:     "groups",
+		AllowedGroups:   []string{},
+		RequiredClaims:  map[string]string{},
+		ClaimHeaders:    map[string]string{},
+		SessionLifetime: 86400,
+		CookieName:      config.CookieName,
+	}
+	if !config.Equal(expected) {
+		t.Errorf("expected %+v but returned %+v", expected, config)
+	}
+	if len(config.CookieName) != 14 {
+		t.Errorf("unexpected cookie name %v", config.CookieName)
+	}
+
+	ing.SetAnnotations(map[string]string{
+		ap("auth-oidc-issuer"):                   issuer,
+		ap("auth-oidc-secret"):                   "default/oidc-client",
+		ap("auth-oidc-scopes"):                   "email groups openid email",
+		ap("auth-oidc-redirect-path"):            "/app/callback",
+		ap("auth-oidc-logout-path"):              "/app/logout",
+		ap("auth-oidc-post-logout-redirect-uri"): "https://www.example.com",
+		ap("auth-oidc-groups-claim"):             "roles",
+		ap("auth-oidc-allowed-groups"):           "admins, developers",
+		ap("auth-oidc-required-claims"):          "email_verified=true",
+		ap("auth-oidc-claim-headers"):            "email:X-Email",
+		ap("auth-oidc-session-lifetime"):         "8h",
+	})
+
+	i, err = NewParser(mockSecret{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config = i.(*Config)
+	expected = &Config{
+		Issuer:                issuer,
+		Secret:                "default/oidc-client",
+		Scopes:                []string{"openid", "email", "groups"},
+		RedirectPath:          "/app/callback",
+		LogoutPath:            "/app/logout",
+		PostLogoutRedirectURI: "https://www.example.com",
+		GroupsClaim:           "roles",
+		AllowedGroups:         []string{"admins", "developers"},
+		RequiredClaims:        map[string]string{"email_verified": "true"},
+		ClaimHeaders:          map[string]string{"email": "X-Email"},
+		SessionLifetime:       28800,
+		CookieName:            config.CookieName,
+	}
+	if !config.Equal(expected) {
+		t.Errorf("expected %+v but returned %+v", expected, config)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	ap := parser.GetAnnotationWithPrefix
+	issuer := "https://auth.example.com"
+
+	ing := buildIngress()
+	_, err := NewParser(mockSecret{}).Parse(ing)
+	if !ing_errors.IsMissingAnnotations(err) {
+		t.Errorf("expected a missing annotations error but returned %v", err)
+	}
+
+	testCases := []map[string]string{
+		{ap("auth-oidc-issuer"): "http://auth.example.com", ap("auth-oidc-secret"): "oidc-client"},
+		{ap("auth-oidc-issuer"): issuer},
+		{ap("auth-oidc-issuer"): issuer, ap("auth-oidc-secret"): "missing"},
+		{ap("auth-oidc-issuer"): issuer, ap("auth-oidc-secret"): "invalid-client"},
+		{ap("auth-oidc-issuer"): issuer, ap("auth-oidc-secret"): "oidc-client", ap("auth-oidc-redirect-path"): "callback"},
+		{ap("auth-oidc-issuer"): issuer, ap("auth-oidc-secret"): "oidc-client", ap("auth-oidc-redirect-path"): "/oauth2/logout"},
+		{ap("auth-oidc-issuer"): issuer, ap("auth-oidc-secret"): "oidc-client", ap("auth-oidc-post-logout-redirect-uri"): "/home"},
+		{ap("auth-oidc-issuer"): issuer, ap("auth-oidc-secret"): "oidc-client", ap("auth-oidc-session-lifetime"): "10s"},
+		{ap("auth-oidc-issuer"): issuer, ap("auth-oidc-secret"): "oidc-client", ap("auth-oidc-claim-headers"): "email"},
+	}
+
+	for _, annotations := range testCases {
+		ing.SetAnnotations(annotations)
+		_, err := NewParser(mockSecret{}).Parse(ing)
+		if !ing_errors.IsLocationDenied(err) {
+			t.Errorf("expected the location to be denied with %v but returned %v", annotations, err)
+		}
+	}
+}
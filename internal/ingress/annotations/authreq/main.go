@@ -44,11 +44,42 @@ type Config struct {
 	AuthSnippet       string   `json:"authSnippet"`
 	AuthCacheKey      string   `json:"authCacheKey"`
 	AuthCacheDuration []string `json:"authCacheDuration"`
+	// AuthCacheNegativeDuration is the time the denials (401 and 403) are cached
+	AuthCacheNegativeDuration string `json:"authCacheNegativeDuration,omitempty"`
+	// ConnectTimeout is the number of seconds to wait for the connection
+	// to the authentication service
+	ConnectTimeout int `json:"connectTimeout,omitempty"`
+	// ReadTimeout is the number of seconds to wait for the response of the
+	// authentication service
+	ReadTimeout int `json:"readTimeout,omitempty"`
+	// FailureMode defines if the requests are allowed (open) or rejected
+	// (closed) when the authentication service is not available
+	FailureMode string `json:"failureMode,omitempty"`
+	// CircuitBreakerFailures is the number of consecutive failures of the
+	// authentication service that stop sending requests to it. Zero
+	// disables the circuit breaker.
+	CircuitBreakerFailures int `json:"circuitBreakerFailures,omitempty"`
+	// CircuitBreakerDuration is the number of seconds without requests to
+	// the authentication service after the circuit breaker opens
+	CircuitBreakerDuration int `json:"circuitBreakerDuration,omitempty"`
 }
 
 // DefaultCacheDuration is the fallback value if no cache duration is provided
 const DefaultCacheDuration = "200 202 401 5m"
 
+const (
+	// FailureModeClosed rejects the requests when the authentication
+	// service is not available
+	FailureModeClosed = "closed"
+	// FailureModeOpen allows the requests when the authentication
+	// service is not available
+	FailureModeOpen = "open"
+
+	// DefaultCircuitBreakerDuration is the default number of seconds the
+	// circuit breaker stays open
+	DefaultCircuitBreakerDuration = 30
+)
+
 // Equal tests for equality between two Config types
 func (e1 *Config) Equal(e2 *Config) bool {
 	if e1 == e2 {
@@ -86,7 +117,27 @@ func (e1 *Config) Equal(e2 *Config) bool {
 		return false
 	}
 
-	return sets.StringElementsMatch(e1.AuthCacheDuration, e2.AuthCacheDuration)
+	if !sets.StringElementsMatch(e1.AuthCacheDuration, e2.AuthCacheDuration) {
+		return false
+	}
+
+	if e1.AuthCacheNegativeDuration != e2.AuthCacheNegativeDuration {
+		return false
+	}
+	if e1.ConnectTimeout != e2.ConnectTimeout {
+		return false
+	}
+	if e1.ReadTimeout != e2.ReadTimeout {
+		return false
+	}
+	if e1.FailureMode != e2.FailureMode {
+		return false
+	}
+	if e1.CircuitBreakerFailures != e2.CircuitBreakerFailures {
+		return false
+	}
+
+	return e1.CircuitBreakerDuration == e2.CircuitBreakerDuration
 }
 
 var (
@@ -140,6 +191,22 @@ func ValidCacheDuration(duration string) bool {
 	return seenDuration
 }
 
+// ValidDuration checks if the provided string is a valid time without
+// status codes, e.g. `1m 30s`
+func ValidDuration(duration string) bool {
+	elements := strings.Fields(duration)
+	if len(elements) == 0 {
+		return false
+	}
+
+	for _, element := range elements {
+		if !durationRegex.MatchString(element) {
+			return false
+		}
+	}
+	return true
+}
+
 type authReq struct {
 	r resolver.Resolver
 }
@@ -207,6 +274,45 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 
 	requestRedirect, _ := parser.GetStringAnnotation("auth-request-redirect", ing)
 
+	negativeDuration, _ := parser.GetStringAnnotation("auth-cache-negative-duration", ing)
+	if len(negativeDuration) != 0 && !ValidDuration(negativeDuration) {
+		return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid negative cache duration: %s", negativeDuration))
+	}
+
+	connectTimeout, err := parseTimeout("auth-connect-timeout", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	readTimeout, err := parseTimeout("auth-read-timeout", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	failureMode, err := parser.GetStringAnnotation("auth-failure-mode", ing)
+	if err != nil {
+		failureMode = FailureModeClosed
+	}
+	if failureMode != FailureModeClosed && failureMode != FailureModeOpen {
+		return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid failure mode %q, must be %v or %v", failureMode, FailureModeClosed, FailureModeOpen))
+	}
+
+	breakerFailures, err := parser.GetIntAnnotation("auth-circuit-breaker-failures", ing)
+	if err != nil {
+		breakerFailures = 0
+	}
+	if breakerFailures < 0 {
+		return nil, ing_errors.NewLocationDenied("the number of failures of the circuit breaker cannot be negative")
+	}
+
+	breakerDuration, err := parser.GetIntAnnotation("auth-circuit-breaker-duration", ing)
+	if err != nil {
+		breakerDuration = DefaultCircuitBreakerDuration
+	}
+	if breakerDuration <= 0 {
+		return nil, ing_errors.NewLocationDenied("the duration of the circuit breaker must be greater than zero")
+	}
+
 	return &Config{
 		URL:               urlString,
 		Host:              authURL.Hostname(),
@@ -217,9 +323,30 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 		AuthSnippet:       authSnippet,
 		AuthCacheKey:      authCacheKey,
 		AuthCacheDuration: authCacheDuration,
+
+		AuthCacheNegativeDuration: negativeDuration,
+		ConnectTimeout:            connectTimeout,
+		ReadTimeout:               readTimeout,
+		FailureMode:               failureMode,
+		CircuitBreakerFailures:    breakerFailures,
+		CircuitBreakerDuration:    breakerDuration,
 	}, nil
 }
 
+// parseTimeout returns the number of seconds of a timeout annotation, or
+// zero when the annotation is not present
+func parseTimeout(name string, ing *networking.Ingress) (int, error) {
+	timeout, err := parser.GetIntAnnotation(name, ing)
+	if err != nil {
+		return 0, nil
+	}
+	if timeout <= 0 {
+		return 0, ing_errors.NewLocationDenied(fmt.Sprintf("invalid %v, must be greater than zero", name))
+	}
+
+	return timeout, nil
+}
+
 // ParseStringToURL parses the provided string into URL and returns error
 // message in case of failure
 func ParseStringToURL(input string) (*url.URL, string) {
@@ -26,6 +26,7 @@ import (
 	networking "k8s.io/api/networking/v1beta1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -234,6 +235,62 @@ func TestCacheDurationAnnotations(t *testing.T) {
 	}
 }
 
+func TestResilienceAnnotations(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix("auth-url")] = "http://goog.url"
+	ing.SetAnnotations(data)
+
+	i, err := NewParser(&resolver.Mock{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u := i.(*Config)
+	if u.FailureMode != FailureModeClosed || u.ConnectTimeout != 0 || u.ReadTimeout != 0 ||
+		u.CircuitBreakerFailures != 0 || u.CircuitBreakerDuration != DefaultCircuitBreakerDuration {
+		t.Errorf("unexpected defaults %+v", u)
+	}
+
+	data[parser.GetAnnotationWithPrefix("auth-connect-timeout")] = "2"
+	data[parser.GetAnnotationWithPrefix("auth-read-timeout")] = "5"
+	data[parser.GetAnnotationWithPrefix("auth-failure-mode")] = "open"
+	data[parser.GetAnnotationWithPrefix("auth-cache-negative-duration")] = "30s"
+	data[parser.GetAnnotationWithPrefix("auth-circuit-breaker-failures")] = "5"
+	data[parser.GetAnnotationWithPrefix("auth-circuit-breaker-duration")] = "60"
+
+	i, err = NewParser(&resolver.Mock{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u = i.(*Config)
+	if u.FailureMode != FailureModeOpen || u.ConnectTimeout != 2 || u.ReadTimeout != 5 ||
+		u.AuthCacheNegativeDuration != "30s" || u.CircuitBreakerFailures != 5 || u.CircuitBreakerDuration != 60 {
+		t.Errorf("unexpected configuration %+v", u)
+	}
+
+	invalid := map[string]string{
+		"auth-connect-timeout":          "0",
+		"auth-read-timeout":             "-1",
+		"auth-failure-mode":             "maybe",
+		"auth-cache-negative-duration":  "401 30s",
+		"auth-circuit-breaker-failures": "-1",
+		"auth-circuit-breaker-duration": "0",
+	}
+	for annotation, value := range invalid {
+		key := parser.GetAnnotationWithPrefix(annotation)
+		previous := data[key]
+		data[key] = value
+
+		_, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if !ing_errors.IsLocationDenied(err) {
+			t.Errorf("expected the location to be denied with %v=%v but returned %v", annotation, value, err)
+		}
+
+		data[key] = previous
+	}
+}
+
 func TestParseStringToURL(t *testing.T) {
 	validURL := "http://bar.foo.com/external-auth"
 	validParsedURL, _ := url.Parse(validURL)
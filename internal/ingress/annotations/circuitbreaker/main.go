@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package circuitbreaker
+
+import (
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	annotationMaxRequests        = "circuit-breaker-max-requests"
+	annotationMaxPendingRequests = "circuit-breaker-max-pending-requests"
+	annotationPendingTimeout     = "circuit-breaker-pending-timeout"
+
+	defaultPendingTimeout = 5
+)
+
+// Config contains the limits of the concurrent requests sent to the
+// backends of an Ingress. The requests over the limits are rejected with
+// the status code 503 without being sent to the backend.
+type Config struct {
+	// MaxRequests is the maximum number of concurrent requests sent to the
+	// backend. Zero disables the limits
+	MaxRequests int `json:"maxRequests,omitempty"`
+	// MaxPendingRequests is the maximum number of requests waiting for a
+	// concurrent request to complete
+	MaxPendingRequests int `json:"maxPendingRequests,omitempty"`
+	// PendingTimeout is the maximum number of seconds a request waits
+	PendingTimeout int `json:"pendingTimeout,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type circuitBreaker struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new circuit breaker annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return circuitBreaker{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// limit the concurrent requests sent to the backends. The limits are
+// enabled by the circuit-breaker-max-requests annotation; invalid values
+// disable them.
+func (a circuitBreaker) Parse(ing *networking.Ingress) (interface{}, error) {
+	maxRequests, err := parser.GetIntAnnotation(annotationMaxRequests, ing)
+	if err != nil {
+		return nil, err
+	}
+	if maxRequests <= 0 {
+		return nil, ing_errors.NewInvalidAnnotationContent(annotationMaxRequests, maxRequests)
+	}
+
+	config := Config{
+		MaxRequests:    maxRequests,
+		PendingTimeout: defaultPendingTimeout,
+	}
+
+	maxPending, err := parser.GetIntAnnotation(annotationMaxPendingRequests, ing)
+	if err == nil {
+		if maxPending < 0 {
+			return nil, ing_errors.NewInvalidAnnotationContent(annotationMaxPendingRequests, maxPending)
+		}
+		config.MaxPendingRequests = maxPending
+	} else if !ing_errors.IsMissingAnnotations(err) {
+		return nil, err
+	}
+
+	timeout, err := parser.GetIntAnnotation(annotationPendingTimeout, ing)
+	if err == nil {
+		if timeout <= 0 {
+			return nil, ing_errors.NewInvalidAnnotationContent(annotationPendingTimeout, timeout)
+		}
+		config.PendingTimeout = timeout
+	} else if !ing_errors.IsMissingAnnotations(err) {
+		return nil, err
+	}
+
+	return config, nil
+}
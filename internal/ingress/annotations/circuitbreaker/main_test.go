@@ -0,0 +1,132 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package circuitbreaker
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress(annotations map[string]string) *networking.Ingress {
+	data := map[string]string{}
+	for k, v := range annotations {
+		data[parser.GetAnnotationWithPrefix(k)] = v
+	}
+
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   api.NamespaceDefault,
+			Annotations: data,
+		},
+		Spec: networking.IngressSpec{
+			Backend: &networking.IngressBackend{
+				ServiceName: "default-backend",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    Config
+	}{
+		{
+			"default values",
+			map[string]string{
+				"circuit-breaker-max-requests": "100",
+			},
+			Config{MaxRequests: 100, PendingTimeout: 5},
+		},
+		{
+			"custom values",
+			map[string]string{
+				"circuit-breaker-max-requests":         "100",
+				"circuit-breaker-max-pending-requests": "50",
+				"circuit-breaker-pending-timeout":      "2",
+			},
+			Config{MaxRequests: 100, MaxPendingRequests: 50, PendingTimeout: 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i, err := NewParser(&resolver.Mock{}).Parse(buildIngress(test.annotations))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			config := i.(Config)
+			if !config.Equal(&test.expected) {
+				t.Errorf("expected %+v but got %+v", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestParseWithoutMaxRequests(t *testing.T) {
+	ing := buildIngress(map[string]string{
+		"circuit-breaker-max-pending-requests": "50",
+	})
+
+	_, err := NewParser(&resolver.Mock{}).Parse(ing)
+	if !errors.IsMissingAnnotations(err) {
+		t.Errorf("expected a missing annotation error but got %v", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"zero max requests": {
+			"circuit-breaker-max-requests": "0",
+		},
+		"invalid max requests": {
+			"circuit-breaker-max-requests": "many",
+		},
+		"negative max pending requests": {
+			"circuit-breaker-max-requests":         "100",
+			"circuit-breaker-max-pending-requests": "-1",
+		},
+		"zero pending timeout": {
+			"circuit-breaker-max-requests":    "100",
+			"circuit-breaker-pending-timeout": "0",
+		},
+	}
+
+	for name, annotations := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewParser(&resolver.Mock{}).Parse(buildIngress(annotations))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.IsMissingAnnotations(err) || errors.IsLocationDenied(err) {
+				t.Errorf("expected an invalid annotation error but got %v", err)
+			}
+		})
+	}
+}
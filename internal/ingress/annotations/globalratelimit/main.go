@@ -0,0 +1,215 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package globalratelimit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/net"
+	"k8s.io/ingress-nginx/internal/sets"
+)
+
+const (
+	// SlidingWindow estimates the number of requests in the last window
+	// using the counters of the current and the previous windows
+	SlidingWindow = "sliding-window"
+	// FixedWindow counts the requests received since the beginning of
+	// the current window
+	FixedWindow = "fixed-window"
+
+	defaultKey = "$remote_addr"
+
+	// claimVariablePrefix is the prefix of the variables replaced with the
+	// claims of the JSON Web Token verified by the JWT authentication
+	claimVariablePrefix = "$jwt_claim_"
+)
+
+// Config contains the configuration of a rate limit shared by all the
+// replicas of the ingress controller
+type Config struct {
+	// Namespace is used as prefix of the counters to avoid collisions
+	// between different Ingress rules using the same key
+	Namespace string `json:"namespace"`
+	// Limit is the number of requests allowed in a window
+	Limit int `json:"limit"`
+	// WindowSize is the size of the window in seconds
+	WindowSize int `json:"window-size"`
+	// Key is the value used to identify a client, composed of NGINX variables
+	Key string `json:"key"`
+	// Algorithm is the windowed algorithm used to count the requests
+	Algorithm string `json:"algorithm"`
+	// IgnoredCIDRs contains the client addresses excluded from the limit
+	IgnoredCIDRs []string `json:"ignored-cidrs"`
+}
+
+// Authenticated returns true if the key uses the claims of the JSON Web
+// Token, only known once the request is authenticated
+func (c *Config) Authenticated() bool {
+	return strings.Contains(c.Key, claimVariablePrefix)
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Namespace != c2.Namespace {
+		return false
+	}
+	if c1.Limit != c2.Limit {
+		return false
+	}
+	if c1.WindowSize != c2.WindowSize {
+		return false
+	}
+	if c1.Key != c2.Key {
+		return false
+	}
+	if c1.Algorithm != c2.Algorithm {
+		return false
+	}
+	if len(c1.IgnoredCIDRs) != len(c2.IgnoredCIDRs) {
+		return false
+	}
+
+	return sets.StringElementsMatch(c1.IgnoredCIDRs, c2.IgnoredCIDRs)
+}
+
+type globalratelimit struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new global rate limit annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return globalratelimit{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to configure the global rate limit
+func (a globalratelimit) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	limit, _ := parser.GetIntAnnotation("global-rate-limit", ing)
+	if limit <= 0 {
+		return config, nil
+	}
+
+	window, err := parser.GetStringAnnotation("global-rate-limit-window", ing)
+	if err != nil {
+		klog.Warningf("Ingress %v/%v: the global-rate-limit annotation requires global-rate-limit-window. Ignoring the global rate limit.", ing.GetNamespace(), ing.GetName())
+		return config, ing_errors.NewInvalidAnnotationConfiguration("global-rate-limit-window", "the annotation is required")
+	}
+
+	windowSize, err := parseWindow(window)
+	if err != nil {
+		klog.Warningf("Ingress %v/%v: %v. Ignoring the global rate limit.", ing.GetNamespace(), ing.GetName(), err)
+		return config, ing_errors.NewInvalidAnnotationContent("global-rate-limit-window", window)
+	}
+
+	key, err := parser.GetStringAnnotation("global-rate-limit-key", ing)
+	if err != nil || !strings.Contains(key, "$") {
+		key = defaultKey
+	}
+
+	if strings.Contains(key, claimVariablePrefix) && !hasJWTAuth(ing) {
+		klog.Warningf("Ingress %v/%v: the claims used by the global-rate-limit-key annotation require the JWT authentication. Ignoring the global rate limit.", ing.GetNamespace(), ing.GetName())
+		return config, ing_errors.NewInvalidAnnotationContent("global-rate-limit-key", key)
+	}
+
+	algorithm, err := parser.GetStringAnnotation("global-rate-limit-algorithm", ing)
+	if err != nil {
+		algorithm = SlidingWindow
+	}
+	if algorithm != SlidingWindow && algorithm != FixedWindow {
+		klog.Warningf("%v is not a valid value for the global-rate-limit-algorithm annotation. Using %v", algorithm, SlidingWindow)
+		algorithm = SlidingWindow
+	}
+
+	val, _ := parser.GetStringAnnotation("global-rate-limit-ignored-cidrs", ing)
+	cidrs, err := parseCIDRs(val)
+	if err != nil {
+		return config, ing_errors.NewInvalidAnnotationContent("global-rate-limit-ignored-cidrs", val)
+	}
+
+	config.Namespace = fmt.Sprintf("%v_%v", ing.GetNamespace(), ing.GetName())
+	config.Limit = limit
+	config.WindowSize = windowSize
+	config.Key = key
+	config.Algorithm = algorithm
+	config.IgnoredCIDRs = cidrs
+
+	return config, nil
+}
+
+// hasJWTAuth returns true if the requests are authenticated with JSON Web Tokens
+func hasJWTAuth(ing *networking.Ingress) bool {
+	secret, _ := parser.GetStringAnnotation("auth-jwt-secret", ing)
+	jwksURL, _ := parser.GetStringAnnotation("auth-jwt-jwks-url", ing)
+	return secret != "" || jwksURL != ""
+}
+
+// parseWindow returns the size in seconds of a window like 30s or 1m
+func parseWindow(s string) (int, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if d < time.Second || d%time.Second != 0 {
+		return 0, fmt.Errorf("the window %v must be a whole number of seconds", s)
+	}
+
+	return int(d / time.Second), nil
+}
+
+func parseCIDRs(s string) ([]string, error) {
+	if s == "" {
+		return []string{}, nil
+	}
+
+	values := strings.Split(s, ",")
+
+	ipnets, ips, err := net.ParseIPNets(values...)
+	if err != nil {
+		return nil, err
+	}
+
+	cidrs := []string{}
+	for k := range ipnets {
+		cidrs = append(cidrs, k)
+	}
+
+	for k := range ips {
+		cidrs = append(cidrs, k)
+	}
+
+	sort.Strings(cidrs)
+
+	return cidrs, nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package globalratelimit
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress() *networking.Ingress {
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+}
+
+func TestWithoutAnnotations(t *testing.T) {
+	i, err := NewParser(&resolver.Mock{}).Parse(buildIngress())
+	if err != nil {
+		t.Errorf("unexpected error with ingress without annotations: %v", err)
+	}
+
+	if !reflect.DeepEqual(i, &Config{}) {
+		t.Errorf("expected an empty configuration but returned %+v", i)
+	}
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	tests := []struct {
+		title       string
+		annotations map[string]string
+		expected    *Config
+		expectErr   bool
+	}{
+		{
+			"defaults",
+			map[string]string{
+				"global-rate-limit":        "100",
+				"global-rate-limit-window": "1m",
+			},
+			&Config{
+				Namespace:    "default_foo",
+				Limit:        100,
+				WindowSize:   60,
+				Key:          "$remote_addr",
+				Algorithm:    SlidingWindow,
+				IgnoredCIDRs: []string{},
+			},
+			false,
+		},
+		{
+			"custom key, algorithm and ignored CIDRs",
+			map[string]string{
+				"global-rate-limit":               "10",
+				"global-rate-limit-window":        "30s",
+				"global-rate-limit-key":           "$http_x_api_key",
+				"global-rate-limit-algorithm":     FixedWindow,
+				"global-rate-limit-ignored-cidrs": "10.0.0.0/8, 192.168.1.1",
+			},
+			&Config{
+				Namespace:    "default_foo",
+				Limit:        10,
+				WindowSize:   30,
+				Key:          "$http_x_api_key",
+				Algorithm:    FixedWindow,
+				IgnoredCIDRs: []string{"10.0.0.0/8", "192.168.1.1"},
+			},
+			false,
+		},
+		{
+			"invalid algorithm and key",
+			map[string]string{
+				"global-rate-limit":           "10",
+				"global-rate-limit-window":    "10s",
+				"global-rate-limit-key":       "client",
+				"global-rate-limit-algorithm": "token-bucket",
+			},
+			&Config{
+				Namespace:    "default_foo",
+				Limit:        10,
+				WindowSize:   10,
+				Key:          "$remote_addr",
+				Algorithm:    SlidingWindow,
+				IgnoredCIDRs: []string{},
+			},
+			false,
+		},
+		{
+			"claims of the authenticated token",
+			map[string]string{
+				"global-rate-limit":        "10",
+				"global-rate-limit-window": "1m",
+				"global-rate-limit-key":    "$jwt_claim_sub",
+				"auth-jwt-jwks-url":        "https://example.com/.well-known/jwks.json",
+			},
+			&Config{
+				Namespace:    "default_foo",
+				Limit:        10,
+				WindowSize:   60,
+				Key:          "$jwt_claim_sub",
+				Algorithm:    SlidingWindow,
+				IgnoredCIDRs: []string{},
+			},
+			false,
+		},
+		{
+			"claims without JWT authentication",
+			map[string]string{
+				"global-rate-limit":        "10",
+				"global-rate-limit-window": "1m",
+				"global-rate-limit-key":    "$jwt_claim_sub",
+			},
+			&Config{},
+			true,
+		},
+		{
+			"missing window",
+			map[string]string{
+				"global-rate-limit": "10",
+			},
+			&Config{},
+			true,
+		},
+		{
+			"invalid window",
+			map[string]string{
+				"global-rate-limit":        "10",
+				"global-rate-limit-window": "500ms",
+			},
+			&Config{},
+			true,
+		},
+		{
+			"invalid ignored CIDRs",
+			map[string]string{
+				"global-rate-limit":               "10",
+				"global-rate-limit-window":        "1s",
+				"global-rate-limit-ignored-cidrs": "example.com",
+			},
+			&Config{},
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		ing := buildIngress()
+
+		data := map[string]string{}
+		for k, v := range test.annotations {
+			data[parser.GetAnnotationWithPrefix(k)] = v
+		}
+		ing.SetAnnotations(data)
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if test.expectErr != (err != nil) {
+			t.Errorf("%v: expected error %v but returned %v", test.title, test.expectErr, err)
+		}
+
+		if !reflect.DeepEqual(i, test.expected) {
+			t.Errorf("%v: expected %+v but returned %+v", test.title, test.expected, i)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package headers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+var (
+	headerNameRegex = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d\-]*$`)
+	// values can contain NGINX variables but no control characters or
+	// characters NGINX would not read back from the quoted string
+	headerValueRegex = regexp.MustCompile(`^[\x20-\x7e]*$`)
+)
+
+// reservedRequestHeaders contains the headers sent to the upstream that
+// are already configured by the controller or other annotations
+var reservedRequestHeaders = []string{
+	"connection",
+	"host",
+	"proxy",
+	"upgrade",
+	"x-forwarded-for",
+	"x-forwarded-host",
+	"x-forwarded-port",
+	"x-forwarded-prefix",
+	"x-forwarded-proto",
+	"x-original-forwarded-for",
+	"x-original-uri",
+	"x-real-ip",
+	"x-request-id",
+	"x-scheme",
+}
+
+// Operations describes the changes applied to the headers of a request
+// or a response
+type Operations struct {
+	// Set contains the headers to set, replacing any existing value
+	Set map[string]string `json:"set,omitempty"`
+	// Append contains the values to append to the headers, separated by
+	// a comma from any existing value
+	Append map[string]string `json:"append,omitempty"`
+	// Remove contains the headers to remove
+	Remove []string `json:"remove,omitempty"`
+}
+
+// Empty returns true if there are no changes to apply
+func (o *Operations) Empty() bool {
+	return len(o.Set) == 0 && len(o.Append) == 0 && len(o.Remove) == 0
+}
+
+// Has returns true if the header is changed by any operation
+func (o *Operations) Has(name string) bool {
+	for k := range o.Set {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	for k := range o.Append {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	for _, k := range o.Remove {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Equal tests for equality between two Operations types
+func (o1 *Operations) Equal(o2 *Operations) bool {
+	if o1 == o2 {
+		return true
+	}
+	if o1 == nil || o2 == nil {
+		return false
+	}
+	if !stringMapEqual(o1.Set, o2.Set) {
+		return false
+	}
+	if !stringMapEqual(o1.Append, o2.Append) {
+		return false
+	}
+	if len(o1.Remove) != len(o2.Remove) {
+		return false
+	}
+	for i := range o1.Remove {
+		if o1.Remove[i] != o2.Remove[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Config contains the changes to the headers of the requests sent to the
+// upstream and of the responses sent to the client
+type Config struct {
+	Request  Operations `json:"request"`
+	Response Operations `json:"response"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if !(&c1.Request).Equal(&c2.Request) {
+		return false
+	}
+
+	return (&c1.Response).Equal(&c2.Response)
+}
+
+func stringMapEqual(m1, m2 map[string]string) bool {
+	if len(m1) != len(m2) {
+		return false
+	}
+	for k, v := range m1 {
+		if v2, ok := m2[k]; !ok || v != v2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+type headers struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new header manipulation annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return headers{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// change the headers of the requests and responses. Invalid headers
+// deny the access to the location.
+func (h headers) Parse(ing *networking.Ingress) (interface{}, error) {
+	request, err := h.parseOperations("request", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range reservedRequestHeaders {
+		if request.Has(name) {
+			return nil, ing_errors.NewLocationDenied(fmt.Sprintf("request header %v is configured by the controller", name))
+		}
+	}
+
+	response, err := h.parseOperations("response", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	return Config{
+		Request:  *request,
+		Response: *response,
+	}, nil
+}
+
+// parseOperations reads the <kind>-headers-configmap, <kind>-headers-set,
+// <kind>-headers-append and <kind>-headers-remove annotations. The values
+// of <kind>-headers-set take precedence over the ones of the ConfigMap.
+func (h headers) parseOperations(kind string, ing *networking.Ingress) (*Operations, error) {
+	ops := &Operations{}
+
+	cm, err := parser.GetStringAnnotation(kind+"-headers-configmap", ing)
+	if err == nil {
+		data, err := h.configMapHeaders(cm, ing)
+		if err != nil {
+			return nil, err
+		}
+		ops.Set = data
+	}
+
+	val, err := parser.GetStringAnnotation(kind+"-headers-set", ing)
+	if err == nil {
+		set, err := parseHeaderValues(val)
+		if err != nil {
+			return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid %v-headers-set annotation: %v", kind, err))
+		}
+		if ops.Set == nil {
+			ops.Set = map[string]string{}
+		}
+		for k, v := range set {
+			for name := range ops.Set {
+				if strings.EqualFold(name, k) {
+					delete(ops.Set, name)
+				}
+			}
+			ops.Set[k] = v
+		}
+	}
+
+	val, err = parser.GetStringAnnotation(kind+"-headers-append", ing)
+	if err == nil {
+		ops.Append, err = parseHeaderValues(val)
+		if err != nil {
+			return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid %v-headers-append annotation: %v", kind, err))
+		}
+	}
+
+	val, err = parser.GetStringAnnotation(kind+"-headers-remove", ing)
+	if err == nil {
+		ops.Remove, err = parseHeaderNames(val)
+		if err != nil {
+			return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid %v-headers-remove annotation: %v", kind, err))
+		}
+	}
+
+	if err := checkConflicts(ops); err != nil {
+		return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid %v headers: %v", kind, err))
+	}
+
+	return ops, nil
+}
+
+// configMapHeaders returns the headers contained in a ConfigMap of the
+// namespace of the Ingress
+func (h headers) configMapHeaders(name string, ing *networking.Ingress) (map[string]string, error) {
+	ns, cmName, err := cache.SplitMetaNamespaceKey(name)
+	if err != nil {
+		return nil, ing_errors.LocationDenied{
+			Reason: errors.Wrap(err, "error reading configmap name from annotation"),
+		}
+	}
+
+	if ns == "" {
+		ns = ing.Namespace
+	}
+
+	if ns != ing.Namespace {
+		return nil, ing_errors.NewLocationDenied(fmt.Sprintf("configmap %v/%v must be in the namespace of the ingress", ns, cmName))
+	}
+
+	key := fmt.Sprintf("%v/%v", ns, cmName)
+	cmap, err := h.r.GetConfigMap(key)
+	if err != nil {
+		return nil, ing_errors.LocationDenied{
+			Reason: errors.Wrapf(err, "unexpected error reading configmap %v", key),
+		}
+	}
+
+	data := make(map[string]string, len(cmap.Data))
+	for k, v := range cmap.Data {
+		if err := validateHeader(k, v); err != nil {
+			return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid header in configmap %v: %v", key, err))
+		}
+		data[k] = v
+	}
+
+	return data, nil
+}
+
+// parseHeaderValues parses a list of headers with the format
+// "Name: value", one header per line
+func parseHeaderValues(s string) (map[string]string, error) {
+	values := map[string]string{}
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("%q is not a header with the format 'Name: value'", line)
+		}
+
+		name, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if err := validateHeader(name, value); err != nil {
+			return nil, err
+		}
+
+		if _, ok := values[name]; ok {
+			return nil, errors.Errorf("header %v is duplicated", name)
+		}
+		values[name] = value
+	}
+
+	return values, nil
+}
+
+// parseHeaderNames parses a comma separated list of header names
+func parseHeaderNames(s string) ([]string, error) {
+	var names []string
+
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if !headerNameRegex.MatchString(name) {
+			return nil, errors.Errorf("invalid header name %q", name)
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func validateHeader(name, value string) error {
+	if !headerNameRegex.MatchString(name) {
+		return errors.Errorf("invalid header name %q", name)
+	}
+
+	if value == "" {
+		return errors.Errorf("header %v has no value", name)
+	}
+
+	if !headerValueRegex.MatchString(value) {
+		return errors.Errorf("header %v contains invalid characters", name)
+	}
+
+	return nil
+}
+
+// checkConflicts returns an error if a header is changed by more than
+// one operation
+func checkConflicts(ops *Operations) error {
+	seen := map[string]string{}
+
+	check := func(name, op string) error {
+		key := strings.ToLower(name)
+		if prev, ok := seen[key]; ok {
+			return errors.Errorf("header %v can not be changed by %v and %v", name, prev, op)
+		}
+		seen[key] = op
+		return nil
+	}
+
+	for k := range ops.Set {
+		if err := check(k, "set"); err != nil {
+			return err
+		}
+	}
+	for k := range ops.Append {
+		if err := check(k, "append"); err != nil {
+			return err
+		}
+	}
+	for _, k := range ops.Remove {
+		if err := check(k, "remove"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package headers
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress() *networking.Ingress {
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{
+			Backend: &networking.IngressBackend{
+				ServiceName: "default-backend",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	}
+}
+
+type mockConfigMap struct {
+	resolver.Mock
+}
+
+func (m mockConfigMap) GetConfigMap(name string) (*api.ConfigMap, error) {
+	switch name {
+	case "default/headers":
+		return &api.ConfigMap{
+			Data: map[string]string{"X-Frame-Options": "DENY", "X-Team": "payments"},
+		}, nil
+	case "default/invalid-headers":
+		return &api.ConfigMap{
+			Data: map[string]string{"X Invalid": "value"},
+		}, nil
+	}
+
+	return nil, errors.Errorf("there is no configmap with name %v", name)
+}
+
+func TestParse(t *testing.T) {
+	ing := buildIngress()
+
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("request-headers-set"):        "X-Tenant: acme\nX-Client-Port: $remote_port",
+		parser.GetAnnotationWithPrefix("request-headers-append"):     "Via: 1.1 ingress",
+		parser.GetAnnotationWithPrefix("request-headers-remove"):     "X-Debug, Cookie2",
+		parser.GetAnnotationWithPrefix("response-headers-configmap"): "headers",
+		parser.GetAnnotationWithPrefix("response-headers-set"):       "X-Team: checkout",
+		parser.GetAnnotationWithPrefix("response-headers-append"):    "Cache-Control: no-transform",
+		parser.GetAnnotationWithPrefix("response-headers-remove"):    "Server,X-Powered-By",
+	})
+
+	i, err := NewParser(mockConfigMap{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := Config{
+		Request: Operations{
+			Set:    map[string]string{"X-Tenant": "acme", "X-Client-Port": "$remote_port"},
+			Append: map[string]string{"Via": "1.1 ingress"},
+			Remove: []string{"Cookie2", "X-Debug"},
+		},
+		Response: Operations{
+			Set:    map[string]string{"X-Frame-Options": "DENY", "X-Team": "checkout"},
+			Append: map[string]string{"Cache-Control": "no-transform"},
+			Remove: []string{"Server", "X-Powered-By"},
+		},
+	}
+
+	if !reflect.DeepEqual(i, expected) {
+		t.Errorf("expected %+v but got %+v", expected, i)
+	}
+}
+
+func TestParseWithoutAnnotations(t *testing.T) {
+	i, err := NewParser(mockConfigMap{}).Parse(buildIngress())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config := i.(Config)
+	if !config.Request.Empty() || !config.Response.Empty() {
+		t.Errorf("expected no changes but got %+v", config)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"header without value": {
+			"request-headers-set": "X-Tenant",
+		},
+		"invalid header name": {
+			"response-headers-set": "X_Tenant: acme",
+		},
+		"value with control characters": {
+			"response-headers-set": "X-Tenant: acme\tcorp",
+		},
+		"duplicated header": {
+			"response-headers-append": "X-Tenant: acme\nX-Tenant: corp",
+		},
+		"invalid header to remove": {
+			"response-headers-remove": "X-Debug, X Debug",
+		},
+		"header with conflicting operations": {
+			"response-headers-set":    "X-Tenant: acme",
+			"response-headers-remove": "x-tenant",
+		},
+		"reserved request header": {
+			"request-headers-set": "X-Forwarded-For: 127.0.0.1",
+		},
+		"configmap in other namespace": {
+			"request-headers-configmap": "other/headers",
+		},
+		"missing configmap": {
+			"request-headers-configmap": "missing",
+		},
+		"configmap with invalid header": {
+			"response-headers-configmap": "invalid-headers",
+		},
+	}
+
+	for name, annotations := range tests {
+		t.Run(name, func(t *testing.T) {
+			ing := buildIngress()
+
+			data := map[string]string{}
+			for k, v := range annotations {
+				data[parser.GetAnnotationWithPrefix(k)] = v
+			}
+			ing.SetAnnotations(data)
+
+			_, err := NewParser(mockConfigMap{}).Parse(ing)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !errors.IsLocationDenied(err) {
+				t.Errorf("expected a LocationDenied error but got %v", err)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	c1 := &Config{Request: Operations{Set: map[string]string{"X-Tenant": "acme"}}}
+	c2 := &Config{Request: Operations{Set: map[string]string{"X-Tenant": "acme"}}}
+	if !c1.Equal(c2) {
+		t.Errorf("expected configurations to be equal")
+	}
+
+	c2.Response.Remove = []string{"Server"}
+	if c1.Equal(c2) {
+		t.Errorf("expected configurations to be different")
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	annotationPath               = "health-check-path"
+	annotationHost               = "health-check-host"
+	annotationInterval           = "health-check-interval"
+	annotationTimeout            = "health-check-timeout"
+	annotationHealthyThreshold   = "health-check-healthy-threshold"
+	annotationUnhealthyThreshold = "health-check-unhealthy-threshold"
+	annotationExpectedStatus     = "health-check-expected-status"
+	annotationBackendProtocol    = "backend-protocol"
+
+	defaultInterval           = 5
+	defaultTimeout            = 2
+	defaultHealthyThreshold   = 2
+	defaultUnhealthyThreshold = 3
+	defaultExpectedStatus     = "200-399"
+)
+
+var (
+	pathRegex = regexp.MustCompile(`^/[\x21-\x7e]*$`)
+	hostRegex = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d\-.]*(:\d+)?$`)
+)
+
+// Config contains the configuration of the active health checks of the
+// endpoints of the backends of an Ingress
+type Config struct {
+	// Path of the HTTP request sent to the endpoints. Active health checks
+	// are disabled when empty
+	Path string `json:"path,omitempty"`
+	// Host is the value of the Host header of the request
+	Host string `json:"host,omitempty"`
+	// Scheme of the request, https when the backend protocol uses TLS
+	Scheme string `json:"scheme,omitempty"`
+	// Interval is the number of seconds between two checks of an endpoint
+	Interval int `json:"interval,omitempty"`
+	// Timeout is the number of seconds to wait for the response
+	Timeout int `json:"timeout,omitempty"`
+	// HealthyThreshold is the number of consecutive successful checks
+	// needed to consider an unhealthy endpoint healthy again
+	HealthyThreshold int `json:"healthyThreshold,omitempty"`
+	// UnhealthyThreshold is the number of consecutive failed checks needed
+	// to consider an endpoint unhealthy
+	UnhealthyThreshold int `json:"unhealthyThreshold,omitempty"`
+	// ExpectedStatus contains the status codes of a healthy response as a
+	// comma separated list of codes and ranges, e.g. 200,300-399
+	ExpectedStatus string `json:"expectedStatus,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type healthCheck struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new active health check annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return healthCheck{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// configure the active health checks. Active health checks are enabled
+// by the health-check-path annotation; invalid values disable them.
+func (a healthCheck) Parse(ing *networking.Ingress) (interface{}, error) {
+	path, err := parser.GetStringAnnotation(annotationPath, ing)
+	if err != nil {
+		return nil, err
+	}
+
+	path = strings.TrimSpace(path)
+	if !pathRegex.MatchString(path) {
+		return nil, ing_errors.NewInvalidAnnotationContent(annotationPath, path)
+	}
+
+	config := Config{
+		Path:               path,
+		Scheme:             "http",
+		Interval:           defaultInterval,
+		Timeout:            defaultTimeout,
+		HealthyThreshold:   defaultHealthyThreshold,
+		UnhealthyThreshold: defaultUnhealthyThreshold,
+		ExpectedStatus:     defaultExpectedStatus,
+	}
+
+	host, err := parser.GetStringAnnotation(annotationHost, ing)
+	if err == nil {
+		host = strings.TrimSpace(host)
+		if !hostRegex.MatchString(host) {
+			return nil, ing_errors.NewInvalidAnnotationContent(annotationHost, host)
+		}
+		config.Host = host
+	}
+
+	protocol, _ := parser.GetStringAnnotation(annotationBackendProtocol, ing)
+	switch strings.ToUpper(protocol) {
+	case "HTTPS", "GRPCS":
+		config.Scheme = "https"
+	}
+
+	for _, setting := range []struct {
+		name  string
+		value *int
+	}{
+		{annotationInterval, &config.Interval},
+		{annotationTimeout, &config.Timeout},
+		{annotationHealthyThreshold, &config.HealthyThreshold},
+		{annotationUnhealthyThreshold, &config.UnhealthyThreshold},
+	} {
+		v, err := parser.GetIntAnnotation(setting.name, ing)
+		if err != nil {
+			if ing_errors.IsMissingAnnotations(err) {
+				continue
+			}
+			return nil, err
+		}
+		if v <= 0 {
+			return nil, ing_errors.NewInvalidAnnotationContent(setting.name, v)
+		}
+		*setting.value = v
+	}
+
+	if config.Timeout >= config.Interval {
+		return nil, ing_errors.NewInvalidAnnotationConfiguration(annotationTimeout, "the timeout must be lower than the interval")
+	}
+
+	status, err := parser.GetStringAnnotation(annotationExpectedStatus, ing)
+	if err == nil {
+		config.ExpectedStatus, err = parseExpectedStatus(status)
+		if err != nil {
+			return nil, ing_errors.NewInvalidAnnotationContent(annotationExpectedStatus, status)
+		}
+	}
+
+	return config, nil
+}
+
+// parseExpectedStatus validates a comma separated list of status codes
+// and ranges of status codes and returns it in its canonical form
+func parseExpectedStatus(s string) (string, error) {
+	var codes []string
+
+	for _, code := range strings.Split(s, ",") {
+		bounds := strings.SplitN(code, "-", 2)
+		min, err := parseStatus(bounds[0])
+		if err != nil {
+			return "", err
+		}
+		if len(bounds) == 1 {
+			codes = append(codes, strconv.Itoa(min))
+			continue
+		}
+
+		max, err := parseStatus(bounds[1])
+		if err != nil {
+			return "", err
+		}
+		if max < min {
+			return "", ing_errors.Errorf("invalid range of status codes %v", code)
+		}
+
+		codes = append(codes, fmt.Sprintf("%v-%v", min, max))
+	}
+
+	return strings.Join(codes, ","), nil
+}
+
+func parseStatus(s string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if code < 100 || code > 599 {
+		return 0, ing_errors.Errorf("invalid status code %v", code)
+	}
+
+	return code, nil
+}
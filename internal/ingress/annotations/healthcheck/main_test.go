@@ -0,0 +1,169 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress(annotations map[string]string) *networking.Ingress {
+	data := map[string]string{}
+	for k, v := range annotations {
+		data[parser.GetAnnotationWithPrefix(k)] = v
+	}
+
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   api.NamespaceDefault,
+			Annotations: data,
+		},
+		Spec: networking.IngressSpec{
+			Backend: &networking.IngressBackend{
+				ServiceName: "default-backend",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    Config
+	}{
+		{
+			"default values",
+			map[string]string{
+				"health-check-path": "/healthz",
+			},
+			Config{
+				Path:               "/healthz",
+				Scheme:             "http",
+				Interval:           5,
+				Timeout:            2,
+				HealthyThreshold:   2,
+				UnhealthyThreshold: 3,
+				ExpectedStatus:     "200-399",
+			},
+		},
+		{
+			"custom values",
+			map[string]string{
+				"health-check-path":                "/status?full=1",
+				"health-check-host":                "app.example.com",
+				"health-check-interval":            "10",
+				"health-check-timeout":             "3",
+				"health-check-healthy-threshold":   "1",
+				"health-check-unhealthy-threshold": "5",
+				"health-check-expected-status":     "200, 204,300 - 302",
+				"backend-protocol":                 "HTTPS",
+			},
+			Config{
+				Path:               "/status?full=1",
+				Host:               "app.example.com",
+				Scheme:             "https",
+				Interval:           10,
+				Timeout:            3,
+				HealthyThreshold:   1,
+				UnhealthyThreshold: 5,
+				ExpectedStatus:     "200,204,300-302",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i, err := NewParser(&resolver.Mock{}).Parse(buildIngress(test.annotations))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			config := i.(Config)
+			if !config.Equal(&test.expected) {
+				t.Errorf("expected %+v but got %+v", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestParseWithoutPath(t *testing.T) {
+	ing := buildIngress(map[string]string{
+		"health-check-interval": "10",
+	})
+
+	_, err := NewParser(&resolver.Mock{}).Parse(ing)
+	if !errors.IsMissingAnnotations(err) {
+		t.Errorf("expected a missing annotation error but got %v", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"relative path": {
+			"health-check-path": "healthz",
+		},
+		"path with spaces": {
+			"health-check-path": "/health z",
+		},
+		"invalid host": {
+			"health-check-host": "app example.com",
+		},
+		"invalid interval": {
+			"health-check-interval": "5s",
+		},
+		"negative threshold": {
+			"health-check-unhealthy-threshold": "-1",
+		},
+		"timeout greater than the interval": {
+			"health-check-interval": "2",
+			"health-check-timeout":  "2",
+		},
+		"invalid status code": {
+			"health-check-expected-status": "200,20",
+		},
+		"invalid range of status codes": {
+			"health-check-expected-status": "399-200",
+		},
+	}
+
+	for name, annotations := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, ok := annotations["health-check-path"]; !ok {
+				annotations["health-check-path"] = "/healthz"
+			}
+
+			_, err := NewParser(&resolver.Mock{}).Parse(buildIngress(annotations))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.IsMissingAnnotations(err) || errors.IsLocationDenied(err) {
+				t.Errorf("expected an invalid annotation error but got %v", err)
+			}
+		})
+	}
+}
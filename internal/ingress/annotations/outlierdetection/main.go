@@ -0,0 +1,140 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package outlierdetection
+
+import (
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	annotationConsecutiveErrors  = "outlier-detection-consecutive-errors"
+	annotationErrorRate          = "outlier-detection-error-rate"
+	annotationMinRequests        = "outlier-detection-min-requests"
+	annotationInterval           = "outlier-detection-interval"
+	annotationBaseEjectionTime   = "outlier-detection-ejection-time"
+	annotationMaxEjectionTime    = "outlier-detection-max-ejection-time"
+	annotationMaxEjectionPercent = "outlier-detection-max-ejection-percent"
+
+	defaultMinRequests        = 10
+	defaultInterval           = 10
+	defaultBaseEjectionTime   = 30
+	defaultMaxEjectionTime    = 300
+	defaultMaxEjectionPercent = 10
+)
+
+// Config contains the configuration of the outlier detection of the
+// endpoints of the backends of an Ingress. Endpoints returning errors are
+// ejected from the load balancing for a period of time.
+type Config struct {
+	// ConsecutiveErrors is the number of consecutive errors that ejects
+	// an endpoint. Zero disables the detection by consecutive errors
+	ConsecutiveErrors int `json:"consecutiveErrors,omitempty"`
+	// ErrorRate is the percentage of errors during an interval that
+	// ejects an endpoint. Zero disables the detection by error rate
+	ErrorRate int `json:"errorRate,omitempty"`
+	// MinRequests is the minimum number of requests during an interval to
+	// compute the error rate of an endpoint
+	MinRequests int `json:"minRequests,omitempty"`
+	// Interval is the number of seconds of the period used to compute the
+	// error rate
+	Interval int `json:"interval,omitempty"`
+	// BaseEjectionTime is the number of seconds an endpoint is ejected the
+	// first time. It is multiplied by the number of recent ejections of
+	// the endpoint
+	BaseEjectionTime int `json:"baseEjectionTime,omitempty"`
+	// MaxEjectionTime is the maximum number of seconds an endpoint is
+	// ejected
+	MaxEjectionTime int `json:"maxEjectionTime,omitempty"`
+	// MaxEjectionPercent is the maximum percentage of the endpoints of the
+	// backend that can be ejected at the same time
+	MaxEjectionPercent int `json:"maxEjectionPercent,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type outlierDetection struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new outlier detection annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return outlierDetection{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// configure the outlier detection. It is enabled by the consecutive errors
+// or the error rate annotations; invalid values disable it.
+func (a outlierDetection) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := Config{
+		MinRequests:        defaultMinRequests,
+		Interval:           defaultInterval,
+		BaseEjectionTime:   defaultBaseEjectionTime,
+		MaxEjectionTime:    defaultMaxEjectionTime,
+		MaxEjectionPercent: defaultMaxEjectionPercent,
+	}
+
+	for _, setting := range []struct {
+		name  string
+		value *int
+		min   int
+		max   int
+	}{
+		{annotationConsecutiveErrors, &config.ConsecutiveErrors, 0, 0},
+		{annotationErrorRate, &config.ErrorRate, 0, 100},
+		{annotationMinRequests, &config.MinRequests, 1, 0},
+		{annotationInterval, &config.Interval, 1, 0},
+		{annotationBaseEjectionTime, &config.BaseEjectionTime, 1, 0},
+		{annotationMaxEjectionTime, &config.MaxEjectionTime, 1, 0},
+		{annotationMaxEjectionPercent, &config.MaxEjectionPercent, 1, 100},
+	} {
+		v, err := parser.GetIntAnnotation(setting.name, ing)
+		if err != nil {
+			if ing_errors.IsMissingAnnotations(err) {
+				continue
+			}
+			return nil, err
+		}
+		if v < setting.min || (setting.max > 0 && v > setting.max) {
+			return nil, ing_errors.NewInvalidAnnotationContent(setting.name, v)
+		}
+		*setting.value = v
+	}
+
+	if config.ConsecutiveErrors == 0 && config.ErrorRate == 0 {
+		return nil, ing_errors.ErrMissingAnnotations
+	}
+
+	if config.MaxEjectionTime < config.BaseEjectionTime {
+		return nil, ing_errors.NewInvalidAnnotationConfiguration(annotationMaxEjectionTime, "the maximum ejection time must not be lower than the ejection time")
+	}
+
+	return config, nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package outlierdetection
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress(annotations map[string]string) *networking.Ingress {
+	data := map[string]string{}
+	for k, v := range annotations {
+		data[parser.GetAnnotationWithPrefix(k)] = v
+	}
+
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   api.NamespaceDefault,
+			Annotations: data,
+		},
+		Spec: networking.IngressSpec{
+			Backend: &networking.IngressBackend{
+				ServiceName: "default-backend",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    Config
+	}{
+		{
+			"consecutive errors with default values",
+			map[string]string{
+				"outlier-detection-consecutive-errors": "5",
+			},
+			Config{
+				ConsecutiveErrors:  5,
+				MinRequests:        10,
+				Interval:           10,
+				BaseEjectionTime:   30,
+				MaxEjectionTime:    300,
+				MaxEjectionPercent: 10,
+			},
+		},
+		{
+			"error rate with custom values",
+			map[string]string{
+				"outlier-detection-error-rate":           "50",
+				"outlier-detection-min-requests":         "20",
+				"outlier-detection-interval":             "30",
+				"outlier-detection-ejection-time":        "10",
+				"outlier-detection-max-ejection-time":    "60",
+				"outlier-detection-max-ejection-percent": "34",
+			},
+			Config{
+				ErrorRate:          50,
+				MinRequests:        20,
+				Interval:           30,
+				BaseEjectionTime:   10,
+				MaxEjectionTime:    60,
+				MaxEjectionPercent: 34,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i, err := NewParser(&resolver.Mock{}).Parse(buildIngress(test.annotations))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			config := i.(Config)
+			if !config.Equal(&test.expected) {
+				t.Errorf("expected %+v but got %+v", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestParseDisabled(t *testing.T) {
+	ing := buildIngress(map[string]string{
+		"outlier-detection-consecutive-errors": "0",
+		"outlier-detection-ejection-time":      "10",
+	})
+
+	_, err := NewParser(&resolver.Mock{}).Parse(ing)
+	if !errors.IsMissingAnnotations(err) {
+		t.Errorf("expected a missing annotation error but got %v", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"invalid number of errors": {
+			"outlier-detection-consecutive-errors": "five",
+		},
+		"negative number of errors": {
+			"outlier-detection-consecutive-errors": "-1",
+		},
+		"error rate over 100": {
+			"outlier-detection-error-rate": "101",
+		},
+		"zero interval": {
+			"outlier-detection-consecutive-errors": "5",
+			"outlier-detection-interval":           "0",
+		},
+		"max ejection percent over 100": {
+			"outlier-detection-consecutive-errors":   "5",
+			"outlier-detection-max-ejection-percent": "200",
+		},
+		"max ejection time lower than the ejection time": {
+			"outlier-detection-consecutive-errors": "5",
+			"outlier-detection-ejection-time":      "60",
+			"outlier-detection-max-ejection-time":  "30",
+		},
+	}
+
+	for name, annotations := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewParser(&resolver.Mock{}).Parse(buildIngress(annotations))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.IsMissingAnnotations(err) || errors.IsLocationDenied(err) {
+				t.Errorf("expected an invalid annotation error but got %v", err)
+			}
+		})
+	}
+}
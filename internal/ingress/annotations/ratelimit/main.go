@@ -19,12 +19,17 @@ package ratelimit
 import (
 	"encoding/base64"
 	"fmt"
+	"net/http"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 	"k8s.io/ingress-nginx/internal/net"
 	"k8s.io/ingress-nginx/internal/sets"
@@ -37,8 +42,25 @@ const (
 	// 1MB -> 16 thousand 64-byte states or about 8 thousand 128-byte states
 	// default is 5MB
 	defSharedSize = 5
+
+	defResponseContentType = "text/plain"
 )
 
+// methodRateRegex matches the rate of a method, like 10r/s or 100r/m
+var methodRateRegex = regexp.MustCompile(`^([0-9]+)r/([sm])$`)
+
+var validMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
 // Config returns rate limit configuration for an Ingress rule limiting the
 // number of connections per IP address and/or connections per second.
 // If you both annotations are specified in a single Ingress rule, RPS limits
@@ -60,6 +82,75 @@ type Config struct {
 	ID string `json:"id"`
 
 	Whitelist []string `json:"whitelist"`
+
+	// Key is the value used to identify a client, composed of NGINX variables.
+	// When empty the limit-conn-zone-variable of the ConfigMap is used
+	Key string `json:"key"`
+
+	// Delay delays the requests over the rate instead of rejecting them,
+	// as long as they do not exceed the burst
+	Delay bool `json:"delay"`
+
+	// Methods contains the HTTP methods limited by the rates of the RPS and
+	// RPM zones. All the methods are limited when both Methods and
+	// MethodZones are empty
+	Methods []string `json:"methods"`
+
+	// MethodZones contains the HTTP methods limited by their own rate
+	MethodZones []MethodZone `json:"methodZones"`
+
+	// Response is returned to the clients over the limit
+	Response Response `json:"response"`
+}
+
+// Response defines the response returned to the clients over the limit
+type Response struct {
+	Body        string `json:"body"`
+	ContentType string `json:"contentType"`
+	// Headers adds the Retry-After and RateLimit-* headers to the response
+	Headers bool `json:"headers"`
+	// Limit is the number of requests allowed in the window of the most
+	// restrictive rate and Reset the size of the window in seconds, 1 for
+	// a rate per second and 60 for a rate per minute
+	Limit int `json:"limit"`
+	Reset int `json:"reset"`
+}
+
+// MethodZone limits the requests of an HTTP method with its own rate
+type MethodZone struct {
+	Method string `json:"method"`
+	Zone   Zone   `json:"zone"`
+	// PerMinute is true when the limit of the zone is a number of requests
+	// per minute instead of per second
+	PerMinute bool `json:"perMinute"`
+}
+
+// Equal tests for equality between two MethodZone types
+func (m1 *MethodZone) Equal(m2 *MethodZone) bool {
+	if m1 == m2 {
+		return true
+	}
+	if m1 == nil || m2 == nil {
+		return false
+	}
+	if m1.Method != m2.Method {
+		return false
+	}
+	if m1.PerMinute != m2.PerMinute {
+		return false
+	}
+
+	return (&m1.Zone).Equal(&m2.Zone)
+}
+
+// LimitsMethods returns true if the limits only apply to some HTTP methods
+func (rt Config) LimitsMethods() bool {
+	return len(rt.Methods) > 0 || len(rt.MethodZones) > 0
+}
+
+// Enabled returns true if the default response must be replaced
+func (r Response) Enabled() bool {
+	return r.Body != "" || r.Headers
 }
 
 // Equal tests for equality between two RateLimit types
@@ -94,8 +185,31 @@ func (rt1 *Config) Equal(rt2 *Config) bool {
 	if len(rt1.Whitelist) != len(rt2.Whitelist) {
 		return false
 	}
+	if !sets.StringElementsMatch(rt1.Whitelist, rt2.Whitelist) {
+		return false
+	}
+	if rt1.Key != rt2.Key {
+		return false
+	}
+	if rt1.Delay != rt2.Delay {
+		return false
+	}
+	if len(rt1.Methods) != len(rt2.Methods) {
+		return false
+	}
+	if !sets.StringElementsMatch(rt1.Methods, rt2.Methods) {
+		return false
+	}
+	if len(rt1.MethodZones) != len(rt2.MethodZones) {
+		return false
+	}
+	for i := range rt1.MethodZones {
+		if !(&rt1.MethodZones[i]).Equal(&rt2.MethodZones[i]) {
+			return false
+		}
+	}
 
-	return sets.StringElementsMatch(rt1.Whitelist, rt2.Whitelist)
+	return rt1.Response == rt2.Response
 }
 
 // Zone returns information about the NGINX rate limit (limit_req_zone)
@@ -165,7 +279,15 @@ func (a ratelimit) Parse(ing *networking.Ingress) (interface{}, error) {
 		return nil, err
 	}
 
-	if rpm == 0 && rps == 0 && conn == 0 {
+	zoneName := fmt.Sprintf("%v_%v", ing.GetNamespace(), ing.GetName())
+
+	val, _ = parser.GetStringAnnotation("limit-methods", ing)
+	methods, methodZones, err := parseMethods(val, zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	if rpm == 0 && rps == 0 && conn == 0 && len(methodZones) == 0 {
 		return &Config{
 			Connections:    Zone{},
 			RPS:            Zone{},
@@ -175,7 +297,27 @@ func (a ratelimit) Parse(ing *networking.Ingress) (interface{}, error) {
 		}, nil
 	}
 
-	zoneName := fmt.Sprintf("%v_%v", ing.GetNamespace(), ing.GetName())
+	key, _ := parser.GetStringAnnotation("limit-key", ing)
+	if key != "" && !strings.Contains(key, "$") {
+		klog.Warningf("%v is not a valid value for the limit-key annotation, it must contain NGINX variables. Using the default key.", key)
+		key = ""
+	}
+
+	// the bursts are counted in the unit of the rate of each zone
+	rpsBurst, err := parser.GetIntAnnotation("limit-rps-burst", ing)
+	if err != nil || rpsBurst < 0 {
+		rpsBurst = rps * defBurst
+	}
+
+	rpmBurst, err := parser.GetIntAnnotation("limit-rpm-burst", ing)
+	if err != nil || rpmBurst < 0 {
+		rpmBurst = rpm * defBurst
+	}
+
+	nodelay, err := parser.GetBoolAnnotation("limit-nodelay", ing)
+	if err != nil {
+		nodelay = true
+	}
 
 	return &Config{
 		Connections: Zone{
@@ -187,13 +329,13 @@ func (a ratelimit) Parse(ing *networking.Ingress) (interface{}, error) {
 		RPS: Zone{
 			Name:       fmt.Sprintf("%v_rps", zoneName),
 			Limit:      rps,
-			Burst:      rps * defBurst,
+			Burst:      rpsBurst,
 			SharedSize: defSharedSize,
 		},
 		RPM: Zone{
 			Name:       fmt.Sprintf("%v_rpm", zoneName),
 			Limit:      rpm,
-			Burst:      rpm * defBurst,
+			Burst:      rpmBurst,
 			SharedSize: defSharedSize,
 		},
 		LimitRate:      lr,
@@ -201,9 +343,107 @@ func (a ratelimit) Parse(ing *networking.Ingress) (interface{}, error) {
 		Name:           zoneName,
 		ID:             encode(zoneName),
 		Whitelist:      cidrs,
+		Key:            key,
+		Delay:          !nodelay,
+		Methods:        methods,
+		MethodZones:    methodZones,
+		Response:       parseResponse(ing, rps, rpm, conn, methodZones),
 	}, nil
 }
 
+// parseMethods returns the methods limited by the rates of the RPS and RPM
+// zones and the zones of the methods with their own rate, like POST:10r/s
+func parseMethods(s, zoneName string) ([]string, []MethodZone, error) {
+	methods := []string{}
+	zones := []MethodZone{}
+	if s == "" {
+		return methods, zones, nil
+	}
+
+	for _, m := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(m), ":", 2)
+		method := strings.ToUpper(strings.TrimSpace(parts[0]))
+		if !validMethods[method] {
+			return nil, nil, ing_errors.NewInvalidAnnotationContent("limit-methods", s)
+		}
+
+		if len(parts) == 1 {
+			methods = append(methods, method)
+			continue
+		}
+
+		rate := methodRateRegex.FindStringSubmatch(strings.TrimSpace(parts[1]))
+		if rate == nil {
+			return nil, nil, ing_errors.NewInvalidAnnotationContent("limit-methods", s)
+		}
+
+		limit, _ := strconv.Atoi(rate[1])
+		if limit <= 0 {
+			return nil, nil, ing_errors.NewInvalidAnnotationContent("limit-methods", s)
+		}
+
+		zones = append(zones, MethodZone{
+			Method: method,
+			Zone: Zone{
+				Name:       fmt.Sprintf("%v_%v", zoneName, strings.ToLower(method)),
+				Limit:      limit,
+				Burst:      limit * defBurst,
+				SharedSize: defSharedSize,
+			},
+			PerMinute: rate[2] == "m",
+		})
+	}
+
+	sort.Strings(methods)
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].Method < zones[j].Method
+	})
+
+	return methods, zones, nil
+}
+
+// parseResponse returns the response for the clients over the limit. The
+// RateLimit-* headers describe the most restrictive rate.
+func parseResponse(ing *networking.Ingress, rps, rpm, conn int, methodZones []MethodZone) Response {
+	body, _ := parser.GetStringAnnotation("limit-response-body", ing)
+	contentType, err := parser.GetStringAnnotation("limit-response-content-type", ing)
+	if err != nil {
+		contentType = defResponseContentType
+	}
+	headers, _ := parser.GetBoolAnnotation("limit-response-headers", ing)
+
+	r := Response{
+		Body:        body,
+		ContentType: contentType,
+		Headers:     headers,
+	}
+
+	r.Limit = conn
+	r.Reset = 1
+
+	// the rates are compared in requests per minute
+	perMinute := 0
+	restrict := func(limit, window int) {
+		if limit > 0 && (perMinute == 0 || limit*60/window < perMinute) {
+			perMinute = limit * 60 / window
+			r.Limit = limit
+			r.Reset = window
+		}
+	}
+
+	restrict(rps, 1)
+	restrict(rpm, 60)
+	for _, mz := range methodZones {
+		if mz.PerMinute {
+			restrict(mz.Zone.Limit, 60)
+		} else {
+			restrict(mz.Zone.Limit, 1)
+		}
+	}
+
+	return r
+}
+
 func parseCIDRs(s string) ([]string, error) {
 	if s == "" {
 		return []string{}, nil
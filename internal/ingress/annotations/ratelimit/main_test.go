@@ -19,6 +19,7 @@ package ratelimit
 import (
 	"reflect"
 	"sort"
+	"strconv"
 	"testing"
 
 	api "k8s.io/api/core/v1"
@@ -149,3 +150,173 @@ func TestRateLimiting(t *testing.T) {
 		t.Errorf("expected 10 in limit by limitrate but %v was returend", rateLimit.LimitRate)
 	}
 }
+
+func TestRateLimitingMethodZones(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix("limit-rps")] = "100"
+	data[parser.GetAnnotationWithPrefix("limit-methods")] = "GET, put:30r/m, post:5r/s"
+	data[parser.GetAnnotationWithPrefix("limit-response-headers")] = "true"
+	ing.SetAnnotations(data)
+
+	i, err := NewParser(mockBackend{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rateLimit := i.(*Config)
+
+	if !reflect.DeepEqual(rateLimit.Methods, []string{"GET"}) {
+		t.Errorf("expected the GET method but %v was returned", rateLimit.Methods)
+	}
+
+	expected := []MethodZone{
+		{Method: "POST", Zone: Zone{Name: "default_foo_post", Limit: 5, Burst: 25, SharedSize: defSharedSize}},
+		{Method: "PUT", Zone: Zone{Name: "default_foo_put", Limit: 30, Burst: 150, SharedSize: defSharedSize}, PerMinute: true},
+	}
+	if !reflect.DeepEqual(rateLimit.MethodZones, expected) {
+		t.Errorf("expected %+v but %+v was returned", expected, rateLimit.MethodZones)
+	}
+
+	// 30r/m is the most restrictive rate
+	if rateLimit.Response.Limit != 30 || rateLimit.Response.Reset != 60 {
+		t.Errorf("expected a limit of 30 requests in 60 seconds but %+v was returned", rateLimit.Response)
+	}
+
+	// the rates of the methods are enough to enable the limits
+	delete(data, parser.GetAnnotationWithPrefix("limit-rps"))
+	data[parser.GetAnnotationWithPrefix("limit-methods")] = "POST:5r/s"
+	ing.SetAnnotations(data)
+
+	i, err = NewParser(mockBackend{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rateLimit = i.(*Config)
+	if len(rateLimit.MethodZones) != 1 || rateLimit.ID == "" {
+		t.Errorf("expected the POST method to be limited but %+v was returned", rateLimit)
+	}
+	if rateLimit.Response.Limit != 5 || rateLimit.Response.Reset != 1 {
+		t.Errorf("expected a limit of 5 requests in 1 second but %+v was returned", rateLimit.Response)
+	}
+
+	for _, invalid := range []string{"POST:5", "POST:0r/s", "POST:5r/h", "FETCH:5r/s"} {
+		data[parser.GetAnnotationWithPrefix("limit-methods")] = invalid
+		ing.SetAnnotations(data)
+
+		if _, err := NewParser(mockBackend{}).Parse(ing); err == nil {
+			t.Errorf("expected an error with the invalid methods %v", invalid)
+		}
+	}
+}
+
+func TestRateLimitingResponseHeaders(t *testing.T) {
+	testCases := []struct {
+		rps, rpm int
+		limit    int
+		reset    int
+	}{
+		{rps: 10, limit: 10, reset: 1},
+		{rpm: 120, limit: 120, reset: 60},
+		{rps: 1, rpm: 120, limit: 1, reset: 1},
+		{rps: 10, rpm: 120, limit: 120, reset: 60},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		ing.SetAnnotations(map[string]string{
+			parser.GetAnnotationWithPrefix("limit-rps"):              strconv.Itoa(tc.rps),
+			parser.GetAnnotationWithPrefix("limit-rpm"):              strconv.Itoa(tc.rpm),
+			parser.GetAnnotationWithPrefix("limit-response-headers"): "true",
+		})
+
+		i, err := NewParser(mockBackend{}).Parse(ing)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		response := i.(*Config).Response
+		if response.Limit != tc.limit || response.Reset != tc.reset {
+			t.Errorf("expected a limit of %v requests in %v seconds with %v rps and %v rpm but %+v was returned",
+				tc.limit, tc.reset, tc.rps, tc.rpm, response)
+		}
+	}
+}
+
+func TestRateLimitingKeyAndResponse(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix("limit-rps")] = "10"
+	data[parser.GetAnnotationWithPrefix("limit-rpm")] = "120"
+	data[parser.GetAnnotationWithPrefix("limit-key")] = "$http_x_api_key"
+	data[parser.GetAnnotationWithPrefix("limit-rps-burst")] = "0"
+	data[parser.GetAnnotationWithPrefix("limit-rpm-burst")] = "30"
+	data[parser.GetAnnotationWithPrefix("limit-nodelay")] = "false"
+	data[parser.GetAnnotationWithPrefix("limit-methods")] = "post, PUT"
+	data[parser.GetAnnotationWithPrefix("limit-response-body")] = `{"error":"too many requests"}`
+	data[parser.GetAnnotationWithPrefix("limit-response-content-type")] = "application/json"
+	data[parser.GetAnnotationWithPrefix("limit-response-headers")] = "true"
+	ing.SetAnnotations(data)
+
+	i, err := NewParser(mockBackend{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rateLimit := i.(*Config)
+
+	if rateLimit.Key != "$http_x_api_key" {
+		t.Errorf("expected $http_x_api_key as key but %v was returned", rateLimit.Key)
+	}
+	if rateLimit.RPS.Burst != 0 || rateLimit.RPM.Burst != 30 {
+		t.Errorf("expected bursts of 0 and 30 but %v and %v were returned", rateLimit.RPS.Burst, rateLimit.RPM.Burst)
+	}
+	if !rateLimit.Delay {
+		t.Errorf("expected requests to be delayed")
+	}
+	if !reflect.DeepEqual(rateLimit.Methods, []string{"POST", "PUT"}) {
+		t.Errorf("expected POST and PUT methods but %v was returned", rateLimit.Methods)
+	}
+
+	expected := Response{
+		Body:        `{"error":"too many requests"}`,
+		ContentType: "application/json",
+		Headers:     true,
+		Limit:       120,
+		Reset:       60,
+	}
+	if rateLimit.Response != expected {
+		t.Errorf("expected %+v but %+v was returned", expected, rateLimit.Response)
+	}
+
+	data[parser.GetAnnotationWithPrefix("limit-key")] = "api-key"
+	data[parser.GetAnnotationWithPrefix("limit-methods")] = "FETCH"
+	ing.SetAnnotations(data)
+
+	_, err = NewParser(mockBackend{}).Parse(ing)
+	if err == nil {
+		t.Errorf("expected an error with an invalid method")
+	}
+
+	delete(data, parser.GetAnnotationWithPrefix("limit-methods"))
+	delete(data, parser.GetAnnotationWithPrefix("limit-rps-burst"))
+	delete(data, parser.GetAnnotationWithPrefix("limit-rpm-burst"))
+	delete(data, parser.GetAnnotationWithPrefix("limit-nodelay"))
+	ing.SetAnnotations(data)
+
+	i, err = NewParser(mockBackend{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rateLimit = i.(*Config)
+
+	if rateLimit.Key != "" {
+		t.Errorf("expected the default key but %v was returned", rateLimit.Key)
+	}
+	if rateLimit.RPS.Burst != 50 || rateLimit.RPM.Burst != 600 {
+		t.Errorf("expected bursts of 50 and 600 but %v and %v were returned", rateLimit.RPS.Burst, rateLimit.RPM.Burst)
+	}
+	if rateLimit.Delay {
+		t.Errorf("expected requests not to be delayed")
+	}
+}
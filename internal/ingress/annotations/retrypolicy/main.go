@@ -0,0 +1,154 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retrypolicy
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	annotationRetryOn          = "retry-on"
+	annotationPerTryTimeout    = "retry-per-try-timeout"
+	annotationBudgetPercent    = "retry-budget-percent"
+	annotationBudgetMinRetries = "retry-budget-min-retries"
+
+	defaultBudgetMinRetries = 3
+)
+
+// Conditions contains the conditions accepted by the retry-on annotation
+// and the values of the proxy_next_upstream directive they configure.
+// NGINX reports connection failures and resets as the same error.
+var Conditions = map[string][]string{
+	"connect-failure": {"error"},
+	"reset":           {"error"},
+	"timeout":         {"timeout"},
+	"non-idempotent":  {"non_idempotent"},
+	"5xx":             {"http_500", "http_502", "http_503", "http_504"},
+	"500":             {"http_500"},
+	"502":             {"http_502"},
+	"503":             {"http_503"},
+	"504":             {"http_504"},
+	"403":             {"http_403"},
+	"404":             {"http_404"},
+	"429":             {"http_429"},
+}
+
+// Config contains the retry policy of a location
+type Config struct {
+	// RetryOn contains the conditions that retry a request on the next
+	// endpoint. Empty uses the proxy-next-upstream configuration
+	RetryOn []string `json:"retryOn,omitempty"`
+	// PerTryTimeout is the number of seconds to wait for the response of
+	// every attempt. Zero uses the proxy timeouts
+	PerTryTimeout int `json:"perTryTimeout,omitempty"`
+	// BudgetPercent is the maximum percentage of the active requests of the
+	// backend being retried. Zero disables the retry budget
+	BudgetPercent int `json:"budgetPercent,omitempty"`
+	// BudgetMinRetries is the number of concurrent retries always allowed
+	// by the retry budget
+	BudgetMinRetries int `json:"budgetMinRetries,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	if !sets.NewString(c1.RetryOn...).Equal(sets.NewString(c2.RetryOn...)) {
+		return false
+	}
+
+	return c1.PerTryTimeout == c2.PerTryTimeout &&
+		c1.BudgetPercent == c2.BudgetPercent &&
+		c1.BudgetMinRetries == c2.BudgetMinRetries
+}
+
+type retryPolicy struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new retry policy annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return retryPolicy{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// configure the retry policy. Invalid values disable it.
+func (a retryPolicy) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := Config{
+		BudgetMinRetries: defaultBudgetMinRetries,
+	}
+	configured := false
+
+	retryOn, err := parser.GetStringAnnotation(annotationRetryOn, ing)
+	if err == nil {
+		conditions := sets.NewString()
+		for _, condition := range strings.Split(retryOn, ",") {
+			condition = strings.ToLower(strings.TrimSpace(condition))
+			if _, ok := Conditions[condition]; !ok {
+				return nil, ing_errors.NewInvalidAnnotationContent(annotationRetryOn, retryOn)
+			}
+			conditions.Insert(condition)
+		}
+
+		config.RetryOn = conditions.List()
+		configured = true
+	} else if !ing_errors.IsMissingAnnotations(err) {
+		return nil, err
+	}
+
+	for _, setting := range []struct {
+		name  string
+		value *int
+		min   int
+		max   int
+	}{
+		{annotationPerTryTimeout, &config.PerTryTimeout, 1, 0},
+		{annotationBudgetPercent, &config.BudgetPercent, 1, 100},
+		{annotationBudgetMinRetries, &config.BudgetMinRetries, 0, 0},
+	} {
+		v, err := parser.GetIntAnnotation(setting.name, ing)
+		if err != nil {
+			if ing_errors.IsMissingAnnotations(err) {
+				continue
+			}
+			return nil, err
+		}
+		if v < setting.min || (setting.max > 0 && v > setting.max) {
+			return nil, ing_errors.NewInvalidAnnotationContent(setting.name, v)
+		}
+		*setting.value = v
+		configured = configured || setting.name != annotationBudgetMinRetries
+	}
+
+	if !configured {
+		return nil, ing_errors.ErrMissingAnnotations
+	}
+
+	return config, nil
+}
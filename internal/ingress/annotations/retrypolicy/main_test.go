@@ -0,0 +1,153 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retrypolicy
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress(annotations map[string]string) *networking.Ingress {
+	data := map[string]string{}
+	for k, v := range annotations {
+		data[parser.GetAnnotationWithPrefix(k)] = v
+	}
+
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   api.NamespaceDefault,
+			Annotations: data,
+		},
+		Spec: networking.IngressSpec{
+			Backend: &networking.IngressBackend{
+				ServiceName: "default-backend",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    Config
+	}{
+		{
+			"conditions",
+			map[string]string{
+				"retry-on": "connect-failure, 503,Reset,503",
+			},
+			Config{
+				RetryOn:          []string{"503", "connect-failure", "reset"},
+				BudgetMinRetries: 3,
+			},
+		},
+		{
+			"retry budget",
+			map[string]string{
+				"retry-budget-percent": "20",
+			},
+			Config{
+				BudgetPercent:    20,
+				BudgetMinRetries: 3,
+			},
+		},
+		{
+			"custom values",
+			map[string]string{
+				"retry-on":                 "5xx,timeout",
+				"retry-per-try-timeout":    "2",
+				"retry-budget-percent":     "10",
+				"retry-budget-min-retries": "0",
+			},
+			Config{
+				RetryOn:          []string{"5xx", "timeout"},
+				PerTryTimeout:    2,
+				BudgetPercent:    10,
+				BudgetMinRetries: 0,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i, err := NewParser(&resolver.Mock{}).Parse(buildIngress(test.annotations))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			config := i.(Config)
+			if !config.Equal(&test.expected) {
+				t.Errorf("expected %+v but got %+v", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestParseWithoutPolicy(t *testing.T) {
+	ing := buildIngress(map[string]string{
+		"retry-budget-min-retries": "5",
+	})
+
+	_, err := NewParser(&resolver.Mock{}).Parse(ing)
+	if !errors.IsMissingAnnotations(err) {
+		t.Errorf("expected a missing annotation error but got %v", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"unknown condition": {
+			"retry-on": "5xx,408",
+		},
+		"empty condition": {
+			"retry-on": "5xx,",
+		},
+		"zero per try timeout": {
+			"retry-per-try-timeout": "0",
+		},
+		"budget over 100 percent": {
+			"retry-budget-percent": "101",
+		},
+		"negative minimum of retries": {
+			"retry-budget-percent":     "10",
+			"retry-budget-min-retries": "-1",
+		},
+	}
+
+	for name, annotations := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewParser(&resolver.Mock{}).Parse(buildIngress(annotations))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.IsMissingAnnotations(err) || errors.IsLocationDenied(err) {
+				t.Errorf("expected an invalid annotation error but got %v", err)
+			}
+		})
+	}
+}
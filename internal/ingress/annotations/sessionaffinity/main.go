@@ -17,7 +17,9 @@ limitations under the License.
 package sessionaffinity
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 
 	networking "k8s.io/api/networking/v1beta1"
 	"k8s.io/klog"
@@ -47,17 +49,68 @@ const (
 
 	// This is used to control the cookie change after request failure
 	annotationAffinityCookieChangeOnFailure = "session-cookie-change-on-failure"
+
+	// This is used to control how the sessions are kept when the endpoints change
+	annotationAffinityMode = "affinity-mode"
+
+	// The name of the request header used in header affinity
+	annotationAffinityHeaderName = "session-header-name"
+
+	// The number of bits of the IPv4 client address used in ip affinity
+	annotationAffinityIPPrefixLength = "session-ip-prefix-length"
+
+	// The number of bits of the IPv6 client address used in ip affinity
+	annotationAffinityIPv6PrefixLength = "session-ipv6-prefix-length"
+
+	defaultAffinityIPPrefixLength   = 32
+	defaultAffinityIPv6PrefixLength = 128
+)
+
+const (
+	// AffinityTypeCookie keeps the sessions with a cookie set by the controller
+	AffinityTypeCookie = "cookie"
+	// AffinityTypeHeader keeps the sessions by the value of a request header
+	AffinityTypeHeader = "header"
+	// AffinityTypeIP keeps the sessions by the address of the client
+	AffinityTypeIP = "ip"
+
+	// AffinityModeBalanced redistributes a part of the sessions when
+	// endpoints are added, keeping the load balanced
+	AffinityModeBalanced = "balanced"
+	// AffinityModePersistent keeps the sessions on their endpoint while it
+	// exists. The sessions of a removed endpoint are remapped
+	// deterministically to the remaining ones.
+	AffinityModePersistent = "persistent"
 )
 
 var (
 	affinityCookieExpiresRegex = regexp.MustCompile(`(^0|-?[1-9]\d*$)`)
+	affinityHeaderNameRegex    = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d\-]*$`)
 )
 
 // Config describes the per ingress session affinity config
 type Config struct {
 	// The type of affinity that will be used
 	Type string `json:"type"`
+	// The mode of the affinity, balanced or persistent
+	Mode string `json:"mode"`
 	Cookie
+	Header Header `json:"header"`
+	IP     IP     `json:"ip"`
+}
+
+// Header describes the Config of header type affinity
+type Header struct {
+	// The name of the request header with the value used to keep the session
+	Name string `json:"name"`
+}
+
+// IP describes the Config of ip type affinity
+type IP struct {
+	// The number of bits of the IPv4 client address used to keep the session
+	PrefixLength int `json:"prefixLength"`
+	// The number of bits of the IPv6 client address used to keep the session
+	IPv6PrefixLength int `json:"ipv6PrefixLength"`
 }
 
 // Cookie describes the Config of cookie type affinity
@@ -117,6 +170,50 @@ func (a affinity) cookieAffinityParse(ing *networking.Ingress) *Cookie {
 	return cookie
 }
 
+// headerAffinityParse gets the annotation values related to Header Affinity
+func (a affinity) headerAffinityParse(ing *networking.Ingress) (*Header, error) {
+	name, err := parser.GetStringAnnotation(annotationAffinityHeaderName, ing)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if !affinityHeaderNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid header name %q", name)
+	}
+
+	return &Header{Name: name}, nil
+}
+
+// ipAffinityParse gets the annotation values related to IP Affinity
+// It also sets default values when no value or incorrect value is found
+func (a affinity) ipAffinityParse(ing *networking.Ingress) *IP {
+	ip := &IP{
+		PrefixLength:     defaultAffinityIPPrefixLength,
+		IPv6PrefixLength: defaultAffinityIPv6PrefixLength,
+	}
+
+	length, err := parser.GetIntAnnotation(annotationAffinityIPPrefixLength, ing)
+	if err == nil {
+		if length >= 0 && length <= 32 {
+			ip.PrefixLength = length
+		} else {
+			klog.Warningf("Invalid value in annotation %v of Ingress %v: %v. Using the default %v", annotationAffinityIPPrefixLength, ing.Name, length, defaultAffinityIPPrefixLength)
+		}
+	}
+
+	length, err = parser.GetIntAnnotation(annotationAffinityIPv6PrefixLength, ing)
+	if err == nil {
+		if length >= 0 && length <= 128 {
+			ip.IPv6PrefixLength = length
+		} else {
+			klog.Warningf("Invalid value in annotation %v of Ingress %v: %v. Using the default %v", annotationAffinityIPv6PrefixLength, ing.Name, length, defaultAffinityIPv6PrefixLength)
+		}
+	}
+
+	return ip
+}
+
 // NewParser creates a new Affinity annotation parser
 func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return affinity{r}
@@ -130,6 +227,9 @@ type affinity struct {
 // rule used to configure the affinity directives
 func (a affinity) Parse(ing *networking.Ingress) (interface{}, error) {
 	cookie := &Cookie{}
+	header := &Header{}
+	ip := &IP{}
+
 	// Check the type of affinity that will be used
 	at, err := parser.GetStringAnnotation(annotationAffinityType, ing)
 	if err != nil {
@@ -137,15 +237,40 @@ func (a affinity) Parse(ing *networking.Ingress) (interface{}, error) {
 	}
 
 	switch at {
-	case "cookie":
+	case AffinityTypeCookie:
 		cookie = a.cookieAffinityParse(ing)
+	case AffinityTypeHeader:
+		header, err = a.headerAffinityParse(ing)
+		if err != nil {
+			klog.Warningf("Ingress %v: invalid or no annotation %v: %v. Ignoring the header affinity", ing.Name, annotationAffinityHeaderName, err)
+			at = ""
+			header = &Header{}
+		}
+	case AffinityTypeIP:
+		ip = a.ipAffinityParse(ing)
 	default:
 		klog.V(3).Infof("No default affinity was found for Ingress %v", ing.Name)
+		at = ""
+	}
+
+	mode := ""
+	if at != "" {
+		mode, err = parser.GetStringAnnotation(annotationAffinityMode, ing)
+		if err != nil {
+			mode = AffinityModeBalanced
+		}
 
+		if mode != AffinityModeBalanced && mode != AffinityModePersistent {
+			klog.Warningf("Invalid value in annotation %v of Ingress %v: %v. Using the default %v", annotationAffinityMode, ing.Name, mode, AffinityModeBalanced)
+			mode = AffinityModeBalanced
+		}
 	}
 
 	return &Config{
 		Type:   at,
+		Mode:   mode,
 		Cookie: *cookie,
+		Header: *header,
+		IP:     *ip,
 	}, nil
 }
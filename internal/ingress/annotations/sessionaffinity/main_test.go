@@ -108,3 +108,92 @@ func TestIngressAffinityCookieConfig(t *testing.T) {
 		t.Errorf("expected change of failure parameter set to true but returned %v", nginxAffinity.Cookie.ChangeOnFailure)
 	}
 }
+
+func TestIngressAffinityMode(t *testing.T) {
+	tests := []struct {
+		annotations map[string]string
+		mode        string
+	}{
+		{map[string]string{annotationAffinityType: "cookie"}, AffinityModeBalanced},
+		{map[string]string{annotationAffinityType: "cookie", annotationAffinityMode: "persistent"}, AffinityModePersistent},
+		{map[string]string{annotationAffinityType: "cookie", annotationAffinityMode: "invalid"}, AffinityModeBalanced},
+		{map[string]string{annotationAffinityMode: "persistent"}, ""},
+	}
+
+	for _, test := range tests {
+		ing := buildIngress()
+
+		data := map[string]string{}
+		for k, v := range test.annotations {
+			data[parser.GetAnnotationWithPrefix(k)] = v
+		}
+		ing.SetAnnotations(data)
+
+		affin, _ := NewParser(&resolver.Mock{}).Parse(ing)
+		nginxAffinity := affin.(*Config)
+
+		if nginxAffinity.Mode != test.mode {
+			t.Errorf("expected %v as affinity mode with annotations %v but returned %v", test.mode, test.annotations, nginxAffinity.Mode)
+		}
+	}
+}
+
+func TestIngressAffinityHeaderConfig(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix(annotationAffinityType)] = "header"
+	data[parser.GetAnnotationWithPrefix(annotationAffinityHeaderName)] = "X-Session-ID"
+	ing.SetAnnotations(data)
+
+	affin, _ := NewParser(&resolver.Mock{}).Parse(ing)
+	nginxAffinity := affin.(*Config)
+
+	if nginxAffinity.Type != "header" {
+		t.Errorf("expected header as affinity but returned %v", nginxAffinity.Type)
+	}
+
+	if nginxAffinity.Header.Name != "X-Session-ID" {
+		t.Errorf("expected X-Session-ID as session-header-name but returned %v", nginxAffinity.Header.Name)
+	}
+
+	data[parser.GetAnnotationWithPrefix(annotationAffinityHeaderName)] = "X Session"
+	ing.SetAnnotations(data)
+
+	affin, _ = NewParser(&resolver.Mock{}).Parse(ing)
+	nginxAffinity = affin.(*Config)
+
+	if nginxAffinity.Type != "" {
+		t.Errorf("expected no affinity with an invalid header but returned %v", nginxAffinity.Type)
+	}
+}
+
+func TestIngressAffinityIPConfig(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix(annotationAffinityType)] = "ip"
+	ing.SetAnnotations(data)
+
+	affin, _ := NewParser(&resolver.Mock{}).Parse(ing)
+	nginxAffinity := affin.(*Config)
+
+	if nginxAffinity.IP.PrefixLength != 32 || nginxAffinity.IP.IPv6PrefixLength != 128 {
+		t.Errorf("expected the default prefix lengths but returned %v", nginxAffinity.IP)
+	}
+
+	data[parser.GetAnnotationWithPrefix(annotationAffinityIPPrefixLength)] = "24"
+	data[parser.GetAnnotationWithPrefix(annotationAffinityIPv6PrefixLength)] = "129"
+	ing.SetAnnotations(data)
+
+	affin, _ = NewParser(&resolver.Mock{}).Parse(ing)
+	nginxAffinity = affin.(*Config)
+
+	if nginxAffinity.IP.PrefixLength != 24 {
+		t.Errorf("expected 24 as session-ip-prefix-length but returned %v", nginxAffinity.IP.PrefixLength)
+	}
+
+	if nginxAffinity.IP.IPv6PrefixLength != 128 {
+		t.Errorf("expected the default session-ipv6-prefix-length with an invalid value but returned %v", nginxAffinity.IP.IPv6PrefixLength)
+	}
+}
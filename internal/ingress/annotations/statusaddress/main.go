@@ -0,0 +1,110 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusaddress
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	annotationAddress       = "status-address"
+	annotationAddressFormat = "status-address-format"
+)
+
+const (
+	// External publishes the addresses of the publish service or of the
+	// nodes in the status of the Ingress
+	External = "external"
+	// Internal publishes the addresses reachable from inside the cluster
+	Internal = "internal"
+)
+
+const (
+	// AnyFormat publishes the IP addresses and the hostnames
+	AnyFormat = "any"
+	// IPFormat prefers the IP addresses to the hostnames
+	IPFormat = "ip"
+	// HostnameFormat prefers the hostnames to the IP addresses
+	HostnameFormat = "hostname"
+)
+
+// Config contains the addresses published in the status of an Ingress
+type Config struct {
+	// Address is the kind of addresses published, external or internal
+	Address string `json:"address,omitempty"`
+	// Format selects the IP addresses or the hostnames when both exist
+	Format string `json:"format,omitempty"`
+}
+
+type statusAddress struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new status address annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return statusAddress{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// select the addresses published in the status of the Ingress
+func (a statusAddress) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := Config{
+		Address: External,
+		Format:  AnyFormat,
+	}
+
+	for _, setting := range []struct {
+		name   string
+		value  *string
+		values []string
+	}{
+		{annotationAddress, &config.Address, []string{External, Internal}},
+		{annotationAddressFormat, &config.Format, []string{AnyFormat, IPFormat, HostnameFormat}},
+	} {
+		v, err := parser.GetStringAnnotation(setting.name, ing)
+		if err != nil {
+			if ing_errors.IsMissingAnnotations(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		v = strings.ToLower(strings.TrimSpace(v))
+		if !contains(setting.values, v) {
+			return nil, ing_errors.NewInvalidAnnotationContent(setting.name, v)
+		}
+		*setting.value = v
+	}
+
+	return config, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusaddress
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress(annotations map[string]string) *networking.Ingress {
+	data := map[string]string{}
+	for k, v := range annotations {
+		data[parser.GetAnnotationWithPrefix(k)] = v
+	}
+
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   api.NamespaceDefault,
+			Annotations: data,
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    Config
+	}{
+		{
+			"without annotations",
+			map[string]string{},
+			Config{Address: External, Format: AnyFormat},
+		},
+		{
+			"internal addresses",
+			map[string]string{"status-address": "Internal"},
+			Config{Address: Internal, Format: AnyFormat},
+		},
+		{
+			"hostnames",
+			map[string]string{"status-address": "external", "status-address-format": "hostname"},
+			Config{Address: External, Format: HostnameFormat},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := NewParser(&resolver.Mock{}).Parse(buildIngress(test.annotations))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(config, test.expected) {
+				t.Errorf("expected %v but returned %v", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, annotations := range []map[string]string{
+		{"status-address": "public"},
+		{"status-address-format": "ipv6"},
+	} {
+		_, err := NewParser(&resolver.Mock{}).Parse(buildIngress(annotations))
+		if err == nil {
+			t.Errorf("expected an error parsing %v", annotations)
+		}
+	}
+}
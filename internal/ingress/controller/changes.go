@@ -0,0 +1,287 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+// Categories of the configuration changes requiring a reload
+const (
+	changeServerAdded     = "server-added"
+	changeServerRemoved   = "server-removed"
+	changeServerChanged   = "server-changed"
+	changeLocationAdded   = "location-added"
+	changeLocationRemoved = "location-removed"
+	changeLocationChanged = "location-changed"
+	changeStreams         = "streams-changed"
+	changePassthrough     = "passthrough-changed"
+	changeConfigMap       = "configmap-changed"
+	changeOther           = "other"
+)
+
+// ignoredFields contains the fields of servers and locations compared
+// separately or not rendered in the NGINX configuration
+var ignoredFields = map[string]bool{
+	"Locations": true,
+	"Ingress":   true,
+	"Service":   true,
+}
+
+// configChange describes a change of the configuration requiring a reload
+type configChange struct {
+	Category string
+	Server   string
+	Location string
+	// Ingress is the namespace/name of the Ingress of the change, if any
+	Ingress string
+	// Fields contains the names of the fields changed
+	Fields []string
+
+	ing *ingress.Ingress
+}
+
+func (c configChange) String() string {
+	s := fmt.Sprintf("category=%v", c.Category)
+	if c.Server != "" {
+		s += fmt.Sprintf(" server=%q", c.Server)
+	}
+	if c.Location != "" {
+		s += fmt.Sprintf(" location=%q", c.Location)
+	}
+	if c.Ingress != "" {
+		s += fmt.Sprintf(" ingress=%v", c.Ingress)
+	}
+	if len(c.Fields) > 0 {
+		s += fmt.Sprintf(" fields=%v", strings.Join(c.Fields, ","))
+	}
+
+	return s
+}
+
+// configurationChanges returns the changes between the running and the new
+// configuration. Both configurations must be cleared of the settings
+// updated without reloading NGINX.
+func configurationChanges(running, pcfg *ingress.Configuration) []configChange {
+	changes := []configChange{}
+
+	runningServers := make(map[string]*ingress.Server)
+	for _, server := range running.Servers {
+		runningServers[server.Hostname] = server
+	}
+
+	newServers := make(map[string]bool)
+	for _, server := range pcfg.Servers {
+		newServers[server.Hostname] = true
+
+		old, ok := runningServers[server.Hostname]
+		if !ok {
+			changes = append(changes, newServerChange(changeServerAdded, server, nil))
+			continue
+		}
+
+		if fields := changedFields(old, server); len(fields) > 0 {
+			changes = append(changes, newServerChange(changeServerChanged, server, fields))
+		}
+
+		changes = append(changes, locationChanges(server.Hostname, old.Locations, server.Locations)...)
+	}
+
+	for _, server := range running.Servers {
+		if !newServers[server.Hostname] {
+			changes = append(changes, newServerChange(changeServerRemoved, server, nil))
+		}
+	}
+
+	if !reflect.DeepEqual(running.TCPEndpoints, pcfg.TCPEndpoints) ||
+		!reflect.DeepEqual(running.UDPEndpoints, pcfg.UDPEndpoints) {
+		changes = append(changes, configChange{Category: changeStreams})
+	}
+
+	if !reflect.DeepEqual(running.PassthroughBackends, pcfg.PassthroughBackends) {
+		changes = append(changes, configChange{Category: changePassthrough})
+	}
+
+	if running.BackendConfigChecksum != pcfg.BackendConfigChecksum {
+		changes = append(changes, configChange{Category: changeConfigMap})
+	}
+
+	if len(changes) == 0 && !running.Equal(pcfg) {
+		changes = append(changes, configChange{Category: changeOther})
+	}
+
+	return changes
+}
+
+// locationChanges returns the changes between the running and the new
+// locations of a server, matched by path
+func locationChanges(hostname string, running, locations []*ingress.Location) []configChange {
+	changes := []configChange{}
+
+	runningLocations := make(map[string]*ingress.Location)
+	for _, location := range running {
+		runningLocations[location.Path] = location
+	}
+
+	newLocations := make(map[string]bool)
+	for _, location := range locations {
+		newLocations[location.Path] = true
+
+		old, ok := runningLocations[location.Path]
+		if !ok {
+			changes = append(changes, newLocationChange(changeLocationAdded, hostname, location, nil))
+			continue
+		}
+
+		if fields := changedFields(old, location); len(fields) > 0 {
+			changes = append(changes, newLocationChange(changeLocationChanged, hostname, location, fields))
+		}
+	}
+
+	for _, location := range running {
+		if !newLocations[location.Path] {
+			changes = append(changes, newLocationChange(changeLocationRemoved, hostname, location, nil))
+		}
+	}
+
+	return changes
+}
+
+func newServerChange(category string, server *ingress.Server, fields []string) configChange {
+	change := configChange{
+		Category: category,
+		Server:   server.Hostname,
+		Fields:   fields,
+	}
+
+	// server settings are defined by the Ingress of one of its locations
+	for _, location := range server.Locations {
+		if location.Ingress != nil {
+			change.ing = location.Ingress
+			change.Ingress = k8s.MetaNamespaceKey(location.Ingress)
+			break
+		}
+	}
+
+	return change
+}
+
+func newLocationChange(category, hostname string, location *ingress.Location, fields []string) configChange {
+	change := configChange{
+		Category: category,
+		Server:   hostname,
+		Location: location.Path,
+		Fields:   fields,
+	}
+
+	if location.Ingress != nil {
+		change.ing = location.Ingress
+		change.Ingress = k8s.MetaNamespaceKey(location.Ingress)
+	}
+
+	return change
+}
+
+// changedFields returns the JSON names of the fields of two structs of the
+// same type with different values. The Equal method of the fields is used
+// when it is available.
+func changedFields(a, b interface{}) []string {
+	va := reflect.Indirect(reflect.ValueOf(a))
+	vb := reflect.Indirect(reflect.ValueOf(b))
+
+	fields := []string{}
+	for i := 0; i < va.NumField(); i++ {
+		field := va.Type().Field(i)
+		if field.PkgPath != "" || ignoredFields[field.Name] {
+			continue
+		}
+
+		if !fieldEqual(va.Field(i), vb.Field(i)) {
+			fields = append(fields, fieldName(field))
+		}
+	}
+
+	return fields
+}
+
+func fieldEqual(a, b reflect.Value) bool {
+	pa := reflect.New(a.Type())
+	pa.Elem().Set(a)
+	pb := reflect.New(b.Type())
+	pb.Elem().Set(b)
+
+	equal := pa.MethodByName("Equal")
+	if equal.IsValid() && equal.Type().NumIn() == 1 && equal.Type().In(0) == pa.Type() &&
+		equal.Type().NumOut() == 1 && equal.Type().Out(0).Kind() == reflect.Bool {
+		return equal.Call([]reflect.Value{pb})[0].Bool()
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+
+	return name
+}
+
+// changeCategories returns the category of each change
+func changeCategories(changes []configChange) []string {
+	categories := make([]string, 0, len(changes))
+	for _, change := range changes {
+		categories = append(categories, change.Category)
+	}
+
+	return categories
+}
+
+// reportChanges logs the changes causing a reload, counts them and
+// creates an Event on the Ingress responsible of each change
+func (n *NGINXController) reportChanges(changes []configChange) {
+	n.metricCollector.IncReloadChanges(changeCategories(changes))
+
+	events := make(map[*ingress.Ingress][]string)
+	for _, change := range changes {
+		klog.Infof("Configuration change requiring a backend reload: %v", change)
+
+		if change.ing != nil {
+			events[change.ing] = append(events[change.ing], change.String())
+		}
+	}
+
+	if n.recorder == nil {
+		return
+	}
+
+	for ing, messages := range events {
+		sort.Strings(messages)
+		n.recorder.Eventf(&ing.Ingress, apiv1.EventTypeNormal, "RELOAD",
+			"Configuration changes requiring a backend reload: %v", strings.Join(messages, "; "))
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+)
+
+func TestConfigurationChanges(t *testing.T) {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		},
+	}
+
+	running := &ingress.Configuration{
+		BackendConfigChecksum: "1",
+		Servers: []*ingress.Server{
+			{
+				Hostname: "a.example.com",
+				Locations: []*ingress.Location{
+					{Path: "/", Backend: "app-80", Ingress: ing},
+					{Path: "/old", Backend: "app-80", Ingress: ing},
+				},
+			},
+			{
+				Hostname:  "removed.example.com",
+				Locations: []*ingress.Location{{Path: "/", Ingress: ing}},
+			},
+		},
+	}
+
+	pcfg := &ingress.Configuration{
+		BackendConfigChecksum: "2",
+		Servers: []*ingress.Server{
+			{
+				Hostname:      "a.example.com",
+				ServerSnippet: "return 200;",
+				Locations: []*ingress.Location{
+					{Path: "/", Backend: "app-80", Ingress: ing, Rewrite: rewrite.Config{Target: "/app"}},
+					{Path: "/new", Backend: "app-80", Ingress: ing},
+				},
+			},
+			{
+				Hostname:  "added.example.com",
+				Locations: []*ingress.Location{{Path: "/"}},
+			},
+		},
+	}
+
+	expected := []configChange{
+		{Category: changeServerChanged, Server: "a.example.com", Ingress: "default/app", Fields: []string{"serverSnippet"}},
+		{Category: changeLocationChanged, Server: "a.example.com", Location: "/", Ingress: "default/app", Fields: []string{"rewrite"}},
+		{Category: changeLocationAdded, Server: "a.example.com", Location: "/new", Ingress: "default/app"},
+		{Category: changeLocationRemoved, Server: "a.example.com", Location: "/old", Ingress: "default/app"},
+		{Category: changeServerAdded, Server: "added.example.com"},
+		{Category: changeServerRemoved, Server: "removed.example.com", Ingress: "default/app"},
+		{Category: changeConfigMap},
+	}
+
+	changes := configurationChanges(running, pcfg)
+	for i := range changes {
+		changes[i].ing = nil
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes\n%v\nbut returned\n%v", expected, changes)
+	}
+
+	if len(configurationChanges(running, running)) != 0 {
+		t.Errorf("expected no changes between the same configurations")
+	}
+}
+
+func TestConfigChangeString(t *testing.T) {
+	change := configChange{
+		Category: changeLocationChanged,
+		Server:   "a.example.com",
+		Location: "/",
+		Ingress:  "default/app",
+		Fields:   []string{"rewrite", "whitelist"},
+	}
+
+	expected := `category=location-changed server="a.example.com" location="/" ingress=default/app fields=rewrite,whitelist`
+	if change.String() != expected {
+		t.Errorf("expected %v but returned %v", expected, change.String())
+	}
+}
@@ -36,6 +36,10 @@ func (n NGINXController) Name() string {
 
 // Check returns if the nginx healthz endpoint is returning ok (status code 200)
 func (n *NGINXController) Check(_ *http.Request) error {
+	if n.isTerminating {
+		return fmt.Errorf("ingress controller is shutting down")
+	}
+
 	statusCode, _, err := nginx.NewGetStatusRequest(nginx.HealthPath)
 	if err != nil {
 		klog.Errorf("healthcheck error: %v", err)
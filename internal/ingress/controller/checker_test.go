@@ -104,6 +104,15 @@ func TestNginxCheck(t *testing.T) {
 		}
 	})
 
+	t.Run("shutting down", func(t *testing.T) {
+		n.isTerminating = true
+		defer func() { n.isTerminating = false }()
+
+		if err := callHealthz(true, mux); err == nil {
+			t.Error("expected an error but none returned")
+		}
+	})
+
 	// pollute pid file
 	pidFile.Write([]byte(fmt.Sprint("999999")))
 	pidFile.Close()
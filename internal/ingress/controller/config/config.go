@@ -562,6 +562,46 @@ type Configuration struct {
 	// Default: 503
 	LimitConnStatusCode int `json:"limit-conn-status-code"`
 
+	// GlobalRateLimitStore is the store used to keep the counters of the
+	// global rate limits. Supported values are memcached, redis and local.
+	// local keeps the counters in every NGINX instance and is only useful
+	// for testing or with a single replica
+	// Default: memcached
+	GlobalRateLimitStore string `json:"global-rate-limit-store"`
+
+	// GlobalRateLimitHost is the address of the store
+	GlobalRateLimitHost string `json:"global-rate-limit-host"`
+
+	// GlobalRateLimitPort is the port of the store
+	// Default: 11211 for memcached, 6379 for redis
+	GlobalRateLimitPort int `json:"global-rate-limit-port"`
+
+	// GlobalRateLimitConnectTimeout is the timeout in milliseconds to
+	// connect, send and read from the store
+	// Default: 50
+	GlobalRateLimitConnectTimeout int `json:"global-rate-limit-connect-timeout"`
+
+	// GlobalRateLimitMaxIdleTimeout is the time in milliseconds an idle
+	// connection to the store is kept in the pool
+	// Default: 10000
+	GlobalRateLimitMaxIdleTimeout int `json:"global-rate-limit-max-idle-timeout"`
+
+	// GlobalRateLimitPoolSize is the number of connections to the store
+	// kept in the pool of each NGINX worker
+	// Default: 50
+	GlobalRateLimitPoolSize int `json:"global-rate-limit-pool-size"`
+
+	// GlobalRateLimitStatusCode is the status code returned when a request
+	// exceeds a global rate limit
+	// Default: 429
+	GlobalRateLimitStatusCode int `json:"global-rate-limit-status-code"`
+
+	// GlobalRateLimitFallback defines what happens with a request when the
+	// store is unreachable. Supported values are allow, deny and local.
+	// local counts the request in the NGINX instance that received it
+	// Default: allow
+	GlobalRateLimitFallback string `json:"global-rate-limit-fallback"`
+
 	// EnableSyslog enables the configuration for remote logging in NGINX
 	EnableSyslog bool `json:"enable-syslog"`
 	// SyslogHost FQDN or IP address where the logs should be sent
@@ -620,7 +660,8 @@ func NewDefault() Configuration {
 	defNginxStatusIpv4Whitelist = append(defNginxStatusIpv4Whitelist, "127.0.0.1")
 	defNginxStatusIpv6Whitelist = append(defNginxStatusIpv6Whitelist, "::1")
 	defProxyDeadlineDuration := time.Duration(5) * time.Second
-	defGlobalExternalAuth := GlobalExternalAuth{"", "", "", "", append(defResponseHeaders, ""), "", "", "", []string{}}
+	defGlobalExternalAuth := GlobalExternalAuth{"", "", "", "", append(defResponseHeaders, ""), "", "", "", []string{},
+		"", 0, 0, "closed", 0, 30}
 
 	cfg := Configuration{
 		AllowBackendServerHeader:         false,
@@ -742,6 +783,13 @@ func NewDefault() Configuration {
 		NoTLSRedirectLocations:       "/.well-known/acme-challenge",
 		NoAuthLocations:              "/.well-known/acme-challenge",
 		GlobalExternalAuth:           defGlobalExternalAuth,
+
+		GlobalRateLimitStore:          "memcached",
+		GlobalRateLimitConnectTimeout: 50,
+		GlobalRateLimitMaxIdleTimeout: 10000,
+		GlobalRateLimitPoolSize:       50,
+		GlobalRateLimitStatusCode:     429,
+		GlobalRateLimitFallback:       "allow",
 	}
 
 	if klog.V(5) {
@@ -783,6 +831,9 @@ type TemplateConfig struct {
 	PublishService            *apiv1.Service
 	EnableDynamicCertificates bool
 	EnableMetrics             bool
+	// ServerIncludes contains the files of the servers rendered separately,
+	// indexed by hostname
+	ServerIncludes map[string]string
 
 	PID          string
 	StatusSocket string
@@ -813,4 +864,11 @@ type GlobalExternalAuth struct {
 	AuthSnippet       string   `json:"authSnippet"`
 	AuthCacheKey      string   `json:"authCacheKey"`
 	AuthCacheDuration []string `json:"authCacheDuration"`
+
+	AuthCacheNegativeDuration string `json:"authCacheNegativeDuration,omitempty"`
+	ConnectTimeout            int    `json:"connectTimeout,omitempty"`
+	ReadTimeout               int    `json:"readTimeout,omitempty"`
+	FailureMode               string `json:"failureMode,omitempty"`
+	CircuitBreakerFailures    int    `json:"circuitBreakerFailures,omitempty"`
+	CircuitBreakerDuration    int    `json:"circuitBreakerDuration,omitempty"`
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"crypto/sha1"
 	"fmt"
 	"sort"
 	"strconv"
@@ -26,6 +27,7 @@ import (
 	"github.com/mitchellh/hashstructure"
 	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -35,8 +37,11 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sessionaffinity"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
 	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/net/ssl"
 	"k8s.io/klog"
 )
 
@@ -57,7 +62,14 @@ type Configuration struct {
 	ConfigMapName  string
 	DefaultService string
 
-	Namespace string
+	// Namespaces contains the namespaces watched, all of them when empty,
+	// and NamespaceSelector selects the namespaces watched by their labels
+	Namespaces        []string
+	NamespaceSelector labels.Selector
+
+	// Shard selects the hosts served when several controllers share the
+	// same ingress class
+	Shard store.Shard
 
 	// +optional
 	TCPConfigMapName string
@@ -70,24 +82,48 @@ type Configuration struct {
 	PublishService       string
 	PublishStatusAddress string
 
+	// PublishInternalService and PublishInternalStatusAddress are the
+	// sources of the status of the Ingresses requesting internal addresses
+	// +optional
+	PublishInternalService       string
+	PublishInternalStatusAddress string
+
+	// StatusUpdateRateLimit is the maximum number of status updates per second
+	StatusUpdateRateLimit float32
+
 	UpdateStatus           bool
 	UseNodeInternalIP      bool
 	ElectionID             string
 	UpdateStatusOnShutdown bool
 
+	ShutdownDelay   time.Duration
+	ShutdownTimeout time.Duration
+
 	ListenPorts *ngx_config.ListenPorts
 
 	EnableSSLPassthrough bool
 
 	EnableProfiling bool
 
-	EnableMetrics  bool
-	MetricsPerHost bool
+	EnableMetrics              bool
+	MetricsPerHost             bool
+	MetricsPerUpstreamEndpoint bool
 
 	FakeCertificate *ingress.SSLCert
 
 	SyncRateLimit float32
 
+	// ReloadQuietPeriod is the time without changes to wait before
+	// reloading NGINX and ReloadMaxDelay the maximum delay of a reload
+	ReloadQuietPeriod time.Duration
+	ReloadMaxDelay    time.Duration
+
+	// EnableServerFragments renders the server of each host in its own
+	// file, included by nginx.conf
+	EnableServerFragments bool
+
+	ConfigHistorySize int
+
 	DisableCatchAll bool
 
 	ValidationWebhook         string
@@ -117,20 +153,56 @@ func (n *NGINXController) syncIngress(interface{}) error {
 		return nil
 	}
 
+	n.syncLock.Lock()
+	defer n.syncLock.Unlock()
+
+	if rev := n.history.Pinned(); rev != nil {
+		return n.syncPinnedRevision(rev)
+	}
+	n.pinnedRevision = 0
+
 	ings := n.store.ListIngresses(nil)
 	hosts, servers, pcfg := n.getConfiguration(ings)
 
 	n.metricCollector.SetSSLExpireTime(servers)
 
-	if n.runningConfig.Equal(pcfg) {
+	if n.runningConfig.Equal(pcfg) && !n.forceReload {
+		if n.reloadScheduler.Pending() {
+			// the changes of the delayed reload were reverted but their
+			// dynamic part is already applied
+			klog.Infof("Configuration changes reverted, cancelling the delayed backend reload.")
+			n.reloadScheduler.Cancel()
+			return n.configureDynamicallyWithRetry(pcfg)
+		}
+
 		klog.V(3).Infof("No configuration change detected, skipping backend reload.")
 		return nil
 	}
 
 	n.metricCollector.SetHosts(hosts)
 
-	if !n.IsDynamicConfigurationEnough(pcfg) {
+	isFirstSync := n.runningConfig.Equal(&ingress.Configuration{})
+
+	reload := n.forceReload || !n.IsDynamicConfigurationEnough(pcfg)
+	if !reload {
+		n.reloadScheduler.Skip()
+	}
+
+	if reload && !isFirstSync && !n.forceReload {
+		if delay := n.reloadScheduler.Schedule(); delay > 0 {
+			klog.Infof("Configuration changes detected, backend reload delayed %v.", delay)
+			n.scheduleSync(delay)
+
+			// changes that do not require a reload are not delayed
+			return n.configureDynamicallyWithRetry(pcfg)
+		}
+	}
+
+	if reload {
 		klog.Infof("Configuration changes detected, backend reload required.")
+		if !isFirstSync {
+			n.reportChanges(configurationChanges(clearDynamicConfiguration(n.runningConfig), clearDynamicConfiguration(pcfg)))
+		}
 
 		hash, _ := hashstructure.Hash(pcfg, &hashstructure.HashOptions{
 			TagName: "json",
@@ -151,7 +223,6 @@ func (n *NGINXController) syncIngress(interface{}) error {
 		n.metricCollector.IncReloadCount()
 	}
 
-	isFirstSync := n.runningConfig.Equal(&ingress.Configuration{})
 	if isFirstSync {
 		// For the initial sync it always takes some time for NGINX to start listening
 		// For large configurations it might take a while so we loop and back off
@@ -159,6 +230,27 @@ func (n *NGINXController) syncIngress(interface{}) error {
 		time.Sleep(1 * time.Second)
 	}
 
+	err := n.configureDynamicallyWithRetry(pcfg)
+	if err != nil {
+		return err
+	}
+
+	ri := getRemovedIngresses(n.runningConfig, pcfg)
+	re := getRemovedHosts(n.runningConfig, pcfg)
+	n.metricCollector.RemoveMetrics(ri, re)
+
+	n.recordRevision(pcfg, reload)
+
+	n.runningConfig = pcfg
+	n.forceReload = false
+	n.retainJWTKeySets(pcfg)
+
+	return nil
+}
+
+// configureDynamicallyWithRetry sends the configuration to the Lua code,
+// backing off while NGINX is not ready to receive it
+func (n *NGINXController) configureDynamicallyWithRetry(pcfg *ingress.Configuration) error {
 	retry := wait.Backoff{
 		Steps:    15,
 		Duration: 1 * time.Second,
@@ -181,12 +273,6 @@ func (n *NGINXController) syncIngress(interface{}) error {
 		return err
 	}
 
-	ri := getRemovedIngresses(n.runningConfig, pcfg)
-	re := getRemovedHosts(n.runningConfig, pcfg)
-	n.metricCollector.RemoveMetrics(ri, re)
-
-	n.runningConfig = pcfg
-
 	return nil
 }
 
@@ -208,8 +294,8 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 		return nil
 	}
 
-	if n.cfg.Namespace != "" && ing.ObjectMeta.Namespace != n.cfg.Namespace {
-		klog.Infof("ignoring ingress %v in namespace %v different from the namespace watched %s", ing.Name, ing.ObjectMeta.Namespace, n.cfg.Namespace)
+	if !n.store.IsWatchedNamespace(ing.ObjectMeta.Namespace) {
+		klog.Infof("ignoring ingress %v in namespace %v not watched by the controller", ing.Name, ing.ObjectMeta.Namespace)
 		return nil
 	}
 
@@ -218,18 +304,26 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 			toCheck.ObjectMeta.Name == ing.ObjectMeta.Name
 	}
 
-	ings := n.store.ListIngresses(filter)
-	ings = append(ings, &ingress.Ingress{
+	toCheck := n.cfg.Shard.Filter(&ingress.Ingress{
 		Ingress:           *ing,
 		ParsedAnnotations: annotations.NewAnnotationExtractor(n.store).Extract(ing),
 	})
+	if toCheck == nil {
+		klog.Infof("ignoring ingress %v in %v with hosts of other shards", ing.Name, ing.ObjectMeta.Namespace)
+		return nil
+	}
+
+	ings := n.store.ListIngresses(filter)
+	ings = append(ings, toCheck)
 
 	_, _, pcfg := n.getConfiguration(ings)
 
 	cfg := n.store.GetBackendConfiguration()
 	cfg.Resolver = n.resolver
 
-	content, err := n.generateTemplate(cfg, *pcfg)
+	// the servers are rendered inline to not replace the fragments of the
+	// running configuration with the ones of a configuration being validated
+	content, err := n.generateInlineTemplate(cfg, *pcfg)
 	if err != nil {
 		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
 		return err
@@ -245,6 +339,41 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 	return err
 }
 
+// DesiredConfiguration returns the configuration the controller would send
+// to NGINX for the current state of the cluster, regardless of the running
+// configuration
+func (n *NGINXController) DesiredConfiguration() (*ingress.DynamicConfiguration, error) {
+	n.syncLock.Lock()
+	defer n.syncLock.Unlock()
+
+	_, _, pcfg := n.getConfiguration(n.store.ListIngresses(nil))
+
+	cfg := n.store.GetBackendConfiguration()
+	cfg.Resolver = n.resolver
+
+	content, err := n.generateTemplate(cfg, *pcfg)
+	if err != nil {
+		return nil, err
+	}
+
+	certificates := make(map[string]string)
+	if ngx_config.EnableDynamicCertificates {
+		for _, server := range certificateServers(pcfg) {
+			certificates[server.Hostname] = fmt.Sprintf("%x", sha1.Sum([]byte(server.SSLCert.PemCertKey)))
+		}
+	}
+
+	return &ingress.DynamicConfiguration{
+		Backends: luaBackends(pcfg),
+		Streams:  streamBackends(pcfg),
+		General: ingress.GeneralConfig{
+			ControllerPodsCount: pcfg.ControllerPodsCount,
+		},
+		Certificates: certificates,
+		NginxConf:    string(content),
+	}, nil
+}
+
 func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Protocol) []ingress.L4Service {
 	if configmapName == "" {
 		return []ingress.L4Service{}
@@ -424,6 +553,8 @@ func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.S
 		}
 	}
 
+	oidcProviders := n.getOIDCProviders(servers)
+
 	return hosts, servers, &ingress.Configuration{
 		Backends:              upstreams,
 		Servers:               servers,
@@ -432,6 +563,9 @@ func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.S
 		PassthroughBackends:   passUpstreams,
 		BackendConfigChecksum: n.store.GetBackendConfiguration().Checksum,
 		ControllerPodsCount:   n.store.GetRunningControllerPodsCount(),
+		JWTKeySets:            n.getJWTKeySets(servers, oidcProviders),
+		OIDCProviders:         oidcProviders,
+		OIDCClients:           n.getOIDCClients(servers),
 	}
 }
 
@@ -552,9 +686,18 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 
 				if ups.SessionAffinity.AffinityType == "" {
 					ups.SessionAffinity.AffinityType = anns.SessionAffinity.Type
+					ups.SessionAffinity.AffinityMode = anns.SessionAffinity.Mode
 				}
 
-				if anns.SessionAffinity.Type == "cookie" {
+				switch anns.SessionAffinity.Type {
+				case sessionaffinity.AffinityTypeHeader:
+					ups.SessionAffinity.HeaderSessionAffinity.Name = anns.SessionAffinity.Header.Name
+				case sessionaffinity.AffinityTypeIP:
+					ups.SessionAffinity.IPSessionAffinity.PrefixLength = anns.SessionAffinity.IP.PrefixLength
+					ups.SessionAffinity.IPSessionAffinity.IPv6PrefixLength = anns.SessionAffinity.IP.IPv6PrefixLength
+				}
+
+				if anns.SessionAffinity.Type == sessionaffinity.AffinityTypeCookie {
 					cookiePath := anns.SessionAffinity.Cookie.Path
 					if anns.Rewrite.UseRegex && cookiePath == "" {
 						klog.Warningf("session-cookie-path should be set when use-regex is true")
@@ -687,6 +830,10 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 				upstreams[defBackend].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
 			}
 
+			upstreams[defBackend].HealthCheck = anns.HealthCheck
+			upstreams[defBackend].OutlierDetection = anns.OutlierDetection
+			upstreams[defBackend].CircuitBreaker = anns.CircuitBreaker
+
 			svcKey := fmt.Sprintf("%v/%v", ing.Namespace, ing.Spec.Backend.ServiceName)
 
 			// add the service ClusterIP as a single Endpoint instead of individual Endpoints
@@ -752,6 +899,10 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 					upstreams[name].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
 				}
 
+				upstreams[name].HealthCheck = anns.HealthCheck
+				upstreams[name].OutlierDetection = anns.OutlierDetection
+				upstreams[name].CircuitBreaker = anns.CircuitBreaker
+
 				svcKey := fmt.Sprintf("%v/%v", ing.Namespace, path.Backend.ServiceName)
 
 				// add the service ClusterIP as a single Endpoint instead of individual Endpoints
@@ -1110,7 +1261,7 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				klog.Warning("Validating certificate against DNS names. This will be deprecated in a future version.")
 				// check the Common Name field
 				// https://github.com/golang/go/issues/22922
-				err := verifyHostname(host, cert.Certificate)
+				err := ssl.VerifyHostname(host, cert.Certificate)
 				if err != nil {
 					klog.Warningf("SSL certificate %q does not contain a Common Name or Subject Alternative Name for server %q: %v",
 						secrKey, host, err)
@@ -1149,9 +1300,12 @@ func locationApplyAnnotations(loc *ingress.Location, anns *annotations.Ingress)
 	loc.CorsConfig = anns.CorsConfig
 	loc.ExternalAuth = anns.ExternalAuth
 	loc.EnableGlobalAuth = anns.EnableGlobalAuth
+	loc.JWTAuth = anns.JWTAuth
+	loc.OIDCAuth = anns.OIDCAuth
 	loc.HTTP2PushPreload = anns.HTTP2PushPreload
 	loc.Proxy = anns.Proxy
 	loc.RateLimit = anns.RateLimit
+	loc.GlobalRateLimit = anns.GlobalRateLimit
 	loc.Redirect = anns.Redirect
 	loc.Rewrite = anns.Rewrite
 	loc.UpstreamVhost = anns.UpstreamVhost
@@ -1170,6 +1324,8 @@ func locationApplyAnnotations(loc *ingress.Location, anns *annotations.Ingress)
 	loc.ModSecurity = anns.ModSecurity
 	loc.Satisfy = anns.Satisfy
 	loc.Mirror = anns.Mirror
+	loc.Headers = anns.Headers
+	loc.RetryPolicy = anns.RetryPolicy
 }
 
 // OK to merge canary ingresses iff there exists one or more ingresses to potentially merge into
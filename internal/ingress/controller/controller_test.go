@@ -53,6 +53,8 @@ import (
 
 type fakeIngressStore struct {
 	ingresses []*ingress.Ingress
+	// namespace is the namespace watched, all of them when empty
+	namespace string
 }
 
 func (fakeIngressStore) GetBackendConfiguration() ngx_config.Configuration {
@@ -99,6 +101,10 @@ func (fakeIngressStore) GetDefaultBackend() defaults.Backend {
 	return defaults.Backend{}
 }
 
+func (fis fakeIngressStore) IsWatchedNamespace(namespace string) bool {
+	return fis.namespace == "" || fis.namespace == namespace
+}
+
 func (fakeIngressStore) Run(stopCh chan struct{}) {}
 
 type testNginxTestCommand struct {
@@ -131,6 +137,10 @@ func (ntc testNginxTestCommand) Test(cfg string) ([]byte, error) {
 
 type fakeTemplate struct{}
 
+func (f fakeTemplate) WriteInline(conf config.TemplateConfig) ([]byte, error) {
+	return f.Write(conf)
+}
+
 func (fakeTemplate) Write(conf config.TemplateConfig) ([]byte, error) {
 	r := []byte{}
 	for _, s := range conf.Servers {
@@ -241,7 +251,10 @@ func TestCheckIngress(t *testing.T) {
 				t:   t,
 				err: fmt.Errorf("test error"),
 			}
-			nginx.cfg.Namespace = "other-namespace"
+			nginx.store = fakeIngressStore{
+				ingresses: []*ingress.Ingress{},
+				namespace: "other-namespace",
+			}
 			ing.ObjectMeta.Namespace = "test-namespace"
 			if nginx.CheckIngress(ing) != nil {
 				t.Errorf("with a new ingress without error, no error should be returned")
@@ -1115,7 +1128,8 @@ func newNGINXController(t *testing.T) *NGINXController {
 	}
 
 	storer := store.New(
-		ns,
+		[]string{ns},
+		nil,
 		fmt.Sprintf("%v/config", ns),
 		fmt.Sprintf("%v/tcp", ns),
 		fmt.Sprintf("%v/udp", ns),
@@ -1125,7 +1139,8 @@ func newNGINXController(t *testing.T) *NGINXController {
 		fs,
 		channels.NewRingChannel(10),
 		pod,
-		false)
+		false,
+		store.Shard{})
 
 	sslCert := ssl.GetFakeSSLCert(fs)
 	config := &Configuration{
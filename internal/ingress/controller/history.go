@@ -0,0 +1,191 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/mitchellh/hashstructure"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/controller/history"
+	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
+	"k8s.io/ingress-nginx/internal/task"
+)
+
+// triggerKey returns the kind and the namespace/name of an object
+// for the configuration history
+func triggerKey(obj interface{}) string {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return fmt.Sprintf("%T", obj)
+	}
+
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return fmt.Sprintf("%v %v", t.Name(), key)
+}
+
+// trigger records the key of an object that requires a new synchronization
+// for the configuration history and the reasons of the reloads
+func (n *NGINXController) trigger(key string) {
+	n.history.Trigger(key)
+	n.reloadScheduler.Change(key)
+}
+
+// recordRevision adds the configuration applied to NGINX to the history
+func (n *NGINXController) recordRevision(pcfg *ingress.Configuration, reload bool) {
+	if !n.history.Enabled() {
+		return
+	}
+
+	conf, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		klog.Warningf("Error reading %v for the configuration history: %v", cfgPath, err)
+	}
+
+	if n.cfg.EnableServerFragments {
+		// the files of the servers are removed after the next reloads
+		conf, err = ngx_template.ExpandServerFragments(conf, file.ServersDirectory)
+		if err != nil {
+			klog.Warningf("Error reading the files of the servers for the configuration history: %v", err)
+		}
+	}
+
+	backends, err := json.Marshal(luaBackends(pcfg))
+	if err != nil {
+		klog.Warningf("Error encoding backends for the configuration history: %v", err)
+	}
+
+	streams, err := json.Marshal(streamBackends(pcfg))
+	if err != nil {
+		klog.Warningf("Error encoding streams for the configuration history: %v", err)
+	}
+
+	// the checksum is only calculated when NGINX is reloaded
+	checksum := pcfg.ConfigurationChecksum
+	if checksum == "" {
+		hash, _ := hashstructure.Hash(pcfg, &hashstructure.HashOptions{
+			TagName: "json",
+		})
+		checksum = fmt.Sprintf("%v", hash)
+	}
+
+	n.history.Add(&history.Revision{
+		Checksum:  checksum,
+		Reload:    reload,
+		NginxConf: string(conf),
+		Backends:  backends,
+		Streams:   streams,
+
+		Configuration: pcfg,
+	})
+}
+
+// syncPinnedRevision applies the pinned revision to NGINX once, ignoring
+// any change in the cluster until the revision is unpinned
+func (n *NGINXController) syncPinnedRevision(rev *history.Revision) error {
+	if n.pinnedRevision == rev.ID {
+		klog.Warningf("Configuration pinned to revision %v, ignoring changes.", rev.ID)
+		return nil
+	}
+
+	klog.Warningf("Applying pinned configuration revision %v.", rev.ID)
+
+	content := []byte(rev.NginxConf)
+	err := n.testTemplate(content)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(cfgPath, content, file.ReadWriteByUser)
+	if err != nil {
+		return err
+	}
+
+	o, err := n.command.ExecCommand("-s", "reload").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v\n%v", err, string(o))
+	}
+
+	// the backends, certificates and keys of the revision, to not mix
+	// them with the ones of the running configuration
+	err = n.configureDynamicallyWithRetry(rev.Configuration)
+	if err != nil {
+		return err
+	}
+
+	n.pinnedRevision = rev.ID
+	// NGINX is not running the configuration of the cluster anymore
+	n.forceReload = true
+
+	return nil
+}
+
+// Revisions returns the last configurations applied to NGINX
+func (n *NGINXController) Revisions() history.Summary {
+	return n.history.List()
+}
+
+// Revision returns a configuration applied to NGINX
+func (n *NGINXController) Revision(id int) (*history.Revision, error) {
+	return n.history.Get(id)
+}
+
+// PinRevision applies a previous configuration to NGINX and ignores any
+// change in the cluster until UnpinRevision is called
+func (n *NGINXController) PinRevision(id int) error {
+	err := n.history.Pin(id)
+	if err != nil {
+		return err
+	}
+
+	n.syncQueue.EnqueueTask(task.GetDummyObject("pin-revision"))
+	return nil
+}
+
+// PinRevisionChecksum applies the last configuration with the given checksum
+// like PinRevision. The pin is kept in memory and lost when the pod restarts.
+func (n *NGINXController) PinRevisionChecksum(checksum string) error {
+	_, err := n.history.PinChecksum(checksum)
+	if err != nil {
+		return err
+	}
+
+	n.syncQueue.EnqueueTask(task.GetDummyObject("pin-revision"))
+	return nil
+}
+
+// UnpinRevision allows NGINX to apply the current configuration again
+func (n *NGINXController) UnpinRevision() {
+	n.history.Unpin()
+	n.trigger("unpin-revision")
+	n.syncQueue.EnqueueTask(task.GetDummyObject("unpin-revision"))
+}
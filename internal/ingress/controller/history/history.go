@@ -0,0 +1,222 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// Revision is a configuration applied to NGINX
+type Revision struct {
+	ID        int       `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Checksum  string    `json:"checksum"`
+	// Reload is true when NGINX was reloaded to apply the revision
+	Reload bool `json:"reload"`
+	// Triggers contains the keys of the objects that caused the synchronization
+	Triggers []string `json:"triggers"`
+
+	// NginxConf contains the nginx.conf file used by the revision
+	NginxConf string `json:"nginxConf,omitempty"`
+	// Backends and Streams contain the payloads sent to the lua code
+	Backends json.RawMessage `json:"backends,omitempty"`
+	Streams  json.RawMessage `json:"streams,omitempty"`
+
+	// Configuration is the configuration applied to NGINX, replayed
+	// when the revision is pinned
+	Configuration *ingress.Configuration `json:"-"`
+}
+
+// summary returns a copy of the revision without the configuration
+func (r *Revision) summary() Revision {
+	return Revision{
+		ID:        r.ID,
+		Timestamp: r.Timestamp,
+		Checksum:  r.Checksum,
+		Reload:    r.Reload,
+		Triggers:  r.Triggers,
+	}
+}
+
+// Summary contains the revisions without their configuration
+type Summary struct {
+	// Pinned is the ID of the pinned revision, if any
+	Pinned    int        `json:"pinned,omitempty"`
+	Revisions []Revision `json:"revisions"`
+}
+
+// Ring keeps the last revisions applied to NGINX. It is safe for concurrent use.
+type Ring struct {
+	mu sync.Mutex
+
+	size      int
+	lastID    int
+	revisions []*Revision
+
+	// triggers contains the keys received since the last revision
+	triggers map[string]bool
+
+	// pinned is the revision NGINX must keep running, if any
+	pinned *Revision
+}
+
+// NewRing creates a ring keeping at most size revisions.
+// A size of zero disables the history.
+func NewRing(size int) *Ring {
+	return &Ring{
+		size:      size,
+		revisions: make([]*Revision, 0, size),
+		triggers:  make(map[string]bool),
+	}
+}
+
+// Enabled returns true if the ring keeps revisions
+func (r *Ring) Enabled() bool {
+	return r != nil && r.size > 0
+}
+
+// Trigger records the key of an object that requires a new revision
+func (r *Ring) Trigger(key string) {
+	if !r.Enabled() {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.triggers[key] = true
+}
+
+// Add stores a new revision, assigning its ID and the triggers received
+// since the previous one. The oldest revision is removed if the ring is full.
+func (r *Ring) Add(rev *Revision) {
+	if !r.Enabled() {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	rev.ID = r.lastID
+	rev.Timestamp = time.Now()
+
+	rev.Triggers = make([]string, 0, len(r.triggers))
+	for key := range r.triggers {
+		rev.Triggers = append(rev.Triggers, key)
+	}
+	sort.Strings(rev.Triggers)
+	r.triggers = make(map[string]bool)
+
+	if len(r.revisions) == r.size {
+		r.revisions = r.revisions[1:]
+	}
+	r.revisions = append(r.revisions, rev)
+}
+
+// List returns the revisions, oldest first, without their configuration
+func (r *Ring) List() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := Summary{
+		Revisions: make([]Revision, 0, len(r.revisions)),
+	}
+	for _, rev := range r.revisions {
+		out.Revisions = append(out.Revisions, rev.summary())
+	}
+	if r.pinned != nil {
+		out.Pinned = r.pinned.ID
+	}
+
+	return out
+}
+
+// Get returns the revision with the given ID
+func (r *Ring) Get(id int) (*Revision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.get(id)
+}
+
+func (r *Ring) get(id int) (*Revision, error) {
+	for _, rev := range r.revisions {
+		if rev.ID == id {
+			return rev, nil
+		}
+	}
+
+	return nil, fmt.Errorf("revision %v not found", id)
+}
+
+// Pin requests NGINX to keep running the given revision, ignoring any
+// change until Unpin is called
+func (r *Ring) Pin(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rev, err := r.get(id)
+	if err != nil {
+		return err
+	}
+
+	r.pinned = rev
+	return nil
+}
+
+// PinChecksum pins the last revision with the given checksum and returns
+// its ID. The checksum identifies the same configuration in every pod.
+func (r *Ring) PinChecksum(checksum string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := len(r.revisions) - 1; i >= 0; i-- {
+		if r.revisions[i].Checksum == checksum {
+			r.pinned = r.revisions[i]
+			return r.pinned.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("revision with checksum %v not found", checksum)
+}
+
+// Unpin allows NGINX to apply new revisions
+func (r *Ring) Unpin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pinned = nil
+}
+
+// Pinned returns the pinned revision or nil
+func (r *Ring) Pinned() *Revision {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.pinned
+}
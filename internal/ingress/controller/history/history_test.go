@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing(2)
+
+	r.Trigger("Ingress default/a")
+	r.Trigger("Secret default/tls")
+	r.Trigger("Ingress default/a")
+	r.Add(&Revision{Checksum: "1", NginxConf: "one"})
+	r.Add(&Revision{Checksum: "2", NginxConf: "two"})
+	r.Trigger("Endpoints default/svc")
+	r.Add(&Revision{Checksum: "3", NginxConf: "three"})
+
+	list := r.List().Revisions
+	if len(list) != 2 {
+		t.Fatalf("expected 2 revisions but returned %v", len(list))
+	}
+
+	if list[0].ID != 2 || list[1].ID != 3 {
+		t.Errorf("expected revisions 2 and 3 but returned %v and %v", list[0].ID, list[1].ID)
+	}
+
+	if list[1].NginxConf != "" {
+		t.Errorf("expected revisions without configuration")
+	}
+
+	if !reflect.DeepEqual(list[1].Triggers, []string{"Endpoints default/svc"}) {
+		t.Errorf("unexpected triggers %v", list[1].Triggers)
+	}
+
+	if len(list[0].Triggers) != 0 {
+		t.Errorf("expected no triggers but returned %v", list[0].Triggers)
+	}
+
+	if _, err := r.Get(1); err == nil {
+		t.Errorf("expected revision 1 to be removed")
+	}
+
+	rev, err := r.Get(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rev.NginxConf != "three" {
+		t.Errorf("expected the configuration of revision 3 but returned %v", rev.NginxConf)
+	}
+}
+
+func TestRingPin(t *testing.T) {
+	r := NewRing(2)
+	r.Add(&Revision{Checksum: "1"})
+
+	if err := r.Pin(5); err == nil {
+		t.Errorf("expected an error pinning an unknown revision")
+	}
+
+	if err := r.Pin(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rev := r.Pinned(); rev == nil || rev.ID != 1 {
+		t.Errorf("expected revision 1 to be pinned but returned %v", rev)
+	}
+
+	if pinned := r.List().Pinned; pinned != 1 {
+		t.Errorf("expected revision 1 to be pinned but returned %v", pinned)
+	}
+
+	r.Unpin()
+	if rev := r.Pinned(); rev != nil {
+		t.Errorf("expected no pinned revision but returned %v", rev)
+	}
+}
+
+func TestRingPinChecksum(t *testing.T) {
+	r := NewRing(3)
+	r.Add(&Revision{Checksum: "1"})
+	r.Add(&Revision{Checksum: "2"})
+	r.Add(&Revision{Checksum: "1"})
+
+	if _, err := r.PinChecksum("3"); err == nil {
+		t.Errorf("expected an error pinning an unknown checksum")
+	}
+
+	id, err := r.PinChecksum("1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rev := r.Pinned(); id != 3 || rev == nil || rev.ID != 3 {
+		t.Errorf("expected the last revision with the checksum to be pinned but returned %v", rev)
+	}
+}
+
+func TestRingDisabled(t *testing.T) {
+	r := NewRing(0)
+	r.Trigger("Ingress default/a")
+	r.Add(&Revision{Checksum: "1"})
+
+	if len(r.List().Revisions) != 0 {
+		t.Errorf("expected no revisions")
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authjwt"
+	"k8s.io/ingress-nginx/internal/jwks"
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/oidc"
+)
+
+// jwksRefreshPeriod defines how often the remote JSON Web Key Sets are downloaded
+const jwksRefreshPeriod = 5 * time.Minute
+
+// getJWTKeySets returns the keys used by the locations validating
+// JSON Web Tokens and by the OpenID Connect providers, indexed by key
+// set. Key sets that are not available are sent empty so the requests
+// are rejected.
+func (n *NGINXController) getJWTKeySets(servers []*ingress.Server, providers map[string]oidc.Provider) map[string][]jwks.Key {
+	keySets := make(map[string][]jwks.Key)
+
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			jwt := location.JWTAuth
+			if !jwt.Enabled() {
+				continue
+			}
+			if _, ok := keySets[jwt.KeySet]; ok {
+				continue
+			}
+
+			keys := []jwks.Key{}
+			if jwt.Secret != "" {
+				keys = n.getSecretJWTKeys(jwt.Secret)
+			} else if jwt.JWKSURL != "" && n.jwks != nil {
+				if k := n.jwks.Keys(jwt.JWKSURL); k != nil {
+					keys = k
+				}
+			}
+
+			keySets[jwt.KeySet] = keys
+		}
+	}
+
+	for _, provider := range providers {
+		keySet := authjwt.URLKeySet(provider.JWKSURI)
+		if _, ok := keySets[keySet]; ok {
+			continue
+		}
+
+		keys := []jwks.Key{}
+		if n.jwks != nil {
+			if k := n.jwks.Keys(provider.JWKSURI); k != nil {
+				keys = k
+			}
+		}
+
+		keySets[keySet] = keys
+	}
+
+	return keySets
+}
+
+// retainJWTKeySets removes from the cache the key sets not used by the
+// configuration applied to NGINX. The configurations built to check an
+// Ingress do not contain every key set, and are not used to remove them.
+func (n *NGINXController) retainJWTKeySets(pcfg *ingress.Configuration) {
+	if n.jwks == nil {
+		return
+	}
+
+	n.jwks.Retain(jwksURLs(pcfg))
+}
+
+// jwksURLs returns the URLs of the remote key sets used by the configuration
+func jwksURLs(pcfg *ingress.Configuration) sets.String {
+	urls := sets.NewString()
+
+	for _, server := range pcfg.Servers {
+		for _, location := range server.Locations {
+			jwt := location.JWTAuth
+			if jwt.Enabled() && jwt.Secret == "" && jwt.JWKSURL != "" {
+				urls.Insert(jwt.JWKSURL)
+			}
+		}
+	}
+
+	for _, provider := range pcfg.OIDCProviders {
+		urls.Insert(provider.JWKSURI)
+	}
+
+	return urls
+}
+
+func (n *NGINXController) getSecretJWTKeys(name string) []jwks.Key {
+	secret, err := n.store.GetSecret(name)
+	if err != nil {
+		klog.Warningf("Error reading the JSON Web Token keys of secret %v: %v", name, err)
+		return []jwks.Key{}
+	}
+
+	keys, err := jwks.FromSecret(secret.Data)
+	if err != nil {
+		klog.Warningf("Invalid JSON Web Token keys in secret %v: %v", name, err)
+		return []jwks.Key{}
+	}
+
+	return keys
+}
+
+// configureJWTKeys sends the keys used to validate JSON Web Tokens to Lua
+func configureJWTKeys(pcfg *ingress.Configuration) error {
+	keySets := pcfg.JWTKeySets
+	if keySets == nil {
+		keySets = map[string][]jwks.Key{}
+	}
+
+	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/jwt-keys", "application/json", keySets)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	return nil
+}
+
+// clearJWTKeySets removes the keys from the configuration. The keys
+// are updated without reloading NGINX.
+func clearJWTKeySets(config *ingress.Configuration) {
+	config.JWTKeySets = nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authjwt"
+	"k8s.io/ingress-nginx/internal/jwks"
+	"k8s.io/ingress-nginx/internal/oidc"
+)
+
+func jwtServer(url string) *ingress.Server {
+	return &ingress.Server{
+		Hostname: "example.com",
+		Locations: []*ingress.Location{{
+			Path:    "/",
+			JWTAuth: authjwt.Config{KeySet: authjwt.URLKeySet(url), JWKSURL: url},
+		}},
+	}
+}
+
+func TestGetJWTKeySetsKeepsUnusedKeySets(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"keys": [{"kty": "oct", "kid": "a", "k": "c2VjcmV0"}]}`)
+	}))
+	defer server.Close()
+
+	first := server.URL + "/first"
+	second := server.URL + "/second"
+
+	n := &NGINXController{jwks: jwks.NewCache(time.Hour, nil)}
+
+	keySets := n.getJWTKeySets([]*ingress.Server{jwtServer(first)}, nil)
+	if keys := keySets[authjwt.URLKeySet(first)]; len(keys) != 1 {
+		t.Fatalf("expected the keys downloaded on first use but returned %v", keys)
+	}
+
+	// a partial configuration, like the one of the admission webhook
+	n.getJWTKeySets([]*ingress.Server{jwtServer(second)}, nil)
+	n.getJWTKeySets([]*ingress.Server{jwtServer(first)}, nil)
+
+	if r := atomic.LoadInt32(&requests); r != 2 {
+		t.Errorf("expected the key sets downloaded once but found %v requests", r)
+	}
+
+	// only the applied configuration removes the unused key sets
+	n.retainJWTKeySets(&ingress.Configuration{Servers: []*ingress.Server{jwtServer(second)}})
+	n.getJWTKeySets([]*ingress.Server{jwtServer(first)}, nil)
+
+	if r := atomic.LoadInt32(&requests); r != 3 {
+		t.Errorf("expected the removed key set downloaded again but found %v requests", r)
+	}
+}
+
+func TestJWKSURLs(t *testing.T) {
+	pcfg := &ingress.Configuration{
+		Servers: []*ingress.Server{
+			jwtServer("https://a.example.com/keys"),
+			{
+				Hostname: "b.example.com",
+				Locations: []*ingress.Location{{
+					Path:    "/",
+					JWTAuth: authjwt.Config{KeySet: "secret", Secret: "default/keys"},
+				}},
+			},
+		},
+		OIDCProviders: map[string]oidc.Provider{
+			"https://issuer.example.com": {JWKSURI: "https://issuer.example.com/keys"},
+		},
+	}
+
+	urls := jwksURLs(pcfg)
+	if urls.Len() != 2 || !urls.Has("https://a.example.com/keys") || !urls.Has("https://issuer.example.com/keys") {
+		t.Errorf("unexpected URLs %v", urls.List())
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
+	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
+	"k8s.io/ingress-nginx/internal/nginx"
+)
+
+// dynamicWhitelist replaces the ranges of the whitelists applied by Lua
+// when comparing configurations
+const dynamicWhitelist = "dynamic"
+
+// luaLocation contains the settings of a location applied by Lua. They
+// are updated without reloading NGINX.
+type luaLocation struct {
+	Whitelist []string     `json:"whitelist,omitempty"`
+	Cors      *cors.Config `json:"cors,omitempty"`
+}
+
+// luaLocations returns the settings of the locations applied by Lua,
+// indexed by location key. Locations without such settings are omitted.
+func luaLocations(pcfg *ingress.Configuration) map[string]luaLocation {
+	locations := make(map[string]luaLocation)
+
+	for _, server := range pcfg.Servers {
+		keys := ngx_template.LocationKeys(server)
+		for _, location := range server.Locations {
+			settings := luaLocation{}
+
+			if ngx_template.IsDynamicWhitelist(location) {
+				for _, cidr := range location.Whitelist.CIDR {
+					if !strings.Contains(cidr, "/") {
+						cidr = cidr + "/32"
+					}
+					settings.Whitelist = append(settings.Whitelist, cidr)
+				}
+			}
+
+			if location.CorsConfig.CorsEnabled {
+				config := location.CorsConfig
+				settings.Cors = &config
+			}
+
+			if settings.Whitelist == nil && settings.Cors == nil {
+				continue
+			}
+
+			locations[keys[location]] = settings
+		}
+	}
+
+	return locations
+}
+
+// configureLocations sends the settings of the locations applied by Lua
+func configureLocations(pcfg *ingress.Configuration) error {
+	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/locations", "application/json", luaLocations(pcfg))
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	return nil
+}
+
+// clearLuaLocations removes the settings of the locations applied by Lua
+// from the configuration. They are updated without reloading NGINX.
+func clearLuaLocations(config *ingress.Configuration) {
+	var clearedServers []*ingress.Server
+	for _, server := range config.Servers {
+		copyOfServer := *server
+		copyOfServer.Locations = make([]*ingress.Location, len(server.Locations))
+
+		for i, location := range server.Locations {
+			copyOfLocation := *location
+			if ngx_template.IsDynamicWhitelist(location) && len(location.Whitelist.CIDR) > 0 {
+				// adding or removing a whitelist requires a reload, as the
+				// template rejects every request of a whitelisted location
+				// until its settings are applied
+				copyOfLocation.Whitelist = ipwhitelist.SourceRange{CIDR: []string{dynamicWhitelist}}
+			}
+			copyOfLocation.CorsConfig = cors.Config{}
+
+			copyOfServer.Locations[i] = &copyOfLocation
+		}
+
+		clearedServers = append(clearedServers, &copyOfServer)
+	}
+	config.Servers = clearedServers
+}
@@ -51,16 +51,20 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/controller/history"
 	"k8s.io/ingress-nginx/internal/ingress/controller/process"
+	"k8s.io/ingress-nginx/internal/ingress/controller/scheduler"
 	"k8s.io/ingress-nginx/internal/ingress/controller/store"
 	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
 	"k8s.io/ingress-nginx/internal/ingress/metric"
 	"k8s.io/ingress-nginx/internal/ingress/status"
+	"k8s.io/ingress-nginx/internal/jwks"
 	"k8s.io/ingress-nginx/internal/k8s"
 	ing_net "k8s.io/ingress-nginx/internal/net"
 	"k8s.io/ingress-nginx/internal/net/dns"
 	"k8s.io/ingress-nginx/internal/net/ssl"
 	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/oidc"
 	"k8s.io/ingress-nginx/internal/task"
 	"k8s.io/ingress-nginx/internal/watch"
 )
@@ -71,6 +75,8 @@ const (
 
 var (
 	tmplPath = "/etc/nginx/template/nginx.tmpl"
+	// partialsPath is the directory of the partials overriding blocks of the template
+	partialsPath = "/etc/nginx/template/partials"
 )
 
 // NewNGINXController creates a new NGINX Ingress controller.
@@ -78,7 +84,8 @@ func NewNGINXController(config *Configuration, mc metric.Collector, fs file.File
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(klog.Infof)
 	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{
-		Interface: config.Client.CoreV1().Events(config.Namespace),
+		// the events are created in the namespace of their object
+		Interface: config.Client.CoreV1().Events(apiv1.NamespaceAll),
 	})
 
 	h, err := dns.GetSystemNameServers()
@@ -101,11 +108,18 @@ func NewNGINXController(config *Configuration, mc metric.Collector, fs file.File
 		updateCh: channels.NewRingChannel(1024),
 
 		stopLock: &sync.Mutex{},
+		syncLock: &sync.Mutex{},
 
 		fileSystem: fs,
 
 		runningConfig: new(ingress.Configuration),
 
+		history: history.NewRing(config.ConfigHistorySize),
+
+		reloads: process.NewReloadTracker(),
+
+		reloadScheduler: scheduler.New(config.ReloadQuietPeriod, config.ReloadMaxDelay),
+
 		Proxy: &TCPProxy{},
 
 		metricCollector: mc,
@@ -128,7 +142,8 @@ func NewNGINXController(config *Configuration, mc metric.Collector, fs file.File
 	n.podInfo = pod
 
 	n.store = store.New(
-		config.Namespace,
+		config.Namespaces,
+		config.NamespaceSelector,
 		config.ConfigMapName,
 		config.TCPConfigMapName,
 		config.UDPConfigMapName,
@@ -138,10 +153,21 @@ func NewNGINXController(config *Configuration, mc metric.Collector, fs file.File
 		fs,
 		n.updateCh,
 		pod,
-		config.DisableCatchAll)
+		config.DisableCatchAll,
+		config.Shard)
 
 	n.syncQueue = task.NewTaskQueue(n.syncIngress)
 
+	n.jwks = jwks.NewCache(jwksRefreshPeriod, func() {
+		n.trigger("jwks-refresh")
+		n.syncQueue.EnqueueTask(task.GetDummyObject("jwks-refresh"))
+	})
+
+	n.oidc = oidc.NewCache(oidcRefreshPeriod, func() {
+		n.trigger("oidc-refresh")
+		n.syncQueue.EnqueueTask(task.GetDummyObject("oidc-refresh"))
+	})
+
 	if config.UpdateStatus {
 		n.syncStatus = status.NewStatusSyncer(pod, status.Config{
 			Client:                 config.Client,
@@ -150,13 +176,18 @@ func NewNGINXController(config *Configuration, mc metric.Collector, fs file.File
 			IngressLister:          n.store,
 			UpdateStatusOnShutdown: config.UpdateStatusOnShutdown,
 			UseNodeInternalIP:      config.UseNodeInternalIP,
+			Shard:                  config.Shard,
+
+			PublishInternalService:       config.PublishInternalService,
+			PublishInternalStatusAddress: config.PublishInternalStatusAddress,
+			UpdateRateLimit:              config.StatusUpdateRateLimit,
 		})
 	} else {
 		klog.Warning("Update of Ingress status is disabled (flag --update-status)")
 	}
 
 	onTemplateChange := func() {
-		template, err := ngx_template.NewTemplate(tmplPath, fs)
+		template, err := n.loadTemplate(fs)
 		if err != nil {
 			// this error is different from the rest because it must be clear why nginx is not working
 			klog.Errorf(`
@@ -169,10 +200,11 @@ Error loading new template: %v
 
 		n.t = template
 		klog.Info("New NGINX configuration template loaded.")
+		n.trigger("template-change")
 		n.syncQueue.EnqueueTask(task.GetDummyObject("template-change"))
 	}
 
-	ngxTpl, err := ngx_template.NewTemplate(tmplPath, fs)
+	ngxTpl, err := n.loadTemplate(fs)
 	if err != nil {
 		klog.Fatalf("Invalid NGINX configuration template: %v", err)
 	}
@@ -189,6 +221,12 @@ Error loading new template: %v
 		klog.Fatalf("Error creating file watcher for %v: %v", tmplPath, err)
 	}
 
+	// the directory of the partials can be created after the start
+	_, err = watch.NewDirectoryWatcher(partialsPath, onTemplateChange)
+	if err != nil {
+		klog.Fatalf("Error creating file watcher for %v: %v", partialsPath, err)
+	}
+
 	filesToWatch := []string{}
 	err = filepath.Walk("/etc/nginx/geoip/", func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -210,6 +248,7 @@ Error loading new template: %v
 	for _, f := range filesToWatch {
 		_, err = watch.NewFileWatcher(f, func() {
 			klog.Infof("File %v changed. Reloading NGINX", f)
+			n.trigger("file-change")
 			n.syncQueue.EnqueueTask(task.GetDummyObject("file-change"))
 		})
 		if err != nil {
@@ -239,6 +278,10 @@ type NGINXController struct {
 	// allowing concurrent stoppers leads to stack traces.
 	stopLock *sync.Mutex
 
+	// syncLock serializes the synchronizations with the renders of the
+	// desired configuration, both writing the state of the controller
+	syncLock *sync.Mutex
+
 	stopCh   chan struct{}
 	updateCh *channels.RingChannel
 
@@ -248,12 +291,38 @@ type NGINXController struct {
 	// runningConfig contains the running configuration in the Backend
 	runningConfig *ingress.Configuration
 
+	// history contains the last configurations applied to NGINX
+	history *history.Ring
+	// pinnedRevision is the ID of the pinned revision running in NGINX
+	pinnedRevision int
+	// forceReload is true when NGINX must be reloaded by the next
+	// synchronization, like after a pinned revision
+	forceReload bool
+
+	// reloads contains the last reloads of NGINX
+	reloads *process.ReloadTracker
+
+	// reloadScheduler batches the changes requiring a reload
+	reloadScheduler *scheduler.Scheduler
+	// reloadTimer synchronizes the configuration once a delayed reload is due
+	reloadTimer *time.Timer
+
+	// jwks contains the JSON Web Key Sets used to validate JSON Web Tokens
+	jwks *jwks.Cache
+
+	// oidc contains the configuration of the OpenID Connect providers
+	oidc *oidc.Cache
+
 	t ngx_template.TemplateWriter
 
 	resolver []net.IP
 
 	isIPV6Enabled bool
 
+	// isTerminating fails the health check as soon as the shutdown starts,
+	// while NGINX keeps serving during the shutdown delay
+	isTerminating bool
+
 	isShuttingDown bool
 
 	Proxy *TCPProxy
@@ -282,6 +351,11 @@ func (n *NGINXController) Start() {
 		electionID = fmt.Sprintf("%v-%v", n.cfg.ElectionID, class.IngressClass)
 	}
 
+	// each shard updates the status of its own Ingresses
+	if n.cfg.Shard.Enabled() {
+		electionID = fmt.Sprintf("%v-shard-%v", electionID, n.cfg.Shard.Index)
+	}
+
 	setupLeaderElection(&leaderElectionConfig{
 		Client:     n.cfg.Client,
 		ElectionID: electionID,
@@ -319,7 +393,10 @@ func (n *NGINXController) Start() {
 	n.start(cmd)
 
 	go n.syncQueue.Run(time.Second, n.stopCh)
+	go n.jwks.Run(n.stopCh)
+	go n.oidc.Run(n.stopCh)
 	// force initial sync
+	n.trigger("initial-sync")
 	n.syncQueue.EnqueueTask(task.GetDummyObject("initial-sync"))
 
 	// In case of error the temporal configuration file will
@@ -371,6 +448,7 @@ func (n *NGINXController) Start() {
 			}
 			if evt, ok := event.(store.Event); ok {
 				klog.V(3).Infof("Event %v received - object %v", evt.Type, evt.Obj)
+				n.trigger(triggerKey(evt.Obj))
 				if evt.Type == store.ConfigurationEvent {
 					// TODO: is this necessary? Consider removing this special case
 					n.syncQueue.EnqueueTask(task.GetDummyObject("configmap-change"))
@@ -389,7 +467,7 @@ func (n *NGINXController) Start() {
 
 // Stop gracefully stops the NGINX master process.
 func (n *NGINXController) Stop() error {
-	n.isShuttingDown = true
+	n.isTerminating = true
 
 	n.stopLock.Lock()
 	defer n.stopLock.Unlock()
@@ -398,6 +476,15 @@ func (n *NGINXController) Stop() error {
 		return fmt.Errorf("shutdown already in progress")
 	}
 
+	// the load balancers stop sending new connections to the pod once the
+	// failed health check removes it from the endpoints of the Service
+	if n.cfg.ShutdownDelay > 0 {
+		klog.Infof("Failing the health check for %v before stopping NGINX", n.cfg.ShutdownDelay)
+		time.Sleep(n.cfg.ShutdownDelay)
+	}
+
+	n.isShuttingDown = true
+
 	klog.Info("Shutting down controller queues")
 	close(n.stopCh)
 	go n.syncQueue.Shutdown()
@@ -413,29 +500,105 @@ func (n *NGINXController) Stop() error {
 		}
 	}
 
-	// send stop signal to NGINX
+	// send stop signal to NGINX. It stops accepting connections and waits
+	// for the in-flight requests to complete
 	klog.Info("Stopping NGINX process")
-	cmd := n.command.ExecCommand("-s", "quit")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	err := n.signalNginx("quit")
 	if err != nil {
 		return err
 	}
 
-	// wait for the NGINX process to terminate
-	timer := time.NewTicker(time.Second * 1)
-	for range timer.C {
-		if !process.IsNginxRunning() {
-			klog.Info("NGINX process has stopped")
-			timer.Stop()
-			break
+	start := time.Now()
+	stopped, open := n.waitForNginxToStop(n.cfg.ShutdownTimeout)
+	if stopped {
+		// NGINX closes itself the connections still open after the
+		// worker-shutdown-timeout
+		workerShutdownTimeout := parseNginxTime(n.store.GetBackendConfiguration().WorkerShutdownTimeout)
+		if open > 0 && workerShutdownTimeout > 0 && time.Since(start) >= workerShutdownTimeout {
+			klog.Warningf("NGINX closed the connections still open after the worker-shutdown-timeout, %v open one second before it stopped", open)
 		}
+
+		return nil
+	}
+
+	// the count is read from the established connections of NGINX, right
+	// before they are closed
+	open = n.drainingConnections()
+	klog.Warningf("Closing %v connections still open after %v", open, n.cfg.ShutdownTimeout)
+
+	err = n.signalNginx("stop")
+	if err != nil {
+		return err
 	}
 
+	n.waitForNginxToStop(0)
 	return nil
 }
 
+// signalNginx sends a signal to the NGINX master process
+func (n *NGINXController) signalNginx(signal string) error {
+	cmd := n.command.ExecCommand("-s", signal)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// waitForNginxToStop waits for the NGINX process to terminate and returns
+// false if it is still running after the timeout. Zero waits forever. It
+// returns the client connections open the last time NGINX was running too.
+func (n *NGINXController) waitForNginxToStop(timeout time.Duration) (bool, int) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+
+	ticker := time.NewTicker(time.Second * 1)
+	defer ticker.Stop()
+
+	open := 0
+	for {
+		select {
+		case <-ticker.C:
+			if !process.IsNginxRunning() {
+				klog.Info("NGINX process has stopped")
+				n.metricCollector.SetDrainingConnections(0)
+				return true, open
+			}
+
+			open = n.drainingConnections()
+			n.metricCollector.SetDrainingConnections(open)
+		case <-deadline:
+			return false, open
+		}
+	}
+}
+
+// parseNginxTime returns the duration of an NGINX time value, like the
+// worker-shutdown-timeout, or zero if it can not be parsed
+func parseNginxTime(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+
+	return d
+}
+
+// drainingConnections returns the number of client connections still open
+func (n *NGINXController) drainingConnections() int {
+	connections, err := process.EstablishedConnections(n.cfg.ListenPorts.HTTP, n.cfg.ListenPorts.HTTPS)
+	if err != nil {
+		klog.Warningf("Unexpected error reading the client connections: %v", err)
+		return 0
+	}
+
+	return connections
+}
+
 func (n *NGINXController) start(cmd *exec.Cmd) {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -461,6 +624,17 @@ func (n NGINXController) DefaultEndpoint() ingress.Endpoint {
 
 // generateTemplate returns the nginx configuration file content
 func (n NGINXController) generateTemplate(cfg ngx_config.Configuration, ingressCfg ingress.Configuration) ([]byte, error) {
+	return n.t.Write(n.templateConfig(cfg, ingressCfg))
+}
+
+// generateInlineTemplate returns the nginx configuration file content with
+// the servers inline, without changing the files of the server fragments
+func (n NGINXController) generateInlineTemplate(cfg ngx_config.Configuration, ingressCfg ingress.Configuration) ([]byte, error) {
+	return n.t.WriteInline(n.templateConfig(cfg, ingressCfg))
+}
+
+// templateConfig returns the data used to render the nginx configuration
+func (n NGINXController) templateConfig(cfg ngx_config.Configuration, ingressCfg ingress.Configuration) ngx_config.TemplateConfig {
 
 	if n.cfg.EnableSSLPassthrough {
 		servers := []*TCPServer{}
@@ -623,7 +797,7 @@ func (n NGINXController) generateTemplate(cfg ngx_config.Configuration, ingressC
 
 	tc.Cfg.Checksum = ingressCfg.ConfigurationChecksum
 
-	return n.t.Write(tc)
+	return tc
 }
 
 // testTemplate checks if the NGINX configuration inside the byte array is valid
@@ -719,14 +893,44 @@ func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 		return err
 	}
 
+	previous := currentWorkers()
+	start := time.Now()
+
 	o, err := n.command.ExecCommand("-s", "reload").CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%v\n%v", err, string(o))
 	}
 
+	if n.cfg.EnableServerFragments {
+		// keeps the files of the configurations being validated
+		err = ngx_template.PruneServerFragments(content, file.ServersDirectory, time.Minute)
+		if err != nil {
+			klog.Warningf("Error removing the files of the servers: %v", err)
+		}
+	}
+
+	triggers := n.reloadScheduler.Reloaded()
+	n.metricCollector.IncReloadReasons(scheduler.Reasons(triggers))
+
+	go n.trackReload(start, previous, triggers)
+
 	return nil
 }
 
+// loadTemplate loads the NGINX template with its partials
+func (n *NGINXController) loadTemplate(fs file.Filesystem) (*ngx_template.Template, error) {
+	template, err := ngx_template.NewTemplateWithPartials(tmplPath, partialsPath, fs)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.cfg.EnableServerFragments {
+		template.EnableServerFragments(file.ServersDirectory)
+	}
+
+	return template, nil
+}
+
 // nginxHashBucketSize computes the correct NGINX hash_bucket_size for a hash
 // with the given longest key.
 func nginxHashBucketSize(longestString int) int {
@@ -841,29 +1045,89 @@ func clearL4serviceEndpoints(config *ingress.Configuration) {
 // IsDynamicConfigurationEnough returns whether a Configuration can be
 // dynamically applied, without reloading the backend.
 func (n *NGINXController) IsDynamicConfigurationEnough(pcfg *ingress.Configuration) bool {
-	copyOfRunningConfig := *n.runningConfig
+	return clearDynamicConfiguration(n.runningConfig).Equal(clearDynamicConfiguration(pcfg))
+}
+
+// clearDynamicConfiguration returns a copy of the configuration without the
+// settings updated without reloading NGINX
+func clearDynamicConfiguration(pcfg *ingress.Configuration) *ingress.Configuration {
 	copyOfPcfg := *pcfg
 
-	copyOfRunningConfig.Backends = []*ingress.Backend{}
 	copyOfPcfg.Backends = []*ingress.Backend{}
 
-	clearL4serviceEndpoints(&copyOfRunningConfig)
 	clearL4serviceEndpoints(&copyOfPcfg)
 
-	copyOfRunningConfig.ControllerPodsCount = 0
 	copyOfPcfg.ControllerPodsCount = 0
 
+	clearJWTKeySets(&copyOfPcfg)
+
+	clearOIDC(&copyOfPcfg)
+
+	clearLuaLocations(&copyOfPcfg)
+
 	if ngx_config.EnableDynamicCertificates {
-		clearCertificates(&copyOfRunningConfig)
 		clearCertificates(&copyOfPcfg)
 	}
 
-	return copyOfRunningConfig.Equal(&copyOfPcfg)
+	return &copyOfPcfg
 }
 
 // configureDynamically encodes new Backends in JSON format and POSTs the
 // payload to an internal HTTP endpoint handled by Lua.
 func configureDynamically(pcfg *ingress.Configuration) error {
+	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/backends", "application/json", luaBackends(pcfg))
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	err = updateStreamConfiguration(streamBackends(pcfg))
+	if err != nil {
+		return err
+	}
+
+	statusCode, _, err = nginx.NewPostStatusRequest("/configuration/general", "application/json", ingress.GeneralConfig{
+		ControllerPodsCount: pcfg.ControllerPodsCount,
+	})
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	err = configureJWTKeys(pcfg)
+	if err != nil {
+		return err
+	}
+
+	err = configureOIDC(pcfg)
+	if err != nil {
+		return err
+	}
+
+	err = configureLocations(pcfg)
+	if err != nil {
+		return err
+	}
+
+	if ngx_config.EnableDynamicCertificates {
+		err = configureCertificates(pcfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// luaBackends returns the backends of the configuration with only
+// the fields used by the lua code
+func luaBackends(pcfg *ingress.Configuration) []*ingress.Backend {
 	backends := make([]*ingress.Backend, len(pcfg.Backends))
 
 	for i, backend := range pcfg.Backends {
@@ -878,6 +1142,9 @@ func configureDynamically(pcfg *ingress.Configuration) error {
 			SessionAffinity:      backend.SessionAffinity,
 			UpstreamHashBy:       backend.UpstreamHashBy,
 			LoadBalancing:        backend.LoadBalancing,
+			HealthCheck:          backend.HealthCheck,
+			OutlierDetection:     backend.OutlierDetection,
+			CircuitBreaker:       backend.CircuitBreaker,
 			Service:              service,
 			NoServer:             backend.NoServer,
 			TrafficShapingPolicy: backend.TrafficShapingPolicy,
@@ -896,15 +1163,12 @@ func configureDynamically(pcfg *ingress.Configuration) error {
 		backends[i] = luaBackend
 	}
 
-	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/backends", "application/json", backends)
-	if err != nil {
-		return err
-	}
-
-	if statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected error code: %d", statusCode)
-	}
+	return backends
+}
 
+// streamBackends returns the TCP and UDP services of the configuration
+// using the format expected by the lua code
+func streamBackends(pcfg *ingress.Configuration) []ingress.Backend {
 	streams := make([]ingress.Backend, 0)
 	for _, ep := range pcfg.TCPEndpoints {
 		var service *apiv1.Service
@@ -935,30 +1199,7 @@ func configureDynamically(pcfg *ingress.Configuration) error {
 		})
 	}
 
-	err = updateStreamConfiguration(streams)
-	if err != nil {
-		return err
-	}
-
-	statusCode, _, err = nginx.NewPostStatusRequest("/configuration/general", "application/json", ingress.GeneralConfig{
-		ControllerPodsCount: pcfg.ControllerPodsCount,
-	})
-	if err != nil {
-		return err
-	}
-
-	if statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected error code: %d", statusCode)
-	}
-
-	if ngx_config.EnableDynamicCertificates {
-		err = configureCertificates(pcfg)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return streams
 }
 
 func updateStreamConfiguration(streams []ingress.Backend) error {
@@ -988,6 +1229,21 @@ func updateStreamConfiguration(streams []ingress.Backend) error {
 // configureCertificates JSON encodes certificates and POSTs it to an internal HTTP endpoint
 // that is handled by Lua
 func configureCertificates(pcfg *ingress.Configuration) error {
+	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/servers", "application/json", certificateServers(pcfg))
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	return nil
+}
+
+// certificateServers returns the hostnames and certificates that are
+// configured dynamically in the lua code
+func certificateServers(pcfg *ingress.Configuration) []*ingress.Server {
 	var servers []*ingress.Server
 
 	for _, server := range pcfg.Servers {
@@ -1026,16 +1282,7 @@ func configureCertificates(pcfg *ingress.Configuration) error {
 		})
 	}
 
-	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/servers", "application/json", servers)
-	if err != nil {
-		return err
-	}
-
-	if statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected error code: %d", statusCode)
-	}
-
-	return nil
+	return servers
 }
 
 const zipkinTmpl = `{
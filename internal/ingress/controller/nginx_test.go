@@ -24,6 +24,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -32,6 +33,8 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/nginx"
 )
@@ -153,6 +156,124 @@ func TestIsDynamicConfigurationEnough(t *testing.T) {
 	}
 }
 
+func TestIsDynamicConfigurationEnoughLocationSettings(t *testing.T) {
+	newServers := func(location ingress.Location) []*ingress.Server {
+		location.Path = "/"
+		location.Backend = "fakenamespace-myapp-80"
+		return []*ingress.Server{{
+			Hostname:  "myapp.fake",
+			Locations: []*ingress.Location{&location},
+		}}
+	}
+
+	cases := []struct {
+		name     string
+		running  ingress.Location
+		new      ingress.Location
+		expected bool
+	}{
+		{
+			name:     "whitelist added",
+			new:      ingress.Location{Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8"}}},
+			expected: false,
+		},
+		{
+			name:     "whitelist removed",
+			running:  ingress.Location{Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8"}}},
+			expected: false,
+		},
+		{
+			name:     "whitelist changed",
+			running:  ingress.Location{Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8"}}},
+			new:      ingress.Location{Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8", "192.168.1.1"}}},
+			expected: true,
+		},
+		{
+			name:     "whitelist with IPv6 ranges added",
+			new:      ingress.Location{Whitelist: ipwhitelist.SourceRange{CIDR: []string{"2001:db8::/32"}}},
+			expected: false,
+		},
+		{
+			name:     "whitelist changed when satisfy is any",
+			running:  ingress.Location{Satisfy: "any", Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8"}}},
+			new:      ingress.Location{Satisfy: "any", Whitelist: ipwhitelist.SourceRange{CIDR: []string{"192.168.0.0/16"}}},
+			expected: false,
+		},
+		{
+			name:     "CORS enabled",
+			new:      ingress.Location{CorsConfig: cors.Config{CorsEnabled: true, CorsAllowOrigin: "*"}},
+			expected: true,
+		},
+		{
+			name:     "CORS changed",
+			running:  ingress.Location{CorsConfig: cors.Config{CorsEnabled: true, CorsAllowOrigin: "*"}},
+			new:      ingress.Location{CorsConfig: cors.Config{CorsEnabled: true, CorsAllowOrigin: "https://origin.fake"}},
+			expected: true,
+		},
+		{
+			name:     "other location setting changed",
+			new:      ingress.Location{UsePortInRedirects: true},
+			expected: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			n := &NGINXController{
+				runningConfig: &ingress.Configuration{Servers: newServers(c.running)},
+				cfg:           &Configuration{},
+			}
+
+			pcfg := &ingress.Configuration{Servers: newServers(c.new)}
+			if n.IsDynamicConfigurationEnough(pcfg) != c.expected {
+				t.Errorf("expected dynamic configuration to be %v", c.expected)
+			}
+
+			if !pcfg.Equal(&ingress.Configuration{Servers: newServers(c.new)}) {
+				t.Errorf("expected new config to not change")
+			}
+		})
+	}
+}
+
+func TestLuaLocations(t *testing.T) {
+	servers := []*ingress.Server{{
+		Hostname: "myapp.fake",
+		Locations: []*ingress.Location{
+			{
+				Path:      "/",
+				Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8", "192.168.1.1"}},
+			},
+			{
+				Path:       "/api",
+				CorsConfig: cors.Config{CorsEnabled: true, CorsAllowOrigin: "*"},
+			},
+			{
+				Path:      "/admin",
+				Satisfy:   "any",
+				Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8"}},
+			},
+			{
+				Path: "/static",
+			},
+		},
+	}}
+
+	expected := map[string]luaLocation{
+		"myapp.fake /": {
+			Whitelist: []string{"10.0.0.0/8", "192.168.1.1/32"},
+		},
+		"myapp.fake /api": {
+			Cors: &cors.Config{CorsEnabled: true, CorsAllowOrigin: "*"},
+		},
+	}
+
+	locations := luaLocations(&ingress.Configuration{Servers: servers})
+	if !reflect.DeepEqual(locations, expected) {
+		t.Errorf("expected locations %+v but got %+v", expected, locations)
+	}
+}
+
 func TestConfigureDynamically(t *testing.T) {
 	listener, err := net.Listen("unix", nginx.StatusSocket)
 	if err != nil {
@@ -201,6 +322,24 @@ func TestConfigureDynamically(t *testing.T) {
 							t.Errorf("controllerPodsCount should be present in JSON content: %v", body)
 						}
 					}
+				case "/configuration/jwt-keys":
+					{
+						if body != "{}" {
+							t.Errorf("expected an empty JSON object but got %v", body)
+						}
+					}
+				case "/configuration/oidc":
+					{
+						if body != `{"providers":{},"clients":{}}` {
+							t.Errorf("expected empty providers and clients but got %v", body)
+						}
+					}
+				case "/configuration/locations":
+					{
+						if body != "{}" {
+							t.Errorf("expected an empty JSON object but got %v", body)
+						}
+					}
 				default:
 					t.Errorf("unknown request to %s", r.URL.Path)
 				}
@@ -422,6 +561,23 @@ func TestNextPowerOf2(t *testing.T) {
 	}
 }
 
+func TestParseNginxTime(t *testing.T) {
+	tests := map[string]time.Duration{
+		"10s":  10 * time.Second,
+		"240":  240 * time.Second,
+		"5m":   5 * time.Minute,
+		"1d":   0,
+		"":     0,
+		"none": 0,
+	}
+
+	for value, expected := range tests {
+		if d := parseNginxTime(value); d != expected {
+			t.Errorf("expected %v for %q but got %v", expected, value, d)
+		}
+	}
+}
+
 func TestCleanTempNginxCfg(t *testing.T) {
 	err := cleanTempNginxCfg()
 	if err != nil {
@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/oidc"
+)
+
+// oidcRefreshPeriod defines how often the configuration of the OpenID
+// Connect providers is discovered
+const oidcRefreshPeriod = 15 * time.Minute
+
+// oidcConfiguration is the configuration of the OpenID Connect
+// authentication sent to Lua
+type oidcConfiguration struct {
+	Providers map[string]oidc.Provider `json:"providers"`
+	Clients   map[string]oidc.Client   `json:"clients"`
+}
+
+// getOIDCProviders returns the discovered providers used by the
+// locations, indexed by issuer. Providers not discovered yet are
+// omitted so the requests are rejected.
+func (n *NGINXController) getOIDCProviders(servers []*ingress.Server) map[string]oidc.Provider {
+	providers := make(map[string]oidc.Provider)
+	if n.oidc == nil {
+		return providers
+	}
+
+	issuers := sets.NewString()
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			if !location.OIDCAuth.Enabled() || issuers.Has(location.OIDCAuth.Issuer) {
+				continue
+			}
+
+			issuers.Insert(location.OIDCAuth.Issuer)
+			if p := n.oidc.Provider(location.OIDCAuth.Issuer); p != nil {
+				providers[location.OIDCAuth.Issuer] = *p
+			}
+		}
+	}
+
+	n.oidc.Retain(issuers)
+
+	return providers
+}
+
+// getOIDCClients returns the credentials of the clients used by the
+// locations, indexed by Secret
+func (n *NGINXController) getOIDCClients(servers []*ingress.Server) map[string]oidc.Client {
+	clients := make(map[string]oidc.Client)
+
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			name := location.OIDCAuth.Secret
+			if !location.OIDCAuth.Enabled() {
+				continue
+			}
+			if _, ok := clients[name]; ok {
+				continue
+			}
+
+			secret, err := n.store.GetSecret(name)
+			if err != nil {
+				klog.Warningf("Error reading the OpenID Connect client of secret %v: %v", name, err)
+				continue
+			}
+
+			client, err := oidc.ClientFromSecret(secret.Data)
+			if err != nil {
+				klog.Warningf("Invalid OpenID Connect client in secret %v: %v", name, err)
+				continue
+			}
+
+			clients[name] = *client
+		}
+	}
+
+	return clients
+}
+
+// configureOIDC sends the providers and the clients used to authenticate
+// users to Lua
+func configureOIDC(pcfg *ingress.Configuration) error {
+	config := oidcConfiguration{
+		Providers: pcfg.OIDCProviders,
+		Clients:   pcfg.OIDCClients,
+	}
+	if config.Providers == nil {
+		config.Providers = map[string]oidc.Provider{}
+	}
+	if config.Clients == nil {
+		config.Clients = map[string]oidc.Client{}
+	}
+
+	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/oidc", "application/json", config)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	return nil
+}
+
+// clearOIDC removes the providers and the clients from the configuration.
+// They are updated without reloading NGINX.
+func clearOIDC(config *ingress.Configuration) {
+	config.OIDCProviders = nil
+	config.OIDCClients = nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package process
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// tcpEstablished is the state of the established connections in /proc/net/tcp
+const tcpEstablished = "01"
+
+// socketTables contains the TCP sockets of the network namespace
+var socketTables = []string{"/proc/net/tcp", "/proc/net/tcp6"}
+
+// EstablishedConnections returns the number of established TCP connections
+// with one of the ports as local port, like the client connections of the
+// NGINX servers listening in those ports
+func EstablishedConnections(ports ...int) (int, error) {
+	total := 0
+	err := readSocketTables(func(r io.Reader) error {
+		count, err := countEstablished(r, ports)
+		total += count
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// establishedSockets returns the inodes of the established TCP connections
+func establishedSockets() (map[string]bool, error) {
+	inodes := map[string]bool{}
+	err := readSocketTables(func(r io.Reader) error {
+		return readEstablished(r, func(port int, inode string) {
+			inodes[inode] = true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inodes, nil
+}
+
+// readSocketTables calls read with the content of every socket table
+func readSocketTables(read func(r io.Reader) error) error {
+	for _, table := range socketTables {
+		f, err := os.Open(table)
+		if os.IsNotExist(err) {
+			// IPv6 is disabled
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = read(f)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "reading %v", table)
+		}
+	}
+
+	return nil
+}
+
+// countEstablished counts the established connections of a socket table
+// with one of the ports as local port
+func countEstablished(r io.Reader, ports []int) (int, error) {
+	localPorts := make(map[int]bool, len(ports))
+	for _, port := range ports {
+		localPorts[port] = true
+	}
+
+	count := 0
+	err := readEstablished(r, func(port int, inode string) {
+		if localPorts[port] {
+			count++
+		}
+	})
+
+	return count, err
+}
+
+// readEstablished calls visit with the local port and the inode of every
+// established connection of a socket table
+func readEstablished(r io.Reader, visit func(port int, inode string)) error {
+	scanner := bufio.NewScanner(r)
+	// skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[3] != tcpEstablished {
+			continue
+		}
+
+		i := strings.LastIndex(fields[1], ":")
+		if i == -1 {
+			continue
+		}
+
+		port, err := strconv.ParseInt(fields[1][i+1:], 16, 32)
+		if err != nil {
+			return errors.Wrapf(err, "invalid local address %v", fields[1])
+		}
+
+		inode := ""
+		if len(fields) > 9 {
+			inode = fields[9]
+		}
+
+		visit(int(port), inode)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package process
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const tcpTable = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 00000000:01BB 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 100 0 0 10 0
+   2: 0A00000B:0050 0A000001:C350 01 00000000:00000000 00:00000000 00000000     0        0 1003 1 0000000000000000 20 4 30 10 -1
+   3: 0A00000B:01BB 0A000001:C351 01 00000000:00000000 00:00000000 00000000     0        0 1004 1 0000000000000000 20 4 30 10 -1
+   4: 0A00000B:01BB 0A000002:C352 06 00000000:00000000 03:00000DE3 00000000     0        0 0 3 0000000000000000
+   5: 0A00000B:9C40 0A000003:1F90 01 00000000:00000000 00:00000000 00000000   101        0 1005 1 0000000000000000 20 4 30 10 -1
+`
+
+const tcp6Table = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0000000000000000FFFF00000B00000A:01BB 0000000000000000FFFF00000100000A:C353 01 00000000:00000000 00:00000000 00000000     0        0 1006 1 0000000000000000 20 4 30 10 -1
+`
+
+func TestCountEstablished(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    string
+		ports    []int
+		expected int
+	}{
+		{"http and https", tcpTable, []int{80, 443}, 2},
+		{"https", tcpTable, []int{443}, 1},
+		{"no ports", tcpTable, nil, 0},
+		{"ipv6", tcp6Table, []int{80, 443}, 1},
+		{"empty table", "", []int{80, 443}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			count, err := countEstablished(strings.NewReader(test.table), test.ports)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if count != test.expected {
+				t.Errorf("expected %v connections but got %v", test.expected, count)
+			}
+		})
+	}
+}
+
+func TestCountEstablishedInvalidAddress(t *testing.T) {
+	table := "header\n   0: 0A00000B:XYZ 0A000001:C350 01\n"
+	if _, err := countEstablished(strings.NewReader(table), []int{80}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestReadEstablishedInodes(t *testing.T) {
+	inodes := []string{}
+	err := readEstablished(strings.NewReader(tcpTable), func(port int, inode string) {
+		inodes = append(inodes, inode)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"1003", "1004", "1005"}
+	if !reflect.DeepEqual(inodes, expected) {
+		t.Errorf("expected inodes %v but got %v", expected, inodes)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ncabatoff/process-exporter/proc"
+)
+
+const (
+	workerTitle       = "nginx: worker process"
+	shuttingDownTitle = "is shutting down"
+
+	// generationWindow is the maximum difference between the start time
+	// of the workers created by the same reload
+	generationWindow = time.Second
+)
+
+// WorkerGeneration contains the NGINX worker processes created by the
+// same start or reload. The workers of the old generations keep running,
+// shutting down, until their connections complete or the
+// worker-shutdown-timeout expires.
+type WorkerGeneration struct {
+	// StartTime is the start time of the oldest worker of the generation
+	StartTime time.Time `json:"startTime"`
+	// ShuttingDown is true for the generations replaced by a reload
+	ShuttingDown bool `json:"shuttingDown"`
+	// Workers contains the PIDs of the worker processes
+	Workers []int `json:"workers"`
+	// Connections is the number of established TCP connections of the workers
+	Connections int `json:"connections"`
+	// ResidentMemory is the resident memory of the workers in bytes
+	ResidentMemory uint64 `json:"residentMemory"`
+}
+
+// worker contains the information of a NGINX worker process
+type worker struct {
+	pid            int
+	startTime      time.Time
+	shuttingDown   bool
+	connections    int
+	residentMemory uint64
+}
+
+// WorkerGenerations returns the generations of the NGINX worker processes,
+// from the oldest to the newest
+func WorkerGenerations() ([]WorkerGeneration, error) {
+	fs, err := proc.NewFS("/proc", false)
+	if err != nil {
+		return nil, err
+	}
+
+	sockets, err := establishedSockets()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := []worker{}
+
+	procs := fs.AllProcs()
+	for procs.Next() {
+		static, err := procs.GetStatic()
+		if err != nil {
+			// the process already exited
+			continue
+		}
+
+		title := strings.Join(static.Cmdline, " ")
+		if !strings.HasPrefix(title, workerTitle) {
+			continue
+		}
+
+		w := worker{
+			pid:          procs.GetPid(),
+			startTime:    static.StartTime,
+			shuttingDown: strings.Contains(title, shuttingDownTitle),
+			connections:  countSockets(fs.MountPoint, procs.GetPid(), sockets),
+		}
+
+		metrics, _, err := procs.GetMetrics()
+		if err == nil {
+			w.residentMemory = metrics.ResidentBytes
+		}
+
+		workers = append(workers, w)
+	}
+
+	err = procs.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return groupWorkers(workers), nil
+}
+
+// countSockets returns the number of file descriptors of the process that
+// are one of the sockets
+func countSockets(mountPoint string, pid int, sockets map[string]bool) int {
+	dir := filepath.Join(mountPoint, fmt.Sprint(pid), "fd")
+
+	fds, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join(dir, fd.Name()))
+		if err != nil {
+			continue
+		}
+
+		// socket:[<inode>]
+		if strings.HasPrefix(target, "socket:[") && sockets[strings.Trim(target[len("socket:"):], "[]")] {
+			count++
+		}
+	}
+
+	return count
+}
+
+// groupWorkers groups the workers started at the same time, from the oldest
+// to the newest generation
+func groupWorkers(workers []worker) []WorkerGeneration {
+	sort.SliceStable(workers, func(i, j int) bool {
+		return workers[i].startTime.Before(workers[j].startTime)
+	})
+
+	generations := []WorkerGeneration{}
+	for _, w := range workers {
+		last := len(generations) - 1
+		if last == -1 ||
+			generations[last].ShuttingDown != w.shuttingDown ||
+			w.startTime.Sub(generations[last].StartTime) > generationWindow {
+			generations = append(generations, WorkerGeneration{
+				StartTime:    w.startTime,
+				ShuttingDown: w.shuttingDown,
+			})
+			last++
+		}
+
+		g := &generations[last]
+		g.Workers = append(g.Workers, w.pid)
+		g.Connections += w.connections
+		g.ResidentMemory += w.residentMemory
+	}
+
+	return generations
+}
+
+// Current returns the newest generation that is not shutting down, if any
+func Current(generations []WorkerGeneration) *WorkerGeneration {
+	for i := len(generations) - 1; i >= 0; i-- {
+		if !generations[i].ShuttingDown {
+			return &generations[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package process
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGroupWorkers(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	workers := []worker{
+		{pid: 30, startTime: start.Add(10 * time.Minute), connections: 1, residentMemory: 100},
+		{pid: 10, startTime: start, shuttingDown: true, connections: 2, residentMemory: 200},
+		{pid: 31, startTime: start.Add(10*time.Minute + 200*time.Millisecond), connections: 3, residentMemory: 300},
+		{pid: 11, startTime: start.Add(100 * time.Millisecond), shuttingDown: true, residentMemory: 400},
+		{pid: 20, startTime: start.Add(5 * time.Minute), shuttingDown: true, connections: 5, residentMemory: 500},
+	}
+
+	expected := []WorkerGeneration{
+		{StartTime: start, ShuttingDown: true, Workers: []int{10, 11}, Connections: 2, ResidentMemory: 600},
+		{StartTime: start.Add(5 * time.Minute), ShuttingDown: true, Workers: []int{20}, Connections: 5, ResidentMemory: 500},
+		{StartTime: start.Add(10 * time.Minute), Workers: []int{30, 31}, Connections: 4, ResidentMemory: 400},
+	}
+
+	generations := groupWorkers(workers)
+	if !reflect.DeepEqual(generations, expected) {
+		t.Errorf("expected generations %+v but got %+v", expected, generations)
+	}
+
+	current := Current(generations)
+	if current == nil || !reflect.DeepEqual(current.Workers, []int{30, 31}) {
+		t.Errorf("expected the generation of the workers 30 and 31 as current but got %+v", current)
+	}
+}
+
+func TestGroupWorkersEmpty(t *testing.T) {
+	generations := groupWorkers(nil)
+	if len(generations) != 0 {
+		t.Errorf("expected no generations but got %+v", generations)
+	}
+
+	if current := Current(generations); current != nil {
+		t.Errorf("expected no current generation but got %+v", current)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package process
+
+import (
+	"sync"
+	"time"
+)
+
+// maxReloads is the number of reloads kept by the tracker
+const maxReloads = 20
+
+// Reload describes a reload of NGINX
+type Reload struct {
+	// Time is the time the reload was signaled
+	Time time.Time `json:"time"`
+	// Duration is the time elapsed until the new workers replaced the
+	// previous ones
+	Duration time.Duration `json:"duration"`
+	// Ready is false when the new workers did not start before the timeout
+	Ready bool `json:"ready"`
+	// Triggers contains the keys of the objects that caused the reload
+	Triggers []string `json:"triggers"`
+}
+
+// ReloadStatus contains the last reloads and the worker generations alive
+type ReloadStatus struct {
+	Reloads     []Reload           `json:"reloads"`
+	Generations []WorkerGeneration `json:"generations"`
+}
+
+// ReloadTracker keeps the last reloads of NGINX
+type ReloadTracker struct {
+	mu      sync.Mutex
+	reloads []Reload
+}
+
+// NewReloadTracker returns an empty reload tracker
+func NewReloadTracker() *ReloadTracker {
+	return &ReloadTracker{}
+}
+
+// Add records a reload, discarding the oldest one when the tracker is full
+func (t *ReloadTracker) Add(r Reload) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reloads = append(t.reloads, r)
+	if len(t.reloads) > maxReloads {
+		t.reloads = t.reloads[len(t.reloads)-maxReloads:]
+	}
+}
+
+// List returns the reloads from the oldest to the newest
+func (t *ReloadTracker) List() []Reload {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reloads := make([]Reload, len(t.reloads))
+	copy(reloads, t.reloads)
+
+	return reloads
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReloadTracker(t *testing.T) {
+	tracker := NewReloadTracker()
+
+	start := time.Unix(1000, 0)
+	for i := 0; i < maxReloads+5; i++ {
+		tracker.Add(Reload{Time: start.Add(time.Duration(i) * time.Minute), Ready: true})
+	}
+
+	reloads := tracker.List()
+	if len(reloads) != maxReloads {
+		t.Fatalf("expected %v reloads but got %v", maxReloads, len(reloads))
+	}
+
+	if !reloads[0].Time.Equal(start.Add(5 * time.Minute)) {
+		t.Errorf("expected the oldest reloads to be discarded but the first one is %v", reloads[0].Time)
+	}
+
+	reloads[0].Ready = false
+	if !tracker.List()[0].Ready {
+		t.Error("expected a copy of the reloads")
+	}
+}
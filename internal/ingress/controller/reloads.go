@@ -0,0 +1,127 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/controller/process"
+	"k8s.io/ingress-nginx/internal/task"
+)
+
+const (
+	// reloadPollInterval is the interval between the checks of the new workers
+	reloadPollInterval = 100 * time.Millisecond
+	// reloadReadyTimeout is the maximum time to wait for the new workers
+	reloadReadyTimeout = time.Minute
+)
+
+// currentWorkers returns the PIDs of the NGINX workers that are not
+// shutting down
+func currentWorkers() map[int]bool {
+	pids := map[int]bool{}
+
+	generations, err := process.WorkerGenerations()
+	if err != nil {
+		klog.Warningf("Error reading the NGINX worker processes: %v", err)
+		return pids
+	}
+
+	if current := process.Current(generations); current != nil {
+		for _, pid := range current.Workers {
+			pids[pid] = true
+		}
+	}
+
+	return pids
+}
+
+// newWorkersReady returns true when the workers that are not shutting
+// down are none of the previous workers
+func newWorkersReady(previous map[int]bool) bool {
+	current := currentWorkers()
+	if len(current) == 0 {
+		return false
+	}
+
+	for pid := range current {
+		if previous[pid] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// trackReload waits for the workers started by the reload signaled at start
+// to replace the previous workers and records the duration of the reload
+// and the keys of the objects that caused it
+func (n *NGINXController) trackReload(start time.Time, previous map[int]bool, triggers []string) {
+	ready := false
+	for time.Since(start) < reloadReadyTimeout {
+		if newWorkersReady(previous) {
+			ready = true
+			break
+		}
+
+		time.Sleep(reloadPollInterval)
+	}
+
+	duration := time.Since(start)
+	n.reloads.Add(process.Reload{
+		Time:     start,
+		Duration: duration,
+		Ready:    ready,
+		Triggers: triggers,
+	})
+
+	if !ready {
+		klog.Warningf("The new NGINX workers did not start %v after the reload", reloadReadyTimeout)
+		return
+	}
+
+	klog.V(2).Infof("NGINX reload completed in %v", duration)
+	n.metricCollector.ObserveReloadDuration(duration)
+}
+
+// Reloads returns the last reloads of NGINX and the generations of NGINX
+// workers alive
+func (n *NGINXController) Reloads() (*process.ReloadStatus, error) {
+	generations, err := process.WorkerGenerations()
+	if err != nil {
+		return nil, err
+	}
+
+	return &process.ReloadStatus{
+		Reloads:     n.reloads.List(),
+		Generations: generations,
+	}, nil
+}
+
+// scheduleSync enqueues a synchronization once the delay expires,
+// replacing the one previously scheduled
+func (n *NGINXController) scheduleSync(delay time.Duration) {
+	if n.reloadTimer != nil {
+		n.reloadTimer.Stop()
+	}
+
+	n.reloadTimer = time.AfterFunc(delay, func() {
+		n.syncQueue.EnqueueTask(task.GetDummyObject("delayed-reload"))
+	})
+}
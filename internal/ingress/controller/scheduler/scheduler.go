@@ -0,0 +1,173 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheduler batches the changes requiring a reload of NGINX. A reload is
+// delayed until no change is received during the quiet period, but never
+// longer than the maximum delay since the first change waiting for it.
+// It is safe for concurrent use.
+type Scheduler struct {
+	mu sync.Mutex
+
+	quietPeriod time.Duration
+	maxDelay    time.Duration
+
+	// lastChange is the time the last change was received
+	lastChange time.Time
+	// firstPending is the time the first delayed reload was requested
+	firstPending time.Time
+
+	// changes contains the keys received since the last synchronization
+	changes map[string]bool
+	// pending contains the keys of the changes waiting for a reload
+	pending map[string]bool
+
+	now func() time.Time
+}
+
+// New creates a scheduler with the given quiet period and maximum delay.
+// A quiet period of zero disables the batching. A maximum delay of zero
+// does not limit the delay of the reloads.
+func New(quietPeriod, maxDelay time.Duration) *Scheduler {
+	return &Scheduler{
+		quietPeriod: quietPeriod,
+		maxDelay:    maxDelay,
+		changes:     make(map[string]bool),
+		pending:     make(map[string]bool),
+		now:         time.Now,
+	}
+}
+
+// Change records the key of an object that requires a new synchronization
+func (s *Scheduler) Change(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastChange = s.now()
+	s.changes[key] = true
+}
+
+// Schedule is called when the synchronization requires a reload. The
+// changes received since the last synchronization are kept until the
+// reload. It returns zero if the reload must be done now or the time
+// to wait before the next synchronization otherwise.
+func (s *Scheduler) Schedule() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.changes {
+		s.pending[key] = true
+	}
+	s.changes = make(map[string]bool)
+
+	now := s.now()
+	if s.firstPending.IsZero() {
+		s.firstPending = now
+	}
+
+	if s.quietPeriod <= 0 {
+		return 0
+	}
+
+	due := s.lastChange.Add(s.quietPeriod)
+	if s.maxDelay > 0 {
+		deadline := s.firstPending.Add(s.maxDelay)
+		if deadline.Before(due) {
+			due = deadline
+		}
+	}
+
+	if !now.Before(due) {
+		return 0
+	}
+
+	return due.Sub(now)
+}
+
+// Skip is called when the synchronization does not require a reload.
+// The changes received since the last synchronization are discarded.
+func (s *Scheduler) Skip() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.changes = make(map[string]bool)
+}
+
+// Pending returns true if a reload was delayed
+func (s *Scheduler) Pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.firstPending.IsZero()
+}
+
+// Cancel discards the delayed reload, if any
+func (s *Scheduler) Cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+}
+
+// Reloaded is called once NGINX is reloaded. It returns the sorted keys of
+// the changes that caused the reload.
+func (s *Scheduler) Reloaded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.pending))
+	for key := range s.pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	s.reset()
+
+	return keys
+}
+
+func (s *Scheduler) reset() {
+	s.firstPending = time.Time{}
+	s.pending = make(map[string]bool)
+}
+
+// Reasons returns the kinds of the objects of the given keys, without
+// duplicates. Keys without kind, like "template-change", are returned
+// as they are.
+func Reasons(keys []string) []string {
+	seen := make(map[string]bool)
+	reasons := []string{}
+
+	for _, key := range keys {
+		reason := strings.SplitN(key, " ", 2)[0]
+		if seen[reason] {
+			continue
+		}
+
+		seen[reason] = true
+		reasons = append(reasons, reason)
+	}
+
+	return reasons
+}
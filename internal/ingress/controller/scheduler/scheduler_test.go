@@ -0,0 +1,159 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Step(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestScheduler(quietPeriod, maxDelay time.Duration) (*Scheduler, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	s := New(quietPeriod, maxDelay)
+	s.now = clock.Now
+
+	return s, clock
+}
+
+func TestScheduleWithoutQuietPeriod(t *testing.T) {
+	s, _ := newTestScheduler(0, time.Minute)
+
+	s.Change("Ingress default/a")
+	if d := s.Schedule(); d != 0 {
+		t.Errorf("expected an immediate reload but returned %v", d)
+	}
+
+	keys := s.Reloaded()
+	if !reflect.DeepEqual(keys, []string{"Ingress default/a"}) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
+
+func TestScheduleQuietPeriod(t *testing.T) {
+	s, clock := newTestScheduler(5*time.Second, time.Minute)
+
+	s.Change("Ingress default/a")
+	if d := s.Schedule(); d != 5*time.Second {
+		t.Errorf("expected a delay of 5s but returned %v", d)
+	}
+
+	clock.Step(3 * time.Second)
+	s.Change("Secret default/tls")
+	if d := s.Schedule(); d != 5*time.Second {
+		t.Errorf("expected a delay of 5s but returned %v", d)
+	}
+
+	clock.Step(5 * time.Second)
+	if d := s.Schedule(); d != 0 {
+		t.Errorf("expected an immediate reload but returned %v", d)
+	}
+
+	if !s.Pending() {
+		t.Errorf("expected a pending reload")
+	}
+
+	keys := s.Reloaded()
+	if !reflect.DeepEqual(keys, []string{"Ingress default/a", "Secret default/tls"}) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	if s.Pending() {
+		t.Errorf("expected no pending reload")
+	}
+}
+
+func TestScheduleMaxDelay(t *testing.T) {
+	s, clock := newTestScheduler(5*time.Second, 12*time.Second)
+
+	s.Change("Endpoints default/a")
+	s.Schedule()
+
+	for i := 0; i < 3; i++ {
+		clock.Step(4 * time.Second)
+		s.Change("Endpoints default/a")
+		d := s.Schedule()
+		if i < 2 && d == 0 {
+			t.Errorf("expected a delayed reload after %v changes", i+1)
+		}
+		if i == 2 && d != 0 {
+			t.Errorf("expected an immediate reload at the maximum delay but returned %v", d)
+		}
+	}
+}
+
+func TestScheduleDeadline(t *testing.T) {
+	s, clock := newTestScheduler(5*time.Second, 6*time.Second)
+
+	s.Change("Ingress default/a")
+	s.Schedule()
+
+	clock.Step(4 * time.Second)
+	s.Change("Ingress default/a")
+	if d := s.Schedule(); d != 2*time.Second {
+		t.Errorf("expected the delay to end at the maximum delay but returned %v", d)
+	}
+}
+
+func TestSkip(t *testing.T) {
+	s, _ := newTestScheduler(0, 0)
+
+	s.Change("Endpoints default/a")
+	s.Skip()
+	s.Change("Ingress default/b")
+	s.Schedule()
+
+	keys := s.Reloaded()
+	if !reflect.DeepEqual(keys, []string{"Ingress default/b"}) {
+		t.Errorf("expected the changes without reload to be discarded but returned %v", keys)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s, _ := newTestScheduler(5*time.Second, 0)
+
+	s.Change("Ingress default/a")
+	s.Schedule()
+	s.Cancel()
+
+	if s.Pending() {
+		t.Errorf("expected no pending reload")
+	}
+
+	if keys := s.Reloaded(); len(keys) != 0 {
+		t.Errorf("expected no keys but returned %v", keys)
+	}
+}
+
+func TestReasons(t *testing.T) {
+	reasons := Reasons([]string{"Endpoints default/a", "Endpoints default/b", "Ingress default/a", "template-change"})
+	if !reflect.DeepEqual(reasons, []string{"Endpoints", "Ingress", "template-change"}) {
+		t.Errorf("unexpected reasons %v", reasons)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"hash/fnv"
+	"strings"
+
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// Shard selects the hosts served by a controller when several controllers
+// share the same ingress class. Each host belongs to the shard of its hash.
+type Shard struct {
+	// Index is the shard of the controller, from 0 to Count-1
+	Index int
+	// Count is the number of shards. The sharding is disabled below two.
+	Count int
+}
+
+// Enabled returns true if the hosts are split between several shards
+func (s Shard) Enabled() bool {
+	return s.Count > 1
+}
+
+// OwnsHost returns true if the host belongs to the shard. The rules
+// without host belong to the shard of the empty host.
+func (s Shard) OwnsHost(host string) bool {
+	if !s.Enabled() {
+		return true
+	}
+
+	return s.shardOf(host) == s.Index
+}
+
+func (s Shard) shardOf(host string) int {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(host)))
+
+	return int(h.Sum32() % uint32(s.Count))
+}
+
+// SpansShards returns true if the hosts of the Ingress belong to several
+// shards. Its status only reports the address of the shard of its first host.
+func (s Shard) SpansShards(ing *networkingv1beta1.Ingress) bool {
+	if !s.Enabled() || len(ing.Spec.Rules) == 0 {
+		return false
+	}
+
+	first := s.shardOf(ing.Spec.Rules[0].Host)
+	for _, rule := range ing.Spec.Rules[1:] {
+		if s.shardOf(rule.Host) != first {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OwnsStatus returns true if the shard updates the status of the Ingress,
+// the shard of its first host
+func (s Shard) OwnsStatus(ing *ingress.Ingress) bool {
+	if len(ing.Spec.Rules) == 0 {
+		return s.OwnsHost("")
+	}
+
+	return s.OwnsHost(ing.Spec.Rules[0].Host)
+}
+
+// Filter returns a copy of the Ingress with the rules of the hosts of the
+// shard, or nil if the shard does not own any of them
+func (s Shard) Filter(ing *ingress.Ingress) *ingress.Ingress {
+	if !s.Enabled() {
+		return ing
+	}
+
+	if len(ing.Spec.Rules) == 0 {
+		// the default backend of an Ingress without rules is a catch-all
+		if s.OwnsHost("") {
+			return ing
+		}
+
+		return nil
+	}
+
+	rules := make([]networkingv1beta1.IngressRule, 0, len(ing.Spec.Rules))
+	for _, rule := range ing.Spec.Rules {
+		if s.OwnsHost(rule.Host) {
+			rules = append(rules, rule)
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	if len(rules) == len(ing.Spec.Rules) {
+		return ing
+	}
+
+	copyOfIngress := *ing
+	copyOfIngress.Spec.Rules = rules
+
+	return &copyOfIngress
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// shardOf returns the shard owning the host
+func shardOf(t *testing.T, host string, count int) int {
+	owners := 0
+	owner := -1
+	for i := 0; i < count; i++ {
+		if (Shard{Index: i, Count: count}).OwnsHost(host) {
+			owners++
+			owner = i
+		}
+	}
+
+	if owners != 1 {
+		t.Fatalf("expected host %q in a single shard but found it in %v", host, owners)
+	}
+
+	return owner
+}
+
+func newShardedIngress(name string, hosts ...string) *ingress.Ingress {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		},
+	}
+
+	for _, host := range hosts {
+		ing.Spec.Rules = append(ing.Spec.Rules, networking.IngressRule{Host: host})
+	}
+
+	return ing
+}
+
+func TestShardOwnsHost(t *testing.T) {
+	counts := make([]int, 3)
+	for i := 0; i < 300; i++ {
+		counts[shardOf(t, fmt.Sprintf("app-%v.example.com", i), 3)]++
+	}
+
+	for shard, count := range counts {
+		if count < 50 {
+			t.Errorf("expected the hosts spread between the shards but shard %v owns %v of 300", shard, count)
+		}
+	}
+
+	if shardOf(t, "Foo.Example.com", 3) != shardOf(t, "foo.example.com", 3) {
+		t.Errorf("expected the hosts to be case insensitive")
+	}
+
+	if !(Shard{}).OwnsHost("foo.example.com") || !(Shard{Count: 1}).OwnsHost("foo.example.com") {
+		t.Errorf("expected every host owned without sharding")
+	}
+}
+
+func TestShardFilter(t *testing.T) {
+	var hosts [2]string
+	for i, found := 0, 0; found < 2; i++ {
+		host := fmt.Sprintf("app-%v.example.com", i)
+		if shardOf(t, host, 2) == found {
+			hosts[found] = host
+			found++
+		}
+	}
+
+	shard := Shard{Index: 0, Count: 2}
+
+	ing := newShardedIngress("both", hosts[1], hosts[0])
+	filtered := shard.Filter(ing)
+	if filtered == nil || len(filtered.Spec.Rules) != 1 || filtered.Spec.Rules[0].Host != hosts[0] {
+		t.Fatalf("expected only the rule of host %v but returned %v", hosts[0], filtered)
+	}
+	if len(ing.Spec.Rules) != 2 {
+		t.Errorf("expected the Ingress of the store not to be modified")
+	}
+
+	if shard.OwnsStatus(ing) {
+		t.Errorf("expected the status updated by the shard of the first host")
+	}
+
+	if shard.Filter(newShardedIngress("other", hosts[1])) != nil {
+		t.Errorf("expected the Ingresses without hosts of the shard to be omitted")
+	}
+
+	catchAll := newShardedIngress("catch-all")
+	owner := shardOf(t, "", 2)
+	for i := 0; i < 2; i++ {
+		if ((Shard{Index: i, Count: 2}).Filter(catchAll) != nil) != (i == owner) {
+			t.Errorf("expected the Ingress without rules only in the shard of the empty host")
+		}
+	}
+}
+
+func TestShardSpansShards(t *testing.T) {
+	var hosts [2]string
+	for i, found := 0, 0; found < 2; i++ {
+		host := fmt.Sprintf("app-%v.example.com", i)
+		if shardOf(t, host, 2) == found {
+			hosts[found] = host
+			found++
+		}
+	}
+
+	shard := Shard{Index: 0, Count: 2}
+
+	testCases := []struct {
+		name     string
+		shard    Shard
+		ing      *ingress.Ingress
+		expected bool
+	}{
+		{"hosts of both shards", shard, newShardedIngress("both", hosts[1], hosts[0]), true},
+		{"hosts of a single shard", shard, newShardedIngress("single", hosts[1], hosts[1]), false},
+		{"without rules", shard, newShardedIngress("catch-all"), false},
+		{"without sharding", Shard{}, newShardedIngress("both", hosts[1], hosts[0]), false},
+	}
+
+	for _, tc := range testCases {
+		if actual := tc.shard.SpansShards(&tc.ing.Ingress); actual != tc.expected {
+			t.Errorf("%v: expected %v but returned %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestListIngressesWithShard(t *testing.T) {
+	var hosts [2]string
+	for i, found := 0, 0; found < 2; i++ {
+		host := fmt.Sprintf("app-%v.example.com", i)
+		if shardOf(t, host, 2) == found {
+			hosts[found] = host
+			found++
+		}
+	}
+
+	s := &k8sStore{
+		listers: &Lister{
+			IngressWithAnnotation: IngressWithAnnotationsLister{cache.NewStore(cache.DeletionHandlingMetaNamespaceKeyFunc)},
+		},
+		shard: Shard{Index: 1, Count: 2},
+	}
+
+	s.listers.IngressWithAnnotation.Add(newShardedIngress("first", hosts[0]))
+	s.listers.IngressWithAnnotation.Add(newShardedIngress("second", hosts[1]))
+	s.listers.IngressWithAnnotation.Add(newShardedIngress("both", hosts[0], hosts[1]))
+
+	ings := s.ListIngresses(func(ing *ingress.Ingress) bool {
+		if ing.Name == "both" && len(ing.Spec.Rules) != 2 {
+			t.Errorf("expected the filter to receive all the rules")
+		}
+
+		return false
+	})
+
+	if len(ings) != 2 {
+		t.Fatalf("expected 2 Ingresses but returned %v", len(ings))
+	}
+
+	for _, ing := range ings {
+		if len(ing.Spec.Rules) != 1 || ing.Spec.Rules[0].Host != hosts[1] {
+			t.Errorf("expected only the rules of host %v in Ingress %v", hosts[1], ing.Name)
+		}
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
@@ -97,6 +98,10 @@ type Storer interface {
 	// GetDefaultBackend returns the default backend configuration
 	GetDefaultBackend() defaults.Backend
 
+	// IsWatchedNamespace returns true if the Ingresses of the namespace are
+	// watched by the controller
+	IsWatchedNamespace(namespace string) bool
+
 	// Run initiates the synchronization of the controllers
 	Run(stopCh chan struct{})
 }
@@ -129,6 +134,8 @@ type Informer struct {
 	Secret    cache.SharedIndexInformer
 	ConfigMap cache.SharedIndexInformer
 	Pod       cache.SharedIndexInformer
+	// Namespace is only used to select the namespaces watched by labels
+	Namespace cache.SharedIndexInformer
 }
 
 // Lister contains object listers (stores).
@@ -140,6 +147,7 @@ type Lister struct {
 	ConfigMap             ConfigMapLister
 	IngressWithAnnotation IngressWithAnnotationsLister
 	Pod                   PodLister
+	Namespace             NamespaceLister
 }
 
 // NotExistsError is returned when an object does not exist in a local store.
@@ -158,14 +166,21 @@ func (i *Informer) Run(stopCh chan struct{}) {
 	go i.ConfigMap.Run(stopCh)
 	go i.Pod.Run(stopCh)
 
-	// wait for all involved caches to be synced before processing items
-	// from the queue
-	if !cache.WaitForCacheSync(stopCh,
+	synced := []cache.InformerSynced{
 		i.Endpoint.HasSynced,
 		i.Service.HasSynced,
 		i.Secret.HasSynced,
 		i.ConfigMap.HasSynced,
-	) {
+	}
+
+	if i.Namespace != nil {
+		go i.Namespace.Run(stopCh)
+		synced = append(synced, i.Namespace.HasSynced)
+	}
+
+	// wait for all involved caches to be synced before processing items
+	// from the queue
+	if !cache.WaitForCacheSync(stopCh, synced...) {
 		runtime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
 	}
 
@@ -208,6 +223,10 @@ type k8sStore struct {
 	// secret in the annotations.
 	secretIngressMap ObjectRefMap
 
+	// configMapIngressMap contains information about which ingress
+	// references a configmap in the annotations.
+	configMapIngressMap ObjectRefMap
+
 	filesystem file.Filesystem
 
 	// updateCh
@@ -221,18 +240,27 @@ type k8sStore struct {
 
 	defaultSSLCertificate string
 
+	// namespaces contains the namespaces watched, all of them when empty
+	namespaces sets.String
+
+	// shard selects the hosts of the Ingresses listed
+	shard Shard
+
 	pod *k8s.PodInfo
 }
 
 // New creates a new object store to be used in the ingress controller
 func New(
-	namespace, configmap, tcp, udp, defaultSSLCertificate string,
+	namespaces []string,
+	namespaceSelector labels.Selector,
+	configmap, tcp, udp, defaultSSLCertificate string,
 	resyncPeriod time.Duration,
 	client clientset.Interface,
 	fs file.Filesystem,
 	updateCh *channels.RingChannel,
 	pod *k8s.PodInfo,
-	disableCatchAll bool) Storer {
+	disableCatchAll bool,
+	shard Shard) Storer {
 
 	store := &k8sStore{
 		informers:             &Informer{},
@@ -244,10 +272,20 @@ func New(
 		syncSecretMu:          &sync.Mutex{},
 		backendConfigMu:       &sync.RWMutex{},
 		secretIngressMap:      NewObjectRefMap(),
+		configMapIngressMap:   NewObjectRefMap(),
 		defaultSSLCertificate: defaultSSLCertificate,
+		namespaces:            sets.NewString(namespaces...),
+		shard:                 shard,
 		pod:                   pod,
 	}
 
+	// a single namespace is watched by the informers, several namespaces
+	// are filtered by the event handlers
+	namespace := corev1.NamespaceAll
+	if len(namespaces) == 1 {
+		namespace = namespaces[0]
+	}
+
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(klog.Infof)
 	eventBroadcaster.StartRecordingToSink(&clientcorev1.EventSinkImpl{
@@ -305,6 +343,26 @@ func New(
 	)
 	store.listers.Pod.Store = store.informers.Pod.GetStore()
 
+	if namespaceSelector != nil && !namespaceSelector.Empty() {
+		// namespaces are added and removed when they gain and lose the labels
+		store.informers.Namespace = cache.NewSharedIndexInformer(
+			&cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (k8sruntime.Object, error) {
+					options.LabelSelector = namespaceSelector.String()
+					return client.CoreV1().Namespaces().List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					options.LabelSelector = namespaceSelector.String()
+					return client.CoreV1().Namespaces().Watch(options)
+				},
+			},
+			&corev1.Namespace{},
+			resyncPeriod,
+			cache.Indexers{},
+		)
+		store.listers.Namespace.Store = store.informers.Namespace.GetStore()
+	}
+
 	ingDeleteHandler := func(obj interface{}) {
 		ing, ok := toIngress(obj)
 		if !ok {
@@ -335,6 +393,7 @@ func New(
 
 		key := k8s.MetaNamespaceKey(ing)
 		store.secretIngressMap.Delete(key)
+		store.configMapIngressMap.Delete(key)
 
 		updateCh.In() <- Event{
 			Type: DeleteEvent,
@@ -342,34 +401,71 @@ func New(
 		}
 	}
 
+	// the Ingresses with hosts of several shards are reported by the shard
+	// publishing their status, the shard of their first host
+	warnSpanningShards := func(ing *networkingv1beta1.Ingress) {
+		if !shard.SpansShards(ing) || !shard.OwnsHost(ing.Spec.Rules[0].Host) {
+			return
+		}
+
+		klog.Warningf("Ingress %v/%v has hosts served by several shards. Its status only reports the address of the shard of the host %v", ing.Namespace, ing.Name, ing.Spec.Rules[0].Host)
+		recorder.Eventf(ing, corev1.EventTypeWarning, "SHARDS", "The hosts are served by several shards. The status only reports the address of the shard of the host %v", ing.Spec.Rules[0].Host)
+	}
+
+	ingAddHandler := func(obj interface{}) {
+		ing, _ := toIngress(obj)
+		if !class.IsValid(ing) {
+			a, _ := parser.GetStringAnnotation(class.IngressKey, ing)
+			klog.Infof("ignoring add for ingress %v based on annotation %v with value %v", ing.Name, class.IngressKey, a)
+			return
+		}
+		if isCatchAllIngress(ing.Spec) && disableCatchAll {
+			klog.Infof("ignoring add for catch-all ingress %v/%v because of --disable-catch-all", ing.Namespace, ing.Name)
+			return
+		}
+		recorder.Eventf(ing, corev1.EventTypeNormal, "CREATE", fmt.Sprintf("Ingress %s/%s", ing.Namespace, ing.Name))
+		warnSpanningShards(ing)
+
+		store.syncIngress(ing)
+		store.updateSecretIngressMap(ing)
+		store.updateConfigMapIngressMap(ing)
+		store.syncSecrets(ing)
+
+		updateCh.In() <- Event{
+			Type: CreateEvent,
+			Obj:  obj,
+		}
+	}
+
 	ingEventHandler := cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			ing, _ := toIngress(obj)
-			if !class.IsValid(ing) {
-				a, _ := parser.GetStringAnnotation(class.IngressKey, ing)
-				klog.Infof("ignoring add for ingress %v based on annotation %v with value %v", ing.Name, class.IngressKey, a)
+			if !store.IsWatchedNamespace(ing.Namespace) {
+				klog.V(3).Infof("ignoring add for ingress %v/%v in a namespace not watched", ing.Namespace, ing.Name)
 				return
 			}
-			if isCatchAllIngress(ing.Spec) && disableCatchAll {
-				klog.Infof("ignoring add for catch-all ingress %v/%v because of --disable-catch-all", ing.Namespace, ing.Name)
+
+			ingAddHandler(obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			ns, _, _ := cache.SplitMetaNamespaceKey(key)
+			if !store.IsWatchedNamespace(ns) {
+				klog.V(3).Infof("ignoring delete for ingress %v in a namespace not watched", key)
 				return
 			}
-			recorder.Eventf(ing, corev1.EventTypeNormal, "CREATE", fmt.Sprintf("Ingress %s/%s", ing.Namespace, ing.Name))
-
-			store.syncIngress(ing)
-			store.updateSecretIngressMap(ing)
-			store.syncSecrets(ing)
 
-			updateCh.In() <- Event{
-				Type: CreateEvent,
-				Obj:  obj,
-			}
+			ingDeleteHandler(obj)
 		},
-		DeleteFunc: ingDeleteHandler,
 		UpdateFunc: func(old, cur interface{}) {
 			oldIng, _ := toIngress(old)
 			curIng, _ := toIngress(cur)
 
+			if !store.IsWatchedNamespace(curIng.Namespace) {
+				klog.V(3).Infof("ignoring update for ingress %v/%v in a namespace not watched", curIng.Namespace, curIng.Name)
+				return
+			}
+
 			validOld := class.IsValid(oldIng)
 			validCur := class.IsValid(curIng)
 			if !validOld && validCur {
@@ -397,8 +493,11 @@ func New(
 				return
 			}
 
+			warnSpanningShards(curIng)
+
 			store.syncIngress(curIng)
 			store.updateSecretIngressMap(curIng)
+			store.updateConfigMapIngressMap(curIng)
 			store.syncSecrets(curIng)
 
 			updateCh.In() <- Event{
@@ -541,6 +640,17 @@ func New(
 					Type: ConfigurationEvent,
 					Obj:  obj,
 				}
+				return
+			}
+
+			// find references in ingresses
+			if ings := store.configMapIngressMap.Reference(key); len(ings) > 0 {
+				klog.Infof("configmap %v was added and it is used in ingress annotations. Parsing...", key)
+				store.syncIngresses(ings)
+				updateCh.In() <- Event{
+					Type: CreateEvent,
+					Obj:  obj,
+				}
 			}
 		},
 		UpdateFunc: func(old, cur interface{}) {
@@ -569,6 +679,45 @@ func New(
 						Type: ConfigurationEvent,
 						Obj:  cur,
 					}
+					return
+				}
+
+				// find references in ingresses
+				if ings := store.configMapIngressMap.Reference(key); len(ings) > 0 {
+					klog.Infof("configmap %v was updated and it is used in ingress annotations. Parsing...", key)
+					store.syncIngresses(ings)
+					updateCh.In() <- Event{
+						Type: UpdateEvent,
+						Obj:  cur,
+					}
+				}
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			cm, ok := obj.(*corev1.ConfigMap)
+			if !ok {
+				// If we reached here it means the configmap was deleted but its final state is unrecorded.
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					klog.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				cm, ok = tombstone.Obj.(*corev1.ConfigMap)
+				if !ok {
+					klog.Errorf("Tombstone contained object that is not a ConfigMap: %#v", obj)
+					return
+				}
+			}
+
+			key := k8s.MetaNamespaceKey(cm)
+
+			// find references in ingresses
+			if ings := store.configMapIngressMap.Reference(key); len(ings) > 0 {
+				klog.Infof("configmap %v was deleted and it is used in ingress annotations. Parsing...", key)
+				store.syncIngresses(ings)
+				updateCh.In() <- Event{
+					Type: DeleteEvent,
+					Obj:  obj,
 				}
 			}
 		},
@@ -602,12 +751,56 @@ func New(
 		},
 	}
 
+	nsEventHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			ns := obj.(*corev1.Namespace)
+			if !store.IsWatchedNamespace(ns.Name) {
+				return
+			}
+
+			ings, err := store.informers.Ingress.GetIndexer().ByIndex(cache.NamespaceIndex, ns.Name)
+			if err != nil {
+				klog.Errorf("unexpected error listing ingresses of namespace %v: %v", ns.Name, err)
+				return
+			}
+
+			// the ingresses are not listed yet during the initial synchronization
+			if len(ings) > 0 {
+				klog.Infof("namespace %v is now watched, adding its %v ingresses", ns.Name, len(ings))
+			}
+			for _, ing := range ings {
+				ingAddHandler(ing)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+
+			var ings []*ingress.Ingress
+			for _, item := range store.listers.IngressWithAnnotation.List() {
+				ing := item.(*ingress.Ingress)
+				if ing.Namespace == key {
+					ings = append(ings, ing)
+				}
+			}
+
+			if len(ings) > 0 {
+				klog.Infof("namespace %v is no longer watched, removing its %v ingresses", key, len(ings))
+			}
+			for _, ing := range ings {
+				ingDeleteHandler(&ing.Ingress)
+			}
+		},
+	}
+
 	store.informers.Ingress.AddEventHandler(ingEventHandler)
 	store.informers.Endpoint.AddEventHandler(epEventHandler)
 	store.informers.Secret.AddEventHandler(secrEventHandler)
 	store.informers.ConfigMap.AddEventHandler(cmEventHandler)
 	store.informers.Service.AddEventHandler(cache.ResourceEventHandlerFuncs{})
 	store.informers.Pod.AddEventHandler(podEventHandler)
+	if store.informers.Namespace != nil {
+		store.informers.Namespace.AddEventHandler(nsEventHandler)
+	}
 
 	// do not wait for informers to read the configmap configuration
 	ns, name, _ := k8s.ParseNameNS(configmap)
@@ -684,6 +877,8 @@ func (s *k8sStore) updateSecretIngressMap(ing *networkingv1beta1.Ingress) {
 	secretAnnotations := []string{
 		"auth-secret",
 		"auth-tls-secret",
+		"auth-jwt-secret",
+		"auth-oidc-secret",
 	}
 	for _, ann := range secretAnnotations {
 		secrKey, err := objectRefAnnotationNsKey(ann, ing)
@@ -700,6 +895,48 @@ func (s *k8sStore) updateSecretIngressMap(ing *networkingv1beta1.Ingress) {
 	s.secretIngressMap.Insert(key, refSecrets...)
 }
 
+// updateConfigMapIngressMap takes an Ingress and updates all ConfigMap
+// objects it references in configMapIngressMap.
+func (s *k8sStore) updateConfigMapIngressMap(ing *networkingv1beta1.Ingress) {
+	key := k8s.MetaNamespaceKey(ing)
+	klog.V(3).Infof("updating references to configmaps for ingress %v", key)
+
+	// delete all existing references first
+	s.configMapIngressMap.Delete(key)
+
+	var refConfigMaps []string
+
+	configMapAnnotations := []string{
+		"request-headers-configmap",
+		"response-headers-configmap",
+	}
+	for _, ann := range configMapAnnotations {
+		cmKey, err := objectRefAnnotationNsKey(ann, ing)
+		if err != nil && !errors.IsMissingAnnotations(err) {
+			klog.Errorf("error reading configmap reference in annotation %q: %s", ann, err)
+			continue
+		}
+		if cmKey != "" {
+			refConfigMaps = append(refConfigMaps, cmKey)
+		}
+	}
+
+	// populate map with all configmap references
+	s.configMapIngressMap.Insert(key, refConfigMaps...)
+}
+
+// syncIngresses parses again the annotations of the given Ingresses
+func (s *k8sStore) syncIngresses(keys []string) {
+	for _, ingKey := range keys {
+		ing, err := s.getIngress(ingKey)
+		if err != nil {
+			klog.Errorf("could not find Ingress %v in local store", ingKey)
+			continue
+		}
+		s.syncIngress(ing)
+	}
+}
+
 // objectRefAnnotationNsKey returns an object reference formatted as a
 // 'namespace/name' key from the given annotation name.
 func objectRefAnnotationNsKey(ann string, ing *networkingv1beta1.Ingress) (string, error) {
@@ -760,7 +997,8 @@ func (s *k8sStore) getIngress(key string) (*networkingv1beta1.Ingress, error) {
 	return &ing.Ingress, nil
 }
 
-// ListIngresses returns the list of Ingresses
+// ListIngresses returns the list of Ingresses, with the rules of the hosts
+// of the shard. The filter receives the Ingresses with all their rules.
 func (s *k8sStore) ListIngresses(filter IngressFilterFunc) []*ingress.Ingress {
 	// filter ingress rules
 	ingresses := make([]*ingress.Ingress, 0)
@@ -771,6 +1009,11 @@ func (s *k8sStore) ListIngresses(filter IngressFilterFunc) []*ingress.Ingress {
 			continue
 		}
 
+		ing = s.shard.Filter(ing)
+		if ing == nil {
+			continue
+		}
+
 		ingresses = append(ingresses, ing)
 	}
 
@@ -845,6 +1088,21 @@ func (s *k8sStore) writeSSLSessionTicketKey(cmap *corev1.ConfigMap, fileName str
 	}
 }
 
+// IsWatchedNamespace returns true if the namespace is one of the namespaces
+// watched and matches the namespace selector
+func (s *k8sStore) IsWatchedNamespace(namespace string) bool {
+	if s.namespaces.Len() > 0 && !s.namespaces.Has(namespace) {
+		return false
+	}
+
+	if s.listers.Namespace.Store == nil {
+		return true
+	}
+
+	_, err := s.listers.Namespace.ByKey(namespace)
+	return err == nil
+}
+
 // GetDefaultBackend returns the default backend
 func (s *k8sStore) GetDefaultBackend() defaults.Backend {
 	return s.GetBackendConfiguration().Backend
@@ -34,6 +34,7 @@ import (
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
@@ -89,7 +90,8 @@ func TestStore(t *testing.T) {
 
 		fs := newFS(t)
 		storer := New(
-			ns,
+			[]string{ns},
+			nil,
 			fmt.Sprintf("%v/config", ns),
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
@@ -99,7 +101,8 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
-			false)
+			false,
+			Shard{})
 
 		storer.Run(stopCh)
 
@@ -169,7 +172,8 @@ func TestStore(t *testing.T) {
 
 		fs := newFS(t)
 		storer := New(
-			ns,
+			[]string{ns},
+			nil,
 			fmt.Sprintf("%v/config", ns),
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
@@ -179,7 +183,8 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
-			false)
+			false,
+			Shard{})
 
 		storer.Run(stopCh)
 
@@ -319,7 +324,8 @@ func TestStore(t *testing.T) {
 
 		fs := newFS(t)
 		storer := New(
-			ns,
+			[]string{ns},
+			nil,
 			fmt.Sprintf("%v/config", ns),
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
@@ -329,7 +335,8 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
-			false)
+			false,
+			Shard{})
 
 		storer.Run(stopCh)
 
@@ -425,7 +432,8 @@ func TestStore(t *testing.T) {
 
 		fs := newFS(t)
 		storer := New(
-			ns,
+			[]string{ns},
+			nil,
 			fmt.Sprintf("%v/config", ns),
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
@@ -435,7 +443,8 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
-			false)
+			false,
+			Shard{})
 
 		storer.Run(stopCh)
 
@@ -514,7 +523,8 @@ func TestStore(t *testing.T) {
 
 		fs := newFS(t)
 		storer := New(
-			ns,
+			[]string{ns},
+			nil,
 			fmt.Sprintf("%v/config", ns),
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
@@ -524,7 +534,8 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
-			false)
+			false,
+			Shard{})
 
 		storer.Run(stopCh)
 
@@ -625,7 +636,8 @@ func TestStore(t *testing.T) {
 
 		fs := newFS(t)
 		storer := New(
-			ns,
+			[]string{ns},
+			nil,
 			fmt.Sprintf("%v/config", ns),
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
@@ -635,7 +647,8 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
-			false)
+			false,
+			Shard{})
 
 		storer.Run(stopCh)
 
@@ -852,13 +865,14 @@ func newStore(t *testing.T) *k8sStore {
 			IngressWithAnnotation: IngressWithAnnotationsLister{cache.NewStore(cache.DeletionHandlingMetaNamespaceKeyFunc)},
 			Pod:                   PodLister{cache.NewStore(cache.MetaNamespaceKeyFunc)},
 		},
-		sslStore:         NewSSLCertTracker(),
-		filesystem:       fs,
-		updateCh:         channels.NewRingChannel(10),
-		syncSecretMu:     new(sync.Mutex),
-		backendConfigMu:  new(sync.RWMutex),
-		secretIngressMap: NewObjectRefMap(),
-		pod:              pod,
+		sslStore:            NewSSLCertTracker(),
+		filesystem:          fs,
+		updateCh:            channels.NewRingChannel(10),
+		syncSecretMu:        new(sync.Mutex),
+		backendConfigMu:     new(sync.RWMutex),
+		secretIngressMap:    NewObjectRefMap(),
+		configMapIngressMap: NewObjectRefMap(),
+		pod:                 pod,
 	}
 }
 
@@ -926,6 +940,42 @@ func TestUpdateSecretIngressMap(t *testing.T) {
 	})
 }
 
+func TestUpdateConfigMapIngressMap(t *testing.T) {
+	s := newStore(t)
+
+	ingTpl := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "testns",
+		},
+	}
+	s.listers.Ingress.Add(ingTpl)
+
+	t.Run("with annotations", func(t *testing.T) {
+		ing := ingTpl.DeepCopy()
+		ing.ObjectMeta.SetAnnotations(map[string]string{
+			parser.GetAnnotationWithPrefix("request-headers-configmap"):  "request-headers",
+			parser.GetAnnotationWithPrefix("response-headers-configmap"): "testns/response-headers",
+		})
+		s.listers.Ingress.Update(ing)
+		s.updateConfigMapIngressMap(ing)
+
+		if l := s.configMapIngressMap.Len(); !(l == 2 && s.configMapIngressMap.Has("testns/request-headers") && s.configMapIngressMap.Has("testns/response-headers")) {
+			t.Errorf("Expected \"testns/request-headers\" and \"testns/response-headers\" to be the referenced ConfigMaps (got %d)", l)
+		}
+	})
+
+	t.Run("without annotations", func(t *testing.T) {
+		ing := ingTpl.DeepCopy()
+		s.listers.Ingress.Update(ing)
+		s.updateConfigMapIngressMap(ing)
+
+		if l := s.configMapIngressMap.Len(); l != 0 {
+			t.Errorf("Expected 0 referenced ConfigMap (got %d)", l)
+		}
+	})
+}
+
 func TestListIngresses(t *testing.T) {
 	s := newStore(t)
 
@@ -1068,6 +1118,39 @@ func TestWriteSSLSessionTicketKey(t *testing.T) {
 	}
 }
 
+func TestIsWatchedNamespace(t *testing.T) {
+	labeled := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	labeled.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	labeled.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}})
+
+	testCases := []struct {
+		name       string
+		namespaces []string
+		selected   cache.Store
+		expected   map[string]bool
+	}{
+		{"all namespaces", nil, nil, map[string]bool{"team-a": true, "other": true}},
+		{"list of namespaces", []string{"team-a", "other"}, nil, map[string]bool{"team-a": true, "other": true, "team-b": false}},
+		{"namespaces selected by labels", nil, labeled, map[string]bool{"team-a": true, "team-b": true, "other": false}},
+		{"list and labels", []string{"team-a", "other"}, labeled, map[string]bool{"team-a": true, "team-b": false, "other": false}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &k8sStore{
+				listers:    &Lister{Namespace: NamespaceLister{tc.selected}},
+				namespaces: sets.NewString(tc.namespaces...),
+			}
+
+			for namespace, expected := range tc.expected {
+				if s.IsWatchedNamespace(namespace) != expected {
+					t.Errorf("expected namespace %v watched to be %v", namespace, expected)
+				}
+			}
+		})
+	}
+}
+
 func TestGetRunningControllerPodsCount(t *testing.T) {
 	os.Setenv("POD_NAMESPACE", "testns")
 	os.Setenv("POD_NAME", "ingress-1")
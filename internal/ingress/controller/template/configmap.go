@@ -59,11 +59,21 @@ const (
 	globalAuthSnippet         = "global-auth-snippet"
 	globalAuthCacheKey        = "global-auth-cache-key"
 	globalAuthCacheDuration   = "global-auth-cache-duration"
+	globalAuthCacheNegative   = "global-auth-cache-negative-duration"
+	globalAuthConnectTimeout  = "global-auth-connect-timeout"
+	globalAuthReadTimeout     = "global-auth-read-timeout"
+	globalAuthFailureMode     = "global-auth-failure-mode"
+	globalAuthBreakerFailures = "global-auth-circuit-breaker-failures"
+	globalAuthBreakerDuration = "global-auth-circuit-breaker-duration"
 	luaSharedDicts            = "lua-shared-dicts"
+	globalRateLimitStore      = "global-rate-limit-store"
+	globalRateLimitFallback   = "global-rate-limit-fallback"
 )
 
 var (
-	validRedirectCodes = sets.NewInt([]int{301, 302, 307, 308}...)
+	validRedirectCodes            = sets.NewInt([]int{301, 302, 307, 308}...)
+	validGlobalRateLimitStores    = sets.NewString("memcached", "redis", "local")
+	validGlobalRateLimitFallbacks = sets.NewString("allow", "deny", "local")
 )
 
 // ReadConfig obtains the configuration defined by the user merged with the defaults.
@@ -261,6 +271,56 @@ func ReadConfig(src map[string]string) config.Configuration {
 		to.GlobalExternalAuth.AuthCacheDuration = cacheDurations
 	}
 
+	if val, ok := conf[globalAuthCacheNegative]; ok {
+		delete(conf, globalAuthCacheNegative)
+
+		if authreq.ValidDuration(val) {
+			to.GlobalExternalAuth.AuthCacheNegativeDuration = val
+		} else {
+			klog.Warningf("Global auth negative cache duration %q is not valid, ignoring", val)
+		}
+	}
+
+	if val, ok := conf[globalAuthFailureMode]; ok {
+		delete(conf, globalAuthFailureMode)
+
+		if val == authreq.FailureModeClosed || val == authreq.FailureModeOpen {
+			to.GlobalExternalAuth.FailureMode = val
+		} else {
+			klog.Warningf("Global auth failure mode %q is not valid, using %v", val, to.GlobalExternalAuth.FailureMode)
+		}
+	}
+
+	// the timeouts and the duration of the circuit breaker must be positive
+	positiveInts := map[string]*int{
+		globalAuthConnectTimeout:  &to.GlobalExternalAuth.ConnectTimeout,
+		globalAuthReadTimeout:     &to.GlobalExternalAuth.ReadTimeout,
+		globalAuthBreakerDuration: &to.GlobalExternalAuth.CircuitBreakerDuration,
+	}
+	for key, field := range positiveInts {
+		if val, ok := conf[key]; ok {
+			delete(conf, key)
+
+			j, err := strconv.Atoi(val)
+			if err != nil || j <= 0 {
+				klog.Warningf("%v of %q is not a positive number, ignoring", key, val)
+				continue
+			}
+			*field = j
+		}
+	}
+
+	if val, ok := conf[globalAuthBreakerFailures]; ok {
+		delete(conf, globalAuthBreakerFailures)
+
+		j, err := strconv.Atoi(val)
+		if err != nil || j < 0 {
+			klog.Warningf("%v of %q is not valid, ignoring", globalAuthBreakerFailures, val)
+		} else {
+			to.GlobalExternalAuth.CircuitBreakerFailures = j
+		}
+	}
+
 	// Verify that the configured timeout is parsable as a duration. if not, set the default value
 	if val, ok := conf[proxyHeaderTimeout]; ok {
 		delete(conf, proxyHeaderTimeout)
@@ -309,6 +369,24 @@ func ReadConfig(src map[string]string) config.Configuration {
 		delete(conf, workerProcesses)
 	}
 
+	if val, ok := conf[globalRateLimitStore]; ok {
+		delete(conf, globalRateLimitStore)
+		if validGlobalRateLimitStores.Has(val) {
+			to.GlobalRateLimitStore = val
+		} else {
+			klog.Warningf("%v is not a valid global rate limit store. Using the default.", val)
+		}
+	}
+
+	if val, ok := conf[globalRateLimitFallback]; ok {
+		delete(conf, globalRateLimitFallback)
+		if validGlobalRateLimitFallbacks.Has(val) {
+			to.GlobalRateLimitFallback = val
+		} else {
+			klog.Warningf("%v is not a valid global rate limit fallback. Using the default.", val)
+		}
+	}
+
 	to.CustomHTTPErrors = filterErrors(errors)
 	to.SkipAccessLogURLs = skipUrls
 	to.WhitelistSourceRange = whiteList
@@ -303,6 +303,40 @@ func TestGlobalExternalAuthCacheDurationParsing(t *testing.T) {
 	}
 }
 
+func TestGlobalExternalAuthResilienceParsing(t *testing.T) {
+	cfg := ReadConfig(map[string]string{})
+	if cfg.GlobalExternalAuth.FailureMode != authreq.FailureModeClosed ||
+		cfg.GlobalExternalAuth.CircuitBreakerDuration != authreq.DefaultCircuitBreakerDuration {
+		t.Errorf("unexpected defaults %+v", cfg.GlobalExternalAuth)
+	}
+
+	cfg = ReadConfig(map[string]string{
+		"global-auth-connect-timeout":          "2",
+		"global-auth-read-timeout":             "5",
+		"global-auth-failure-mode":             "open",
+		"global-auth-cache-negative-duration":  "30s",
+		"global-auth-circuit-breaker-failures": "5",
+		"global-auth-circuit-breaker-duration": "60",
+	})
+	auth := cfg.GlobalExternalAuth
+	if auth.ConnectTimeout != 2 || auth.ReadTimeout != 5 || auth.FailureMode != authreq.FailureModeOpen ||
+		auth.AuthCacheNegativeDuration != "30s" || auth.CircuitBreakerFailures != 5 || auth.CircuitBreakerDuration != 60 {
+		t.Errorf("unexpected configuration %+v", auth)
+	}
+
+	cfg = ReadConfig(map[string]string{
+		"global-auth-connect-timeout":          "0",
+		"global-auth-failure-mode":             "maybe",
+		"global-auth-cache-negative-duration":  "401 30s",
+		"global-auth-circuit-breaker-failures": "-1",
+	})
+	auth = cfg.GlobalExternalAuth
+	if auth.ConnectTimeout != 0 || auth.FailureMode != authreq.FailureModeClosed ||
+		auth.AuthCacheNegativeDuration != "" || auth.CircuitBreakerFailures != 0 {
+		t.Errorf("expected invalid values to be ignored but returned %+v", auth)
+	}
+}
+
 func TestLuaSharedDict(t *testing.T) {
 
 	testsCases := []struct {
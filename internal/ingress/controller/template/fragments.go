@@ -0,0 +1,298 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/hashstructure"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+const (
+	// serverTemplate is the template rendering the server of a host
+	serverTemplate = "HOST_SERVER"
+	// fragmentExtension is the extension of the files of the servers
+	fragmentExtension = ".conf"
+)
+
+// serverFragments contains the servers rendered in their own files, indexed
+// by the key of their content. The files are named after the key, so the
+// files included by the running configuration are never modified.
+type serverFragments struct {
+	mu  sync.Mutex
+	dir string
+	// cache contains the servers rendered by the last configuration
+	cache map[string][]byte
+}
+
+// serverKey contains everything that changes the rendering of a server
+type serverKey struct {
+	// Template and Global are the checksums of the template and of the
+	// data shared by all the servers
+	Template uint64
+	Global   uint64
+	Server   *ingress.Server
+	// Backends contains the backends of the locations, without endpoints
+	Backends []*ingress.Backend
+	// Ingresses contains the information of the Ingress of the locations,
+	// ignored by the checksum of the server
+	Ingresses []*ingressInformation
+}
+
+// EnableServerFragments renders the server of each host in its own file of
+// the directory, included by nginx.conf. Only the servers changed since the
+// previous configuration are rendered again.
+func (t *Template) EnableServerFragments(dir string) {
+	t.fragments = &serverFragments{
+		dir:   dir,
+		cache: make(map[string][]byte),
+	}
+}
+
+// writeServerFragments renders the servers not rendered by the previous
+// configuration and returns the files of the servers, indexed by hostname
+func (t *Template) writeServerFragments(conf config.TemplateConfig) (map[string]string, error) {
+	t.fragments.mu.Lock()
+	defer t.fragments.mu.Unlock()
+
+	global, err := globalChecksum(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	backends := make(map[string]*ingress.Backend, len(conf.Backends))
+	for _, backend := range conf.Backends {
+		copyOfBackend := *backend
+		copyOfBackend.Endpoints = nil
+		backends[backend.Name] = &copyOfBackend
+	}
+
+	cache := make(map[string][]byte, len(conf.Servers))
+	includes := make(map[string]string, len(conf.Servers))
+	rendered := 0
+
+	for _, server := range conf.Servers {
+		key, err := fragmentKey(t.checksum, global, server, backends)
+		if err != nil {
+			return nil, err
+		}
+
+		content, ok := t.fragments.cache[key]
+		if !ok {
+			content, err = t.renderServer(conf, server)
+			if err != nil {
+				return nil, err
+			}
+			rendered++
+		}
+		cache[key] = content
+
+		path := filepath.Join(t.fragments.dir, key+fragmentExtension)
+		err = writeFragment(path, content)
+		if err != nil {
+			return nil, err
+		}
+
+		includes[server.Hostname] = path
+	}
+
+	t.fragments.cache = cache
+
+	klog.V(2).Infof("Rendered %v of %v servers", rendered, len(conf.Servers))
+
+	return includes, nil
+}
+
+// globalChecksum returns the checksum of the template data used by the
+// servers, other than the servers and backends
+func globalChecksum(conf config.TemplateConfig) (uint64, error) {
+	conf.Servers = nil
+	conf.Backends = nil
+	conf.PassthroughBackends = nil
+	conf.TCPBackends = nil
+	conf.UDPBackends = nil
+	conf.RedirectServers = nil
+	conf.PublishService = nil
+	conf.ServerIncludes = nil
+	conf.Cfg.Checksum = ""
+
+	return hashstructure.Hash(conf, nil)
+}
+
+// fragmentKey returns the key of the content of a server
+func fragmentKey(checksum, global uint64, server *ingress.Server, backends map[string]*ingress.Backend) (string, error) {
+	key := serverKey{
+		Template: checksum,
+		Global:   global,
+		Server:   server,
+	}
+
+	for _, location := range server.Locations {
+		key.Backends = append(key.Backends, backends[location.Backend])
+		key.Ingresses = append(key.Ingresses, getIngressInformation(location.Ingress, server.Hostname, location.Path))
+	}
+
+	hash, err := hashstructure.Hash(key, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "unexpected error calculating the checksum of server %v", server.Hostname)
+	}
+
+	return fmt.Sprintf("%016x", hash), nil
+}
+
+// renderServer renders the server of a host, without consecutive empty lines
+func (t *Template) renderServer(conf config.TemplateConfig, server *ingress.Server) ([]byte, error) {
+	buf := t.bp.Get()
+	defer t.bp.Put(buf)
+
+	err := t.tmpl.ExecuteTemplate(buf, serverTemplate, struct{ First, Second interface{} }{conf, server})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unexpected error rendering server %v", server.Hostname)
+	}
+
+	return squeezeEmptyLines(buf.Bytes()), nil
+}
+
+// writeFragment writes the content of a server, unless the file already
+// has the same checksum. The content is written to a temporary file of
+// the same directory renamed to the path, so the file is never partial.
+func writeFragment(path string, content []byte) error {
+	existing, err := ioutil.ReadFile(path)
+	if err == nil && sha1.Sum(existing) == sha1.Sum(content) {
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return errors.Wrapf(err, "unexpected error creating server file %v", path)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Chmod(file.ReadWriteByUser)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unexpected error writing server file %v", path)
+	}
+
+	return nil
+}
+
+// squeezeEmptyLines removes the carriage returns, the spaces of the lines
+// without other characters and the consecutive empty lines, like the
+// script cleaning nginx.conf
+func squeezeEmptyLines(content []byte) []byte {
+	out := make([]byte, 0, len(content))
+	empty := false
+
+	for _, line := range bytes.Split(bytes.Replace(content, []byte("\r"), nil, -1), []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			if empty {
+				continue
+			}
+			empty = true
+			out = append(out, '\n')
+			continue
+		}
+
+		empty = false
+		out = append(out, line...)
+		out = append(out, '\n')
+	}
+
+	return out
+}
+
+// includeRegexp returns the expression of the include directives of the
+// server files of the directory
+func includeRegexp(dir string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^[ \t]*include[ \t]+(` + regexp.QuoteMeta(filepath.Clean(dir)) + `/[0-9a-f]+\` + fragmentExtension + `);[ \t]*\n?`)
+}
+
+// ExpandServerFragments replaces the include directives of the server files
+// of the directory with the content of the files
+func ExpandServerFragments(conf []byte, dir string) ([]byte, error) {
+	var readErr error
+
+	re := includeRegexp(dir)
+	expanded := re.ReplaceAllFunc(conf, func(include []byte) []byte {
+		path := re.FindSubmatch(include)[1]
+
+		content, err := ioutil.ReadFile(string(path))
+		if err != nil {
+			readErr = err
+			return include
+		}
+
+		return content
+	})
+
+	return expanded, readErr
+}
+
+// PruneServerFragments removes the server files of the directory not
+// included by the configuration and not modified since the grace period,
+// to keep the files used by concurrent validations of the configuration
+func PruneServerFragments(conf []byte, dir string, grace time.Duration) error {
+	included := make(map[string]bool)
+	for _, match := range includeRegexp(dir).FindAllSubmatch(conf, -1) {
+		included[filepath.Base(string(match[1]))] = true
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != fragmentExtension || included[f.Name()] {
+			continue
+		}
+
+		if time.Since(f.ModTime()) < grace {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(dir, f.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,360 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"k8s.io/kubernetes/pkg/util/filesystem"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+const fragmentsTemplate = `{{ $all := . }}
+http {
+    {{ range $server := .Servers }}
+    {{ with index $all.ServerIncludes $server.Hostname }}
+    include {{ . }};
+    {{ else }}
+    {{ template "HOST_SERVER" serverConfig $all $server }}
+    {{ end }}
+    {{ end }}
+}
+{{ define "HOST_SERVER" }}
+    server {
+
+
+        server_name {{ .Second.Hostname }};
+        {{ range $location := .Second.Locations }}
+        location {{ $location.Path }} {
+            proxy_pass http://{{ $location.Backend }};
+        }
+        {{ end }}
+    }
+{{ end }}
+`
+
+func newFragmentsTemplate(t *testing.T) (*Template, string) {
+	fs := filesystem.NewFakeFs()
+	f, err := fs.Create(testTemplatePath)
+	if err != nil {
+		t.Fatalf("unexpected error creating the template: %v", err)
+	}
+	f.Write([]byte(fragmentsTemplate))
+
+	tmpl, err := NewTemplate(testTemplatePath, fs)
+	if err != nil {
+		t.Fatalf("unexpected error loading the template: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "servers")
+	if err != nil {
+		t.Fatalf("unexpected error creating the servers directory: %v", err)
+	}
+
+	tmpl.EnableServerFragments(dir)
+
+	return tmpl, dir
+}
+
+func fragmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.conf"))
+	if err != nil {
+		t.Fatalf("unexpected error listing the servers directory: %v", err)
+	}
+
+	return files
+}
+
+func TestServerFragments(t *testing.T) {
+	tmpl, dir := newFragmentsTemplate(t)
+	defer os.RemoveAll(dir)
+
+	servers := []*ingress.Server{
+		{Hostname: "a.example.com", Locations: []*ingress.Location{{Path: "/", Backend: "a-80"}}},
+		{Hostname: "b.example.com", Locations: []*ingress.Location{{Path: "/", Backend: "b-80"}}},
+	}
+
+	conf, err := tmpl.Write(config.TemplateConfig{Servers: servers})
+	if err != nil {
+		t.Fatalf("unexpected error rendering the template: %v", err)
+	}
+
+	if strings.Contains(string(conf), "server_name") {
+		t.Errorf("expected the servers in their own files\n%v", string(conf))
+	}
+
+	files := fragmentFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("expected 2 server files but found %v", len(files))
+	}
+
+	for _, file := range files {
+		if !strings.Contains(string(conf), fmt.Sprintf("include %v;", file)) {
+			t.Errorf("expected nginx.conf to include %v\n%v", file, string(conf))
+		}
+	}
+
+	expanded, err := ExpandServerFragments(conf, dir)
+	if err != nil {
+		t.Fatalf("unexpected error expanding the servers: %v", err)
+	}
+
+	for _, expected := range []string{"server_name a.example.com;", "server_name b.example.com;", "proxy_pass http://b-80;"} {
+		if !strings.Contains(string(expanded), expected) {
+			t.Errorf("expected %q in the expanded configuration\n%v", expected, string(expanded))
+		}
+	}
+
+	if strings.Contains(string(expanded), "\n\n\n") {
+		t.Errorf("expected the servers without consecutive empty lines\n%v", string(expanded))
+	}
+
+	servers[1] = &ingress.Server{Hostname: "b.example.com", Locations: []*ingress.Location{{Path: "/", Backend: "c-80"}}}
+	changed, err := tmpl.Write(config.TemplateConfig{Servers: servers})
+	if err != nil {
+		t.Fatalf("unexpected error rendering the template: %v", err)
+	}
+
+	if len(fragmentFiles(t, dir)) != 3 {
+		t.Errorf("expected a new file for the changed server only")
+	}
+
+	err = PruneServerFragments(changed, dir, 0)
+	if err != nil {
+		t.Fatalf("unexpected error pruning the servers: %v", err)
+	}
+
+	files = fragmentFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("expected 2 server files after pruning but found %v", len(files))
+	}
+
+	for _, file := range files {
+		if !strings.Contains(string(changed), file) {
+			t.Errorf("expected the files not included by the configuration to be removed")
+		}
+	}
+}
+
+func TestPruneServerFragmentsGracePeriod(t *testing.T) {
+	tmpl, dir := newFragmentsTemplate(t)
+	defer os.RemoveAll(dir)
+
+	_, err := tmpl.Write(config.TemplateConfig{Servers: []*ingress.Server{{Hostname: "a.example.com"}}})
+	if err != nil {
+		t.Fatalf("unexpected error rendering the template: %v", err)
+	}
+
+	err = PruneServerFragments([]byte{}, dir, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error pruning the servers: %v", err)
+	}
+
+	if len(fragmentFiles(t, dir)) != 1 {
+		t.Errorf("expected the recent files to be kept")
+	}
+}
+
+func TestServerFragmentsGlobalChange(t *testing.T) {
+	tmpl, dir := newFragmentsTemplate(t)
+	defer os.RemoveAll(dir)
+
+	servers := []*ingress.Server{{Hostname: "a.example.com"}}
+
+	render := func(cfg config.Configuration) string {
+		conf, err := tmpl.Write(config.TemplateConfig{Servers: servers, Cfg: cfg})
+		if err != nil {
+			t.Fatalf("unexpected error rendering the template: %v", err)
+		}
+
+		// the buffer of the configuration is reused by the next write
+		return string(conf)
+	}
+
+	first := render(config.Configuration{Checksum: "1"})
+
+	if render(config.Configuration{Checksum: "2"}) != first {
+		t.Errorf("expected the same server files with a different checksum")
+	}
+
+	if render(config.Configuration{ServerSnippet: "return 200;"}) == first {
+		t.Errorf("expected new server files with a different global configuration")
+	}
+}
+
+func TestServerFragmentsStaleFile(t *testing.T) {
+	tmpl, dir := newFragmentsTemplate(t)
+	defer os.RemoveAll(dir)
+
+	conf := config.TemplateConfig{Servers: []*ingress.Server{{Hostname: "a.example.com"}}}
+	_, err := tmpl.Write(conf)
+	if err != nil {
+		t.Fatalf("unexpected error rendering the template: %v", err)
+	}
+
+	files := fragmentFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 server file but found %v", len(files))
+	}
+
+	err = ioutil.WriteFile(files[0], []byte("server {"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error truncating the server file: %v", err)
+	}
+
+	_, err = tmpl.Write(conf)
+	if err != nil {
+		t.Fatalf("unexpected error rendering the template: %v", err)
+	}
+
+	content, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("unexpected error reading the server file: %v", err)
+	}
+	if !strings.Contains(string(content), "server_name a.example.com;") {
+		t.Errorf("expected the stale server file to be written again\n%v", string(content))
+	}
+
+	all, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error listing the servers directory: %v", err)
+	}
+	if len(all) != 1 {
+		t.Errorf("expected the temporary files to be renamed but found %v files", len(all))
+	}
+}
+
+func TestWriteInline(t *testing.T) {
+	tmpl, dir := newFragmentsTemplate(t)
+	defer os.RemoveAll(dir)
+
+	_, err := tmpl.Write(config.TemplateConfig{Servers: []*ingress.Server{{Hostname: "a.example.com"}}})
+	if err != nil {
+		t.Fatalf("unexpected error rendering the template: %v", err)
+	}
+
+	conf, err := tmpl.WriteInline(config.TemplateConfig{Servers: []*ingress.Server{{Hostname: "b.example.com"}}})
+	if err != nil {
+		t.Fatalf("unexpected error rendering the template: %v", err)
+	}
+
+	if !strings.Contains(string(conf), "server_name b.example.com;") {
+		t.Errorf("expected the server inline\n%v", string(conf))
+	}
+	if len(fragmentFiles(t, dir)) != 1 {
+		t.Errorf("expected the server files not to be written")
+	}
+	for _, content := range tmpl.fragments.cache {
+		if !strings.Contains(string(content), "server_name a.example.com;") {
+			t.Errorf("expected the rendered servers not to be replaced")
+		}
+	}
+}
+
+func TestSqueezeEmptyLines(t *testing.T) {
+	out := squeezeEmptyLines([]byte("a\r\n\n   \n\t\nb\n\nc"))
+	if string(out) != "a\n\nb\n\nc\n" {
+		t.Errorf("unexpected output %q", string(out))
+	}
+}
+
+// largeTemplateConfig returns the configuration of the test data with
+// the given number of servers
+func largeTemplateConfig(b *testing.B, size int) config.TemplateConfig {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		b.Fatalf("unexpected error reading json file: %v", err)
+	}
+
+	var conf config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &conf); err != nil {
+		b.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if conf.ListenPorts == nil {
+		conf.ListenPorts = &config.ListenPorts{}
+	}
+
+	servers := make([]*ingress.Server, 0, size)
+	for i := 0; len(servers) < size; i++ {
+		copyOfServer := *conf.Servers[i%len(conf.Servers)]
+		copyOfServer.Hostname = fmt.Sprintf("%v-%v", i, copyOfServer.Hostname)
+		servers = append(servers, &copyOfServer)
+	}
+	conf.Servers = servers
+
+	return conf
+}
+
+func benchmarkTemplate(b *testing.B, size int, fragments bool) {
+	pwd, _ := os.Getwd()
+	tmpl, err := NewTemplate(path.Join(pwd, "../../../../rootfs/etc/nginx/template/nginx.tmpl"), filesystem.DefaultFs{})
+	if err != nil {
+		b.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if fragments {
+		dir, err := ioutil.TempDir("", "servers")
+		if err != nil {
+			b.Fatalf("unexpected error creating the servers directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		tmpl.EnableServerFragments(dir)
+	}
+
+	conf := largeTemplateConfig(b, size)
+
+	_, err = tmpl.Write(conf)
+	if err != nil {
+		b.Fatalf("unexpected error rendering the template: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// a change of a single server between configurations
+		copyOfServer := *conf.Servers[0]
+		copyOfServer.ServerSnippet = fmt.Sprintf("# %v", i)
+		conf.Servers[0] = &copyOfServer
+
+		_, err = tmpl.Write(conf)
+		if err != nil {
+			b.Fatalf("unexpected error rendering the template: %v", err)
+		}
+	}
+}
+
+func BenchmarkTemplateLargeConfiguration(b *testing.B) {
+	for _, size := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("full-%v", size), func(b *testing.B) {
+			benchmarkTemplate(b, size, false)
+		})
+		b.Run(fmt.Sprintf("fragments-%v", size), func(b *testing.B) {
+			benchmarkTemplate(b, size, true)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	text_template "text/template"
+	"text/template/parse"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+// partialExtension is the extension of the files of the partials directory
+const partialExtension = ".tmpl"
+
+// partialBlocks contains the blocks of nginx.tmpl that partials can override
+var partialBlocks = []string{"http", "server", "location", "stream"}
+
+// PartialConfig is the data of the blocks overridden by partials. Server
+// is nil in the http and stream blocks and Location is nil outside of the
+// location block.
+type PartialConfig struct {
+	All      config.TemplateConfig
+	Server   *ingress.Server
+	Location *ingress.Location
+}
+
+func partialConfig(all config.TemplateConfig, server *ingress.Server, location *ingress.Location) PartialConfig {
+	return PartialConfig{
+		All:      all,
+		Server:   server,
+		Location: location,
+	}
+}
+
+// loadPartials overrides the blocks of the template with the ones defined
+// by the .tmpl files of the directory, writing their sources to checksum.
+// A missing directory is ignored.
+func loadPartials(tmpl *text_template.Template, dir string, fs file.Filesystem, checksum io.Writer) error {
+	files, err := fs.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return errors.Wrapf(err, "unexpected error reading partials directory %v", dir)
+	}
+
+	// definedIn contains the file defining each block
+	definedIn := make(map[string]string)
+
+	for _, f := range files {
+		// ConfigMap volumes contain hidden directories and links
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || filepath.Ext(f.Name()) != partialExtension {
+			continue
+		}
+
+		path := filepath.Join(dir, f.Name())
+		data, err := fs.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "unexpected error reading partial %v", path)
+		}
+		checksum.Write(data)
+
+		partial, err := text_template.New(f.Name()).Funcs(funcMap).Parse(string(data))
+		if err != nil {
+			return errors.Wrapf(err, "invalid partial %v", path)
+		}
+
+		for _, t := range partial.Templates() {
+			if t.Name() == f.Name() {
+				if hasContent(t.Tree) {
+					return fmt.Errorf("invalid partial %v: content outside of a define action", path)
+				}
+				continue
+			}
+
+			if !isPartialBlock(t.Name()) {
+				return fmt.Errorf("invalid partial %v: unknown block %q, expected one of %v", path, t.Name(), strings.Join(partialBlocks, ", "))
+			}
+
+			if other, ok := definedIn[t.Name()]; ok {
+				return fmt.Errorf("invalid partial %v: block %q already defined in %v", path, t.Name(), other)
+			}
+			definedIn[t.Name()] = path
+
+			_, err = tmpl.AddParseTree(t.Name(), t.Tree)
+			if err != nil {
+				return errors.Wrapf(err, "invalid partial %v", path)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isPartialBlock(name string) bool {
+	for _, block := range partialBlocks {
+		if block == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasContent returns true if the tree contains anything but spaces
+func hasContent(tree *parse.Tree) bool {
+	if tree == nil || tree.Root == nil {
+		return false
+	}
+
+	for _, node := range tree.Root.Nodes {
+		text, ok := node.(*parse.TextNode)
+		if !ok || strings.TrimSpace(string(text.Text)) != "" {
+			return true
+		}
+	}
+
+	return false
+}
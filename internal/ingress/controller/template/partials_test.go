@@ -0,0 +1,141 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/util/filesystem"
+
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+const (
+	testTemplatePath = "/etc/nginx/template/nginx.tmpl"
+	testPartialsPath = "/etc/nginx/template/partials"
+
+	testTemplate = `{{ $all := . }}
+http {
+    {{ range $server := $all.Servers }}
+    server {
+        {{ block "server" (partialConfig $all $server nil) }}{{ end }}
+    }
+    {{ end }}
+    {{ block "http" (partialConfig $all nil nil) }}{{ end }}
+}
+`
+)
+
+func newPartialsFS(t *testing.T, partials map[string]string) file.Filesystem {
+	fs := filesystem.NewFakeFs()
+
+	files := map[string]string{testTemplatePath: testTemplate}
+	for name, content := range partials {
+		files[testPartialsPath+"/"+name] = content
+	}
+
+	for path, content := range files {
+		f, err := fs.Create(path)
+		if err != nil {
+			t.Fatalf("unexpected error creating %v: %v", path, err)
+		}
+
+		_, err = f.Write([]byte(content))
+		if err != nil {
+			t.Fatalf("unexpected error writing %v: %v", path, err)
+		}
+	}
+
+	return fs
+}
+
+func TestTemplateWithPartials(t *testing.T) {
+	fs := newPartialsFS(t, map[string]string{
+		"http.tmpl": `{{ define "http" }}map_hash_bucket_size {{ .All.Cfg.MapHashBucketSize }};{{ end }}`,
+		"server.tmpl": `
+{{/* custom server settings */}}
+{{ define "server" }}# custom {{ .Server.Hostname | toUpper }}{{ end }}
+`,
+		"README.md": `not a partial`,
+	})
+
+	tmpl, err := NewTemplateWithPartials(testTemplatePath, testPartialsPath, fs)
+	if err != nil {
+		t.Fatalf("unexpected error loading the partials: %v", err)
+	}
+
+	cfg := config.NewDefault()
+	cfg.MapHashBucketSize = 128
+
+	out, err := tmpl.Write(config.TemplateConfig{
+		Cfg:     cfg,
+		Servers: []*ingress.Server{{Hostname: "example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error rendering the template: %v", err)
+	}
+
+	for _, expected := range []string{"map_hash_bucket_size 128;", "# custom EXAMPLE.COM"} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("expected %q in the configuration\n%v", expected, string(out))
+		}
+	}
+}
+
+func TestTemplateWithoutPartials(t *testing.T) {
+	fs := newPartialsFS(t, nil)
+
+	_, err := NewTemplateWithPartials(testTemplatePath, testPartialsPath, fs)
+	if err != nil {
+		t.Errorf("unexpected error without partials directory: %v", err)
+	}
+}
+
+func TestInvalidPartials(t *testing.T) {
+	tests := map[string]map[string]string{
+		"syntax error": {
+			"http.tmpl": `{{ define "http" }}{{ .All.Cfg }{{ end }}`,
+		},
+		"unknown function": {
+			"http.tmpl": `{{ define "http" }}{{ unknownFunction }}{{ end }}`,
+		},
+		"unknown block": {
+			"http.tmpl": `{{ define "sever" }}{{ end }}`,
+		},
+		"content outside of define": {
+			"http.tmpl": `gzip on;`,
+		},
+		"block defined twice": {
+			"a.tmpl": `{{ define "http" }}gzip on;{{ end }}`,
+			"b.tmpl": `{{ define "http" }}gzip off;{{ end }}`,
+		},
+	}
+
+	for name, partials := range tests {
+		t.Run(name, func(t *testing.T) {
+			fs := newPartialsFS(t, partials)
+
+			_, err := NewTemplateWithPartials(testTemplatePath, testPartialsPath, fs)
+			if err == nil {
+				t.Errorf("expected an error loading the partials")
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"math/rand"
 	"net"
 	"net/url"
@@ -41,8 +42,12 @@ import (
 
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authoidc"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/globalratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/retrypolicy"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	ing_net "k8s.io/ingress-nginx/internal/net"
 )
@@ -56,6 +61,9 @@ const (
 // TemplateWriter is the interface to render a template
 type TemplateWriter interface {
 	Write(conf config.TemplateConfig) ([]byte, error)
+	// WriteInline renders the servers inline, without writing the files of
+	// the server fragments
+	WriteInline(conf config.TemplateConfig) ([]byte, error)
 }
 
 // Template ...
@@ -63,11 +71,23 @@ type Template struct {
 	tmpl *text_template.Template
 	//fw   watch.FileWatcher
 	bp *BufferPool
+
+	// checksum identifies the sources of the template and its partials
+	checksum uint64
+	// fragments renders the servers in their own files, if enabled
+	fragments *serverFragments
 }
 
 //NewTemplate returns a new Template instance or an
 //error if the specified template file contains errors
 func NewTemplate(file string, fs file.Filesystem) (*Template, error) {
+	return NewTemplateWithPartials(file, "", fs)
+}
+
+// NewTemplateWithPartials returns a new Template instance with the blocks
+// overridden by the partials of the given directory, or an error if the
+// template file or one of the partials contains errors
+func NewTemplateWithPartials(file, partialsDir string, fs file.Filesystem) (*Template, error) {
 	data, err := fs.ReadFile(file)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unexpected error reading template %v", file)
@@ -78,15 +98,37 @@ func NewTemplate(file string, fs file.Filesystem) (*Template, error) {
 		return nil, err
 	}
 
+	checksum := fnv.New64a()
+	checksum.Write(data)
+
+	if partialsDir != "" {
+		err = loadPartials(tmpl, partialsDir, fs, checksum)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Template{
-		tmpl: tmpl,
-		bp:   NewBufferPool(defBufferSize),
+		tmpl:     tmpl,
+		bp:       NewBufferPool(defBufferSize),
+		checksum: checksum.Sum64(),
 	}, nil
 }
 
 // Write populates a buffer using a template with NGINX configuration
 // and the servers and upstreams created by Ingress rules
 func (t *Template) Write(conf config.TemplateConfig) ([]byte, error) {
+	return t.write(conf, t.fragments != nil)
+}
+
+// WriteInline populates a buffer like Write, with the servers rendered
+// inline even when the server fragments are enabled. The files and the
+// servers rendered by the previous configuration are not modified.
+func (t *Template) WriteInline(conf config.TemplateConfig) ([]byte, error) {
+	return t.write(conf, false)
+}
+
+func (t *Template) write(conf config.TemplateConfig, fragments bool) ([]byte, error) {
 	tmplBuf := t.bp.Get()
 	defer t.bp.Put(tmplBuf)
 
@@ -98,6 +140,15 @@ func (t *Template) Write(conf config.TemplateConfig) ([]byte, error) {
 		conf.Cfg.DisableLuaRestyWAF = true
 	}
 
+	if fragments {
+		includes, err := t.writeServerFragments(conf)
+		if err != nil {
+			return nil, err
+		}
+
+		conf.ServerIncludes = includes
+	}
+
 	if klog.V(3) {
 		b, err := json.Marshal(conf)
 		if err != nil {
@@ -142,14 +193,25 @@ var (
 		"buildAuthResponseHeaders":   buildAuthResponseHeaders,
 		"buildProxyPass":             buildProxyPass,
 		"filterRateLimits":           filterRateLimits,
+		"filterRateLimitResponses":   filterRateLimitResponses,
+		"filterAppendedHeaders":      filterAppendedHeaders,
+		"buildAppendRequestHeader":   buildAppendRequestHeader,
+		"buildAppendResponseHeader":  buildAppendResponseHeader,
 		"buildRateLimitZones":        buildRateLimitZones,
 		"buildRateLimit":             buildRateLimit,
 		"configForLua":               configForLua,
 		"locationConfigForLua":       locationConfigForLua,
+		"jwtAuthConfigForLua":        jwtAuthConfigForLua,
+		"oidcAuthConfigForLua":       oidcAuthConfigForLua,
+		"externalAuthConfigForLua":   externalAuthConfigForLua,
+		"filterOIDCAuths":            filterOIDCAuths,
+		"shouldConfigureOIDC":        shouldConfigureOIDC,
 		"buildResolvers":             buildResolvers,
 		"buildUpstreamName":          buildUpstreamName,
 		"isLocationInLocationList":   isLocationInLocationList,
 		"isLocationAllowed":          isLocationAllowed,
+		"isDynamicWhitelist":         isDynamicWhitelist,
+		"locationKeyForLua":          locationKeyForLua,
 		"buildLogFormatUpstream":     buildLogFormatUpstream,
 		"buildDenyVariable":          buildDenyVariable,
 		"getenv":                     os.Getenv,
@@ -162,7 +224,10 @@ var (
 		"formatIP":                   formatIP,
 		"quote":                      quote,
 		"buildNextUpstream":          buildNextUpstream,
+		"buildRetryOn":               buildRetryOn,
+		"retryPolicyForLua":          retryPolicyForLua,
 		"getIngressInformation":      getIngressInformation,
+		"partialConfig":              partialConfig,
 		"serverConfig": func(all config.TemplateConfig, server *ingress.Server) interface{} {
 			return struct{ First, Second interface{} }{all, server}
 		},
@@ -260,6 +325,76 @@ func buildLuaSharedDictionaries(c interface{}, s interface{}, disableLuaRestyWAF
 		certData = 16
 	}
 	out = append(out, fmt.Sprintf("lua_shared_dict certificate_data %dM", certData))
+
+	// the state of the active health checks of the upstream endpoints
+	healthData, ok := cfg.LuaSharedDicts["healthcheck"]
+	if !ok {
+		healthData = 1
+	}
+	out = append(out, fmt.Sprintf("lua_shared_dict healthcheck %dM", healthData))
+
+	// the state of the outlier detection of the upstream endpoints
+	outlierData, ok := cfg.LuaSharedDicts["outlier_detection"]
+	if !ok {
+		outlierData = 1
+	}
+	out = append(out, fmt.Sprintf("lua_shared_dict outlier_detection %dM", outlierData))
+
+	// the concurrent and pending requests of the backends with circuit breaking
+	circuitData, ok := cfg.LuaSharedDicts["circuit_breaker"]
+	if !ok {
+		circuitData = 1
+	}
+	out = append(out, fmt.Sprintf("lua_shared_dict circuit_breaker %dM", circuitData))
+
+	// the active requests and retries of the backends with a retry budget
+	retryData, ok := cfg.LuaSharedDicts["retry_budget"]
+	if !ok {
+		retryData = 1
+	}
+	out = append(out, fmt.Sprintf("lua_shared_dict retry_budget %dM", retryData))
+
+	// the global rate limits cache the decisions and keep the local counters
+	globalThrottleEnabled := func() bool {
+		for _, server := range servers {
+			for _, location := range server.Locations {
+				if location.GlobalRateLimit.Limit > 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}()
+	if globalThrottleEnabled {
+		throttleData, ok := cfg.LuaSharedDicts["global_throttle_cache"]
+		if !ok {
+			throttleData = 10
+		}
+		out = append(out, fmt.Sprintf("lua_shared_dict global_throttle_cache %dM", throttleData))
+	}
+
+	// the circuit breakers of the external authentication services
+	circuitBreakerEnabled := func() bool {
+		if cfg.GlobalExternalAuth.CircuitBreakerFailures > 0 {
+			return true
+		}
+		for _, server := range servers {
+			for _, location := range server.Locations {
+				if location.ExternalAuth.CircuitBreakerFailures > 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}()
+	if circuitBreakerEnabled {
+		authData, ok := cfg.LuaSharedDicts["external_auth"]
+		if !ok {
+			authData = 1
+		}
+		out = append(out, fmt.Sprintf("lua_shared_dict external_auth %dM", authData))
+	}
+
 	if !disableLuaRestyWAF {
 		luaRestyWAFEnabled := func() bool {
 			for _, server := range servers {
@@ -291,7 +426,50 @@ func configForLua(input interface{}) string {
 		is_ssl_passthrough_enabled = %t,
 		http_redirect_code = %v,
 		listen_ports = { ssl_proxy = "%v", https = "%v" },
-	}`, all.Cfg.UseForwardedHeaders, all.IsSSLPassthroughEnabled, all.Cfg.HTTPRedirectCode, all.ListenPorts.SSLProxy, all.ListenPorts.HTTPS)
+		global_throttle = %v,
+	}`, all.Cfg.UseForwardedHeaders, all.IsSSLPassthroughEnabled, all.Cfg.HTTPRedirectCode, all.ListenPorts.SSLProxy, all.ListenPorts.HTTPS,
+		globalThrottleStoreForLua(all.Cfg))
+}
+
+// globalThrottleStoreForLua returns the configuration of the store used by
+// the global rate limits as Lua table represented as string
+func globalThrottleStoreForLua(cfg config.Configuration) string {
+	port := cfg.GlobalRateLimitPort
+	if port == 0 {
+		switch cfg.GlobalRateLimitStore {
+		case "redis":
+			port = 6379
+		case "memcached":
+			port = 11211
+		}
+	}
+
+	return fmt.Sprintf(`{
+			store = %q, host = %q, port = %d,
+			connect_timeout = %d, max_idle_timeout = %d, pool_size = %d,
+			status_code = %d, fallback = %q,
+		}`, cfg.GlobalRateLimitStore, cfg.GlobalRateLimitHost, port,
+		cfg.GlobalRateLimitConnectTimeout, cfg.GlobalRateLimitMaxIdleTimeout, cfg.GlobalRateLimitPoolSize,
+		cfg.GlobalRateLimitStatusCode, cfg.GlobalRateLimitFallback)
+}
+
+// globalThrottleForLua returns the global rate limit of a location as Lua
+// table represented as string, or nil if the location is not limited
+func globalThrottleForLua(l globalratelimit.Config) string {
+	if l.Limit <= 0 {
+		return "nil"
+	}
+
+	cidrs := make([]string, 0, len(l.IgnoredCIDRs))
+	for _, cidr := range l.IgnoredCIDRs {
+		cidrs = append(cidrs, fmt.Sprintf("%q", cidr))
+	}
+
+	return fmt.Sprintf(`{
+			namespace = %q, limit = %d, window_size = %d,
+			key = %q, algorithm = %q, ignored_cidrs = { %v },
+			authenticated = %t,
+		}`, l.Namespace, l.Limit, l.WindowSize, l.Key, l.Algorithm, strings.Join(cidrs, ", "), l.Authenticated())
 }
 
 // locationConfigForLua formats some location specific configuration into Lua table represented as string
@@ -320,7 +498,151 @@ func locationConfigForLua(l interface{}, s interface{}, a interface{}) string {
 	return fmt.Sprintf(`{
 		force_ssl_redirect = %t,
 		use_port_in_redirects = %t,
-	}`, forceSSLRedirect, location.UsePortInRedirects)
+		global_throttle = %v,
+	}`, forceSSLRedirect, location.UsePortInRedirects, globalThrottleForLua(location.GlobalRateLimit))
+}
+
+// jwtAuthConfigForLua formats the JWT authentication configuration of a
+// location into a Lua table represented as string
+func jwtAuthConfigForLua(l interface{}) string {
+	location, ok := l.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was given", l)
+		return "{}"
+	}
+
+	jwt := location.JWTAuth
+
+	return fmt.Sprintf(`{
+		key_set = %q,
+		issuer = %q,
+		audiences = %v,
+		required_claims = %v,
+		clock_skew = %d,
+		claim_headers = %v,
+	}`, jwt.KeySet, jwt.Issuer, luaStringList(jwt.Audiences),
+		luaStringMap(jwt.RequiredClaims), jwt.ClockSkew, luaStringMap(jwt.ClaimHeaders))
+}
+
+// externalAuthConfigForLua formats the configuration of the circuit
+// breaker of an external authentication service for the external_auth
+// Lua module
+func externalAuthConfigForLua(input interface{}) string {
+	var url string
+	var failures, duration int
+
+	switch auth := input.(type) {
+	case authreq.Config:
+		url, failures, duration = auth.URL, auth.CircuitBreakerFailures, auth.CircuitBreakerDuration
+	case config.GlobalExternalAuth:
+		url, failures, duration = auth.URL, auth.CircuitBreakerFailures, auth.CircuitBreakerDuration
+	default:
+		klog.Errorf("expected an 'authreq.Config' or 'config.GlobalExternalAuth' type but %T was given", input)
+		return "{}"
+	}
+
+	return fmt.Sprintf(`{ url = %q, circuit_breaker_failures = %d, circuit_breaker_duration = %d }`,
+		url, failures, duration)
+}
+
+// oidcAuthConfigForLua formats the OpenID Connect authentication
+// configuration of a location for the oidc_auth Lua module
+func oidcAuthConfigForLua(c interface{}) string {
+	oidc, ok := c.(authoidc.Config)
+	if !ok {
+		klog.Errorf("expected an 'authoidc.Config' type but %T was given", c)
+		return "{}"
+	}
+
+	return fmt.Sprintf(`{
+		issuer = %q,
+		secret = %q,
+		scopes = %v,
+		redirect_path = %q,
+		logout_path = %q,
+		post_logout_redirect_uri = %q,
+		groups_claim = %q,
+		allowed_groups = %v,
+		required_claims = %v,
+		claim_headers = %v,
+		session_lifetime = %d,
+		cookie_name = %q,
+	}`, oidc.Issuer, oidc.Secret, luaStringList(oidc.Scopes), oidc.RedirectPath,
+		oidc.LogoutPath, oidc.PostLogoutRedirectURI, oidc.GroupsClaim,
+		luaStringList(oidc.AllowedGroups), luaStringMap(oidc.RequiredClaims),
+		luaStringMap(oidc.ClaimHeaders), oidc.SessionLifetime, oidc.CookieName)
+}
+
+// filterOIDCAuths returns the OpenID Connect configurations of a server
+// that need redirect and logout locations. Locations sharing a path use
+// the configuration of the first location.
+func filterOIDCAuths(input interface{}) []authoidc.Config {
+	configs := []authoidc.Config{}
+	paths := sets.String{}
+
+	server, ok := input.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was returned", input)
+		return configs
+	}
+
+	for _, loc := range server.Locations {
+		oidc := loc.OIDCAuth
+		if !oidc.Enabled() || paths.Has(oidc.RedirectPath) || paths.Has(oidc.LogoutPath) {
+			continue
+		}
+
+		paths.Insert(oidc.RedirectPath, oidc.LogoutPath)
+		configs = append(configs, oidc)
+	}
+
+	return configs
+}
+
+// shouldConfigureOIDC returns true if a location authenticates users with
+// an OpenID Connect provider
+func shouldConfigureOIDC(input interface{}) bool {
+	servers, ok := input.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected a '[]*ingress.Server' type but %T was returned", input)
+		return false
+	}
+
+	for _, server := range servers {
+		for _, loc := range server.Locations {
+			if loc.OIDCAuth.Enabled() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// luaStringList formats a list into a Lua table
+func luaStringList(l []string) string {
+	entries := make([]string, 0, len(l))
+	for _, v := range l {
+		entries = append(entries, fmt.Sprintf("%q", v))
+	}
+
+	return fmt.Sprintf("{ %v }", strings.Join(entries, ", "))
+}
+
+// luaStringMap formats a map into a Lua table sorted by key
+func luaStringMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, fmt.Sprintf("[%q] = %q", k, m[k]))
+	}
+
+	return fmt.Sprintf("{ %v }", strings.Join(entries, ", "))
 }
 
 // buildResolvers returns the resolvers reading the /etc/resolv.conf file
@@ -563,11 +885,80 @@ func filterRateLimits(input interface{}) []ratelimit.Config {
 	return ratelimits
 }
 
+// headerVariable returns the name of the NGINX variable with the value of
+// a header, e.g. http_x_tenant for the request header X-Tenant
+func headerVariable(prefix, name string) string {
+	return prefix + strings.ToLower(strings.Replace(name, "-", "_", -1))
+}
+
+// filterAppendedHeaders returns the variables of the request and upstream
+// response headers to which a location appends values. A map for each of
+// them defines the separator from the existing value.
+func filterAppendedHeaders(input interface{}) []string {
+	variables := sets.String{}
+
+	servers, ok := input.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected a '[]*ingress.Server' type but %T was returned", input)
+		return variables.List()
+	}
+
+	for _, server := range servers {
+		for _, loc := range server.Locations {
+			for name := range loc.Headers.Request.Append {
+				variables.Insert(headerVariable("http_", name))
+			}
+			for name := range loc.Headers.Response.Append {
+				variables.Insert(headerVariable("upstream_http_", name))
+			}
+		}
+	}
+
+	return variables.List()
+}
+
+// buildAppendRequestHeader returns the value of a request header sent to
+// the upstream with a value appended to the one sent by the client
+func buildAppendRequestHeader(name, value string) string {
+	variable := headerVariable("http_", name)
+	return quote(fmt.Sprintf("${%v}${append_separator_%v}%v", variable, variable, value))
+}
+
+// buildAppendResponseHeader returns the header sent to the client with a
+// value appended to the one returned by the upstream
+func buildAppendResponseHeader(name, value string) string {
+	variable := headerVariable("upstream_http_", name)
+	return quote(fmt.Sprintf("%v: ${%v}${append_separator_%v}%v", name, variable, variable, value))
+}
+
+// filterRateLimitResponses returns the rate limits of a server that replace
+// the response returned to the clients over the limit
+func filterRateLimitResponses(input interface{}) []ratelimit.Config {
+	ratelimits := []ratelimit.Config{}
+	found := sets.String{}
+
+	server, ok := input.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was returned", input)
+		return ratelimits
+	}
+
+	for _, loc := range server.Locations {
+		if loc.RateLimit.ID != "" && loc.RateLimit.Response.Enabled() && !found.Has(loc.RateLimit.ID) {
+			found.Insert(loc.RateLimit.ID)
+			ratelimits = append(ratelimits, loc.RateLimit)
+		}
+	}
+
+	return ratelimits
+}
+
 // TODO: Needs Unit Tests
 // buildRateLimitZones produces an array of limit_conn_zone in order to allow
 // rate limiting of request. Each Ingress rule could have up to three zones, one
 // for connection limit by IP address, one for limiting requests per minute, and
-// one for limiting requests per second.
+// one for limiting requests per second, plus one zone per HTTP method with its
+// own rate.
 func buildRateLimitZones(input interface{}) []string {
 	zones := sets.String{}
 
@@ -610,6 +1001,24 @@ func buildRateLimitZones(input interface{}) []string {
 					zones.Insert(zone)
 				}
 			}
+
+			for _, mz := range loc.RateLimit.MethodZones {
+				unit := "s"
+				if mz.PerMinute {
+					unit = "m"
+				}
+
+				zone := fmt.Sprintf("limit_req_zone $limit_%s_%s zone=%v:%vm rate=%vr/%v;",
+					loc.RateLimit.ID,
+					mz.Method,
+					mz.Zone.Name,
+					mz.Zone.SharedSize,
+					mz.Zone.Limit,
+					unit)
+				if !zones.Has(zone) {
+					zones.Insert(zone)
+				}
+			}
 		}
 	}
 
@@ -617,7 +1026,8 @@ func buildRateLimitZones(input interface{}) []string {
 }
 
 // buildRateLimit produces an array of limit_req to be used inside the Path of
-// Ingress rules. The order: connections by IP first, then RPS, and RPM last.
+// Ingress rules. The order: connections by IP first, then RPS, RPM, and the
+// rates of the HTTP methods last.
 func buildRateLimit(input interface{}) []string {
 	limits := []string{}
 
@@ -633,15 +1043,26 @@ func buildRateLimit(input interface{}) []string {
 		limits = append(limits, limit)
 	}
 
+	nodelay := " nodelay"
+	if loc.RateLimit.Delay {
+		nodelay = ""
+	}
+
 	if loc.RateLimit.RPS.Limit > 0 {
-		limit := fmt.Sprintf("limit_req zone=%v burst=%v nodelay;",
-			loc.RateLimit.RPS.Name, loc.RateLimit.RPS.Burst)
+		limit := fmt.Sprintf("limit_req zone=%v burst=%v%v;",
+			loc.RateLimit.RPS.Name, loc.RateLimit.RPS.Burst, nodelay)
 		limits = append(limits, limit)
 	}
 
 	if loc.RateLimit.RPM.Limit > 0 {
-		limit := fmt.Sprintf("limit_req zone=%v burst=%v nodelay;",
-			loc.RateLimit.RPM.Name, loc.RateLimit.RPM.Burst)
+		limit := fmt.Sprintf("limit_req zone=%v burst=%v%v;",
+			loc.RateLimit.RPM.Name, loc.RateLimit.RPM.Burst, nodelay)
+		limits = append(limits, limit)
+	}
+
+	for _, mz := range loc.RateLimit.MethodZones {
+		limit := fmt.Sprintf("limit_req zone=%v burst=%v%v;",
+			mz.Zone.Name, mz.Zone.Burst, nodelay)
 		limits = append(limits, limit)
 	}
 
@@ -692,6 +1113,68 @@ func isLocationAllowed(input interface{}) bool {
 	return loc.Denied == nil
 }
 
+// IsDynamicWhitelist returns true when the whitelist of the location is
+// enforced by Lua, which allows changing it without reloading NGINX. The
+// whitelist is enforced by NGINX when any of the access checks is enough
+// to allow the request or when it contains IPv6 ranges.
+func IsDynamicWhitelist(location *ingress.Location) bool {
+	if location.Satisfy == "any" {
+		return false
+	}
+
+	for _, cidr := range location.Whitelist.CIDR {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			ip, _, _ = net.ParseCIDR(cidr)
+		}
+		if ip == nil || ip.To4() == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isDynamicWhitelist(input interface{}) bool {
+	loc, ok := input.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", input)
+		return false
+	}
+
+	return IsDynamicWhitelist(loc)
+}
+
+// LocationKeys returns the keys of the settings applied by Lua of the
+// locations of a server. Each location is identified by the path of its
+// location block, including the modifier, like the template renders it.
+func LocationKeys(server *ingress.Server) map[*ingress.Location]string {
+	keys := make(map[*ingress.Location]string, len(server.Locations))
+
+	enforceRegex := enforceRegexModifier(server.Locations)
+	for _, location := range server.Locations {
+		keys[location] = locationKey(server.Hostname, buildLocation(location, enforceRegex))
+	}
+
+	return keys
+}
+
+func locationKey(hostname, path string) string {
+	return hostname + " " + path
+}
+
+// locationKeyForLua returns the key of the settings of a location applied
+// by Lua as a Lua string, from the path of the location block
+func locationKeyForLua(s interface{}, path string) string {
+	server, ok := s.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was given", s)
+		return `""`
+	}
+
+	return fmt.Sprintf("%q", locationKey(server.Hostname, path))
+}
+
 var (
 	denyPathSlugMap = map[string]string{}
 )
@@ -756,6 +1239,47 @@ func buildNextUpstream(i, r interface{}) string {
 	return strings.Join(nextUpstreamCodes, " ")
 }
 
+// buildRetryOn returns the value of the proxy_next_upstream directive for
+// the conditions of a retry policy, or the default value when the policy
+// has no conditions
+func buildRetryOn(p, d interface{}) string {
+	policy, ok := p.(retrypolicy.Config)
+	if !ok {
+		klog.Errorf("expected a 'retrypolicy.Config' type but %T was returned", p)
+		return ""
+	}
+
+	nextUpstream, ok := d.(string)
+	if !ok {
+		klog.Errorf("expected a 'string' type but %T was returned", d)
+		return ""
+	}
+
+	if len(policy.RetryOn) == 0 {
+		return nextUpstream
+	}
+
+	values := sets.NewString()
+	for _, condition := range policy.RetryOn {
+		values.Insert(retrypolicy.Conditions[condition]...)
+	}
+
+	return strings.Join(values.List(), " ")
+}
+
+// retryPolicyForLua formats the per try timeout and the retry budget of a
+// retry policy for the balancer Lua module
+func retryPolicyForLua(p interface{}) string {
+	policy, ok := p.(retrypolicy.Config)
+	if !ok {
+		klog.Errorf("expected a 'retrypolicy.Config' type but %T was given", p)
+		return "{}"
+	}
+
+	return fmt.Sprintf(`{ per_try_timeout = %d, budget_percent = %d, budget_min_retries = %d }`,
+		policy.PerTryTimeout, policy.BudgetPercent, policy.BudgetMinRetries)
+}
+
 // refer to http://nginx.org/en/docs/syntax.html
 // Nginx differentiates between size and offset
 // offset directives support gigabytes in addition
@@ -32,11 +32,17 @@ import (
 	networking "k8s.io/api/networking/v1beta1"
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authjwt"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authoidc"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/globalratelimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/headers"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/luarestywaf"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/retrypolicy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 )
@@ -196,6 +202,18 @@ func TestBuildLuaSharedDictionaries(t *testing.T) {
 	if !strings.Contains(configuration, "lua_shared_dict configuration_data 10M;\n\rlua_shared_dict certificate_data 20M;") {
 		t.Errorf("expected to include 'configuration_data' but got %s", configuration)
 	}
+	if !strings.Contains(configuration, "lua_shared_dict healthcheck 1M") {
+		t.Errorf("expected to include 'healthcheck' but got %s", configuration)
+	}
+	if !strings.Contains(configuration, "lua_shared_dict outlier_detection 1M") {
+		t.Errorf("expected to include 'outlier_detection' but got %s", configuration)
+	}
+	if !strings.Contains(configuration, "lua_shared_dict circuit_breaker 1M") {
+		t.Errorf("expected to include 'circuit_breaker' but got %s", configuration)
+	}
+	if !strings.Contains(configuration, "lua_shared_dict retry_budget 1M") {
+		t.Errorf("expected to include 'retry_budget' but got %s", configuration)
+	}
 	if strings.Contains(configuration, "waf_storage") {
 		t.Errorf("expected to not include 'waf_storage' but got %s", configuration)
 	}
@@ -205,6 +223,24 @@ func TestBuildLuaSharedDictionaries(t *testing.T) {
 	if !strings.Contains(configuration, "lua_shared_dict waf_storage") {
 		t.Errorf("expected to configure 'waf_storage', but got %s", configuration)
 	}
+	if strings.Contains(configuration, "global_throttle_cache") {
+		t.Errorf("expected to not include 'global_throttle_cache' but got %s", configuration)
+	}
+
+	servers[0].Locations[0].GlobalRateLimit = globalratelimit.Config{Limit: 10, WindowSize: 1}
+	configuration = buildLuaSharedDictionaries(cfg, servers, false)
+	if !strings.Contains(configuration, "lua_shared_dict global_throttle_cache 10M") {
+		t.Errorf("expected to configure 'global_throttle_cache', but got %s", configuration)
+	}
+	if strings.Contains(configuration, "external_auth") {
+		t.Errorf("expected to not include 'external_auth' but got %s", configuration)
+	}
+
+	servers[1].Locations[0].ExternalAuth = authreq.Config{URL: "http://auth.example.com", CircuitBreakerFailures: 5}
+	configuration = buildLuaSharedDictionaries(cfg, servers, false)
+	if !strings.Contains(configuration, "lua_shared_dict external_auth 1M") {
+		t.Errorf("expected to configure 'external_auth', but got %s", configuration)
+	}
 	// test invalid config
 	configuration = buildLuaSharedDictionaries(invalidType, servers, false)
 	if expected != actual {
@@ -463,6 +499,77 @@ func TestTemplateWithData(t *testing.T) {
 	}
 }
 
+func TestTemplateRateLimitStatusCodes(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	dat.Cfg.LimitReqStatusCode = 429
+	dat.Cfg.LimitConnStatusCode = 503
+	dat.Servers[0].Locations[0].RateLimit = ratelimit.Config{
+		ID:          "abc",
+		Connections: ratelimit.Zone{Name: "default_api_conn", Limit: 10},
+		RPS:         ratelimit.Zone{Name: "default_api_rps", Limit: 5, Burst: 1},
+		MethodZones: []ratelimit.MethodZone{
+			{Method: "POST", Zone: ratelimit.Zone{Name: "default_api_post", Limit: 1, Burst: 5, SharedSize: 5}},
+		},
+		Response: ratelimit.Response{Body: "slow down", ContentType: "text/plain"},
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	for _, expected := range []string{
+		"error_page 470 =429 @ratelimit_abc;",
+		"error_page 471 =503 @connlimit_abc;",
+		`return 429 "slow down";`,
+		`return 503 "slow down";`,
+		"map $whitelist_abc$request_method $limit_abc_POST {",
+		"limit_req_zone $limit_abc_POST zone=default_api_post:5m rate=1r/s;",
+		"limit_req zone=default_api_post burst=5 nodelay;",
+	} {
+		if !strings.Contains(string(rt), expected) {
+			t.Errorf("expected %q in the NGINX configuration", expected)
+		}
+	}
+
+	// the rejected requests must release the slots acquired in the rewrite phase
+	for _, named := range []string{"location @ratelimit_abc {", "location @connlimit_abc {"} {
+		i := strings.Index(string(rt), named)
+		if i < 0 {
+			t.Fatalf("expected %q in the NGINX configuration", named)
+		}
+		block := string(rt)[i+len(named):]
+		if end := strings.Index(block, "location "); end >= 0 {
+			block = block[:end]
+		}
+		if !strings.Contains(block, "balancer.release()") {
+			t.Errorf("expected the %q block to release the balancer", named)
+		}
+	}
+}
+
 func BenchmarkTemplateWithData(b *testing.B) {
 	pwd, _ := os.Getwd()
 	f, err := os.Open(path.Join(pwd, "../../../../test/data/config.json"))
@@ -659,6 +766,137 @@ func TestBuildNextUpstream(t *testing.T) {
 	}
 }
 
+func TestBuildRetryOn(t *testing.T) {
+	cases := map[string]struct {
+		RetryOn []string
+		Output  string
+	}{
+		"without conditions": {
+			nil,
+			"error timeout",
+		},
+		"conditions": {
+			[]string{"5xx", "connect-failure", "reset", "503"},
+			"error http_500 http_502 http_503 http_504",
+		},
+		"non idempotent requests": {
+			[]string{"429", "non-idempotent", "timeout"},
+			"http_429 non_idempotent timeout",
+		},
+	}
+
+	for k, tc := range cases {
+		retryOn := buildRetryOn(retrypolicy.Config{RetryOn: tc.RetryOn}, "error timeout")
+		if retryOn != tc.Output {
+			t.Errorf("%s: expected '%v' but returned '%v'", k, tc.Output, retryOn)
+		}
+	}
+
+	if actual := buildRetryOn(&ingress.Ingress{}, "error timeout"); actual != "" {
+		t.Errorf("expected '' but returned '%v'", actual)
+	}
+}
+
+func TestRetryPolicyForLua(t *testing.T) {
+	policy := retrypolicy.Config{PerTryTimeout: 2, BudgetPercent: 20, BudgetMinRetries: 3}
+	expected := "{ per_try_timeout = 2, budget_percent = 20, budget_min_retries = 3 }"
+	if actual := retryPolicyForLua(policy); actual != expected {
+		t.Errorf("expected '%v' but returned '%v'", expected, actual)
+	}
+
+	if actual := retryPolicyForLua(&ingress.Ingress{}); actual != "{}" {
+		t.Errorf("expected '{}' but returned '%v'", actual)
+	}
+}
+
+func TestIsDynamicWhitelist(t *testing.T) {
+	cases := map[string]struct {
+		Location *ingress.Location
+		Output   bool
+	}{
+		"without whitelist": {
+			&ingress.Location{},
+			true,
+		},
+		"IPv4 ranges and addresses": {
+			&ingress.Location{Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8", "192.168.1.1"}}},
+			true,
+		},
+		"IPv6 ranges": {
+			&ingress.Location{Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8", "2001:db8::/32"}}},
+			false,
+		},
+		"satisfy any": {
+			&ingress.Location{Satisfy: "any", Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/8"}}},
+			false,
+		},
+	}
+
+	for k, tc := range cases {
+		if actual := isDynamicWhitelist(tc.Location); actual != tc.Output {
+			t.Errorf("%s: expected '%v' but returned '%v'", k, tc.Output, actual)
+		}
+	}
+
+	if isDynamicWhitelist(&ingress.Ingress{}) {
+		t.Errorf("expected false for an invalid type")
+	}
+}
+
+func TestLocationKeyForLua(t *testing.T) {
+	server := &ingress.Server{Hostname: "example.com"}
+
+	expected := `"example.com /api"`
+	if actual := locationKeyForLua(server, "/api"); actual != expected {
+		t.Errorf("expected '%v' but returned '%v'", expected, actual)
+	}
+
+	if actual := locationKeyForLua(&ingress.Location{}, "/api"); actual != `""` {
+		t.Errorf("expected '\"\"' but returned '%v'", actual)
+	}
+}
+
+func TestLocationKeys(t *testing.T) {
+	prefix := &ingress.Location{Path: "/api"}
+	server := &ingress.Server{
+		Hostname:  "example.com",
+		Locations: []*ingress.Location{prefix, {Path: "/"}},
+	}
+
+	keys := LocationKeys(server)
+	if keys[prefix] != "example.com /api" || keys[server.Locations[1]] != "example.com /" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	// the same path is rendered with a regular expression modifier when
+	// another location of the server uses a regular expression
+	regex := &ingress.Location{Path: "/api"}
+	rewrite := &ingress.Location{Path: "/v1(/|$)(.*)"}
+	rewrite.Rewrite.UseRegex = true
+	regexServer := &ingress.Server{
+		Hostname:  "example.com",
+		Locations: []*ingress.Location{regex, rewrite},
+	}
+
+	regexKeys := LocationKeys(regexServer)
+	if regexKeys[regex] != `example.com ~* "^/api"` {
+		t.Errorf("unexpected key %v", regexKeys[regex])
+	}
+	if regexKeys[regex] == keys[prefix] {
+		t.Errorf("expected different keys for the prefix and the regular expression locations of %v", prefix.Path)
+	}
+	if regexKeys[regex] == regexKeys[rewrite] {
+		t.Errorf("expected different keys for the locations of the same server")
+	}
+
+	for location, key := range regexKeys {
+		expected := fmt.Sprintf("%q", key)
+		if actual := locationKeyForLua(regexServer, buildLocation(location, true)); actual != expected {
+			t.Errorf("expected the template key %v but returned %v", expected, actual)
+		}
+	}
+}
+
 func TestBuildRateLimit(t *testing.T) {
 	invalidType := &ingress.Ingress{}
 	expected := []string{}
@@ -718,6 +956,71 @@ func TestBuildRateLimitZones(t *testing.T) {
 	}
 }
 
+func TestBuildRateLimitMethodZones(t *testing.T) {
+	loc := &ingress.Location{}
+	loc.RateLimit.ID = "abc"
+	loc.RateLimit.MethodZones = []ratelimit.MethodZone{
+		{Method: "POST", Zone: ratelimit.Zone{Name: "default_foo_post", Limit: 10, Burst: 50, SharedSize: 5}},
+		{Method: "PUT", Zone: ratelimit.Zone{Name: "default_foo_put", Limit: 100, Burst: 500, SharedSize: 5}, PerMinute: true},
+	}
+
+	expected := []string{
+		"limit_req zone=default_foo_post burst=50 nodelay;",
+		"limit_req zone=default_foo_put burst=500 nodelay;",
+	}
+	actual := buildRateLimit(loc)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	expected = []string{
+		"limit_req_zone $limit_abc_POST zone=default_foo_post:5m rate=10r/s;",
+		"limit_req_zone $limit_abc_PUT zone=default_foo_put:5m rate=100r/m;",
+	}
+	actual = buildRateLimitZones([]*ingress.Server{{Locations: []*ingress.Location{loc}}})
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+}
+
+func TestBuildRateLimitDelay(t *testing.T) {
+	loc := &ingress.Location{}
+	loc.RateLimit.RPS.Name = "rps"
+	loc.RateLimit.RPS.Limit = 10
+	loc.RateLimit.RPS.Burst = 0
+	loc.RateLimit.Delay = true
+
+	expected := []string{"limit_req zone=rps burst=0;"}
+	actual := buildRateLimit(loc)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+}
+
+func TestFilterRateLimitResponses(t *testing.T) {
+	expected := []ratelimit.Config{}
+	actual := filterRateLimitResponses(&ingress.Ingress{})
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	withResponse := ratelimit.Config{ID: "a", Response: ratelimit.Response{Headers: true}}
+	server := &ingress.Server{
+		Locations: []*ingress.Location{
+			{Path: "/a", RateLimit: withResponse},
+			{Path: "/b", RateLimit: withResponse},
+			{Path: "/c", RateLimit: ratelimit.Config{ID: "c"}},
+			{Path: "/d"},
+		},
+	}
+
+	expected = []ratelimit.Config{withResponse}
+	actual = filterRateLimitResponses(server)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+}
+
 // TODO: Needs more tests
 func TestFilterRateLimits(t *testing.T) {
 	invalidType := &ingress.Ingress{}
@@ -729,6 +1032,51 @@ func TestFilterRateLimits(t *testing.T) {
 	}
 }
 
+func TestFilterAppendedHeaders(t *testing.T) {
+	invalidType := &ingress.Ingress{}
+	expected := []string{}
+	actual := filterAppendedHeaders(invalidType)
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	servers := []*ingress.Server{
+		{
+			Locations: []*ingress.Location{
+				{Headers: headers.Config{Request: headers.Operations{Append: map[string]string{"Via": "ingress"}}}},
+				{Headers: headers.Config{Response: headers.Operations{Append: map[string]string{"Cache-Control": "no-transform"}}}},
+			},
+		},
+		{
+			Locations: []*ingress.Location{
+				{Headers: headers.Config{Request: headers.Operations{Append: map[string]string{"via": "proxy"}}}},
+			},
+		},
+	}
+
+	expected = []string{"http_via", "upstream_http_cache_control"}
+	actual = filterAppendedHeaders(servers)
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+}
+
+func TestBuildAppendHeader(t *testing.T) {
+	expected := `"${http_x_tenant}${append_separator_http_x_tenant}acme"`
+	actual := buildAppendRequestHeader("X-Tenant", "acme")
+	if expected != actual {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	expected = `"Cache-Control: ${upstream_http_cache_control}${append_separator_upstream_http_cache_control}no-transform"`
+	actual = buildAppendResponseHeader("Cache-Control", "no-transform")
+	if expected != actual {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+}
+
 func TestBuildAuthSignURL(t *testing.T) {
 	invalidType := &ingress.Ingress{}
 	expected := ""
@@ -1257,3 +1605,166 @@ func TestShouldLoadModSecurityModule(t *testing.T) {
 		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
 	}
 }
+
+func TestGlobalThrottleForLua(t *testing.T) {
+	if actual := globalThrottleForLua(globalratelimit.Config{}); actual != "nil" {
+		t.Errorf("expected nil but returned %v", actual)
+	}
+
+	actual := globalThrottleForLua(globalratelimit.Config{
+		Namespace:    "default_foo",
+		Limit:        10,
+		WindowSize:   60,
+		Key:          "$http_x_api_key",
+		Algorithm:    globalratelimit.FixedWindow,
+		IgnoredCIDRs: []string{"10.0.0.0/8", "127.0.0.1"},
+	})
+	for _, expected := range []string{
+		`namespace = "default_foo", limit = 10, window_size = 60`,
+		`key = "$http_x_api_key", algorithm = "fixed-window"`,
+		`ignored_cidrs = { "10.0.0.0/8", "127.0.0.1" }`,
+		`authenticated = false`,
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected %v to contain %v", actual, expected)
+		}
+	}
+
+	actual = globalThrottleForLua(globalratelimit.Config{Limit: 10, WindowSize: 60, Key: "$jwt_claim_sub"})
+	if !strings.Contains(actual, `authenticated = true`) {
+		t.Errorf("expected the claims of the token to be used once authenticated but returned %v", actual)
+	}
+
+	cfg := config.NewDefault()
+	cfg.GlobalRateLimitStore = "redis"
+	cfg.GlobalRateLimitHost = "redis.default.svc"
+	actual = globalThrottleStoreForLua(cfg)
+	if !strings.Contains(actual, `store = "redis", host = "redis.default.svc", port = 6379`) {
+		t.Errorf("expected the default redis port but returned %v", actual)
+	}
+}
+
+func TestJWTAuthConfigForLua(t *testing.T) {
+	actual := jwtAuthConfigForLua(&ingress.Location{
+		JWTAuth: authjwt.Config{
+			KeySet:         "secret/default/jwt-keys",
+			Issuer:         "https://auth.example.com",
+			Audiences:      []string{"api", "web"},
+			RequiredClaims: map[string]string{"role": "admin", "email": ""},
+			ClockSkew:      30,
+			ClaimHeaders:   map[string]string{"sub": "X-User"},
+		},
+	})
+	for _, expected := range []string{
+		`key_set = "secret/default/jwt-keys"`,
+		`issuer = "https://auth.example.com"`,
+		`audiences = { "api", "web" }`,
+		`required_claims = { ["email"] = "", ["role"] = "admin" }`,
+		`clock_skew = 30`,
+		`claim_headers = { ["sub"] = "X-User" }`,
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected %v to contain %v", actual, expected)
+		}
+	}
+
+	if actual := jwtAuthConfigForLua("invalid"); actual != "{}" {
+		t.Errorf("expected an empty table but returned %v", actual)
+	}
+}
+
+func TestExternalAuthConfigForLua(t *testing.T) {
+	expected := `{ url = "http://auth.example.com/validate", circuit_breaker_failures = 5, circuit_breaker_duration = 30 }`
+
+	actual := externalAuthConfigForLua(authreq.Config{
+		URL:                    "http://auth.example.com/validate",
+		CircuitBreakerFailures: 5,
+		CircuitBreakerDuration: 30,
+	})
+	if actual != expected {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	actual = externalAuthConfigForLua(config.GlobalExternalAuth{
+		URL:                    "http://auth.example.com/validate",
+		CircuitBreakerFailures: 5,
+		CircuitBreakerDuration: 30,
+	})
+	if actual != expected {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	actual = externalAuthConfigForLua("invalid")
+	if actual != "{}" {
+		t.Errorf("Expected '{}' but returned '%v'", actual)
+	}
+}
+
+func TestOIDCAuthConfigForLua(t *testing.T) {
+	actual := oidcAuthConfigForLua(authoidc.Config{
+		Issuer:          "https://auth.example.com",
+		Secret:          "default/oidc-client",
+		Scopes:          []string{"openid", "email"},
+		RedirectPath:    "/oauth2/callback",
+		LogoutPath:      "/oauth2/logout",
+		GroupsClaim:     "groups",
+		AllowedGroups:   []string{"admins"},
+		ClaimHeaders:    map[string]string{"email": "X-Email"},
+		SessionLifetime: 3600,
+		CookieName:      "_oidc_01234567",
+	})
+	for _, expected := range []string{
+		`issuer = "https://auth.example.com"`,
+		`secret = "default/oidc-client"`,
+		`scopes = { "openid", "email" }`,
+		`redirect_path = "/oauth2/callback"`,
+		`post_logout_redirect_uri = ""`,
+		`allowed_groups = { "admins" }`,
+		`required_claims = {  }`,
+		`claim_headers = { ["email"] = "X-Email" }`,
+		`session_lifetime = 3600`,
+		`cookie_name = "_oidc_01234567"`,
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected %v to contain %v", actual, expected)
+		}
+	}
+
+	if actual := oidcAuthConfigForLua("invalid"); actual != "{}" {
+		t.Errorf("expected an empty table but returned %v", actual)
+	}
+}
+
+func TestFilterOIDCAuths(t *testing.T) {
+	expected := []authoidc.Config{}
+	actual := filterOIDCAuths(&ingress.Ingress{})
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	first := authoidc.Config{Issuer: "https://a.example.com", RedirectPath: "/oauth2/callback", LogoutPath: "/oauth2/logout"}
+	samePath := authoidc.Config{Issuer: "https://b.example.com", RedirectPath: "/other/callback", LogoutPath: "/oauth2/logout"}
+	other := authoidc.Config{Issuer: "https://b.example.com", RedirectPath: "/b/callback", LogoutPath: "/b/logout"}
+	server := &ingress.Server{
+		Locations: []*ingress.Location{
+			{Path: "/a", OIDCAuth: first},
+			{Path: "/b", OIDCAuth: first},
+			{Path: "/c", OIDCAuth: samePath},
+			{Path: "/d", OIDCAuth: other},
+			{Path: "/e"},
+		},
+	}
+
+	expected = []authoidc.Config{first, other}
+	actual = filterOIDCAuths(server)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	if shouldConfigureOIDC([]*ingress.Server{{Locations: []*ingress.Location{{Path: "/e"}}}}) {
+		t.Errorf("expected OpenID Connect to be disabled")
+	}
+	if !shouldConfigureOIDC([]*ingress.Server{server}) {
+		t.Errorf("expected OpenID Connect to be enabled")
+	}
+}
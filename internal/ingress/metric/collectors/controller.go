@@ -42,10 +42,15 @@ type Controller struct {
 
 	reloadOperation             *prometheus.CounterVec
 	reloadOperationErrors       *prometheus.CounterVec
+	reloadDuration              prometheus.Histogram
+	reloadReasons               *prometheus.CounterVec
+	reloadChanges               *prometheus.CounterVec
 	checkIngressOperation       *prometheus.CounterVec
 	checkIngressOperationErrors *prometheus.CounterVec
 	sslExpireTime               *prometheus.GaugeVec
 
+	drainingConnections prometheus.Gauge
+
 	constLabels prometheus.Labels
 	labels      prometheus.Labels
 
@@ -91,6 +96,13 @@ func NewController(pod, namespace, class string) *Controller {
 				Help:        "Timestamp of the last successful configuration reload.",
 				ConstLabels: constLabels,
 			}),
+		drainingConnections: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "shutdown_draining_connections",
+				Help:        "Number of client connections NGINX is waiting for to complete during the shutdown",
+				ConstLabels: constLabels,
+			}),
 		reloadOperation: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: PrometheusNamespace,
@@ -107,6 +119,32 @@ func NewController(pod, namespace, class string) *Controller {
 			},
 			operation,
 		),
+		reloadDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "reload_duration_seconds",
+				Help:        "Time elapsed between the reload signal and the new NGINX workers replacing the previous ones",
+				Buckets:     []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+				ConstLabels: constLabels,
+			}),
+		reloadReasons: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "reload_reasons_total",
+				Help:        "Cumulative number of reloads caused by changes of each kind of object",
+				ConstLabels: constLabels,
+			},
+			[]string{"reason"},
+		),
+		reloadChanges: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "reload_changes_total",
+				Help:        "Cumulative number of configuration changes requiring a reload, by category",
+				ConstLabels: constLabels,
+			},
+			[]string{"category"},
+		),
 		checkIngressOperationErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: PrometheusNamespace,
@@ -156,6 +194,34 @@ func (cm *Controller) IncReloadErrorCount() {
 	cm.reloadOperationErrors.With(cm.constLabels).Inc()
 }
 
+// ObserveReloadDuration records the time elapsed between the reload signal
+// and the new NGINX workers replacing the previous ones
+func (cm *Controller) ObserveReloadDuration(duration time.Duration) {
+	cm.reloadDuration.Observe(duration.Seconds())
+}
+
+// IncReloadReasons increments the reload counter of each reason, the kinds
+// of the objects that caused the reload
+func (cm *Controller) IncReloadReasons(reasons []string) {
+	for _, reason := range reasons {
+		cm.reloadReasons.WithLabelValues(reason).Inc()
+	}
+}
+
+// IncReloadChanges increments the counter of the configuration changes
+// requiring a reload of each category
+func (cm *Controller) IncReloadChanges(categories []string) {
+	for _, category := range categories {
+		cm.reloadChanges.WithLabelValues(category).Inc()
+	}
+}
+
+// SetDrainingConnections sets the number of client connections NGINX is
+// waiting for to complete during the shutdown
+func (cm *Controller) SetDrainingConnections(connections int) {
+	cm.drainingConnections.Set(float64(connections))
+}
+
 // OnStartedLeading indicates the pod was elected as the leader
 func (cm *Controller) OnStartedLeading(electionID string) {
 	cm.leaderElection.WithLabelValues(electionID).Set(1.0)
@@ -206,10 +272,14 @@ func (cm Controller) Describe(ch chan<- *prometheus.Desc) {
 	cm.configSuccessTime.Describe(ch)
 	cm.reloadOperation.Describe(ch)
 	cm.reloadOperationErrors.Describe(ch)
+	cm.reloadDuration.Describe(ch)
+	cm.reloadReasons.Describe(ch)
+	cm.reloadChanges.Describe(ch)
 	cm.checkIngressOperation.Describe(ch)
 	cm.checkIngressOperationErrors.Describe(ch)
 	cm.sslExpireTime.Describe(ch)
 	cm.leaderElection.Describe(ch)
+	cm.drainingConnections.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -219,10 +289,14 @@ func (cm Controller) Collect(ch chan<- prometheus.Metric) {
 	cm.configSuccessTime.Collect(ch)
 	cm.reloadOperation.Collect(ch)
 	cm.reloadOperationErrors.Collect(ch)
+	cm.reloadDuration.Collect(ch)
+	cm.reloadReasons.Collect(ch)
+	cm.reloadChanges.Collect(ch)
 	cm.checkIngressOperation.Collect(ch)
 	cm.checkIngressOperationErrors.Collect(ch)
 	cm.sslExpireTime.Collect(ch)
 	cm.leaderElection.Collect(ch)
+	cm.drainingConnections.Collect(ch)
 }
 
 // SetSSLExpireTime sets the expiration time of SSL Certificates
@@ -70,6 +70,70 @@ func TestControllerCounters(t *testing.T) {
 			`,
 			metrics: []string{"nginx_ingress_controller_errors"},
 		},
+		{
+			name: "should count the connections during the shutdown",
+			test: func(cm *Controller) {
+				cm.SetDrainingConnections(5)
+				cm.SetDrainingConnections(2)
+			},
+			want: `
+				# HELP nginx_ingress_controller_shutdown_draining_connections Number of client connections NGINX is waiting for to complete during the shutdown
+				# TYPE nginx_ingress_controller_shutdown_draining_connections gauge
+				nginx_ingress_controller_shutdown_draining_connections{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 2
+			`,
+			metrics: []string{"nginx_ingress_controller_shutdown_draining_connections"},
+		},
+		{
+			name: "should observe the reload duration",
+			test: func(cm *Controller) {
+				cm.ObserveReloadDuration(300 * time.Millisecond)
+				cm.ObserveReloadDuration(2 * time.Second)
+			},
+			want: `
+				# HELP nginx_ingress_controller_reload_duration_seconds Time elapsed between the reload signal and the new NGINX workers replacing the previous ones
+				# TYPE nginx_ingress_controller_reload_duration_seconds histogram
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="0.1"} 0
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="0.25"} 0
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="0.5"} 1
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="1"} 1
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="2.5"} 2
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="5"} 2
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="10"} 2
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="30"} 2
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="60"} 2
+				nginx_ingress_controller_reload_duration_seconds_bucket{controller_class="nginx",controller_namespace="default",controller_pod="pod",le="+Inf"} 2
+				nginx_ingress_controller_reload_duration_seconds_sum{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 2.3
+				nginx_ingress_controller_reload_duration_seconds_count{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 2
+			`,
+			metrics: []string{"nginx_ingress_controller_reload_duration_seconds"},
+		},
+		{
+			name: "should count the reasons of the reloads",
+			test: func(cm *Controller) {
+				cm.IncReloadReasons([]string{"Ingress", "Secret"})
+				cm.IncReloadReasons([]string{"Ingress"})
+			},
+			want: `
+				# HELP nginx_ingress_controller_reload_reasons_total Cumulative number of reloads caused by changes of each kind of object
+				# TYPE nginx_ingress_controller_reload_reasons_total counter
+				nginx_ingress_controller_reload_reasons_total{controller_class="nginx",controller_namespace="default",controller_pod="pod",reason="Ingress"} 2
+				nginx_ingress_controller_reload_reasons_total{controller_class="nginx",controller_namespace="default",controller_pod="pod",reason="Secret"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_reload_reasons_total"},
+		},
+		{
+			name: "should count the configuration changes",
+			test: func(cm *Controller) {
+				cm.IncReloadChanges([]string{"location-changed", "location-changed", "server-added"})
+			},
+			want: `
+				# HELP nginx_ingress_controller_reload_changes_total Cumulative number of configuration changes requiring a reload, by category
+				# TYPE nginx_ingress_controller_reload_changes_total counter
+				nginx_ingress_controller_reload_changes_total{category="location-changed",controller_class="nginx",controller_namespace="default",controller_pod="pod"} 2
+				nginx_ingress_controller_reload_changes_total{category="server-added",controller_class="nginx",controller_namespace="default",controller_pod="pod"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_reload_changes_total"},
+		},
 		{
 			name: "should set SSL certificates metrics",
 			test: func(cm *Controller) {
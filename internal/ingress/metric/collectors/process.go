@@ -19,12 +19,15 @@ package collectors
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"k8s.io/klog"
 
 	common "github.com/ncabatoff/process-exporter"
 	"github.com/ncabatoff/process-exporter/proc"
 	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/ingress-nginx/internal/ingress/controller/process"
 )
 
 type scrapeRequest struct {
@@ -67,6 +70,11 @@ type namedProcessData struct {
 	memResidentbytes *prometheus.Desc
 	memVirtualbytes  *prometheus.Desc
 	startTime        *prometheus.Desc
+
+	oldGenerations      *prometheus.Desc
+	oldestGenerationAge *prometheus.Desc
+	workerConnections   *prometheus.Desc
+	workerMemory        *prometheus.Desc
 }
 
 type namedProcess struct {
@@ -154,6 +162,26 @@ func NewNGINXProcess(pod, namespace, ingressClass string) (NGINXProcessCollector
 			prometheus.BuildFQName(PrometheusNamespace, subSystem, "oldest_start_time_seconds"),
 			"start time in seconds since 1970/01/01",
 			nil, constLabels),
+
+		oldGenerations: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, subSystem, "old_worker_generations"),
+			"number of generations of workers replaced by a reload that are still running",
+			nil, constLabels),
+
+		oldestGenerationAge: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, subSystem, "oldest_worker_generation_age_seconds"),
+			"age in seconds of the oldest generation of workers replaced by a reload",
+			nil, constLabels),
+
+		workerConnections: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, subSystem, "worker_connections"),
+			"number of established connections of the current and the old workers",
+			[]string{"generation"}, constLabels),
+
+		workerMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, subSystem, "worker_resident_memory_bytes"),
+			"number of bytes of memory in use by the current and the old workers",
+			[]string{"generation"}, constLabels),
 	}
 
 	return p, nil
@@ -168,6 +196,10 @@ func (p namedProcess) Describe(ch chan<- *prometheus.Desc) {
 	ch <- p.data.memResidentbytes
 	ch <- p.data.memVirtualbytes
 	ch <- p.data.startTime
+	ch <- p.data.oldGenerations
+	ch <- p.data.oldestGenerationAge
+	ch <- p.data.workerConnections
+	ch <- p.data.workerMemory
 }
 
 // Collect implements prometheus.Collector.
@@ -212,4 +244,46 @@ func (p namedProcess) scrape(ch chan<- prometheus.Metric) {
 		ch <- prometheus.MustNewConstMetric(p.data.writeBytes,
 			prometheus.CounterValue, float64(gcounts.WriteBytes))
 	}
+
+	generations, err := process.WorkerGenerations()
+	if err != nil {
+		klog.Warningf("unexpected error obtaining nginx worker generations: %v", err)
+		return
+	}
+
+	p.scrapeGenerations(ch, generations, time.Now())
+}
+
+// scrapeGenerations reports the old generations of workers, which keep
+// running after a reload until their connections complete
+func (p namedProcess) scrapeGenerations(ch chan<- prometheus.Metric, generations []process.WorkerGeneration, now time.Time) {
+	old := 0
+	oldestAge := 0.0
+	connections := map[string]int{"current": 0, "old": 0}
+	memory := map[string]uint64{"current": 0, "old": 0}
+
+	for _, g := range generations {
+		generation := "current"
+		if g.ShuttingDown {
+			generation = "old"
+			old++
+			if age := now.Sub(g.StartTime).Seconds(); age > oldestAge {
+				oldestAge = age
+			}
+		}
+
+		connections[generation] += g.Connections
+		memory[generation] += g.ResidentMemory
+	}
+
+	ch <- prometheus.MustNewConstMetric(p.data.oldGenerations,
+		prometheus.GaugeValue, float64(old))
+	ch <- prometheus.MustNewConstMetric(p.data.oldestGenerationAge,
+		prometheus.GaugeValue, oldestAge)
+	for _, generation := range []string{"current", "old"} {
+		ch <- prometheus.MustNewConstMetric(p.data.workerConnections,
+			prometheus.GaugeValue, float64(connections[generation]), generation)
+		ch <- prometheus.MustNewConstMetric(p.data.workerMemory,
+			prometheus.GaugeValue, float64(memory[generation]), generation)
+	}
 }
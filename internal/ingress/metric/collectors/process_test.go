@@ -20,8 +20,11 @@ import (
 	"os/exec"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/ingress-nginx/internal/ingress/controller/process"
 )
 
 func TestProcessCollector(t *testing.T) {
@@ -94,3 +97,66 @@ func TestProcessCollector(t *testing.T) {
 		})
 	}
 }
+
+// generationsCollector collects the metrics of fixed worker generations
+type generationsCollector struct {
+	namedProcess
+
+	generations []process.WorkerGeneration
+	now         time.Time
+}
+
+func (c generationsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.data.oldGenerations
+	ch <- c.data.oldestGenerationAge
+	ch <- c.data.workerConnections
+	ch <- c.data.workerMemory
+}
+
+func (c generationsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.scrapeGenerations(ch, c.generations, c.now)
+}
+
+func TestWorkerGenerationMetrics(t *testing.T) {
+	cm, err := NewNGINXProcess("pod", "default", "nginx")
+	if err != nil {
+		t.Fatalf("unexpected error creating nginx process collector: %v", err)
+	}
+
+	now := time.Unix(1000, 0)
+	c := generationsCollector{
+		namedProcess: cm.(namedProcess),
+		generations: []process.WorkerGeneration{
+			{StartTime: now.Add(-10 * time.Minute), ShuttingDown: true, Workers: []int{10, 11}, Connections: 3, ResidentMemory: 2048},
+			{StartTime: now.Add(-5 * time.Minute), ShuttingDown: true, Workers: []int{20}, Connections: 1, ResidentMemory: 1024},
+			{StartTime: now.Add(-time.Minute), Workers: []int{30, 31}, Connections: 7, ResidentMemory: 4096},
+		},
+		now: now,
+	}
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("registering collector failed: %s", err)
+	}
+
+	want := `
+		# HELP nginx_ingress_controller_nginx_process_old_worker_generations number of generations of workers replaced by a reload that are still running
+		# TYPE nginx_ingress_controller_nginx_process_old_worker_generations gauge
+		nginx_ingress_controller_nginx_process_old_worker_generations{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 2
+		# HELP nginx_ingress_controller_nginx_process_oldest_worker_generation_age_seconds age in seconds of the oldest generation of workers replaced by a reload
+		# TYPE nginx_ingress_controller_nginx_process_oldest_worker_generation_age_seconds gauge
+		nginx_ingress_controller_nginx_process_oldest_worker_generation_age_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 600
+		# HELP nginx_ingress_controller_nginx_process_worker_connections number of established connections of the current and the old workers
+		# TYPE nginx_ingress_controller_nginx_process_worker_connections gauge
+		nginx_ingress_controller_nginx_process_worker_connections{controller_class="nginx",controller_namespace="default",controller_pod="pod",generation="current"} 7
+		nginx_ingress_controller_nginx_process_worker_connections{controller_class="nginx",controller_namespace="default",controller_pod="pod",generation="old"} 4
+		# HELP nginx_ingress_controller_nginx_process_worker_resident_memory_bytes number of bytes of memory in use by the current and the old workers
+		# TYPE nginx_ingress_controller_nginx_process_worker_resident_memory_bytes gauge
+		nginx_ingress_controller_nginx_process_worker_resident_memory_bytes{controller_class="nginx",controller_namespace="default",controller_pod="pod",generation="current"} 4096
+		nginx_ingress_controller_nginx_process_worker_resident_memory_bytes{controller_class="nginx",controller_namespace="default",controller_pod="pod",generation="old"} 3072
+	`
+
+	if err := GatherAndCompare(c, want, nil, reg); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
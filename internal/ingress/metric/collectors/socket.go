@@ -34,6 +34,19 @@ type upstream struct {
 	ResponseLength float64 `json:"upstreamResponseLength"`
 	ResponseTime   float64 `json:"upstreamResponseTime"`
 	//Status         string  `json:"upstreamStatus"`
+	Retries              float64 `json:"upstreamRetries"`
+	RetryBudgetExhausted bool    `json:"retryBudgetExhausted"`
+	// Addr is the address of the endpoint that served the last attempt
+	Addr string `json:"upstreamAddr"`
+}
+
+// externalAuth contains the result of the authentication of a request
+// with an external service
+type externalAuth struct {
+	AuthURL          string  `json:"authURL"`
+	AuthResult       string  `json:"authResult"`
+	AuthResponseTime float64 `json:"authResponseTime"`
+	AuthCacheStatus  string  `json:"authCacheStatus"`
 }
 
 type socketData struct {
@@ -49,6 +62,8 @@ type socketData struct {
 
 	upstream
 
+	externalAuth
+
 	Namespace string `json:"namespace"`
 	Ingress   string `json:"ingress"`
 	Service   string `json:"service"`
@@ -65,8 +80,17 @@ type SocketCollector struct {
 	responseTime   *prometheus.HistogramVec
 	responseLength *prometheus.HistogramVec
 
+	endpointResponseTime *prometheus.HistogramVec
+
 	upstreamLatency *prometheus.SummaryVec
 
+	upstreamRetries      *prometheus.CounterVec
+	retryBudgetExhausted *prometheus.CounterVec
+
+	authRequests      *prometheus.CounterVec
+	authResponseTime  *prometheus.HistogramVec
+	authCacheRequests *prometheus.CounterVec
+
 	bytesSent *prometheus.HistogramVec
 
 	requests *prometheus.CounterVec
@@ -78,6 +102,8 @@ type SocketCollector struct {
 	hosts sets.String
 
 	metricsPerHost bool
+
+	metricsPerUpstreamEndpoint bool
 }
 
 var (
@@ -95,7 +121,7 @@ var (
 
 // NewSocketCollector creates a new SocketCollector instance using
 // the ingress watch namespace and class used by the controller
-func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*SocketCollector, error) {
+func NewSocketCollector(pod, namespace, class string, metricsPerHost, metricsPerUpstreamEndpoint bool) (*SocketCollector, error) {
 	socket := "/tmp/prometheus-nginx.socket"
 	listener, err := net.Listen("unix", socket)
 	if err != nil {
@@ -123,6 +149,8 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 
 		metricsPerHost: metricsPerHost,
 
+		metricsPerUpstreamEndpoint: metricsPerUpstreamEndpoint,
+
 		responseTime: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:        "response_duration_seconds",
@@ -142,6 +170,16 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 			requestTags,
 		),
 
+		endpointResponseTime: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "upstream_endpoint_response_duration_seconds",
+				Help:        "The time spent on receiving the response from each endpoint of the upstream",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"status", "namespace", "ingress", "service", "endpoint"},
+		),
+
 		requestTime: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:        "request_duration_seconds",
@@ -192,6 +230,56 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 			},
 			[]string{"ingress", "namespace", "service"},
 		),
+
+		upstreamRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "upstream_retries",
+				Help:        "The number of retries of the requests sent to the upstream",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"ingress", "namespace", "service"},
+		),
+
+		retryBudgetExhausted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "retry_budget_exhausted",
+				Help:        "The number of retries not sent to the upstream because the retry budget was exhausted",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"ingress", "namespace", "service"},
+		),
+
+		authRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "auth_requests",
+				Help:        "The number of requests authenticated by an external service, by result (allowed, denied, error or unavailable)",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"ingress", "namespace", "url", "result"},
+		),
+
+		authResponseTime: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "auth_response_duration_seconds",
+				Help:        "The time spent on receiving the response from the external authentication service",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"ingress", "namespace", "url"},
+		),
+
+		authCacheRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "auth_cache_requests",
+				Help:        "The number of responses of the external authentication service by cache status",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"ingress", "namespace", "url", "cache_status"},
+		),
 	}
 
 	sc.metricMapping = map[string]interface{}{
@@ -201,9 +289,13 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 		prometheus.BuildFQName(PrometheusNamespace, "", "response_duration_seconds"): sc.responseTime,
 		prometheus.BuildFQName(PrometheusNamespace, "", "response_size"):             sc.responseLength,
 
+		prometheus.BuildFQName(PrometheusNamespace, "", "upstream_endpoint_response_duration_seconds"): sc.endpointResponseTime,
+
 		prometheus.BuildFQName(PrometheusNamespace, "", "bytes_sent"): sc.bytesSent,
 
 		prometheus.BuildFQName(PrometheusNamespace, "", "ingress_upstream_latency_seconds"): sc.upstreamLatency,
+
+		prometheus.BuildFQName(PrometheusNamespace, "", "auth_response_duration_seconds"): sc.authResponseTime,
 	}
 
 	return sc, nil
@@ -293,6 +385,15 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 			} else {
 				responseTimeMetric.Observe(stats.ResponseTime)
 			}
+
+			if sc.metricsPerUpstreamEndpoint && stats.Addr != "" {
+				endpointMetric, err := sc.endpointResponseTime.GetMetricWith(mergeLabels(collectorLabels, "endpoint", stats.Addr))
+				if err != nil {
+					klog.Errorf("Error fetching upstream endpoint response time metric: %v", err)
+				} else {
+					endpointMetric.Observe(stats.ResponseTime)
+				}
+			}
 		}
 
 		if stats.ResponseLength != -1 {
@@ -310,9 +411,75 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 				responseSizeMetric.Observe(stats.ResponseLength)
 			}
 		}
+
+		if stats.Retries > 0 {
+			retriesMetric, err := sc.upstreamRetries.GetMetricWith(latencyLabels)
+			if err != nil {
+				klog.Errorf("Error fetching upstream retries metric: %v", err)
+			} else {
+				retriesMetric.Add(stats.Retries)
+			}
+		}
+
+		if stats.RetryBudgetExhausted {
+			exhaustedMetric, err := sc.retryBudgetExhausted.GetMetricWith(latencyLabels)
+			if err != nil {
+				klog.Errorf("Error fetching retry budget exhausted metric: %v", err)
+			} else {
+				exhaustedMetric.Inc()
+			}
+		}
+
+		if stats.AuthURL != "" {
+			sc.handleExternalAuth(stats)
+		}
 	}
 }
 
+func (sc *SocketCollector) handleExternalAuth(stats socketData) {
+	authLabels := prometheus.Labels{
+		"namespace": stats.Namespace,
+		"ingress":   stats.Ingress,
+		"url":       stats.AuthURL,
+	}
+
+	requestsMetric, err := sc.authRequests.GetMetricWith(mergeLabels(authLabels, "result", stats.AuthResult))
+	if err != nil {
+		klog.Errorf("Error fetching auth requests metric: %v", err)
+	} else {
+		requestsMetric.Inc()
+	}
+
+	if stats.AuthResponseTime != -1 {
+		responseTimeMetric, err := sc.authResponseTime.GetMetricWith(authLabels)
+		if err != nil {
+			klog.Errorf("Error fetching auth response time metric: %v", err)
+		} else {
+			responseTimeMetric.Observe(stats.AuthResponseTime)
+		}
+	}
+
+	if stats.AuthCacheStatus != "" {
+		cacheMetric, err := sc.authCacheRequests.GetMetricWith(mergeLabels(authLabels, "cache_status", stats.AuthCacheStatus))
+		if err != nil {
+			klog.Errorf("Error fetching auth cache metric: %v", err)
+		} else {
+			cacheMetric.Inc()
+		}
+	}
+}
+
+// mergeLabels returns a copy of labels with an additional label
+func mergeLabels(labels prometheus.Labels, name, value string) prometheus.Labels {
+	merged := make(prometheus.Labels, len(labels)+1)
+	for k, v := range labels {
+		merged[k] = v
+	}
+	merged[name] = value
+
+	return merged
+}
+
 // Start listen for connections in the unix socket and spawns a goroutine to process the content
 func (sc *SocketCollector) Start() {
 	for {
@@ -404,8 +571,16 @@ func (sc SocketCollector) Describe(ch chan<- *prometheus.Desc) {
 
 	sc.upstreamLatency.Describe(ch)
 
+	sc.upstreamRetries.Describe(ch)
+	sc.retryBudgetExhausted.Describe(ch)
+
+	sc.authRequests.Describe(ch)
+	sc.authResponseTime.Describe(ch)
+	sc.authCacheRequests.Describe(ch)
+
 	sc.responseTime.Describe(ch)
 	sc.responseLength.Describe(ch)
+	sc.endpointResponseTime.Describe(ch)
 
 	sc.bytesSent.Describe(ch)
 }
@@ -419,8 +594,16 @@ func (sc SocketCollector) Collect(ch chan<- prometheus.Metric) {
 
 	sc.upstreamLatency.Collect(ch)
 
+	sc.upstreamRetries.Collect(ch)
+	sc.retryBudgetExhausted.Collect(ch)
+
+	sc.authRequests.Collect(ch)
+	sc.authResponseTime.Collect(ch)
+	sc.authCacheRequests.Collect(ch)
+
 	sc.responseTime.Collect(ch)
 	sc.responseLength.Collect(ch)
+	sc.endpointResponseTime.Collect(ch)
 
 	sc.bytesSent.Collect(ch)
 }
@@ -224,6 +224,107 @@ func TestCollector(t *testing.T) {
 			`,
 		},
 
+		{
+			name: "valid metric object with external authentication should update auth metrics",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"200",
+				"bytesSent":150.0,
+				"method":"GET",
+				"path":"/admin",
+				"requestLength":300.0,
+				"requestTime":60.0,
+				"upstreamName":"test-upstream",
+				"upstreamIP":"1.1.1.1:8080",
+				"upstreamResponseTime":200,
+				"upstreamStatus":"220",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"authURL":"http://auth.test/validate",
+				"authResult":"allowed",
+				"authResponseTime":0.2,
+				"authCacheStatus":"MISS"
+			}]`},
+			metrics: []string{"nginx_ingress_controller_auth_requests", "nginx_ingress_controller_auth_cache_requests"},
+			wantBefore: `
+				# HELP nginx_ingress_controller_auth_cache_requests The number of responses of the external authentication service by cache status
+				# TYPE nginx_ingress_controller_auth_cache_requests counter
+				nginx_ingress_controller_auth_cache_requests{cache_status="MISS",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",url="http://auth.test/validate"} 1
+				# HELP nginx_ingress_controller_auth_requests The number of requests authenticated by an external service, by result (allowed, denied, error or unavailable)
+				# TYPE nginx_ingress_controller_auth_requests counter
+				nginx_ingress_controller_auth_requests{controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",result="allowed",url="http://auth.test/validate"} 1
+			`,
+		},
+
+		{
+			name: "valid metric object with retries should update retry metrics",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"502",
+				"bytesSent":150.0,
+				"method":"GET",
+				"path":"/admin",
+				"requestLength":300.0,
+				"requestTime":60.0,
+				"upstreamName":"test-upstream",
+				"upstreamIP":"1.1.1.1:8080",
+				"upstreamResponseTime":200,
+				"upstreamStatus":"502",
+				"upstreamRetries":2,
+				"retryBudgetExhausted":true,
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app"
+			}]`},
+			metrics: []string{"nginx_ingress_controller_upstream_retries", "nginx_ingress_controller_retry_budget_exhausted"},
+			wantBefore: `
+				# HELP nginx_ingress_controller_retry_budget_exhausted The number of retries not sent to the upstream because the retry budget was exhausted
+				# TYPE nginx_ingress_controller_retry_budget_exhausted counter
+				nginx_ingress_controller_retry_budget_exhausted{controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",service="test-app"} 1
+				# HELP nginx_ingress_controller_upstream_retries The number of retries of the requests sent to the upstream
+				# TYPE nginx_ingress_controller_upstream_retries counter
+				nginx_ingress_controller_upstream_retries{controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",service="test-app"} 2
+			`,
+		},
+
+		{
+			name: "valid metric object with an upstream address should update the endpoint metrics",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"200",
+				"bytesSent":150.0,
+				"method":"GET",
+				"path":"/admin",
+				"requestLength":300.0,
+				"requestTime":0.3,
+				"upstreamResponseTime":0.2,
+				"upstreamAddr":"10.0.0.2:8080",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app"
+			}]`},
+			metrics: []string{"nginx_ingress_controller_upstream_endpoint_response_duration_seconds"},
+			wantBefore: `
+				# HELP nginx_ingress_controller_upstream_endpoint_response_duration_seconds The time spent on receiving the response from each endpoint of the upstream
+				# TYPE nginx_ingress_controller_upstream_endpoint_response_duration_seconds histogram
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="0.005"} 0
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="0.01"} 0
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="0.025"} 0
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="0.05"} 0
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="0.1"} 0
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="0.25"} 1
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="0.5"} 1
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="1"} 1
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="2.5"} 1
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="5"} 1
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="10"} 1
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_bucket{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200",le="+Inf"} 1
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_sum{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200"} 0.2
+				nginx_ingress_controller_upstream_endpoint_response_duration_seconds_count{controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200"} 1
+			`,
+		},
+
 		{
 			name: "collector should be able to handle batched metrics correctly",
 			data: []string{`[
@@ -288,7 +389,7 @@ func TestCollector(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			registry := prometheus.NewPedanticRegistry()
 
-			sc, err := NewSocketCollector("pod", "default", "ingress", true)
+			sc, err := NewSocketCollector("pod", "default", "ingress", true, true)
 			if err != nil {
 				t.Errorf("%v: unexpected error creating new SocketCollector: %v", c.name, err)
 			}
@@ -0,0 +1,113 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/nginx"
+)
+
+type upstreamHealthCollector struct {
+	scrapeChan chan scrapeRequest
+
+	healthy  *prometheus.Desc
+	failures *prometheus.Desc
+}
+
+// UpstreamHealthCollector defines a collector of the state of the active
+// health checks of the upstream endpoints
+type UpstreamHealthCollector interface {
+	prometheus.Collector
+
+	Start()
+	Stop()
+}
+
+// NewUpstreamHealth returns a new prometheus collector of the state of
+// the active health checks of the upstream endpoints
+func NewUpstreamHealth(podName, namespace, ingressClass string) (UpstreamHealthCollector, error) {
+	constLabels := prometheus.Labels{
+		"controller_namespace": namespace,
+		"controller_class":     ingressClass,
+		"controller_pod":       podName,
+	}
+
+	return upstreamHealthCollector{
+		scrapeChan: make(chan scrapeRequest),
+
+		healthy: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, "", "upstream_endpoint_healthy"),
+			"whether the upstream endpoint passes the active health checks (1) or not (0)",
+			[]string{"upstream", "endpoint"}, constLabels),
+
+		failures: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, "", "upstream_endpoint_health_check_failures"),
+			"number of consecutive failed active health checks of the upstream endpoint",
+			[]string{"upstream", "endpoint"}, constLabels),
+	}, nil
+}
+
+// Describe implements prometheus.Collector.
+func (p upstreamHealthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.healthy
+	ch <- p.failures
+}
+
+// Collect implements prometheus.Collector.
+func (p upstreamHealthCollector) Collect(ch chan<- prometheus.Metric) {
+	req := scrapeRequest{results: ch, done: make(chan struct{})}
+	p.scrapeChan <- req
+	<-req.done
+}
+
+func (p upstreamHealthCollector) Start() {
+	for req := range p.scrapeChan {
+		ch := req.results
+		p.scrape(ch)
+		req.done <- struct{}{}
+	}
+}
+
+func (p upstreamHealthCollector) Stop() {
+	close(p.scrapeChan)
+}
+
+// scrape reads the state of the active health checks from nginx
+func (p upstreamHealthCollector) scrape(ch chan<- prometheus.Metric) {
+	klog.V(3).Infof("start scraping socket: %v", nginx.BackendsHealthPath)
+	health, err := nginx.ReadBackendsHealth()
+	if err != nil {
+		klog.Warningf("unexpected error obtaining the state of the health checks: %v", err)
+		return
+	}
+
+	for upstream, endpoints := range health {
+		for endpoint, eh := range endpoints {
+			healthy := 0.0
+			if eh.Healthy {
+				healthy = 1
+			}
+
+			ch <- prometheus.MustNewConstMetric(p.healthy,
+				prometheus.GaugeValue, healthy, upstream, endpoint)
+			ch <- prometheus.MustNewConstMetric(p.failures,
+				prometheus.GaugeValue, float64(eh.Failures), upstream, endpoint)
+		}
+	}
+}
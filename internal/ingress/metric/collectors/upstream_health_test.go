@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/ingress-nginx/internal/nginx"
+)
+
+func TestUpstreamHealthCollector(t *testing.T) {
+	cases := []struct {
+		name    string
+		mock    string
+		metrics []string
+		want    string
+	}{
+		{
+			name:    "should return empty metrics",
+			mock:    `{}`,
+			want:    ``,
+			metrics: []string{"nginx_ingress_controller_upstream_endpoint_healthy"},
+		},
+		{
+			name: "should return the state of the endpoints",
+			mock: `{
+				"default-app-80": {
+					"10.0.0.1:8080": {"healthy": true, "failures": 0, "successes": 4, "status": 200},
+					"10.0.0.2:8080": {"healthy": false, "failures": 3, "successes": 0, "error": "timeout"}
+				}
+			}`,
+			want: `
+				# HELP nginx_ingress_controller_upstream_endpoint_health_check_failures number of consecutive failed active health checks of the upstream endpoint
+				# TYPE nginx_ingress_controller_upstream_endpoint_health_check_failures gauge
+				nginx_ingress_controller_upstream_endpoint_health_check_failures{controller_class="nginx",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.1:8080",upstream="default-app-80"} 0
+				nginx_ingress_controller_upstream_endpoint_health_check_failures{controller_class="nginx",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",upstream="default-app-80"} 3
+				# HELP nginx_ingress_controller_upstream_endpoint_healthy whether the upstream endpoint passes the active health checks (1) or not (0)
+				# TYPE nginx_ingress_controller_upstream_endpoint_healthy gauge
+				nginx_ingress_controller_upstream_endpoint_healthy{controller_class="nginx",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.1:8080",upstream="default-app-80"} 1
+				nginx_ingress_controller_upstream_endpoint_healthy{controller_class="nginx",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",upstream="default-app-80"} 0
+			`,
+			metrics: []string{
+				"nginx_ingress_controller_upstream_endpoint_healthy",
+				"nginx_ingress_controller_upstream_endpoint_health_check_failures",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			listener, err := net.Listen("unix", nginx.StatusSocket)
+			if err != nil {
+				t.Fatalf("crating unix listener: %s", err)
+			}
+
+			server := &httptest.Server{
+				Listener: listener,
+				Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+
+					if r.URL.Path == nginx.BackendsHealthPath {
+						fmt.Fprint(w, c.mock)
+						return
+					}
+
+					fmt.Fprintf(w, "OK")
+				})},
+			}
+			server.Start()
+
+			time.Sleep(1 * time.Second)
+
+			cm, err := NewUpstreamHealth("pod", "default", "nginx")
+			if err != nil {
+				t.Errorf("unexpected error creating upstream health collector: %v", err)
+			}
+
+			go cm.Start()
+
+			reg := prometheus.NewPedanticRegistry()
+			if err := reg.Register(cm); err != nil {
+				t.Errorf("registering collector failed: %s", err)
+			}
+
+			if err := GatherAndCompare(cm, c.want, c.metrics, reg); err != nil {
+				t.Errorf("unexpected collecting result:\n%s", err)
+			}
+
+			reg.Unregister(cm)
+
+			server.Close()
+			cm.Stop()
+
+			listener.Close()
+			os.Remove(nginx.StatusSocket)
+		})
+	}
+}
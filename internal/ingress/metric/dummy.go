@@ -17,6 +17,8 @@ limitations under the License.
 package metric
 
 import (
+	"time"
+
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/ingress-nginx/internal/ingress"
 )
@@ -56,6 +58,18 @@ func (dc DummyCollector) Stop() {}
 // SetSSLExpireTime ...
 func (dc DummyCollector) SetSSLExpireTime([]*ingress.Server) {}
 
+// ObserveReloadDuration ...
+func (dc DummyCollector) ObserveReloadDuration(time.Duration) {}
+
+// IncReloadReasons ...
+func (dc DummyCollector) IncReloadReasons([]string) {}
+
+// IncReloadChanges ...
+func (dc DummyCollector) IncReloadChanges([]string) {}
+
+// SetDrainingConnections ...
+func (dc DummyCollector) SetDrainingConnections(int) {}
+
 // SetHosts ...
 func (dc DummyCollector) SetHosts(hosts sets.String) {}
 
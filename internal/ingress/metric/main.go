@@ -36,6 +36,15 @@ type Collector interface {
 
 	IncReloadCount()
 	IncReloadErrorCount()
+	// ObserveReloadDuration records the time elapsed between the reload
+	// signal and the new NGINX workers replacing the previous ones
+	ObserveReloadDuration(time.Duration)
+	// IncReloadReasons increments the reload counter of each reason, the
+	// kinds of the objects that caused the reload
+	IncReloadReasons([]string)
+	// IncReloadChanges increments the counter of the configuration changes
+	// requiring a reload of each category
+	IncReloadChanges([]string)
 
 	OnStartedLeading(string)
 	OnStoppedLeading(string)
@@ -47,6 +56,10 @@ type Collector interface {
 
 	SetSSLExpireTime([]*ingress.Server)
 
+	// SetDrainingConnections sets the number of client connections NGINX
+	// is waiting for to complete during the shutdown
+	SetDrainingConnections(int)
+
 	// SetHosts sets the hostnames that are being served by the ingress controller
 	SetHosts(sets.String)
 
@@ -55,8 +68,9 @@ type Collector interface {
 }
 
 type collector struct {
-	nginxStatus  collectors.NGINXStatusCollector
-	nginxProcess collectors.NGINXProcessCollector
+	nginxStatus    collectors.NGINXStatusCollector
+	nginxProcess   collectors.NGINXProcessCollector
+	upstreamHealth collectors.UpstreamHealthCollector
 
 	ingressController *collectors.Controller
 
@@ -66,7 +80,7 @@ type collector struct {
 }
 
 // NewCollector creates a new metric collector the for ingress controller
-func NewCollector(metricsPerHost bool, registry *prometheus.Registry) (Collector, error) {
+func NewCollector(metricsPerHost, metricsPerUpstreamEndpoint bool, registry *prometheus.Registry) (Collector, error) {
 	podNamespace := os.Getenv("POD_NAMESPACE")
 	if podNamespace == "" {
 		podNamespace = "default"
@@ -84,7 +98,12 @@ func NewCollector(metricsPerHost bool, registry *prometheus.Registry) (Collector
 		return nil, err
 	}
 
-	s, err := collectors.NewSocketCollector(podName, podNamespace, class.IngressClass, metricsPerHost)
+	uh, err := collectors.NewUpstreamHealth(podName, podNamespace, class.IngressClass)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := collectors.NewSocketCollector(podName, podNamespace, class.IngressClass, metricsPerHost, metricsPerUpstreamEndpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -92,8 +111,9 @@ func NewCollector(metricsPerHost bool, registry *prometheus.Registry) (Collector
 	ic := collectors.NewController(podName, podNamespace, class.IngressClass)
 
 	return Collector(&collector{
-		nginxStatus:  nc,
-		nginxProcess: pc,
+		nginxStatus:    nc,
+		nginxProcess:   pc,
+		upstreamHealth: uh,
 
 		ingressController: ic,
 
@@ -123,6 +143,22 @@ func (c *collector) IncReloadErrorCount() {
 	c.ingressController.IncReloadErrorCount()
 }
 
+func (c *collector) ObserveReloadDuration(duration time.Duration) {
+	c.ingressController.ObserveReloadDuration(duration)
+}
+
+func (c *collector) IncReloadReasons(reasons []string) {
+	c.ingressController.IncReloadReasons(reasons)
+}
+
+func (c *collector) IncReloadChanges(categories []string) {
+	c.ingressController.IncReloadChanges(categories)
+}
+
+func (c *collector) SetDrainingConnections(connections int) {
+	c.ingressController.SetDrainingConnections(connections)
+}
+
 func (c *collector) RemoveMetrics(ingresses, hosts []string) {
 	c.socket.RemoveMetrics(ingresses, c.registry)
 	c.ingressController.RemoveMetrics(hosts, c.registry)
@@ -131,6 +167,7 @@ func (c *collector) RemoveMetrics(ingresses, hosts []string) {
 func (c *collector) Start() {
 	c.registry.MustRegister(c.nginxStatus)
 	c.registry.MustRegister(c.nginxProcess)
+	c.registry.MustRegister(c.upstreamHealth)
 	c.registry.MustRegister(c.ingressController)
 	c.registry.MustRegister(c.socket)
 
@@ -138,6 +175,7 @@ func (c *collector) Start() {
 	// a server section with the status port
 	go func() {
 		time.Sleep(5 * time.Second)
+		go c.upstreamHealth.Start()
 		c.nginxStatus.Start()
 	}()
 	go c.nginxProcess.Start()
@@ -147,11 +185,13 @@ func (c *collector) Start() {
 func (c *collector) Stop() {
 	c.registry.Unregister(c.nginxStatus)
 	c.registry.Unregister(c.nginxProcess)
+	c.registry.Unregister(c.upstreamHealth)
 	c.registry.Unregister(c.ingressController)
 	c.registry.Unregister(c.socket)
 
 	c.nginxStatus.Stop()
 	c.nginxProcess.Stop()
+	c.upstreamHealth.Stop()
 	c.socket.Stop()
 }
 
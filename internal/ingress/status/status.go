@@ -18,6 +18,7 @@ package status
 
 import (
 	"fmt"
+	"math"
 	"net"
 	"sort"
 	"strings"
@@ -28,13 +29,17 @@ import (
 
 	pool "gopkg.in/go-playground/pool.v3"
 	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/kubernetes/pkg/kubelet/util/sliceutils"
 
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/statusaddress"
 	"k8s.io/ingress-nginx/internal/ingress/controller/store"
 	"k8s.io/ingress-nginx/internal/k8s"
 	"k8s.io/ingress-nginx/internal/task"
@@ -42,6 +47,9 @@ import (
 
 const (
 	updateInterval = 60 * time.Second
+
+	// defaultUpdateRateLimit is the number of status updates per second
+	defaultUpdateRateLimit = 10
 )
 
 // Syncer ...
@@ -64,11 +72,23 @@ type Config struct {
 
 	PublishStatusAddress string
 
+	// PublishInternalService and PublishInternalStatusAddress are the
+	// sources of the addresses of the Ingresses requesting internal ones
+	PublishInternalService string
+
+	PublishInternalStatusAddress string
+
 	UpdateStatusOnShutdown bool
 
+	// UpdateRateLimit is the maximum number of status updates per second
+	UpdateRateLimit float32
+
 	UseNodeInternalIP bool
 
 	IngressLister ingressLister
+
+	// Shard selects the Ingresses whose status is updated
+	Shard store.Shard
 }
 
 // statusSync keeps the status IP in each Ingress rule updated executing a periodic check
@@ -87,6 +107,52 @@ type statusSync struct {
 	// workqueue used to keep in sync the status IP/s
 	// in the Ingress rules
 	syncQueue *task.Queue
+
+	// rateLimiter limits the status updates sent to the API server
+	rateLimiter flowcontrol.RateLimiter
+}
+
+// statusAddresses contains the addresses published in the status of the
+// Ingresses
+type statusAddresses struct {
+	external []apiv1.LoadBalancerIngress
+	internal []apiv1.LoadBalancerIngress
+}
+
+// forIngress returns a sorted copy of the addresses requested by the
+// annotations of the Ingress. The addresses are shared by the workers
+// updating the Ingresses and must not be modified.
+func (a statusAddresses) forIngress(ing *ingress.Ingress) []apiv1.LoadBalancerIngress {
+	config := statusaddress.Config{}
+	if ing.ParsedAnnotations != nil {
+		config = ing.ParsedAnnotations.StatusAddress
+	}
+
+	addrs := a.external
+	if config.Address == statusaddress.Internal {
+		addrs = a.internal
+	}
+
+	var preferred []apiv1.LoadBalancerIngress
+	for _, addr := range addrs {
+		switch config.Format {
+		case statusaddress.IPFormat:
+			if addr.IP != "" {
+				preferred = append(preferred, addr)
+			}
+		case statusaddress.HostnameFormat:
+			if addr.Hostname != "" {
+				preferred = append(preferred, addr)
+			}
+		}
+	}
+
+	// the format is a preference, every address is kept without a match
+	if len(preferred) == 0 {
+		return sortedStatus(addrs)
+	}
+
+	return sortedStatus(preferred)
 }
 
 // Start starts the loop to keep the status in sync
@@ -134,7 +200,7 @@ func (s statusSync) Shutdown() {
 	}
 
 	klog.Infof("removing address from ingress status (%v)", addrs)
-	s.updateStatus([]apiv1.LoadBalancerIngress{})
+	s.updateStatus(statusAddresses{})
 }
 
 func (s *statusSync) sync(key interface{}) error {
@@ -147,7 +213,16 @@ func (s *statusSync) sync(key interface{}) error {
 	if err != nil {
 		return err
 	}
-	s.updateStatus(sliceToStatus(addrs))
+
+	internal, err := s.internalAddresses()
+	if err != nil {
+		return err
+	}
+
+	s.updateStatus(statusAddresses{
+		external: sliceToStatus(addrs),
+		internal: sliceToStatus(internal),
+	})
 
 	return nil
 }
@@ -158,10 +233,17 @@ func (s statusSync) keyfunc(input interface{}) (interface{}, error) {
 
 // NewStatusSyncer returns a new Syncer instance
 func NewStatusSyncer(podInfo *k8s.PodInfo, config Config) Syncer {
+	rateLimit := config.UpdateRateLimit
+	if rateLimit <= 0 {
+		rateLimit = defaultUpdateRateLimit
+	}
+
 	st := statusSync{
 		pod: podInfo,
 
 		Config: config,
+
+		rateLimiter: flowcontrol.NewTokenBucketRateLimiter(rateLimit, int(math.Ceil(float64(rateLimit)))),
 	}
 	st.syncQueue = task.NewCustomTaskQueue(st.sync, st.keyfunc)
 
@@ -171,37 +253,89 @@ func NewStatusSyncer(podInfo *k8s.PodInfo, config Config) Syncer {
 // runningAddresses returns a list of IP addresses and/or FQDN where the
 // ingress controller is currently running
 func (s *statusSync) runningAddresses() ([]string, error) {
-	addrs := []string{}
-
 	if s.PublishService != "" {
-		ns, name, _ := k8s.ParseNameNS(s.PublishService)
-		svc, err := s.Client.CoreV1().Services(ns).Get(name, metav1.GetOptions{})
+		svc, err := s.publishService(s.PublishService)
 		if err != nil {
 			return nil, err
 		}
 
-		if svc.Spec.Type == apiv1.ServiceTypeExternalName {
-			addrs = append(addrs, svc.Spec.ExternalName)
-			return addrs, nil
+		return serviceAddresses(svc), nil
+	}
+
+	if s.PublishStatusAddress != "" {
+		return []string{s.PublishStatusAddress}, nil
+	}
+
+	return s.nodeAddresses(s.UseNodeInternalIP)
+}
+
+// internalAddresses returns the addresses published in the status of the
+// Ingresses requesting internal addresses: the internal publish service or
+// status address, the cluster IP of the publish service or the internal IP
+// addresses of the nodes running the ingress controller
+func (s *statusSync) internalAddresses() ([]string, error) {
+	if s.PublishInternalService != "" {
+		svc, err := s.publishService(s.PublishInternalService)
+		if err != nil {
+			return nil, err
 		}
 
-		for _, ip := range svc.Status.LoadBalancer.Ingress {
-			if ip.IP == "" {
-				addrs = append(addrs, ip.Hostname)
-			} else {
-				addrs = append(addrs, ip.IP)
-			}
+		return serviceAddresses(svc), nil
+	}
+
+	if s.PublishInternalStatusAddress != "" {
+		return []string{s.PublishInternalStatusAddress}, nil
+	}
+
+	if s.PublishService != "" {
+		svc, err := s.publishService(s.PublishService)
+		if err != nil {
+			return nil, err
+		}
+
+		if svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != apiv1.ClusterIPNone {
+			return []string{svc.Spec.ClusterIP}, nil
 		}
 
-		addrs = append(addrs, svc.Spec.ExternalIPs...)
-		return addrs, nil
+		return []string{}, nil
 	}
 
-	if s.PublishStatusAddress != "" {
-		addrs = append(addrs, s.PublishStatusAddress)
-		return addrs, nil
+	return s.nodeAddresses(true)
+}
+
+// publishService returns the Service matching the namespace/name key
+func (s *statusSync) publishService(key string) (*apiv1.Service, error) {
+	ns, name, _ := k8s.ParseNameNS(key)
+	return s.Client.CoreV1().Services(ns).Get(name, metav1.GetOptions{})
+}
+
+// serviceAddresses returns the addresses of the load balancer of the
+// Service and its external IP addresses
+func serviceAddresses(svc *apiv1.Service) []string {
+	addrs := []string{}
+
+	if svc.Spec.Type == apiv1.ServiceTypeExternalName {
+		addrs = append(addrs, svc.Spec.ExternalName)
+		return addrs
+	}
+
+	for _, ip := range svc.Status.LoadBalancer.Ingress {
+		if ip.IP == "" {
+			addrs = append(addrs, ip.Hostname)
+		} else {
+			addrs = append(addrs, ip.IP)
+		}
 	}
 
+	addrs = append(addrs, svc.Spec.ExternalIPs...)
+	return addrs
+}
+
+// nodeAddresses returns the addresses of the nodes running the ingress
+// controller
+func (s *statusSync) nodeAddresses(useInternalIP bool) ([]string, error) {
+	addrs := []string{}
+
 	// get information about all the pods running the ingress controller
 	pods, err := s.Client.CoreV1().Pods(s.pod.Namespace).List(metav1.ListOptions{
 		LabelSelector: labels.SelectorFromSet(s.pod.Labels).String(),
@@ -216,7 +350,7 @@ func (s *statusSync) runningAddresses() ([]string, error) {
 			continue
 		}
 
-		name := k8s.GetNodeIPOrName(s.Client, pod.Spec.NodeName, s.UseNodeInternalIP)
+		name := k8s.GetNodeIPOrName(s.Client, pod.Spec.NodeName, useInternalIP)
 		if !sliceutils.StringInSlice(name, addrs) {
 			addrs = append(addrs, name)
 		}
@@ -254,68 +388,122 @@ func sliceToStatus(endpoints []string) []apiv1.LoadBalancerIngress {
 	return lbi
 }
 
-// updateStatus changes the status information of Ingress rules
-func (s *statusSync) updateStatus(newIngressPoint []apiv1.LoadBalancerIngress) {
-	ings := s.IngressLister.ListIngresses(nil)
+// updateStatus changes the status information of Ingress rules, in
+// batches limited by the rate of status updates
+func (s *statusSync) updateStatus(addrs statusAddresses) {
+	ings := s.IngressLister.ListIngresses(func(ing *ingress.Ingress) bool {
+		// the Ingresses with hosts of several shards report the
+		// address of the shard of their first host
+		return !s.Shard.OwnsStatus(ing)
+	})
 
 	p := pool.NewLimited(10)
 	defer p.Close()
 
 	batch := p.Batch()
-	sort.SliceStable(newIngressPoint, lessLoadBalancerIngress(newIngressPoint))
 
 	for _, ing := range ings {
-		curIPs := ing.Status.LoadBalancer.Ingress
-		sort.SliceStable(curIPs, lessLoadBalancerIngress(curIPs))
+		newIngressPoint := addrs.forIngress(ing)
+
+		curIPs := sortedStatus(ing.Status.LoadBalancer.Ingress)
 		if ingressSliceEqual(curIPs, newIngressPoint) {
 			klog.V(3).Infof("skipping update of Ingress %v/%v (no change)", ing.Namespace, ing.Name)
 			continue
 		}
 
-		batch.Queue(runUpdate(ing, newIngressPoint, s.Client))
+		batch.Queue(s.runUpdate(ing, newIngressPoint))
 	}
 
 	batch.QueueComplete()
 	batch.WaitAll()
 }
 
-func runUpdate(ing *ingress.Ingress, status []apiv1.LoadBalancerIngress,
-	client clientset.Interface) pool.WorkFunc {
+func (s *statusSync) runUpdate(ing *ingress.Ingress, status []apiv1.LoadBalancerIngress) pool.WorkFunc {
 	return func(wu pool.WorkUnit) (interface{}, error) {
 		if wu.IsCancelled() {
 			return nil, nil
 		}
 
-		if k8s.IsNetworkingIngressAvailable {
-			ingClient := client.NetworkingV1beta1().Ingresses(ing.Namespace)
-			currIng, err := ingClient.Get(ing.Name, metav1.GetOptions{})
-			if err != nil {
-				return nil, errors.Wrap(err, fmt.Sprintf("unexpected error searching Ingress %v/%v", ing.Namespace, ing.Name))
+		var lastErr error
+		err := wait.ExponentialBackoff(retry.DefaultBackoff, func() (bool, error) {
+			// every attempt sends requests to the API server
+			s.rateLimiter.Accept()
+
+			lastErr = updateIngressStatus(ing, status, s.Client)
+			switch {
+			case lastErr == nil:
+				return true, nil
+			case isRetriable(lastErr):
+				klog.V(2).Infof("retrying status update of Ingress %v/%v: %v", ing.Namespace, ing.Name, lastErr)
+				return false, nil
+			default:
+				return false, lastErr
 			}
+		})
+		if err == wait.ErrWaitTimeout {
+			err = lastErr
+		}
+		if err != nil {
+			klog.Warningf("error updating ingress rule: %v", err)
+		}
 
-			klog.Infof("updating Ingress %v/%v status from %v to %v", currIng.Namespace, currIng.Name, currIng.Status.LoadBalancer.Ingress, status)
-			currIng.Status.LoadBalancer.Ingress = status
-			_, err = ingClient.UpdateStatus(currIng)
-			if err != nil {
-				klog.Warningf("error updating ingress rule: %v", err)
-			}
-		} else {
-			ingClient := client.ExtensionsV1beta1().Ingresses(ing.Namespace)
-			currIng, err := ingClient.Get(ing.Name, metav1.GetOptions{})
-			if err != nil {
-				return nil, errors.Wrap(err, fmt.Sprintf("unexpected error searching Ingress %v/%v", ing.Namespace, ing.Name))
-			}
+		return true, nil
+	}
+}
 
-			klog.Infof("updating Ingress %v/%v status from %v to %v", currIng.Namespace, currIng.Name, currIng.Status.LoadBalancer.Ingress, status)
-			currIng.Status.LoadBalancer.Ingress = status
-			_, err = ingClient.UpdateStatus(currIng)
-			if err != nil {
-				klog.Warningf("error updating ingress rule: %v", err)
-			}
+// updateIngressStatus replaces the load balancer status of the current
+// version of the Ingress
+func updateIngressStatus(ing *ingress.Ingress, status []apiv1.LoadBalancerIngress, client clientset.Interface) error {
+	if k8s.IsNetworkingIngressAvailable {
+		ingClient := client.NetworkingV1beta1().Ingresses(ing.Namespace)
+		currIng, err := ingClient.Get(ing.Name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("unexpected error searching Ingress %v/%v", ing.Namespace, ing.Name))
 		}
 
-		return true, nil
+		if ingressSliceEqual(sortedStatus(currIng.Status.LoadBalancer.Ingress), status) {
+			return nil
+		}
+
+		klog.Infof("updating Ingress %v/%v status from %v to %v", currIng.Namespace, currIng.Name, currIng.Status.LoadBalancer.Ingress, status)
+		currIng.Status.LoadBalancer.Ingress = status
+		_, err = ingClient.UpdateStatus(currIng)
+		return err
 	}
+
+	ingClient := client.ExtensionsV1beta1().Ingresses(ing.Namespace)
+	currIng, err := ingClient.Get(ing.Name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unexpected error searching Ingress %v/%v", ing.Namespace, ing.Name))
+	}
+
+	if ingressSliceEqual(sortedStatus(currIng.Status.LoadBalancer.Ingress), status) {
+		return nil
+	}
+
+	klog.Infof("updating Ingress %v/%v status from %v to %v", currIng.Namespace, currIng.Name, currIng.Status.LoadBalancer.Ingress, status)
+	currIng.Status.LoadBalancer.Ingress = status
+	_, err = ingClient.UpdateStatus(currIng)
+	return err
+}
+
+// sortedStatus returns a sorted copy of the load balancer status
+func sortedStatus(status []apiv1.LoadBalancerIngress) []apiv1.LoadBalancerIngress {
+	sorted := make([]apiv1.LoadBalancerIngress, len(status))
+	copy(sorted, status)
+	sort.SliceStable(sorted, lessLoadBalancerIngress(sorted))
+	return sorted
+}
+
+// isRetriable returns true if the status update failed because of a
+// conflict with another writer or a transient error of the API server
+func isRetriable(err error) bool {
+	err = errors.Cause(err)
+	return k8sErrors.IsConflict(err) ||
+		k8sErrors.IsServerTimeout(err) ||
+		k8sErrors.IsTooManyRequests(err) ||
+		k8sErrors.IsTimeout(err) ||
+		k8sErrors.IsInternalError(err)
 }
 
 func lessLoadBalancerIngress(addrs []apiv1.LoadBalancerIngress) func(int, int) bool {
@@ -21,13 +21,21 @@ import (
 	"testing"
 	"time"
 
+	pool "gopkg.in/go-playground/pool.v3"
 	apiv1 "k8s.io/api/core/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
 	networking "k8s.io/api/networking/v1beta1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/statusaddress"
 	"k8s.io/ingress-nginx/internal/ingress/controller/store"
 	"k8s.io/ingress-nginx/internal/k8s"
 	"k8s.io/ingress-nginx/internal/task"
@@ -115,6 +123,9 @@ func buildSimpleClientSet() *testclient.Clientset {
 					Name:      "foo",
 					Namespace: apiv1.NamespaceDefault,
 				},
+				Spec: apiv1.ServiceSpec{
+					ClusterIP: "10.96.0.10",
+				},
 				Status: apiv1.ServiceStatus{
 					LoadBalancer: apiv1.LoadBalancerStatus{
 						Ingress: buildLoadBalancerIngressByIP(),
@@ -422,6 +433,158 @@ func TestRunningAddresessWithPublishStatusAddress(t *testing.T) {
 	}
 }
 
+func TestInternalAddresses(t *testing.T) {
+	fk := buildStatusSync()
+
+	r, err := fk.internalAddresses()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(r) != 1 || r[0] != "10.96.0.10" {
+		t.Errorf("returned %v but expected the cluster IP of the publish service", r)
+	}
+
+	fk.PublishInternalService = apiv1.NamespaceDefault + "/" + "foo"
+	r, _ = fk.internalAddresses()
+	if len(r) != 4 {
+		t.Errorf("returned %v but expected the addresses of the internal publish service", r)
+	}
+
+	fk.PublishInternalService = ""
+	fk.PublishInternalStatusAddress = "10.0.0.100"
+	r, _ = fk.internalAddresses()
+	if len(r) != 1 || r[0] != "10.0.0.100" {
+		t.Errorf("returned %v but expected %v", r, "10.0.0.100")
+	}
+
+	fk.PublishService = ""
+	fk.PublishInternalStatusAddress = ""
+	r, _ = fk.internalAddresses()
+	if len(r) != 1 || r[0] != "11.0.0.1" {
+		t.Errorf("returned %v but expected the internal IP of the node", r)
+	}
+}
+
+func TestStatusAddressesForIngress(t *testing.T) {
+	addrs := statusAddresses{
+		external: []apiv1.LoadBalancerIngress{{IP: "1.1.1.1"}, {Hostname: "lb.example.com"}},
+		internal: []apiv1.LoadBalancerIngress{{IP: "10.0.0.1"}},
+	}
+
+	testCases := []struct {
+		name     string
+		config   *annotations.Ingress
+		expected []apiv1.LoadBalancerIngress
+	}{
+		{"without annotations", nil, addrs.external},
+		{"external", &annotations.Ingress{StatusAddress: statusaddress.Config{Address: statusaddress.External, Format: statusaddress.AnyFormat}}, addrs.external},
+		{"internal", &annotations.Ingress{StatusAddress: statusaddress.Config{Address: statusaddress.Internal, Format: statusaddress.AnyFormat}}, addrs.internal},
+		{"external ip", &annotations.Ingress{StatusAddress: statusaddress.Config{Address: statusaddress.External, Format: statusaddress.IPFormat}}, addrs.external[:1]},
+		{"external hostname", &annotations.Ingress{StatusAddress: statusaddress.Config{Address: statusaddress.External, Format: statusaddress.HostnameFormat}}, addrs.external[1:]},
+		{"internal hostname", &annotations.Ingress{StatusAddress: statusaddress.Config{Address: statusaddress.Internal, Format: statusaddress.HostnameFormat}}, addrs.internal},
+	}
+
+	for _, tc := range testCases {
+		ing := &ingress.Ingress{ParsedAnnotations: tc.config}
+		r := addrs.forIngress(ing)
+		if !ingressSliceEqual(r, sortedStatus(tc.expected)) {
+			t.Errorf("%v: returned %v but expected %v", tc.name, r, tc.expected)
+		}
+	}
+}
+
+func TestStatusAddressesForIngressCopy(t *testing.T) {
+	addrs := statusAddresses{
+		external: []apiv1.LoadBalancerIngress{{IP: "2.2.2.2"}, {IP: "1.1.1.1"}},
+	}
+
+	r := addrs.forIngress(&ingress.Ingress{})
+	if r[0].IP != "1.1.1.1" || r[1].IP != "2.2.2.2" {
+		t.Errorf("expected sorted addresses but %v was returned", r)
+	}
+
+	r[0].IP = "3.3.3.3"
+	if addrs.external[0].IP != "2.2.2.2" || addrs.external[1].IP != "1.1.1.1" {
+		t.Errorf("expected the shared addresses to be unchanged but got %v", addrs.external)
+	}
+}
+
+// countingRateLimiter counts the tokens taken without limiting them
+type countingRateLimiter struct {
+	accepted int
+}
+
+func (l *countingRateLimiter) TryAccept() bool {
+	l.accepted++
+	return true
+}
+
+func (l *countingRateLimiter) Accept() {
+	l.accepted++
+}
+
+func (l *countingRateLimiter) Stop() {}
+
+func (l *countingRateLimiter) QPS() float32 {
+	return 0
+}
+
+func TestRunUpdateRateLimitsRetries(t *testing.T) {
+	networkingAvailable := k8s.IsNetworkingIngressAvailable
+	k8s.IsNetworkingIngressAvailable = false
+	defer func() { k8s.IsNetworkingIngressAvailable = networkingAvailable }()
+
+	client := testclient.NewSimpleClientset(&extensions.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: apiv1.NamespaceDefault,
+		},
+	})
+
+	conflicts := 0
+	client.PrependReactor("update", "ingresses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "status" || conflicts == 2 {
+			return false, nil, nil
+		}
+
+		conflicts++
+		return true, nil, k8sErrors.NewConflict(schema.GroupResource{Resource: "ingresses"}, "foo", nil)
+	})
+
+	limiter := &countingRateLimiter{}
+	s := statusSync{
+		Config:      Config{Client: client},
+		rateLimiter: limiter,
+	}
+
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: apiv1.NamespaceDefault,
+			},
+		},
+	}
+	status := []apiv1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+
+	p := pool.NewLimited(1)
+	defer p.Close()
+
+	p.Queue(s.runUpdate(ing, status)).Wait()
+
+	if limiter.accepted != 3 {
+		t.Errorf("expected a token for each of the 3 attempts but %v were taken", limiter.accepted)
+	}
+
+	updated, err := client.ExtensionsV1beta1().Ingresses(apiv1.NamespaceDefault).Get("foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ingressSliceEqual(updated.Status.LoadBalancer.Ingress, status) {
+		t.Errorf("expected status %v but got %v", status, updated.Status.LoadBalancer.Ingress)
+	}
+}
+
 /*
 TODO: this test requires a refactoring
 func TestUpdateStatus(t *testing.T) {
@@ -23,22 +23,32 @@ import (
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authjwt"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authoidc"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/circuitbreaker"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/connection"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/fastcgi"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/globalratelimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/headers"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/healthcheck"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/luarestywaf"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/mirror"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/outlierdetection"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/redirect"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/retrypolicy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/jwks"
+	"k8s.io/ingress-nginx/internal/oidc"
 )
 
 var (
@@ -76,6 +86,21 @@ type Configuration struct {
 
 	// ControllerPodsCount contains the list of running ingress controller Pod(s)
 	ControllerPodsCount int `json:"controllerPodsCount,omitempty"`
+
+	// JWTKeySets contains the keys used to validate JSON Web Tokens
+	// indexed by the KeySet of the locations
+	// +optional
+	JWTKeySets map[string][]jwks.Key `json:"jwtKeySets,omitempty"`
+
+	// OIDCProviders contains the discovered OpenID Connect providers
+	// indexed by issuer
+	// +optional
+	OIDCProviders map[string]oidc.Provider `json:"oidcProviders,omitempty"`
+
+	// OIDCClients contains the credentials of the OpenID Connect clients
+	// indexed by the Secret of the locations
+	// +optional
+	OIDCClients map[string]oidc.Client `json:"oidcClients,omitempty"`
 }
 
 // Backend describes one or more remote server/s (endpoints) associated with a service
@@ -98,6 +123,12 @@ type Backend struct {
 	UpstreamHashBy UpstreamHashByConfig `json:"upstreamHashByConfig,omitempty"`
 	// LB algorithm configuration per ingress
 	LoadBalancing string `json:"load-balance,omitempty"`
+	// Active health checks of the endpoints
+	HealthCheck healthcheck.Config `json:"healthCheck,omitempty"`
+	// Ejection of the endpoints returning errors
+	OutlierDetection outlierdetection.Config `json:"outlierDetection,omitempty"`
+	// Limits of the concurrent requests sent to the endpoints
+	CircuitBreaker circuitbreaker.Config `json:"circuitBreaker,omitempty"`
 	// Denotes if a backend has no server. The backend instead shares a server with another backend and acts as an
 	// alternative backend.
 	// This can be used to share multiple upstreams in the sam nginx server block.
@@ -140,7 +171,10 @@ func (s Backend) HashInclude(field string, v interface{}) (bool, error) {
 // +k8s:deepcopy-gen=true
 type SessionAffinityConfig struct {
 	AffinityType          string                `json:"name"`
+	AffinityMode          string                `json:"mode"`
 	CookieSessionAffinity CookieSessionAffinity `json:"cookieSessionAffinity"`
+	HeaderSessionAffinity HeaderSessionAffinity `json:"headerSessionAffinity"`
+	IPSessionAffinity     IPSessionAffinity     `json:"ipSessionAffinity"`
 }
 
 // CookieSessionAffinity defines the structure used in Affinity configured by Cookies.
@@ -154,6 +188,19 @@ type CookieSessionAffinity struct {
 	ChangeOnFailure bool                `json:"change_on_failure,omitempty"`
 }
 
+// HeaderSessionAffinity defines the structure used in Affinity configured by a request header.
+// +k8s:deepcopy-gen=true
+type HeaderSessionAffinity struct {
+	Name string `json:"name,omitempty"`
+}
+
+// IPSessionAffinity defines the structure used in Affinity configured by the client address.
+// +k8s:deepcopy-gen=true
+type IPSessionAffinity struct {
+	PrefixLength     int `json:"prefixLength,omitempty"`
+	IPv6PrefixLength int `json:"ipv6PrefixLength,omitempty"`
+}
+
 // UpstreamHashByConfig described setting from the upstream-hash-by* annotations.
 type UpstreamHashByConfig struct {
 	UpstreamHashBy           string `json:"upstream-hash-by,omitempty"`
@@ -252,6 +299,14 @@ type Location struct {
 	// EnableGlobalAuth indicates if the access to this location requires
 	// authentication using an external provider defined in controller's config
 	EnableGlobalAuth bool `json:"enableGlobalAuth"`
+	// JWTAuth indicates the access to this location requires a valid
+	// JSON Web Token
+	// +optional
+	JWTAuth authjwt.Config `json:"jwtAuth,omitempty"`
+	// OIDCAuth indicates the access to this location requires users
+	// authenticated by an OpenID Connect provider
+	// +optional
+	OIDCAuth authoidc.Config `json:"oidcAuth,omitempty"`
 	// HTTP2PushPreload allows to configure the HTTP2 Push Preload from backend
 	// original location.
 	// +optional
@@ -261,6 +316,10 @@ type Location struct {
 	// The Redirect annotation precedes RateLimit
 	// +optional
 	RateLimit ratelimit.Config `json:"rateLimit,omitempty"`
+	// GlobalRateLimit describes a limit in the number of requests shared
+	// by all the replicas of the ingress controller
+	// +optional
+	GlobalRateLimit globalratelimit.Config `json:"globalRateLimit,omitempty"`
 	// Redirect describes a temporal o permanent redirection this location.
 	// +optional
 	Redirect redirect.Config `json:"redirect,omitempty"`
@@ -324,6 +383,14 @@ type Location struct {
 	// Mirror allows you to mirror traffic to a "test" backend
 	// +optional
 	Mirror mirror.Config `json:"mirror,omitempty"`
+	// Headers contains the changes to the headers of the requests sent to
+	// the upstream and of the responses sent to the client
+	// +optional
+	Headers headers.Config `json:"headers,omitempty"`
+	// RetryPolicy contains the conditions and the limits of the retries of
+	// the requests sent to the upstream
+	// +optional
+	RetryPolicy retrypolicy.Config `json:"retryPolicy,omitempty"`
 }
 
 // SSLPassthroughBackend describes a SSL upstream server configured
@@ -377,3 +444,15 @@ type Ingress struct {
 type GeneralConfig struct {
 	ControllerPodsCount int `json:"controllerPodsCount"`
 }
+
+// DynamicConfiguration holds the configuration the controller sends to the
+// lua code and the nginx.conf file rendered from the same state
+type DynamicConfiguration struct {
+	Backends []*Backend    `json:"backends"`
+	Streams  []Backend     `json:"streams"`
+	General  GeneralConfig `json:"general"`
+	// Certificates maps each hostname with a dynamic certificate to the
+	// SHA1 checksum of the PEM certificate and key
+	Certificates map[string]string `json:"certificates"`
+	NginxConf    string            `json:"nginxConf"`
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package ingress
 
 import (
+	"k8s.io/ingress-nginx/internal/jwks"
 	"k8s.io/ingress-nginx/internal/sets"
 )
 
@@ -80,6 +81,50 @@ func (c1 *Configuration) Equal(c2 *Configuration) bool {
 		return false
 	}
 
+	if !compareJWTKeySets(c1.JWTKeySets, c2.JWTKeySets) {
+		return false
+	}
+
+	if len(c1.OIDCProviders) != len(c2.OIDCProviders) {
+		return false
+	}
+	for issuer, p1 := range c1.OIDCProviders {
+		if p2, ok := c2.OIDCProviders[issuer]; !ok || p1 != p2 {
+			return false
+		}
+	}
+
+	if len(c1.OIDCClients) != len(c2.OIDCClients) {
+		return false
+	}
+	for secret, client1 := range c1.OIDCClients {
+		if client2, ok := c2.OIDCClients[secret]; !ok || client1 != client2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func compareJWTKeySets(s1, s2 map[string][]jwks.Key) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+
+	for id, keys1 := range s1 {
+		keys2, ok := s2[id]
+		if !ok || len(keys1) != len(keys2) {
+			return false
+		}
+
+		// keys are sorted
+		for i := range keys1 {
+			if keys1[i] != keys2[i] {
+				return false
+			}
+		}
+	}
+
 	return true
 }
 
@@ -128,6 +173,15 @@ func (b1 *Backend) Equal(b2 *Backend) bool {
 	if b1.LoadBalancing != b2.LoadBalancing {
 		return false
 	}
+	if !(&b1.HealthCheck).Equal(&b2.HealthCheck) {
+		return false
+	}
+	if !(&b1.OutlierDetection).Equal(&b2.OutlierDetection) {
+		return false
+	}
+	if !(&b1.CircuitBreaker).Equal(&b2.CircuitBreaker) {
+		return false
+	}
 
 	match := compareEndpoints(b1.Endpoints, b2.Endpoints)
 	if !match {
@@ -152,9 +206,18 @@ func (sac1 *SessionAffinityConfig) Equal(sac2 *SessionAffinityConfig) bool {
 	if sac1.AffinityType != sac2.AffinityType {
 		return false
 	}
+	if sac1.AffinityMode != sac2.AffinityMode {
+		return false
+	}
 	if !(&sac1.CookieSessionAffinity).Equal(&sac2.CookieSessionAffinity) {
 		return false
 	}
+	if sac1.HeaderSessionAffinity != sac2.HeaderSessionAffinity {
+		return false
+	}
+	if sac1.IPSessionAffinity != sac2.IPSessionAffinity {
+		return false
+	}
 
 	return true
 }
@@ -350,12 +413,21 @@ func (l1 *Location) Equal(l2 *Location) bool {
 	if l1.EnableGlobalAuth != l2.EnableGlobalAuth {
 		return false
 	}
+	if !(&l1.JWTAuth).Equal(&l2.JWTAuth) {
+		return false
+	}
+	if !(&l1.OIDCAuth).Equal(&l2.OIDCAuth) {
+		return false
+	}
 	if l1.HTTP2PushPreload != l2.HTTP2PushPreload {
 		return false
 	}
 	if !(&l1.RateLimit).Equal(&l2.RateLimit) {
 		return false
 	}
+	if !(&l1.GlobalRateLimit).Equal(&l2.GlobalRateLimit) {
+		return false
+	}
 	if !(&l1.Redirect).Equal(&l2.Redirect) {
 		return false
 	}
@@ -430,6 +502,14 @@ func (l1 *Location) Equal(l2 *Location) bool {
 		return false
 	}
 
+	if !(&l1.Headers).Equal(&l2.Headers) {
+		return false
+	}
+
+	if !(&l1.RetryPolicy).Equal(&l2.RetryPolicy) {
+		return false
+	}
+
 	return true
 }
 
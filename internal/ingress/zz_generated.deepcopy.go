@@ -43,6 +43,9 @@ func (in *Backend) DeepCopyInto(out *Backend) {
 	}
 	in.SessionAffinity.DeepCopyInto(&out.SessionAffinity)
 	out.UpstreamHashBy = in.UpstreamHashBy
+	out.HealthCheck = in.HealthCheck
+	out.OutlierDetection = in.OutlierDetection
+	out.CircuitBreaker = in.CircuitBreaker
 	out.TrafficShapingPolicy = in.TrafficShapingPolicy
 	if in.AlternativeBackends != nil {
 		in, out := &in.AlternativeBackends, &out.AlternativeBackends
@@ -114,10 +117,44 @@ func (in *Endpoint) DeepCopy() *Endpoint {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeaderSessionAffinity) DeepCopyInto(out *HeaderSessionAffinity) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeaderSessionAffinity.
+func (in *HeaderSessionAffinity) DeepCopy() *HeaderSessionAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(HeaderSessionAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPSessionAffinity) DeepCopyInto(out *IPSessionAffinity) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPSessionAffinity.
+func (in *IPSessionAffinity) DeepCopy() *IPSessionAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(IPSessionAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SessionAffinityConfig) DeepCopyInto(out *SessionAffinityConfig) {
 	*out = *in
 	in.CookieSessionAffinity.DeepCopyInto(&out.CookieSessionAffinity)
+	out.HeaderSessionAffinity = in.HeaderSessionAffinity
+	out.IPSessionAffinity = in.IPSessionAffinity
 	return
 }
 
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// maxSetSize is the maximum size of a JSON Web Key Set downloaded by the cache
+const maxSetSize = 1 << 20
+
+// Cache keeps the keys of remote JSON Web Key Sets, refreshing them periodically.
+// It is safe for concurrent use.
+type Cache struct {
+	mu sync.Mutex
+
+	client *http.Client
+	period time.Duration
+
+	// keys contains the keys of every URL. A nil value means the keys
+	// were not downloaded yet.
+	keys map[string][]Key
+
+	// onChange is called after the keys of an URL change
+	onChange func()
+}
+
+// NewCache creates a cache downloading the key sets every period
+func NewCache(period time.Duration, onChange func()) *Cache {
+	return &Cache{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		period:   period,
+		keys:     make(map[string][]Key),
+		onChange: onChange,
+	}
+}
+
+// Keys returns the keys of the key set available in url. The key set of
+// a new URL is downloaded before returning, so the requests are not
+// rejected while it is not available. A key set that could not be
+// downloaded is retried in the background.
+func (c *Cache) Keys(url string) []Key {
+	c.mu.Lock()
+	keys, ok := c.keys[url]
+	c.mu.Unlock()
+
+	if ok {
+		return keys
+	}
+
+	keys, err := c.fetch(url)
+	if err != nil {
+		klog.Warningf("Error downloading the JSON Web Key Set %v: %v", url, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the key set could be downloaded by a concurrent call
+	if current := c.keys[url]; current != nil {
+		return current
+	}
+
+	c.keys[url] = keys
+	return keys
+}
+
+// Retain removes the key sets of the URLs not contained in urls
+func (c *Cache) Retain(urls sets.String) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for url := range c.keys {
+		if !urls.Has(url) {
+			delete(c.keys, url)
+		}
+	}
+}
+
+// Run refreshes the key sets every period until stopCh is closed
+func (c *Cache) Run(stopCh <-chan struct{}) {
+	wait.Until(c.refreshAll, c.period, stopCh)
+}
+
+func (c *Cache) refreshAll() {
+	c.mu.Lock()
+	urls := make([]string, 0, len(c.keys))
+	for url := range c.keys {
+		urls = append(urls, url)
+	}
+	c.mu.Unlock()
+
+	for _, url := range urls {
+		c.refresh(url)
+	}
+}
+
+// refresh downloads the key set of url, keeping the previous keys on error
+func (c *Cache) refresh(url string) {
+	keys, err := c.fetch(url)
+	if err != nil {
+		klog.Warningf("Error downloading the JSON Web Key Set %v: %v", url, err)
+		return
+	}
+
+	c.mu.Lock()
+	old, ok := c.keys[url]
+	if !ok || (old != nil && reflect.DeepEqual(old, keys)) {
+		c.mu.Unlock()
+		return
+	}
+	c.keys[url] = keys
+	c.mu.Unlock()
+
+	klog.Infof("JSON Web Key Set %v updated (%v keys)", url, len(keys))
+	if c.onChange != nil {
+		c.onChange()
+	}
+}
+
+func (c *Cache) fetch(url string) ([]Key, error) {
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSetSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
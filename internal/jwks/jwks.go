@@ -0,0 +1,280 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jwks reads the keys used to verify the signature of JSON Web Tokens
+// from JSON Web Key Sets (RFC 7517) and PEM encoded public keys.
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// SetFile is the key of a Secret containing a JSON Web Key Set
+const SetFile = "jwks.json"
+
+const (
+	// TypeRSA is the type of the RSA keys
+	TypeRSA = "RSA"
+	// TypeEC is the type of the elliptic curve keys
+	TypeEC = "EC"
+	// TypeOct is the type of the symmetric keys used by HMAC algorithms
+	TypeOct = "oct"
+)
+
+// Key is a key used to verify the signature of JSON Web Tokens
+type Key struct {
+	ID string `json:"kid,omitempty"`
+	// Type is the family of the key: RSA, EC or oct
+	Type string `json:"kty"`
+	// Algorithm restricts the algorithm the key can be used with
+	Algorithm string `json:"alg,omitempty"`
+	// PEM contains the public key of the RSA and EC keys
+	PEM string `json:"pem,omitempty"`
+	// Secret contains the base64 encoded value of the oct keys
+	Secret string `json:"secret,omitempty"`
+}
+
+type jsonWebKey struct {
+	Type      string `json:"kty"`
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+
+	// oct
+	K string `json:"k"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// Parse returns the keys of a JSON Web Key Set. The keys used for
+// encryption and the keys of unknown types are ignored.
+func Parse(data []byte) ([]Key, error) {
+	set := &jsonWebKeySet{}
+	err := json.Unmarshal(data, set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON Web Key Set: %v", err)
+	}
+
+	keys := make([]Key, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := parseJSONWebKey(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %v", jwk.ID, err)
+		}
+		if key == nil {
+			continue
+		}
+
+		keys = append(keys, *key)
+	}
+
+	sortKeys(keys)
+	return keys, nil
+}
+
+func parseJSONWebKey(jwk jsonWebKey) (*Key, error) {
+	key := &Key{
+		ID:        jwk.ID,
+		Type:      jwk.Type,
+		Algorithm: jwk.Algorithm,
+	}
+
+	switch jwk.Type {
+	case TypeRSA:
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+
+		key.PEM, err = encodePublicKey(&rsa.PublicKey{N: n, E: int(e.Int64())})
+		if err != nil {
+			return nil, err
+		}
+	case TypeEC:
+		curve, ok := curves[jwk.Curve]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("the point is not on the curve %v", jwk.Curve)
+		}
+
+		key.PEM, err = encodePublicKey(&ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+		if err != nil {
+			return nil, err
+		}
+	case TypeOct:
+		k, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.K, "="))
+		if err != nil {
+			return nil, err
+		}
+		if len(k) == 0 {
+			return nil, fmt.Errorf("empty key")
+		}
+
+		key.Secret = base64.StdEncoding.EncodeToString(k)
+	default:
+		return nil, nil
+	}
+
+	return key, nil
+}
+
+// ParsePEM returns the public key contained in a PEM block. The block can
+// contain a public key or a certificate.
+func ParsePEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var pub interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			pub = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		key.Type = TypeRSA
+	case *ecdsa.PublicKey:
+		key.Type = TypeEC
+	default:
+		return nil, fmt.Errorf("unsupported public key %T", pub)
+	}
+
+	key.PEM, err = encodePublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// FromSecret returns the keys contained in the data of a Secret. The
+// jwks.json key contains a JSON Web Key Set and every other key a PEM
+// encoded public key, using the name of the key as key ID.
+func FromSecret(data map[string][]byte) ([]Key, error) {
+	keys := []Key{}
+	for name, value := range data {
+		if name == SetFile {
+			set, err := Parse(value)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, set...)
+			continue
+		}
+
+		key, err := ParsePEM(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %v", name, err)
+		}
+		keys = append(keys, *key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found")
+	}
+
+	sortKeys(keys)
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("missing parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+func encodePublicKey(pub interface{}) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+func sortKeys(keys []Key) {
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func publicPEM(t *testing.T, pub interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestParse(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	set := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "b", "alg": "RS256", "use": "sig", "n": "%v", "e": "AQAB"},
+		{"kty": "EC", "kid": "a", "crv": "P-256", "x": "%v", "y": "%v"},
+		{"kty": "oct", "kid": "c", "k": "%v"},
+		{"kty": "RSA", "kid": "d", "use": "enc", "n": "%v", "e": "AQAB"},
+		{"kty": "OKP", "kid": "e", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	]}`, encode(rsaKey.N.Bytes()), encode(ecKey.X.Bytes()), encode(ecKey.Y.Bytes()), encode([]byte("secret")), encode(rsaKey.N.Bytes()))
+
+	keys, err := Parse([]byte(set))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Key{
+		{ID: "a", Type: TypeEC, PEM: publicPEM(t, &ecKey.PublicKey)},
+		{ID: "b", Type: TypeRSA, Algorithm: "RS256", PEM: publicPEM(t, &rsaKey.PublicKey)},
+		{ID: "c", Type: TypeOct, Secret: base64.StdEncoding.EncodeToString([]byte("secret"))},
+	}
+	if len(keys) != len(expected) {
+		t.Fatalf("expected %v keys but returned %v", len(expected), len(keys))
+	}
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Errorf("expected %+v but returned %+v", expected[i], keys[i])
+		}
+	}
+
+	invalid := []string{
+		`not json`,
+		`{"keys": [{"kty": "RSA", "kid": "a", "e": "AQAB"}]}`,
+		`{"keys": [{"kty": "EC", "kid": "a", "crv": "P-256", "x": "AQAB", "y": "AQAB"}]}`,
+		`{"keys": [{"kty": "EC", "kid": "a", "crv": "secp256k1", "x": "AQAB", "y": "AQAB"}]}`,
+	}
+	for _, s := range invalid {
+		if _, err := Parse([]byte(s)); err == nil {
+			t.Errorf("expected an error parsing %v", s)
+		}
+	}
+}
+
+func TestFromSecret(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)})
+
+	keys, err := FromSecret(map[string][]byte{
+		"rsa.pem": pkcs1,
+		SetFile:   []byte(`{"keys": [{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"}]}`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(keys) != 2 || keys[0].ID != "hmac" || keys[1].ID != "rsa.pem" {
+		t.Fatalf("unexpected keys %+v", keys)
+	}
+	if keys[1].Type != TypeRSA || keys[1].PEM != publicPEM(t, &rsaKey.PublicKey) {
+		t.Errorf("unexpected RSA key %+v", keys[1])
+	}
+
+	if _, err := FromSecret(map[string][]byte{"key": []byte("invalid")}); err == nil {
+		t.Errorf("expected an error with an invalid key")
+	}
+
+	if _, err := FromSecret(map[string][]byte{}); err == nil {
+		t.Errorf("expected an error without keys")
+	}
+}
+
+func TestCache(t *testing.T) {
+	var mu sync.Mutex
+	set := `{"keys": [{"kty": "oct", "kid": "a", "k": "c2VjcmV0"}]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprint(w, set)
+	}))
+	defer server.Close()
+
+	changes := make(chan struct{}, 10)
+	c := NewCache(time.Hour, func() { changes <- struct{}{} })
+
+	// the key set of a new URL is downloaded on first use
+	keys := c.Keys(server.URL)
+	if len(keys) != 1 || keys[0].ID != "a" {
+		t.Fatalf("unexpected keys %+v", keys)
+	}
+
+	// an unchanged key set does not notify
+	c.refreshAll()
+	select {
+	case <-changes:
+		t.Errorf("unexpected notification")
+	default:
+	}
+
+	mu.Lock()
+	set = `{"keys": [{"kty": "oct", "kid": "b", "k": "c2VjcmV0"}]}`
+	mu.Unlock()
+
+	c.refreshAll()
+	select {
+	case <-changes:
+	default:
+		t.Errorf("expected a notification")
+	}
+
+	data, _ := json.Marshal(c.Keys(server.URL))
+	if string(data) != `[{"kid":"b","kty":"oct","secret":"c2VjcmV0"}]` {
+		t.Errorf("unexpected keys %v", string(data))
+	}
+
+	c.Retain(sets.NewString())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.keys) != 0 {
+		t.Errorf("expected the key set to be removed")
+	}
+}
+
+func TestCacheUnavailable(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusServiceUnavailable
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"keys": [{"kty": "oct", "kid": "a", "k": "c2VjcmV0"}]}`)
+	}))
+	defer server.Close()
+
+	changes := make(chan struct{}, 10)
+	c := NewCache(time.Hour, func() { changes <- struct{}{} })
+
+	if keys := c.Keys(server.URL); keys != nil {
+		t.Errorf("expected no keys while the key set is unavailable but returned %v", keys)
+	}
+
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+
+	// the key set is downloaded by the next refresh
+	c.refreshAll()
+	select {
+	case <-changes:
+	default:
+		t.Errorf("expected a notification")
+	}
+
+	if keys := c.Keys(server.URL); len(keys) != 1 {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+}
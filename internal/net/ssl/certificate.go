@@ -14,7 +14,7 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package controller
+package ssl
 
 import (
 	"crypto/x509"
@@ -29,9 +29,9 @@ import (
 // We copy the code to not break existing clusters that doesn't have certificates with SAN yet
 // TODO: Remove this helpers in the future.
 
-// verifyHostname returns nil if c is a valid certificate for the named host.
+// VerifyHostname returns nil if c is a valid certificate for the named host.
 // Otherwise it returns an error describing the mismatch.
-func verifyHostname(h string, c *x509.Certificate) error {
+func VerifyHostname(h string, c *x509.Certificate) error {
 	// IP addresses may be written in [ ].
 	candidateIP := h
 	if len(h) >= 3 && h[0] == '[' && h[len(h)-1] == ']' {
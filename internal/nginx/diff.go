@@ -0,0 +1,432 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nginx
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"sort"
+	"syscall"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// Reasons of a difference between the desired and the running configuration
+const (
+	// DriftMissing means the item is desired but NGINX does not have it
+	DriftMissing = "missing"
+	// DriftUnexpected means NGINX has the item but it is not desired
+	DriftUnexpected = "unexpected"
+	// DriftChanged means both contain the item with different content
+	DriftChanged = "changed"
+)
+
+// Drift contains the differences between the configuration the controller
+// would send to NGINX and the configuration NGINX is running
+type Drift struct {
+	Backends     []BackendDrift     `json:"backends"`
+	Streams      []BackendDrift     `json:"streams"`
+	Certificates []CertificateDrift `json:"certificates"`
+	// General contains the fields of the general configuration with a different value
+	General []string `json:"general"`
+	// NginxConf contains the unified diff between the running and the desired nginx.conf
+	NginxConf string `json:"nginxConf"`
+}
+
+// BackendDrift describes the difference of a backend or a stream service
+type BackendDrift struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	// Fields contains the name of the attributes, other than the endpoints, with a different value
+	Fields []string `json:"fields,omitempty"`
+	// MissingEndpoints contains the desired endpoints NGINX does not have
+	MissingEndpoints []string `json:"missingEndpoints,omitempty"`
+	// UnexpectedEndpoints contains the endpoints NGINX has that are not desired
+	UnexpectedEndpoints []string `json:"unexpectedEndpoints,omitempty"`
+}
+
+// CertificateDrift describes the difference of a dynamic certificate
+type CertificateDrift struct {
+	Hostname string `json:"hostname"`
+	Reason   string `json:"reason"`
+}
+
+// InSync returns true if no differences were found
+func (d *Drift) InSync() bool {
+	return len(d.Backends) == 0 && len(d.Streams) == 0 && len(d.Certificates) == 0 &&
+		len(d.General) == 0 && d.NginxConf == ""
+}
+
+// RunningConfiguration contains the configuration read from NGINX
+type RunningConfiguration struct {
+	// Backends, Streams and General contain the JSON stored in the lua shared dictionaries
+	Backends []byte
+	Streams  []byte
+	General  []byte
+	// Certificates maps hostnames to the SHA1 checksum of the certificate
+	// loaded in NGINX, or to the empty string if there is no certificate
+	Certificates map[string]string
+	NginxConf    string
+}
+
+// ReadRunningConfiguration reads the configuration from the lua shared
+// dictionaries and the nginx.conf file. The lua code does not allow to list
+// the certificates, so only the given hostnames are read.
+func ReadRunningConfiguration(hostnames []string) (*RunningConfiguration, error) {
+	running := &RunningConfiguration{
+		Certificates: make(map[string]string),
+	}
+
+	var err error
+	running.Backends, err = getStatus("/configuration/backends")
+	if err != nil {
+		return nil, err
+	}
+
+	running.General, err = getStatus("/configuration/general")
+	if err != nil {
+		return nil, err
+	}
+
+	running.Streams, err = NewGetStreamRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hostname := range hostnames {
+		statusCode, body, err := NewGetStatusRequest("/configuration/certs?hostname=" + url.QueryEscape(hostname))
+		if err != nil {
+			return nil, err
+		}
+
+		switch statusCode {
+		case http.StatusOK:
+			running.Certificates[hostname] = fmt.Sprintf("%x", sha1.Sum(body))
+		case http.StatusNotFound:
+			running.Certificates[hostname] = ""
+		default:
+			return nil, fmt.Errorf("unexpected error code reading the certificate of %v: %d", hostname, statusCode)
+		}
+	}
+
+	running.NginxConf, err = ReadNginxConf()
+	if err != nil {
+		return nil, err
+	}
+
+	return running, nil
+}
+
+func getStatus(path string) ([]byte, error) {
+	statusCode, body, err := NewGetStatusRequest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected error code reading %v: %d", path, statusCode)
+	}
+
+	return body, nil
+}
+
+// Diff compares the desired and the running configuration
+func Diff(desired *ingress.DynamicConfiguration, running *RunningConfiguration) (*Drift, error) {
+	var err error
+	drift := &Drift{}
+
+	drift.Backends, err = diffBackends(desired.Backends, running.Backends)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing backends: %v", err)
+	}
+
+	drift.Streams, err = diffBackends(desired.Streams, running.Streams)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing streams: %v", err)
+	}
+
+	drift.General, err = diffGeneral(desired.General, running.General)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing the general configuration: %v", err)
+	}
+
+	drift.Certificates = diffCertificates(desired.Certificates, running.Certificates)
+
+	drift.NginxConf, err = diffNginxConf(desired.NginxConf, running.NginxConf)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing nginx.conf: %v", err)
+	}
+
+	return drift, nil
+}
+
+// toObjects converts a value to its generic JSON representation, so the
+// desired configuration can be compared with the JSON stored by lua
+func toObjects(v interface{}) ([]map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseObjects(b)
+}
+
+func parseObjects(data []byte) ([]map[string]interface{}, error) {
+	objects := make([]map[string]interface{}, 0)
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return objects, nil
+	}
+
+	err := json.Unmarshal(data, &objects)
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+func byName(objects []map[string]interface{}) map[string]map[string]interface{} {
+	index := make(map[string]map[string]interface{})
+	for _, obj := range objects {
+		index[fmt.Sprintf("%v", obj["name"])] = obj
+	}
+	return index
+}
+
+func diffBackends(desired interface{}, running []byte) ([]BackendDrift, error) {
+	desiredObjects, err := toObjects(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	runningObjects, err := parseObjects(running)
+	if err != nil {
+		return nil, err
+	}
+
+	desiredByName := byName(desiredObjects)
+	runningByName := byName(runningObjects)
+
+	drift := make([]BackendDrift, 0)
+	for _, name := range sortedKeys(desiredByName) {
+		d := desiredByName[name]
+		r, ok := runningByName[name]
+		if !ok {
+			drift = append(drift, BackendDrift{Name: name, Reason: DriftMissing})
+			continue
+		}
+
+		desiredEndpoints := endpoints(d["endpoints"])
+		runningEndpoints := endpoints(r["endpoints"])
+
+		bd := BackendDrift{
+			Name:                name,
+			Reason:              DriftChanged,
+			Fields:              diffFields(d, r, "name", "endpoints"),
+			MissingEndpoints:    difference(desiredEndpoints, runningEndpoints),
+			UnexpectedEndpoints: difference(runningEndpoints, desiredEndpoints),
+		}
+
+		if len(bd.Fields) > 0 || len(bd.MissingEndpoints) > 0 || len(bd.UnexpectedEndpoints) > 0 {
+			drift = append(drift, bd)
+		}
+	}
+
+	for _, name := range sortedKeys(runningByName) {
+		if _, ok := desiredByName[name]; !ok {
+			drift = append(drift, BackendDrift{Name: name, Reason: DriftUnexpected})
+		}
+	}
+
+	return drift, nil
+}
+
+func diffGeneral(desired ingress.GeneralConfig, running []byte) ([]string, error) {
+	d, err := toObjects([]ingress.GeneralConfig{desired})
+	if err != nil {
+		return nil, err
+	}
+
+	r := make(map[string]interface{})
+	running = bytes.TrimSpace(running)
+	if len(running) > 0 {
+		err = json.Unmarshal(running, &r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return diffFields(d[0], r), nil
+}
+
+func diffCertificates(desired, running map[string]string) []CertificateDrift {
+	hostnames := make([]string, 0, len(desired))
+	for hostname := range desired {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	drift := make([]CertificateDrift, 0)
+	for _, hostname := range hostnames {
+		checksum := running[hostname]
+		switch {
+		case checksum == "":
+			drift = append(drift, CertificateDrift{Hostname: hostname, Reason: DriftMissing})
+		case checksum != desired[hostname]:
+			drift = append(drift, CertificateDrift{Hostname: hostname, Reason: DriftChanged})
+		}
+	}
+
+	return drift
+}
+
+// checksumRegex matches the checksum of the configuration, which is only
+// updated when NGINX is reloaded
+var checksumRegex = regexp.MustCompile(`(?m)^# Configuration checksum:.*$`)
+
+func diffNginxConf(desired, running string) (string, error) {
+	desired = checksumRegex.ReplaceAllString(desired, "")
+	running = checksumRegex.ReplaceAllString(running, "")
+	return UnifiedDiff(running, desired, "running", "desired")
+}
+
+// UnifiedDiff returns the output of diff -u between two texts, or the empty
+// string if they are equal
+func UnifiedDiff(from, to, fromLabel, toLabel string) (string, error) {
+	if from == to {
+		return "", nil
+	}
+
+	fromFile, err := writeTempFile("from-nginx-cfg", from)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(fromFile)
+
+	toFile, err := writeTempFile("to-nginx-cfg", to)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(toFile)
+
+	out, err := exec.Command("diff", "-u", "--label", fromLabel, "--label", toLabel, fromFile, toFile).CombinedOutput()
+	if err != nil {
+		// diff returns 1 when the files are different
+		if exitError, ok := err.(*exec.ExitError); !ok || exitError.Sys().(syscall.WaitStatus).ExitStatus() != 1 {
+			return "", fmt.Errorf("%v\n%v", err, string(out))
+		}
+	}
+
+	return string(out), nil
+}
+
+func writeTempFile(pattern, content string) (string, error) {
+	f, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	if err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// diffFields returns the sorted names of the fields with a different value,
+// without the ignored ones
+func diffFields(desired, running map[string]interface{}, ignored ...string) []string {
+	skip := make(map[string]bool)
+	for _, field := range ignored {
+		skip[field] = true
+	}
+
+	fields := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, obj := range []map[string]interface{}{desired, running} {
+		for field := range obj {
+			if skip[field] || seen[field] {
+				continue
+			}
+			seen[field] = true
+
+			if !reflect.DeepEqual(desired[field], running[field]) {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	sort.Strings(fields)
+	return fields
+}
+
+// endpoints returns the address:port of the endpoints of a backend
+func endpoints(v interface{}) map[string]bool {
+	out := make(map[string]bool)
+
+	list, ok := v.([]interface{})
+	if !ok {
+		return out
+	}
+
+	for _, item := range list {
+		ep, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		out[fmt.Sprintf("%v:%v", ep["address"], ep["port"])] = true
+	}
+
+	return out
+}
+
+// difference returns the sorted items of a not present in b
+func difference(a, b map[string]bool) []string {
+	var out []string
+	for item := range a {
+		if !b[item] {
+			out = append(out, item)
+		}
+	}
+
+	sort.Strings(out)
+	return out
+}
+
+func sortedKeys(m map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
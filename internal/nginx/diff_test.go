@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nginx
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+func TestDiff(t *testing.T) {
+	desired := &ingress.DynamicConfiguration{
+		Backends: []*ingress.Backend{
+			{
+				Name: "default-app-80",
+				Port: intstr.FromInt(80),
+				Endpoints: []ingress.Endpoint{
+					{Address: "10.0.0.1", Port: "8080"},
+					{Address: "10.0.0.2", Port: "8080"},
+				},
+			},
+			{
+				Name: "default-new-80",
+				Port: intstr.FromInt(80),
+			},
+			{
+				Name:     "default-sticky-80",
+				Port:     intstr.FromInt(80),
+				NoServer: true,
+			},
+		},
+		Streams: []ingress.Backend{
+			{
+				Name:      "tcp-default-db-5432",
+				Port:      intstr.FromInt(5432),
+				Endpoints: []ingress.Endpoint{{Address: "10.0.1.1", Port: "5432"}},
+			},
+		},
+		General: ingress.GeneralConfig{ControllerPodsCount: 2},
+		Certificates: map[string]string{
+			"a.example.com": "aaa",
+			"b.example.com": "bbb",
+			"c.example.com": "ccc",
+		},
+		NginxConf: "# Configuration checksum: 1\nhttp {}\n",
+	}
+
+	running := &RunningConfiguration{
+		Backends: marshal(t, []*ingress.Backend{
+			{
+				Name: "default-app-80",
+				Port: intstr.FromInt(80),
+				Endpoints: []ingress.Endpoint{
+					{Address: "10.0.0.1", Port: "8080"},
+					{Address: "10.0.0.3", Port: "8080"},
+				},
+			},
+			{
+				Name: "default-old-80",
+				Port: intstr.FromInt(80),
+			},
+			{
+				Name: "default-sticky-80",
+				Port: intstr.FromInt(80),
+			},
+		}),
+		Streams: marshal(t, desired.Streams),
+		General: []byte(`{"controllerPodsCount":1}`),
+		Certificates: map[string]string{
+			"a.example.com": "aaa",
+			"b.example.com": "old",
+			"c.example.com": "",
+		},
+		// the checksum is only updated after a reload
+		NginxConf: "# Configuration checksum: 2\nhttp {}\n",
+	}
+
+	drift, err := Diff(desired, running)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := &Drift{
+		Backends: []BackendDrift{
+			{
+				Name:                "default-app-80",
+				Reason:              DriftChanged,
+				Fields:              []string{},
+				MissingEndpoints:    []string{"10.0.0.2:8080"},
+				UnexpectedEndpoints: []string{"10.0.0.3:8080"},
+			},
+			{Name: "default-new-80", Reason: DriftMissing},
+			{Name: "default-sticky-80", Reason: DriftChanged, Fields: []string{"noServer"}},
+			{Name: "default-old-80", Reason: DriftUnexpected},
+		},
+		Streams: []BackendDrift{},
+		Certificates: []CertificateDrift{
+			{Hostname: "b.example.com", Reason: DriftChanged},
+			{Hostname: "c.example.com", Reason: DriftMissing},
+		},
+		General: []string{"controllerPodsCount"},
+	}
+
+	if !reflect.DeepEqual(drift, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, drift)
+	}
+
+	if drift.InSync() {
+		t.Errorf("expected configuration not in sync")
+	}
+}
+
+func TestDiffInSync(t *testing.T) {
+	desired := &ingress.DynamicConfiguration{
+		Backends: []*ingress.Backend{
+			{
+				Name:      "default-app-80",
+				Port:      intstr.FromInt(80),
+				Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}},
+			},
+		},
+		Certificates: map[string]string{},
+	}
+
+	running := &RunningConfiguration{
+		Backends: marshal(t, desired.Backends),
+		General:  []byte(`{"controllerPodsCount":0}`),
+	}
+
+	drift, err := Diff(desired, running)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !drift.InSync() {
+		t.Errorf("expected configuration in sync but returned %+v", drift)
+	}
+}
+
+func marshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return b
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nginx
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// BackendsHealthPath defines the path used to read the state of the active
+// health checks of the endpoints
+var BackendsHealthPath = "/configuration/health"
+
+// EndpointHealth contains the state of the active health checks of an
+// endpoint
+type EndpointHealth struct {
+	Healthy bool `json:"healthy"`
+	// Failures is the number of consecutive failed checks
+	Failures int `json:"failures"`
+	// Successes is the number of consecutive successful checks
+	Successes int `json:"successes"`
+	// Status is the status code of the last response
+	Status int `json:"status,omitempty"`
+	// Error contains the reason of the failure of the last check
+	Error string `json:"error,omitempty"`
+	// CheckedAt is the Unix time of the last check
+	CheckedAt float64 `json:"checkedAt,omitempty"`
+}
+
+// BackendsHealth contains the state of the endpoints of the backends with
+// active health checks, indexed by the name of the backend and the
+// address of the endpoint
+type BackendsHealth map[string]map[string]EndpointHealth
+
+// ReadBackendsHealth returns the state of the active health checks
+func ReadBackendsHealth() (BackendsHealth, error) {
+	status, body, err := NewGetStatusRequest(BackendsHealthPath)
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		return nil, fmt.Errorf("nginx returned code %v", status)
+	}
+
+	health := BackendsHealth{}
+	if err := json.Unmarshal(body, &health); err != nil {
+		return nil, err
+	}
+
+	return health, nil
+}
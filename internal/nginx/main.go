@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -99,6 +100,33 @@ func NewPostStatusRequest(path, contentType string, data interface{}) (int, []by
 	return res.StatusCode, body, nil
 }
 
+// NewGetStreamRequest reads the TCP and UDP configuration from the NGINX
+// stream configuration socket
+func NewGetStreamRequest() ([]byte, error) {
+	conn, err := net.DialTimeout("unix", StreamSocket, HealthCheckTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(HealthCheckTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintf(conn, "GET\r\n")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSpace(data), nil
+}
+
 // GetServerBlock takes an nginx.conf file and a host and tries to find the server block for that host
 func GetServerBlock(conf string, host string) (string, error) {
 	startMsg := fmt.Sprintf("## start server %v\n", host)
@@ -0,0 +1,147 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// maxDocumentSize is the maximum size of a discovery document
+const maxDocumentSize = 1 << 20
+
+// Cache keeps the configuration of the providers, discovering it again
+// periodically. It is safe for concurrent use.
+type Cache struct {
+	mu sync.Mutex
+
+	client *http.Client
+	period time.Duration
+
+	// providers contains the configuration of every issuer. A nil value
+	// means the discovery did not succeed yet.
+	providers map[string]*Provider
+
+	// onChange is called after the configuration of an issuer changes
+	onChange func()
+}
+
+// NewCache creates a cache discovering the providers every period
+func NewCache(period time.Duration, onChange func()) *Cache {
+	return &Cache{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		period:    period,
+		providers: make(map[string]*Provider),
+		onChange:  onChange,
+	}
+}
+
+// Provider returns the configuration of issuer. New issuers are
+// discovered in the background.
+func (c *Cache) Provider(issuer string) *Provider {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.providers[issuer]
+	if !ok {
+		c.providers[issuer] = nil
+		go c.refresh(issuer)
+	}
+
+	return p
+}
+
+// Retain removes the providers not contained in issuers
+func (c *Cache) Retain(issuers sets.String) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for issuer := range c.providers {
+		if !issuers.Has(issuer) {
+			delete(c.providers, issuer)
+		}
+	}
+}
+
+// Run discovers the providers every period until stopCh is closed
+func (c *Cache) Run(stopCh <-chan struct{}) {
+	wait.Until(c.refreshAll, c.period, stopCh)
+}
+
+func (c *Cache) refreshAll() {
+	c.mu.Lock()
+	issuers := make([]string, 0, len(c.providers))
+	for issuer := range c.providers {
+		issuers = append(issuers, issuer)
+	}
+	c.mu.Unlock()
+
+	for _, issuer := range issuers {
+		c.refresh(issuer)
+	}
+}
+
+// refresh discovers the configuration of issuer, keeping the previous
+// configuration on error
+func (c *Cache) refresh(issuer string) {
+	p, err := c.discover(issuer)
+	if err != nil {
+		klog.Warningf("Error discovering the OpenID Connect provider %v: %v", issuer, err)
+		return
+	}
+
+	c.mu.Lock()
+	old, ok := c.providers[issuer]
+	if !ok || (old != nil && *old == *p) {
+		c.mu.Unlock()
+		return
+	}
+	c.providers[issuer] = p
+	c.mu.Unlock()
+
+	klog.Infof("OpenID Connect provider %v updated", issuer)
+	if c.onChange != nil {
+		c.onChange()
+	}
+}
+
+func (c *Cache) discover(issuer string) (*Provider, error) {
+	resp, err := c.client.Get(DiscoveryURL(issuer))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDiscovery(issuer, data)
+}
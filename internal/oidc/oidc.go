@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package oidc reads the configuration of OpenID Connect providers and
+// the credentials of the clients used to authenticate users.
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// ClientIDKey is the key of the Secret containing the client ID
+	ClientIDKey = "client-id"
+	// ClientSecretKey is the key of the Secret containing the client secret
+	ClientSecretKey = "client-secret"
+	// CookieSecretKey is the key of the Secret containing the secret used
+	// to encrypt the session cookies
+	CookieSecretKey = "cookie-secret"
+)
+
+// Provider contains the endpoints of an OpenID Connect provider
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	EndSessionEndpoint    string `json:"end_session_endpoint,omitempty"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Client contains the credentials of an OpenID Connect client
+type Client struct {
+	ID     string `json:"client_id"`
+	Secret string `json:"client_secret"`
+	// CookieSecret is the base64 encoded secret used to encrypt the
+	// session cookies
+	CookieSecret string `json:"cookie_secret"`
+}
+
+// DiscoveryURL returns the URL of the configuration of the provider (OpenID
+// Connect Discovery 1.0)
+func DiscoveryURL(issuer string) string {
+	return strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+}
+
+// ParseDiscovery returns the provider described in a discovery document
+func ParseDiscovery(issuer string, data []byte) (*Provider, error) {
+	p := &Provider{}
+	err := json.Unmarshal(data, p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid discovery document: %v", err)
+	}
+
+	if strings.TrimSuffix(p.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return nil, fmt.Errorf("the issuer %q does not match the expected issuer %q", p.Issuer, issuer)
+	}
+
+	endpoints := map[string]string{
+		"authorization_endpoint": p.AuthorizationEndpoint,
+		"token_endpoint":         p.TokenEndpoint,
+		"jwks_uri":               p.JWKSURI,
+	}
+	for name, endpoint := range endpoints {
+		if !isHTTPURL(endpoint) {
+			return nil, fmt.Errorf("invalid %v %q", name, endpoint)
+		}
+	}
+	if p.EndSessionEndpoint != "" && !isHTTPURL(p.EndSessionEndpoint) {
+		return nil, fmt.Errorf("invalid end_session_endpoint %q", p.EndSessionEndpoint)
+	}
+
+	return p, nil
+}
+
+// ClientFromSecret returns the client contained in the data of a Secret.
+// When the Secret does not contain a cookie secret one is derived from
+// the client secret.
+func ClientFromSecret(data map[string][]byte) (*Client, error) {
+	id := strings.TrimSpace(string(data[ClientIDKey]))
+	if id == "" {
+		return nil, fmt.Errorf("the secret does not contain a key %v", ClientIDKey)
+	}
+
+	secret := strings.TrimSpace(string(data[ClientSecretKey]))
+	if secret == "" {
+		return nil, fmt.Errorf("the secret does not contain a key %v", ClientSecretKey)
+	}
+
+	cookieSecret, ok := data[CookieSecretKey]
+	if ok && len(cookieSecret) < 16 {
+		return nil, fmt.Errorf("the %v must contain at least 16 bytes", CookieSecretKey)
+	}
+	if !ok {
+		sum := sha256.Sum256([]byte("oidc-cookie:" + secret))
+		cookieSecret = sum[:]
+	}
+
+	return &Client{
+		ID:           id,
+		Secret:       secret,
+		CookieSecret: base64.StdEncoding.EncodeToString(cookieSecret),
+	}, nil
+}
+
+// IsValidIssuer returns true if issuer can be used as issuer of a provider
+func IsValidIssuer(issuer string) bool {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "https" && u.Host != "" && u.RawQuery == "" && u.Fragment == ""
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func discoveryDocument(issuer string) string {
+	return fmt.Sprintf(`{
+		"issuer": "%[1]v",
+		"authorization_endpoint": "%[1]v/authorize",
+		"token_endpoint": "%[1]v/token",
+		"end_session_endpoint": "%[1]v/logout",
+		"jwks_uri": "%[1]v/keys"
+	}`, issuer)
+}
+
+func TestParseDiscovery(t *testing.T) {
+	issuer := "https://auth.example.com"
+
+	p, err := ParseDiscovery(issuer+"/", []byte(discoveryDocument(issuer)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := Provider{
+		Issuer:                issuer,
+		AuthorizationEndpoint: issuer + "/authorize",
+		TokenEndpoint:         issuer + "/token",
+		EndSessionEndpoint:    issuer + "/logout",
+		JWKSURI:               issuer + "/keys",
+	}
+	if *p != expected {
+		t.Errorf("expected %+v but returned %+v", expected, *p)
+	}
+
+	invalid := []string{
+		"not json",
+		discoveryDocument("https://other.example.com"),
+		`{"issuer": "https://auth.example.com", "authorization_endpoint": "/authorize"}`,
+	}
+	for _, doc := range invalid {
+		if _, err := ParseDiscovery(issuer, []byte(doc)); err == nil {
+			t.Errorf("expected an error parsing %v", doc)
+		}
+	}
+}
+
+func TestClientFromSecret(t *testing.T) {
+	c, err := ClientFromSecret(map[string][]byte{
+		ClientIDKey:     []byte("app"),
+		ClientSecretKey: []byte("secret\n"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.ID != "app" || c.Secret != "secret" || c.CookieSecret == "" {
+		t.Errorf("unexpected client %+v", c)
+	}
+
+	other, _ := ClientFromSecret(map[string][]byte{
+		ClientIDKey:     []byte("app"),
+		ClientSecretKey: []byte("other"),
+	})
+	if other.CookieSecret == c.CookieSecret {
+		t.Errorf("expected different cookie secrets for different client secrets")
+	}
+
+	c, err = ClientFromSecret(map[string][]byte{
+		ClientIDKey:     []byte("app"),
+		ClientSecretKey: []byte("secret"),
+		CookieSecretKey: []byte("0123456789abcdef"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.CookieSecret != "MDEyMzQ1Njc4OWFiY2RlZg==" {
+		t.Errorf("unexpected cookie secret %v", c.CookieSecret)
+	}
+
+	invalid := []map[string][]byte{
+		{ClientSecretKey: []byte("secret")},
+		{ClientIDKey: []byte("app")},
+		{ClientIDKey: []byte("app"), ClientSecretKey: []byte("secret"), CookieSecretKey: []byte("short")},
+	}
+	for _, data := range invalid {
+		if _, err := ClientFromSecret(data); err == nil {
+			t.Errorf("expected an error with %v", data)
+		}
+	}
+}
+
+func TestIsValidIssuer(t *testing.T) {
+	testCases := map[string]bool{
+		"https://auth.example.com":          true,
+		"https://auth.example.com/realms/a": true,
+		"http://auth.example.com":           false,
+		"https://auth.example.com?a=b":      false,
+		"auth.example.com":                  false,
+	}
+
+	for issuer, expected := range testCases {
+		if actual := IsValidIssuer(issuer); actual != expected {
+			t.Errorf("expected %v for %v but returned %v", expected, issuer, actual)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, discoveryDocument(server.URL))
+	}))
+	defer server.Close()
+
+	changes := make(chan struct{}, 10)
+	c := NewCache(time.Hour, func() { changes <- struct{}{} })
+
+	if p := c.Provider(server.URL); p != nil {
+		t.Errorf("expected no provider before the discovery but returned %+v", p)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the provider to be discovered")
+	}
+
+	p := c.Provider(server.URL)
+	if p == nil || p.TokenEndpoint != server.URL+"/token" {
+		t.Fatalf("unexpected provider %+v", p)
+	}
+
+	// an unchanged provider does not notify
+	c.refreshAll()
+	select {
+	case <-changes:
+		t.Errorf("unexpected notification")
+	default:
+	}
+}
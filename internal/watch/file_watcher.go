@@ -18,6 +18,7 @@ package watch
 
 import (
 	"log"
+	"os"
 	"path"
 	"strings"
 
@@ -52,7 +53,8 @@ func (f OSFileWatcher) Close() error {
 	return f.watcher.Close()
 }
 
-// watch creates a fsnotify watcher for a file and create of write events
+// watch creates a fsnotify watcher for a file and create, write, remove
+// or rename events
 func (f *OSFileWatcher) watch() error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -65,9 +67,7 @@ func (f *OSFileWatcher) watch() error {
 		for {
 			select {
 			case event := <-watcher.Events:
-				if (event.Op&fsnotify.Write == fsnotify.Write ||
-					event.Op&fsnotify.Create == fsnotify.Create) &&
-					strings.HasSuffix(event.Name, file) {
+				if isChange(event) && strings.HasSuffix(event.Name, file) {
 					f.onEvent()
 				}
 			case err := <-watcher.Errors:
@@ -79,3 +79,88 @@ func (f *OSFileWatcher) watch() error {
 	}(file)
 	return watcher.Add(dir)
 }
+
+// OSDirectoryWatcher defines a watch over the files of a directory that
+// can be created, removed or replaced after the watch starts
+type OSDirectoryWatcher struct {
+	dir     string
+	watcher *fsnotify.Watcher
+	// onEvent callback to be invoked after the directory or its files change
+	onEvent func()
+}
+
+// NewDirectoryWatcher creates a new FileWatcher over the files of a
+// directory. The parent directory is watched as well, so the directory
+// does not need to exist when the watch starts.
+func NewDirectoryWatcher(dir string, onEvent func()) (FileWatcher, error) {
+	dw := OSDirectoryWatcher{
+		dir:     path.Clean(dir),
+		onEvent: onEvent,
+	}
+	err := dw.watch()
+	return dw, err
+}
+
+// Close ends the watch
+func (d OSDirectoryWatcher) Close() error {
+	return d.watcher.Close()
+}
+
+// watch creates a fsnotify watcher for the directory and its parent
+func (d *OSDirectoryWatcher) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	d.watcher = watcher
+
+	err = watcher.Add(path.Dir(d.dir))
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	// the directory is watched once it is created
+	if err := watcher.Add(d.dir); err != nil && !os.IsNotExist(err) {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case event := <-watcher.Events:
+				if !isChange(event) {
+					continue
+				}
+
+				switch {
+				case event.Name == d.dir:
+					if event.Op&fsnotify.Create == fsnotify.Create {
+						if err := watcher.Add(d.dir); err != nil {
+							log.Printf("error watching directory: %v\n", err)
+						}
+					}
+					if event.Op&fsnotify.Rename == fsnotify.Rename {
+						// the watch follows the directory moved away
+						watcher.Remove(d.dir)
+					}
+					d.onEvent()
+				case path.Dir(event.Name) == d.dir:
+					d.onEvent()
+				}
+			case err := <-watcher.Errors:
+				if err != nil {
+					log.Printf("error watching directory: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// isChange returns true if the event creates, writes, removes or renames a file
+func isChange(event fsnotify.Event) bool {
+	return event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0
+}
@@ -19,6 +19,7 @@ package watch
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -67,3 +68,94 @@ func TestFileWatcher(t *testing.T) {
 		t.Fatalf("expected an event shortly after writing a file")
 	}
 }
+
+func TestFileWatcherRemove(t *testing.T) {
+	f, err := ioutil.TempFile("", "fw")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	events := make(chan bool, 10)
+	fw, err := NewFileWatcher(f.Name(), func() {
+		events <- true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer fw.Close()
+
+	os.Remove(f.Name())
+	select {
+	case <-events:
+	case <-prepareTimeout():
+		t.Fatalf("expected an event shortly after removing the file")
+	}
+}
+
+func TestDirectoryWatcher(t *testing.T) {
+	parent, err := ioutil.TempDir("", "dw")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(parent)
+
+	dir := filepath.Join(parent, "partials")
+
+	events := make(chan bool, 100)
+	dw, err := NewDirectoryWatcher(dir, func() {
+		events <- true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error watching a directory that does not exist: %v", err)
+	}
+	defer dw.Close()
+
+	expectEvent := func(action string) {
+		select {
+		case <-events:
+		case <-prepareTimeout():
+			t.Fatalf("expected an event shortly after %v", action)
+		}
+		// drain the other events of the same change
+		for {
+			select {
+			case <-events:
+			case <-time.After(100 * time.Millisecond):
+				return
+			}
+		}
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectEvent("creating the directory")
+
+	partial := filepath.Join(dir, "server.tmpl")
+	ioutil.WriteFile(partial, []byte{}, file.ReadWriteByUser)
+	expectEvent("creating a file")
+
+	os.Rename(partial, filepath.Join(dir, "location.tmpl"))
+	expectEvent("renaming a file")
+
+	os.Remove(filepath.Join(dir, "location.tmpl"))
+	expectEvent("removing a file")
+
+	ioutil.WriteFile(filepath.Join(parent, "other.tmpl"), []byte{}, file.ReadWriteByUser)
+	select {
+	case <-events:
+		t.Fatalf("expected no events after writing a file outside of the directory")
+	case <-prepareTimeout():
+	}
+
+	os.Remove(dir)
+	expectEvent("removing the directory")
+
+	os.Mkdir(dir, 0755)
+	expectEvent("creating the directory again")
+
+	ioutil.WriteFile(partial, []byte{}, file.ReadWriteByUser)
+	expectEvent("creating a file in the new directory")
+}
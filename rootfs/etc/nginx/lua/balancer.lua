@@ -3,10 +3,15 @@ local cjson = require("cjson.safe")
 local util = require("util")
 local dns_util = require("util.dns")
 local configuration = require("configuration")
+local healthcheck = require("healthcheck")
+local outlier_detection = require("outlier_detection")
+local circuit_breaker = require("circuit_breaker")
+local retry_budget = require("retry_budget")
 local round_robin = require("balancer.round_robin")
 local chash = require("balancer.chash")
 local chashsubset = require("balancer.chashsubset")
 local sticky = require("balancer.sticky")
+local affinity_hash = require("balancer.affinity_hash")
 local ewma = require("balancer.ewma")
 
 -- measured in seconds
@@ -20,6 +25,7 @@ local IMPLEMENTATIONS = {
   chash = chash,
   chashsubset = chashsubset,
   sticky = sticky,
+  affinity_hash = affinity_hash,
   ewma = ewma,
 }
 
@@ -29,8 +35,12 @@ local balancers = {}
 local function get_implementation(backend)
   local name = backend["load-balance"] or DEFAULT_LB_ALG
 
-  if backend["sessionAffinityConfig"] and backend["sessionAffinityConfig"]["name"] == "cookie" then
+  local affinity_type = backend["sessionAffinityConfig"] and backend["sessionAffinityConfig"]["name"]
+
+  if affinity_type == "cookie" then
     name = "sticky"
+  elseif affinity_type == "header" or affinity_type == "ip" then
+    name = "affinity_hash"
   elseif backend["upstreamHashByConfig"] and backend["upstreamHashByConfig"]["upstream-hash-by"] then
     if backend["upstreamHashByConfig"]["upstream-hash-by-subset"] then
       name = "chashsubset"
@@ -80,6 +90,11 @@ local function sync_backend(backend)
     return
   end
 
+  -- the endpoints failing the active health checks are excluded from every implementation
+  backend.endpoints = healthcheck.healthy_endpoints(backend)
+  -- and so are the endpoints ejected by the outlier detection
+  backend.endpoints = outlier_detection.available_endpoints(backend)
+
   local implementation = get_implementation(backend)
   local balancer = balancers[backend.name]
 
@@ -121,6 +136,10 @@ local function sync_backends()
     return
   end
 
+  healthcheck.sync(new_backends)
+  outlier_detection.sync(new_backends)
+  circuit_breaker.sync(new_backends)
+
   local balancers_to_keep = {}
   for _, new_backend in ipairs(new_backends) do
     sync_backend(new_backend)
@@ -213,20 +232,43 @@ local function get_balancer()
   return balancer
 end
 
+-- get_backend_name returns the name of the backend of the balancer chosen
+-- by get_balancer
+local function get_backend_name()
+  local alternative_backend_name = ngx.var.proxy_alternative_upstream_name
+  if alternative_backend_name and alternative_backend_name ~= "" then
+    return alternative_backend_name
+  end
+
+  return ngx.var.proxy_upstream_name
+end
+
 function _M.init_worker()
   sync_backends() -- when worker starts, sync backends without delay
   local _, err = ngx.timer.every(BACKENDS_SYNC_INTERVAL, sync_backends)
   if err then
     ngx.log(ngx.ERR, string.format("error when setting up timer.every for sync_backends: %s", tostring(err)))
   end
+
+  healthcheck.init_worker()
 end
 
-function _M.rewrite()
+-- rewrite takes the retry policy of the location, applied to the
+-- attempts of the request in balance
+function _M.rewrite(retry_policy)
   local balancer = get_balancer()
   if not balancer then
     ngx.status = ngx.HTTP_SERVICE_UNAVAILABLE
     return ngx.exit(ngx.status)
   end
+
+  if not circuit_breaker.acquire(get_backend_name()) then
+    ngx.status = ngx.HTTP_SERVICE_UNAVAILABLE
+    return ngx.exit(ngx.status)
+  end
+
+  ngx.ctx.retry_policy = retry_policy
+  retry_budget.start(get_backend_name(), retry_policy)
 end
 
 function _M.balance()
@@ -235,6 +277,14 @@ function _M.balance()
     return
   end
 
+  local retry_policy = ngx.ctx.retry_policy
+
+  -- a previous attempt of the request failed
+  if ngx_balancer.get_last_failure() and not retry_budget.allow(retry_policy) then
+    ngx.ctx.retry_budget_exhausted = true
+    return ngx.exit(ngx.ERROR)
+  end
+
   local peer = balancer:balance()
   if not peer then
     ngx.log(ngx.WARN, "no peer was returned, balancer: " .. balancer.name)
@@ -243,18 +293,37 @@ function _M.balance()
 
   ngx_balancer.set_more_tries(1)
 
+  if retry_policy and retry_policy.per_try_timeout and retry_policy.per_try_timeout > 0 then
+    local ok, err = ngx_balancer.set_timeouts(nil, retry_policy.per_try_timeout, retry_policy.per_try_timeout)
+    if not ok then
+      ngx.log(ngx.ERR, string.format("error while setting the timeouts of the attempt: %s", err))
+    end
+  end
+
   local ok, err = ngx_balancer.set_current_peer(peer)
   if not ok then
     ngx.log(ngx.ERR, string.format("error while setting current upstream peer %s: %s", peer, err))
   end
 end
 
+-- release releases the concurrent request and the retry budget reserved in
+-- the rewrite phase. It is called in the log phase of the custom error
+-- pages too.
+function _M.release()
+  circuit_breaker.release()
+  retry_budget.release()
+end
+
 function _M.log()
+  _M.release()
+
   local balancer = get_balancer()
   if not balancer then
     return
   end
 
+  outlier_detection.after_request(get_backend_name())
+
   if not balancer.after_balance then
     return
   end
@@ -0,0 +1,174 @@
+local balancer_resty = require("balancer.resty")
+local resty_chash = require("resty.chash")
+local util = require("util")
+local math = require("math")
+
+local string_format = string.format
+local ngx_log = ngx.log
+local INFO = ngx.INFO
+
+local HEADER_AFFINITY = "header"
+local IP_AFFINITY = "ip"
+local DEFAULT_IP_PREFIX_LENGTH = 32
+local DEFAULT_IPV6_PREFIX_LENGTH = 128
+
+-- affinity_hash keeps the sessions identified by a request header or by the
+-- address of the client on the same endpoint using consistent hashing, so
+-- adding or removing an endpoint only moves the sessions of a fraction of
+-- the keys
+local _M = balancer_resty:new({ factory = resty_chash, name = "affinity_hash" })
+
+function _M.new(self, backend)
+  local nodes = util.get_nodes(backend.endpoints)
+
+  local o = {
+    instance = self.factory:new(nodes),
+    traffic_shaping_policy = backend.trafficShapingPolicy,
+    alternative_backends = backend.alternativeBackends,
+    session_affinity = backend.sessionAffinityConfig,
+  }
+  setmetatable(o, self)
+  self.__index = self
+  return o
+end
+
+-- mask keeps the first bits of a number of the given size
+local function mask(value, size, bits)
+  if bits >= size then
+    return value
+  end
+  if bits <= 0 then
+    return 0
+  end
+
+  local divisor = 2 ^ (size - bits)
+  return math.floor(value / divisor) * divisor
+end
+
+local function ipv4_prefix(address, length)
+  local octets = { address:match("^(%d+)%.(%d+)%.(%d+)%.(%d+)$") }
+  if #octets ~= 4 then
+    return nil
+  end
+
+  for i, octet in ipairs(octets) do
+    octets[i] = mask(tonumber(octet), 8, length - 8 * (i - 1))
+  end
+
+  return table.concat(octets, ".")
+end
+
+local function expand_ipv6(address)
+  local groups = {}
+
+  local head, tail = address:match("^(.-)::(.*)$")
+  if not head then
+    for group in address:gmatch("[^:]+") do
+      table.insert(groups, group)
+    end
+    return groups
+  end
+
+  local tail_groups = {}
+  for group in head:gmatch("[^:]+") do
+    table.insert(groups, group)
+  end
+  for group in tail:gmatch("[^:]+") do
+    table.insert(tail_groups, group)
+  end
+
+  for _ = 1, 8 - #groups - #tail_groups do
+    table.insert(groups, "0")
+  end
+  for _, group in ipairs(tail_groups) do
+    table.insert(groups, group)
+  end
+
+  return groups
+end
+
+local function ipv6_prefix(address, length)
+  local groups = expand_ipv6(address)
+  if #groups ~= 8 then
+    return nil
+  end
+
+  for i, group in ipairs(groups) do
+    local value = tonumber(group, 16)
+    if not value then
+      return nil
+    end
+    groups[i] = string_format("%x", mask(value, 16, length - 16 * (i - 1)))
+  end
+
+  return table.concat(groups, ":")
+end
+
+-- ip_prefix returns the network of the client address with the given
+-- prefix lengths, so the clients of the same network share the session
+local function ip_prefix(address, length, ipv6_length)
+  if not address then
+    return nil
+  end
+
+  -- IPv4-mapped IPv6 addresses use the IPv4 prefix length
+  local ipv4 = address:match("^::[fF][fF][fF][fF]:(%d+%.%d+%.%d+%.%d+)$") or address
+  if ipv4:find(".", 1, true) then
+    return ipv4_prefix(ipv4, length or DEFAULT_IP_PREFIX_LENGTH)
+  end
+
+  return ipv6_prefix(address, ipv6_length or DEFAULT_IPV6_PREFIX_LENGTH)
+end
+
+local function header_variable(name)
+  return "http_" .. name:lower():gsub("-", "_")
+end
+
+-- key returns the value identifying the session of the request
+function _M.key(self)
+  local affinity_type = self.session_affinity.name
+
+  if affinity_type == HEADER_AFFINITY then
+    local header = self.session_affinity.headerSessionAffinity or {}
+    if not header.name then
+      return nil
+    end
+    return ngx.var[header_variable(header.name)]
+  end
+
+  if affinity_type == IP_AFFINITY then
+    local ip = self.session_affinity.ipSessionAffinity or {}
+    return ip_prefix(ngx.var.remote_addr, ip.prefixLength, ip.ipv6PrefixLength)
+  end
+
+  return nil
+end
+
+function _M.balance(self)
+  local key = self:key()
+  if not key or key == "" then
+    -- requests without session are distributed randomly
+    key = string_format("%s.%s.%s", ngx.now(), ngx.worker.pid(), math.random(999999))
+  end
+
+  return self.instance:find(key)
+end
+
+function _M.sync(self, backend)
+  balancer_resty.sync(self, backend)
+
+  local changed = not util.deep_compare(self.session_affinity, backend.sessionAffinityConfig)
+  if not changed then
+    return
+  end
+
+  ngx_log(INFO, string_format("[%s] session affinity has changed for backend %s", self.name, backend.name))
+
+  self.session_affinity = backend.sessionAffinityConfig
+end
+
+if _TEST then
+  _M.ip_prefix = ip_prefix
+end
+
+return _M
@@ -12,6 +12,7 @@ local INFO = ngx.INFO
 
 local _M = balancer_resty:new({ factory = resty_chash, name = "sticky" })
 local DEFAULT_COOKIE_NAME = "route"
+local PERSISTENT_MODE = "persistent"
 
 -- Consider the situation of N upstreams one of which is failing.
 -- Then the probability to obtain failing upstream after M iterations would be close to (1/N)**M.
@@ -23,6 +24,20 @@ function _M.cookie_name(self)
   return self.cookie_session_affinity.name or DEFAULT_COOKIE_NAME
 end
 
+-- endpoint_id returns the value of the cookie of an endpoint in persistent
+-- mode, hiding its address from the clients
+local function endpoint_id(endpoint)
+  return ngx.md5(endpoint)
+end
+
+local function get_endpoint_ids(nodes)
+  local ids = {}
+  for endpoint, _ in pairs(nodes) do
+    ids[endpoint_id(endpoint)] = endpoint
+  end
+  return ids
+end
+
 function _M.new(self, backend)
   local nodes = util.get_nodes(backend.endpoints)
 
@@ -30,13 +45,19 @@ function _M.new(self, backend)
     instance = self.factory:new(nodes),
     traffic_shaping_policy = backend.trafficShapingPolicy,
     alternative_backends = backend.alternativeBackends,
-    cookie_session_affinity = backend["sessionAffinityConfig"]["cookieSessionAffinity"]
+    cookie_session_affinity = backend["sessionAffinityConfig"]["cookieSessionAffinity"],
+    affinity_mode = backend["sessionAffinityConfig"]["mode"],
+    endpoint_ids = get_endpoint_ids(nodes),
   }
   setmetatable(o, self)
   self.__index = self
   return o
 end
 
+function _M.is_persistent(self)
+  return self.affinity_mode == PERSISTENT_MODE
+end
+
 local function set_cookie(self, value)
   local cookie, err = ck:new()
   if not cookie then
@@ -127,7 +148,17 @@ function _M.balance(self)
   local upstream_from_cookie
 
   local key = cookie:get(self:cookie_name())
-  if key then
+  if key and self:is_persistent() then
+    upstream_from_cookie = self.endpoint_ids[key]
+    if not upstream_from_cookie then
+      -- the endpoint of the session was removed, every replica remaps the
+      -- sessions of the endpoint to the same remaining one
+      upstream_from_cookie = self.instance:find(key)
+      if upstream_from_cookie and should_set_cookie(self) then
+        set_cookie(self, endpoint_id(upstream_from_cookie))
+      end
+    end
+  elseif key then
     upstream_from_cookie = self.instance:find(key)
   end
 
@@ -145,6 +176,9 @@ function _M.balance(self)
   if not new_upstream then
     ngx.log(ngx.WARN, string.format("failed to get new upstream; using upstream %s", new_upstream))
   elseif should_set_cookie(self) then
+    if self:is_persistent() then
+      key = endpoint_id(new_upstream)
+    end
     set_cookie(self, key)
   end
 
@@ -154,11 +188,13 @@ end
 function _M.sync(self, backend)
   balancer_resty.sync(self, backend)
 
+  self.endpoint_ids = get_endpoint_ids(util.get_nodes(backend.endpoints))
+
   -- Reload the balancer if any of the annotations have changed.
   local changed = not util.deep_compare(
     self.cookie_session_affinity,
     backend.sessionAffinityConfig.cookieSessionAffinity
-  )
+  ) or self.affinity_mode ~= backend.sessionAffinityConfig.mode
   if not changed then
     return
   end
@@ -166,6 +202,7 @@ function _M.sync(self, backend)
   ngx_log(INFO, string_format("[%s] nodes have changed for backend %s", self.name, backend.name))
 
   self.cookie_session_affinity = backend.sessionAffinityConfig.cookieSessionAffinity
+  self.affinity_mode = backend.sessionAffinityConfig.mode
 end
 
 return _M
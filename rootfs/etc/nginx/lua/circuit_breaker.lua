@@ -0,0 +1,112 @@
+local ngx = ngx
+local string_format = string.format
+
+-- measured in seconds
+local PENDING_POLL_INTERVAL = 0.01
+
+local _M = {}
+
+-- limits of the backends with circuit breaking, indexed by name
+local limits = {}
+
+local function active_key(backend_name)
+  return "active:" .. backend_name
+end
+
+local function pending_key(backend_name)
+  return "pending:" .. backend_name
+end
+
+-- try_acquire reserves one of the concurrent requests of the backend
+local function try_acquire(dict, backend_name, config)
+  local active, err = dict:incr(active_key(backend_name), 1, 0)
+  if not active then
+    ngx.log(ngx.ERR, string_format("error incrementing active requests of backend %s: %s", backend_name, err))
+    -- fail open, the limits are not enforced when the dictionary is full
+    return true
+  end
+
+  if active > config.maxRequests then
+    dict:incr(active_key(backend_name), -1, 0)
+    return false
+  end
+
+  return true
+end
+
+-- wait waits for a concurrent request of the backend to complete, as long
+-- as the pending requests and the pending timeout allow it
+local function wait(dict, backend_name, config)
+  if not config.maxPendingRequests or config.maxPendingRequests <= 0 then
+    return false
+  end
+
+  local pending = dict:incr(pending_key(backend_name), 1, 0)
+  if not pending or pending > config.maxPendingRequests then
+    dict:incr(pending_key(backend_name), -1, 0)
+    return false
+  end
+
+  local deadline = ngx.now() + (config.pendingTimeout or 5)
+  local acquired = false
+  repeat
+    ngx.sleep(PENDING_POLL_INTERVAL)
+    ngx.update_time()
+    acquired = try_acquire(dict, backend_name, config)
+  until acquired or ngx.now() >= deadline
+
+  dict:incr(pending_key(backend_name), -1, 0)
+
+  return acquired
+end
+
+-- acquire reserves a concurrent request of the backend and returns false
+-- when the limits are reached. The reservation is released by release.
+function _M.acquire(backend_name)
+  local config = limits[backend_name]
+  local dict = ngx.shared.circuit_breaker
+  if not config or not dict then
+    return true
+  end
+
+  if not try_acquire(dict, backend_name, config) and not wait(dict, backend_name, config) then
+    ngx.log(ngx.WARN, string_format("maximum of concurrent requests reached for backend %s", backend_name))
+    return false
+  end
+
+  -- the variable survives the internal redirects to the custom error pages
+  ngx.var.circuit_breaker_backend = backend_name
+
+  return true
+end
+
+-- release releases the concurrent request reserved by the request, if any
+function _M.release()
+  local backend_name = ngx.var.circuit_breaker_backend
+  if not backend_name or backend_name == "" then
+    return
+  end
+
+  ngx.var.circuit_breaker_backend = ""
+
+  local dict = ngx.shared.circuit_breaker
+  if dict then
+    dict:incr(active_key(backend_name), -1, 0)
+  end
+end
+
+-- sync replaces the limits of the backends of the worker
+function _M.sync(new_backends)
+  local new_limits = {}
+
+  for _, backend in ipairs(new_backends) do
+    local config = backend.circuitBreaker
+    if config and (config.maxRequests or 0) > 0 then
+      new_limits[backend.name] = config
+    end
+  end
+
+  limits = new_limits
+end
+
+return _M
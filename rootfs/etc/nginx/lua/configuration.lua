@@ -1,4 +1,5 @@
 local cjson = require("cjson.safe")
+local healthcheck = require("healthcheck")
 
 -- this is the Lua representation of Configuration struct in internal/ingress/types.go
 local configuration_data = ngx.shared.configuration_data
@@ -14,6 +15,18 @@ function _M.get_general_data()
   return configuration_data:get("general")
 end
 
+function _M.get_jwt_keys_data()
+  return configuration_data:get("jwt_keys")
+end
+
+function _M.get_oidc_data()
+  return configuration_data:get("oidc")
+end
+
+function _M.get_locations_data()
+  return configuration_data:get("locations")
+end
+
 local function fetch_request_body()
   ngx.req.read_body()
   local body = ngx.req.get_body_data()
@@ -106,6 +119,96 @@ local function handle_general()
   ngx.status = ngx.HTTP_CREATED
 end
 
+local function handle_jwt_keys()
+  if ngx.var.request_method == "GET" then
+    ngx.status = ngx.HTTP_OK
+    ngx.print(_M.get_jwt_keys_data())
+    return
+  end
+
+  local keys = fetch_request_body()
+  if not keys then
+    ngx.log(ngx.ERR, "dynamic-configuration: unable to read valid request body")
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  local success, err = configuration_data:safe_set("jwt_keys", keys)
+  if not success then
+    ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+    ngx.log(ngx.ERR, "error setting JWT keys: " .. tostring(err))
+    return
+  end
+
+  ngx.status = ngx.HTTP_CREATED
+end
+
+local function handle_oidc()
+  if ngx.var.request_method == "GET" then
+    ngx.status = ngx.HTTP_OK
+    ngx.print(_M.get_oidc_data())
+    return
+  end
+
+  local oidc = fetch_request_body()
+  if not oidc then
+    ngx.log(ngx.ERR, "dynamic-configuration: unable to read valid request body")
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  local success, err = configuration_data:safe_set("oidc", oidc)
+  if not success then
+    ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+    ngx.log(ngx.ERR, "error setting OpenID Connect configuration: " .. tostring(err))
+    return
+  end
+
+  ngx.status = ngx.HTTP_CREATED
+end
+
+local function handle_locations()
+  if ngx.var.request_method == "GET" then
+    ngx.status = ngx.HTTP_OK
+    ngx.print(_M.get_locations_data())
+    return
+  end
+
+  local locations = fetch_request_body()
+  if not locations then
+    ngx.log(ngx.ERR, "dynamic-configuration: unable to read valid request body")
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  local success, err = configuration_data:safe_set("locations", locations)
+  if not success then
+    ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+    ngx.log(ngx.ERR, "error setting the settings of the locations: " .. tostring(err))
+    return
+  end
+
+  ngx.status = ngx.HTTP_CREATED
+end
+
+local function handle_health()
+  if ngx.var.request_method ~= "GET" then
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    ngx.print("Only GET requests are allowed!")
+    return
+  end
+
+  local status, err = cjson.encode(healthcheck.status())
+  if not status then
+    ngx.log(ngx.ERR, "could not encode the health check state: ", err)
+    ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+    return
+  end
+
+  ngx.status = ngx.HTTP_OK
+  ngx.print(status)
+end
+
 local function handle_certs()
   if ngx.var.request_method ~= "GET" then
     ngx.status = ngx.HTTP_BAD_REQUEST
@@ -149,6 +252,26 @@ function _M.call()
     return
   end
 
+  if ngx.var.request_uri == "/configuration/jwt-keys" then
+    handle_jwt_keys()
+    return
+  end
+
+  if ngx.var.request_uri == "/configuration/oidc" then
+    handle_oidc()
+    return
+  end
+
+  if ngx.var.request_uri == "/configuration/locations" then
+    handle_locations()
+    return
+  end
+
+  if ngx.var.request_uri == "/configuration/health" then
+    handle_health()
+    return
+  end
+
   if ngx.var.uri == "/configuration/certs" then
     handle_certs()
     return
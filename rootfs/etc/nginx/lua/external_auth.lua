@@ -0,0 +1,130 @@
+local ngx = ngx
+local tonumber = tonumber
+
+local _M = {}
+
+local function circuit_key(url)
+  return "open:" .. url
+end
+
+local function failures_key(url)
+  return "failures:" .. url
+end
+
+-- access rejects the requests to the authentication service while its
+-- circuit is open. It runs in the subrequest of auth_request.
+function _M.access(config)
+  local dict = ngx.shared.external_auth
+  if not dict or config.circuit_breaker_failures <= 0 then
+    return
+  end
+
+  if dict:get(circuit_key(config.url)) then
+    ngx.log(ngx.INFO, "circuit open for the authentication service ", config.url)
+    return ngx.exit(ngx.HTTP_SERVICE_UNAVAILABLE)
+  end
+end
+
+-- result classifies the response of the authentication service using the
+-- variables of the subrequest set with auth_request_set
+local function result()
+  local last_status
+  for code in (ngx.var.auth_upstream_status or ""):gmatch("%d%d%d") do
+    last_status = tonumber(code)
+  end
+
+  -- NGINX returns 502 and 504 on connection errors and timeouts
+  if last_status and last_status >= 500 then
+    return "error"
+  end
+
+  -- without response of the service or the cache the subrequest was
+  -- rejected by the circuit breaker or could not be sent
+  local cache_status = ngx.var.auth_cache_status or ""
+  if not last_status and (cache_status == "" or cache_status == "BYPASS") then
+    return "unavailable"
+  end
+
+  local status = tonumber(ngx.var.auth_status)
+  if status == ngx.HTTP_UNAUTHORIZED or status == ngx.HTTP_FORBIDDEN then
+    return "denied"
+  end
+
+  return "allowed"
+end
+
+local function response_time()
+  local value = ngx.var.auth_response_time
+  if not value or value == "" then
+    return -1
+  end
+
+  -- the times of every attempt are separated by commas or colons
+  local total
+  for time in value:gmatch("[%d.]+") do
+    total = (total or 0) + tonumber(time)
+  end
+
+  return total or -1
+end
+
+local function update_circuit(config, res)
+  local dict = ngx.shared.external_auth
+  if not dict or config.circuit_breaker_failures <= 0 then
+    return
+  end
+
+  local key = failures_key(config.url)
+
+  if res == "allowed" or res == "denied" then
+    if (dict:get(key) or 0) > 0 then
+      dict:set(key, 0)
+    end
+    return
+  end
+
+  if res ~= "error" then
+    return
+  end
+
+  local failures, err = dict:incr(key, 1, 0)
+  if not failures then
+    ngx.log(ngx.ERR, "error counting failures of the authentication service ", config.url, ": ", err)
+    return
+  end
+
+  if failures >= config.circuit_breaker_failures and not dict:get(circuit_key(config.url)) then
+    ngx.log(ngx.WARN, "opening circuit for the authentication service ", config.url,
+      " after ", failures, " consecutive failures")
+    dict:set(circuit_key(config.url), true, config.circuit_breaker_duration)
+    -- a single failure after the circuit closes opens it again
+    dict:set(key, config.circuit_breaker_failures - 1)
+  end
+end
+
+-- log records the result of the authentication of the request, updating
+-- the circuit breaker and the metrics sent by the monitor module
+function _M.log(config)
+  local status = ngx.var.auth_status
+  if not status or status == "" then
+    -- the authentication was not requested
+    return
+  end
+
+  local res = result()
+  update_circuit(config, res)
+
+  ngx.ctx.external_auth = {
+    url = config.url,
+    result = res,
+    response_time = response_time(),
+    cache_status = ngx.var.auth_cache_status or "",
+  }
+end
+
+if _TEST then
+  _M.result = result
+  _M.response_time = response_time
+end
+
+return _M
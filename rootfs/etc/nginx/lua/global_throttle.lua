@@ -0,0 +1,204 @@
+local ngx_re_gsub = ngx.re.gsub
+local cjson = require("cjson.safe")
+local iputils = require("resty.iputils")
+local util = require("util")
+
+local math_floor = math.floor
+local math_ceil = math.ceil
+local math_min = math.min
+local string_format = string.format
+
+local _M = {}
+
+local CACHE_DICT = "global_throttle_cache"
+
+local stores = {
+  memcached = require("global_throttle.memcached"),
+  redis = require("global_throttle.redis"),
+  ["local"] = require("global_throttle.shdict"),
+}
+
+-- parsed ignored CIDRs indexed by the namespace of the location
+local ignored_cidrs_cache = {}
+
+-- jwt_claim returns a claim of the bearer token verified by jwt_auth.
+-- The claims are only available once the request is authenticated.
+local function jwt_claim(name)
+  local claims = ngx.ctx.jwt_claims
+  if type(claims) ~= "table" or claims[name] == nil or claims[name] == cjson.null then
+    return nil
+  end
+
+  return tostring(claims[name])
+end
+
+-- resolve_key replaces the NGINX variables in key with their values.
+-- $jwt_claim_<name> is replaced with the claim <name> of the verified token.
+local function resolve_key(key)
+  local value, _, err = ngx_re_gsub(key, [[\$(\w+)]], function(m)
+    local claim = m[1]:match("^jwt_claim_(.+)$")
+    if claim then
+      return jwt_claim(claim) or ""
+    end
+
+    return util.lua_ngx_var("$" .. m[1]) or ""
+  end, "jo")
+  if err then
+    return nil, err
+  end
+
+  return value
+end
+
+local function is_ignored(location)
+  if not location.ignored_cidrs or #location.ignored_cidrs == 0 then
+    return false
+  end
+
+  local cached = ignored_cidrs_cache[location.namespace]
+  local source = table.concat(location.ignored_cidrs, ",")
+  if not cached or cached.source ~= source then
+    cached = { source = source, cidrs = iputils.parse_cidrs(location.ignored_cidrs) }
+    ignored_cidrs_cache[location.namespace] = cached
+  end
+
+  return iputils.ip_in_cidrs(ngx.var.remote_addr, cached.cidrs)
+end
+
+-- count adds the request to the counter of the current window and returns
+-- the number of requests received in the last window_size seconds and the
+-- number of seconds until the count goes below the limit
+local function count(store, location, key)
+  local now = ngx.now()
+  local size = location.window_size
+  local window = math_floor(now / size)
+  local elapsed = now - window * size
+  local prefix = string_format("%s:%s:", location.namespace, ngx.md5(key))
+
+  -- the counter is read by the next window when using the sliding window
+  local current, err = store:incr(prefix .. window, 1, size * 2)
+  if not current then
+    return nil, nil, err
+  end
+
+  if location.algorithm == "fixed-window" then
+    return current, size - elapsed
+  end
+
+  local previous
+  previous, err = store:get(prefix .. (window - 1))
+  if not previous then
+    return nil, nil, err
+  end
+
+  local estimate = previous * (size - elapsed) / size + current
+
+  -- the weight of the previous window decreases until the end of the
+  -- current one, when the current window becomes the previous one
+  local delay = size - elapsed
+  if previous > 0 then
+    delay = math_min((estimate - location.limit) * size / previous, delay)
+  end
+
+  return estimate, delay
+end
+
+local function reject(config, delay)
+  if delay and delay > 0 then
+    ngx.header["Retry-After"] = math_ceil(delay)
+  end
+
+  return ngx.exit(config.status_code)
+end
+
+local function new_store(config)
+  local store = stores[config.store]
+  if not store then
+    return nil, "unknown store " .. tostring(config.store)
+  end
+
+  if config.store ~= "local" and (not config.host or config.host == "") then
+    return nil, "the global-rate-limit-host setting is not configured"
+  end
+
+  return store.new(config)
+end
+
+-- throttle rejects the request when the client identified by the key of the
+-- location exceeded the limit. config contains the store configuration
+-- and location the limit of the location.
+function _M.throttle(config, location)
+  if not config or not location or is_ignored(location) then
+    return
+  end
+
+  local key, err = resolve_key(location.key)
+  if not key then
+    ngx.log(ngx.ERR, "error building the global rate limit key: ", err)
+    return
+  end
+  if key == "" then
+    return
+  end
+
+  local cache = ngx.shared[CACHE_DICT]
+  local blocked_key = string_format("blocked:%s:%s", location.namespace, ngx.md5(key))
+
+  -- avoid the round trip to the store while the client is over the limit
+  if cache then
+    local blocked_until = cache:get(blocked_key)
+    if blocked_until then
+      return reject(config, blocked_until - ngx.now())
+    end
+  end
+
+  local store, delay, current
+  store, err = new_store(config)
+  if store then
+    current, delay, err = count(store, location, key)
+  end
+
+  if err then
+    ngx.log(ngx.ERR, "global rate limit store ", config.store, " is unavailable: ", err)
+
+    if config.fallback == "deny" then
+      return reject(config)
+    end
+    if config.fallback ~= "local" then
+      return
+    end
+
+    store, err = stores["local"].new()
+    if not store then
+      ngx.log(ngx.ERR, "error using the local global rate limit store: ", err)
+      return
+    end
+
+    current, delay, err = count(store, location, key)
+    if err then
+      ngx.log(ngx.ERR, "error counting the request in the local store: ", err)
+      return
+    end
+  end
+
+  if current <= location.limit then
+    return
+  end
+
+  if cache and delay > 0 then
+    local ok
+    ok, err = cache:safe_set(blocked_key, ngx.now() + delay, delay)
+    if not ok then
+      ngx.log(ngx.WARN, "error caching the global rate limit decision: ", err)
+    end
+  end
+
+  return reject(config, delay)
+end
+
+if _TEST then
+  _M.resolve_key = resolve_key
+  _M.stores = stores
+end
+
+return _M
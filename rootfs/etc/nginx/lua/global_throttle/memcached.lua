@@ -0,0 +1,77 @@
+local memcached = require("resty.memcached")
+
+local _M = {}
+local mt = { __index = _M }
+
+function _M.new(options)
+  return setmetatable({ options = options }, mt)
+end
+
+local function with_client(self, command)
+  local memc, err = memcached:new()
+  if not memc then
+    return nil, err
+  end
+
+  memc:set_timeout(self.options.connect_timeout)
+
+  local ok
+  ok, err = memc:connect(self.options.host, self.options.port)
+  if not ok then
+    return nil, err
+  end
+
+  local value, command_err = command(memc)
+
+  ok, err = memc:set_keepalive(self.options.max_idle_timeout, self.options.pool_size)
+  if not ok then
+    ngx.log(ngx.WARN, "failed to keep the memcached connection alive: ", err)
+  end
+
+  return value, command_err
+end
+
+-- incr increments the counter stored in key, creating it
+-- with the given ttl (in seconds) when it does not exist
+function _M.incr(self, key, delta, ttl)
+  return with_client(self, function(memc)
+    local value, err = memc:incr(key, delta)
+    if value then
+      return tonumber(value)
+    end
+    if err ~= "NOT_FOUND" then
+      return nil, err
+    end
+
+    local ok
+    ok, err = memc:add(key, delta, ttl)
+    if ok then
+      return delta
+    end
+    if err ~= "NOT_STORED" then
+      return nil, err
+    end
+
+    -- the key was created by another NGINX instance in the meantime
+    value, err = memc:incr(key, delta)
+    if not value then
+      return nil, err
+    end
+
+    return tonumber(value)
+  end)
+end
+
+-- get returns the counter stored in key or 0 when it does not exist
+function _M.get(self, key)
+  return with_client(self, function(memc)
+    local value, _, err = memc:get(key)
+    if err then
+      return nil, err
+    end
+
+    return tonumber(value) or 0
+  end)
+end
+
+return _M
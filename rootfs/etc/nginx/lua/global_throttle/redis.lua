@@ -0,0 +1,72 @@
+local redis = require("resty.redis")
+
+local _M = {}
+local mt = { __index = _M }
+
+function _M.new(options)
+  return setmetatable({ options = options }, mt)
+end
+
+local function with_client(self, command)
+  local red, err = redis:new()
+  if not red then
+    return nil, err
+  end
+
+  red:set_timeout(self.options.connect_timeout)
+
+  local ok
+  ok, err = red:connect(self.options.host, self.options.port)
+  if not ok then
+    return nil, err
+  end
+
+  local value, command_err = command(red)
+
+  ok, err = red:set_keepalive(self.options.max_idle_timeout, self.options.pool_size)
+  if not ok then
+    ngx.log(ngx.WARN, "failed to keep the redis connection alive: ", err)
+  end
+
+  return value, command_err
+end
+
+-- incr increments the counter stored in key, creating it
+-- with the given ttl (in seconds) when it does not exist
+function _M.incr(self, key, delta, ttl)
+  return with_client(self, function(red)
+    red:init_pipeline()
+    red:incrby(key, delta)
+    red:expire(key, ttl)
+
+    local res, err = red:commit_pipeline()
+    if not res then
+      return nil, err
+    end
+
+    -- errors of the commands in the pipeline are returned as { false, err }
+    if type(res[1]) == "table" then
+      return nil, res[1][2]
+    end
+
+    return tonumber(res[1])
+  end)
+end
+
+-- get returns the counter stored in key or 0 when it does not exist
+function _M.get(self, key)
+  return with_client(self, function(red)
+    local value, err = red:get(key)
+    if err then
+      return nil, err
+    end
+
+    if value == ngx.null then
+      return 0
+    end
+
+    return tonumber(value) or 0
+  end)
+end
+
+return _M
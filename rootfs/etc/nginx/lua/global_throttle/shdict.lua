@@ -0,0 +1,35 @@
+-- shdict keeps the counters in a shared dictionary of the NGINX instance.
+-- It is used when the store is "local", as fallback when the external store
+-- is unreachable and in the tests.
+local _M = {}
+local mt = { __index = _M }
+
+local DEFAULT_DICT = "global_throttle_cache"
+
+function _M.new(options)
+  local dict_name = options and options.dict or DEFAULT_DICT
+  local dict = ngx.shared[dict_name]
+  if not dict then
+    return nil, "shared dictionary " .. dict_name .. " is not defined"
+  end
+
+  return setmetatable({ dict = dict }, mt)
+end
+
+-- incr increments the counter stored in key, creating it
+-- with the given ttl (in seconds) when it does not exist
+function _M.incr(self, key, delta, ttl)
+  return self.dict:incr(key, delta, 0, ttl)
+end
+
+-- get returns the counter stored in key or 0 when it does not exist
+function _M.get(self, key)
+  local value, err = self.dict:get(key)
+  if err then
+    return nil, err
+  end
+
+  return value or 0
+end
+
+return _M
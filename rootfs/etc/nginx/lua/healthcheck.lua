@@ -0,0 +1,240 @@
+local cjson = require("cjson.safe")
+local http = require("resty.http")
+
+local ngx = ngx
+local tonumber = tonumber
+local string_format = string.format
+
+-- measured in seconds
+-- the endpoints are checked by the first worker that runs its timer once
+-- their interval has elapsed
+local CHECK_TIMER_INTERVAL = 1
+
+local USER_AGENT = "ingress-nginx-healthcheck"
+
+local _M = {}
+
+-- backends with active health checks, indexed by name
+local backends = {}
+
+local function endpoint_name(endpoint)
+  return endpoint.address .. ":" .. endpoint.port
+end
+
+local function state_key(backend_name, endpoint)
+  return "state:" .. backend_name .. "/" .. endpoint_name(endpoint)
+end
+
+local function lock_key(backend_name, endpoint)
+  return "lock:" .. backend_name .. "/" .. endpoint_name(endpoint)
+end
+
+local function get_state(backend_name, endpoint)
+  local dict = ngx.shared.healthcheck
+  if not dict then
+    return nil
+  end
+
+  local data = dict:get(state_key(backend_name, endpoint))
+  if not data then
+    return nil
+  end
+
+  return cjson.decode(data)
+end
+
+-- expected_status returns true if the status code is in the comma
+-- separated list of codes and ranges, e.g. 200,300-399
+local function expected_status(status, expected)
+  for code in (expected or ""):gmatch("[^,]+") do
+    local min, max = code:match("^(%d+)-(%d+)$")
+    if min then
+      if status >= tonumber(min) and status <= tonumber(max) then
+        return true
+      end
+    elseif status == tonumber(code) then
+      return true
+    end
+  end
+
+  return false
+end
+
+local function check_url(config, endpoint)
+  local address = endpoint.address
+  if address:find(":", 1, true) then
+    address = "[" .. address .. "]"
+  end
+
+  return string_format("%s://%s:%s%s", config.scheme or "http", address, endpoint.port, config.path)
+end
+
+-- record updates the state of the endpoint with the result of a check. The
+-- state changes once the number of consecutive results reaches the
+-- threshold. Endpoints without state are healthy.
+local function record(backend_name, config, endpoint, status, err)
+  local dict = ngx.shared.healthcheck
+  if not dict then
+    return
+  end
+
+  local state = get_state(backend_name, endpoint) or { healthy = true, failures = 0, successes = 0 }
+
+  local success = status and expected_status(status, config.expectedStatus)
+  if success then
+    state.successes = state.successes + 1
+    state.failures = 0
+
+    if not state.healthy and state.successes >= config.healthyThreshold then
+      ngx.log(ngx.NOTICE, string_format("endpoint %s of backend %s is healthy",
+        endpoint_name(endpoint), backend_name))
+      state.healthy = true
+    end
+  else
+    state.failures = state.failures + 1
+    state.successes = 0
+
+    if state.healthy and state.failures >= config.unhealthyThreshold then
+      ngx.log(ngx.WARN, string_format("endpoint %s of backend %s is unhealthy: %s",
+        endpoint_name(endpoint), backend_name, err or ("unexpected status code " .. tostring(status))))
+      state.healthy = false
+    end
+  end
+
+  state.status = status
+  state.error = err
+  state.checkedAt = ngx.now()
+
+  -- the state of the endpoints that are not checked anymore expires
+  local ttl = config.interval * 3 + config.timeout
+  local ok, set_err = dict:set(state_key(backend_name, endpoint), cjson.encode(state), ttl)
+  if not ok then
+    ngx.log(ngx.ERR, "error saving the health check state: ", tostring(set_err))
+  end
+end
+
+local function check(premature, backend_name, config, endpoint)
+  if premature then
+    return
+  end
+
+  local headers = { ["User-Agent"] = USER_AGENT }
+  if config.host and config.host ~= "" then
+    headers["Host"] = config.host
+  end
+
+  local httpc = http.new()
+  httpc:set_timeout(config.timeout * 1000)
+
+  local res, err = httpc:request_uri(check_url(config, endpoint), {
+    method = "GET",
+    headers = headers,
+    ssl_verify = false,
+  })
+  if not res then
+    record(backend_name, config, endpoint, nil, err)
+    return
+  end
+
+  record(backend_name, config, endpoint, res.status, nil)
+end
+
+local function run_checks(premature)
+  if premature then
+    return
+  end
+
+  local dict = ngx.shared.healthcheck
+  if not dict then
+    return
+  end
+
+  for name, backend in pairs(backends) do
+    local config = backend.healthCheck
+    for _, endpoint in ipairs(backend.endpoints) do
+      -- the lock makes sure every endpoint is checked once per interval by
+      -- a single worker
+      local ok = dict:add(lock_key(name, endpoint), true, config.interval)
+      if ok then
+        local _, err = ngx.timer.at(0, check, name, config, endpoint)
+        if err then
+          ngx.log(ngx.ERR, "error creating the health check timer: ", tostring(err))
+        end
+      end
+    end
+  end
+end
+
+-- sync replaces the backends checked by the worker
+function _M.sync(new_backends)
+  local checked = {}
+
+  for _, backend in ipairs(new_backends) do
+    local config = backend.healthCheck
+    if config and config.path and config.path ~= "" and backend.endpoints then
+      local endpoints = {}
+      for _, endpoint in ipairs(backend.endpoints) do
+        table.insert(endpoints, { address = endpoint.address, port = endpoint.port })
+      end
+      checked[backend.name] = { healthCheck = config, endpoints = endpoints }
+    end
+  end
+
+  backends = checked
+end
+
+-- healthy_endpoints returns the endpoints of the backend that are not
+-- unhealthy. When every endpoint is unhealthy all of them are returned,
+-- as the active health checks could be failing for another reason.
+function _M.healthy_endpoints(backend)
+  local config = backend.healthCheck
+  if not config or not config.path or config.path == "" or not backend.endpoints then
+    return backend.endpoints
+  end
+
+  local endpoints = {}
+  for _, endpoint in ipairs(backend.endpoints) do
+    local state = get_state(backend.name, endpoint)
+    if not state or state.healthy then
+      table.insert(endpoints, endpoint)
+    end
+  end
+
+  if #endpoints == 0 then
+    ngx.log(ngx.INFO, string_format("every endpoint of backend %s is unhealthy, using all of them", backend.name))
+    return backend.endpoints
+  end
+
+  return endpoints
+end
+
+-- status returns the state of the endpoints of every backend with active
+-- health checks indexed by the name of the backend and the endpoint
+function _M.status()
+  local status = {}
+
+  for name, backend in pairs(backends) do
+    local endpoints = {}
+    for _, endpoint in ipairs(backend.endpoints) do
+      endpoints[endpoint_name(endpoint)] = get_state(name, endpoint) or { healthy = true, failures = 0, successes = 0 }
+    end
+    status[name] = endpoints
+  end
+
+  return status
+end
+
+function _M.init_worker()
+  local _, err = ngx.timer.every(CHECK_TIMER_INTERVAL, run_checks)
+  if err then
+    ngx.log(ngx.ERR, string_format("error when setting up timer.every for health checks: %s", tostring(err)))
+  end
+end
+
+if _TEST then
+  _M.expected_status = expected_status
+  _M.record = record
+  _M.run_checks = run_checks
+end
+
+return _M
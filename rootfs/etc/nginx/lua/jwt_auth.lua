@@ -0,0 +1,441 @@
+local ffi = require("ffi")
+local bit = require("bit")
+local cjson = require("cjson.safe")
+local configuration = require("configuration")
+local util = require("util")
+
+local C = ffi.C
+local ffi_gc = ffi.gc
+local ffi_new = ffi.new
+local ffi_string = ffi.string
+local string_format = string.format
+local table_concat = table.concat
+
+local _M = {}
+
+-- the declarations are loaded one by one because some of them can be
+-- already declared by other modules using OpenSSL
+local declarations = {
+  "void *BIO_new_mem_buf(const void *buf, int len);",
+  "int BIO_free(void *a);",
+  "void *PEM_read_bio_PUBKEY(void *bp, void **x, void *cb, void *u);",
+  "void EVP_PKEY_free(void *pkey);",
+  "void *EVP_MD_CTX_new(void);",
+  "void EVP_MD_CTX_free(void *ctx);",
+  "void *EVP_MD_CTX_create(void);",
+  "void EVP_MD_CTX_destroy(void *ctx);",
+  "const void *EVP_sha256(void);",
+  "const void *EVP_sha384(void);",
+  "const void *EVP_sha512(void);",
+  "int EVP_DigestVerifyInit(void *ctx, void **pctx, const void *type, void *e, void *pkey);",
+  "int EVP_DigestUpdate(void *ctx, const void *d, size_t cnt);",
+  "int EVP_DigestVerifyFinal(void *ctx, const unsigned char *sig, size_t siglen);",
+  "unsigned char *HMAC(const void *evp_md, const void *key, int key_len, "
+    .. "const unsigned char *d, size_t n, unsigned char *md, unsigned int *md_len);",
+  "void ERR_clear_error(void);",
+}
+for _, declaration in ipairs(declarations) do
+  pcall(ffi.cdef, declaration)
+end
+
+-- OpenSSL 1.1 renamed EVP_MD_CTX_create and EVP_MD_CTX_destroy
+local md_ctx_new, md_ctx_free
+if pcall(function() return C.EVP_MD_CTX_new end) then
+  md_ctx_new, md_ctx_free = C.EVP_MD_CTX_new, C.EVP_MD_CTX_free
+else
+  md_ctx_new, md_ctx_free = C.EVP_MD_CTX_create, C.EVP_MD_CTX_destroy
+end
+
+local digests = {
+  sha256 = C.EVP_sha256,
+  sha384 = C.EVP_sha384,
+  sha512 = C.EVP_sha512,
+}
+
+-- supported signature algorithms (RFC 7518). size is the length of
+-- each of the integers of the ECDSA signatures.
+local ALGORITHMS = {
+  RS256 = { kty = "RSA", digest = "sha256" },
+  RS384 = { kty = "RSA", digest = "sha384" },
+  RS512 = { kty = "RSA", digest = "sha512" },
+  ES256 = { kty = "EC", digest = "sha256", size = 32 },
+  ES384 = { kty = "EC", digest = "sha384", size = 48 },
+  ES512 = { kty = "EC", digest = "sha512", size = 66 },
+  HS256 = { kty = "oct", digest = "sha256" },
+  HS384 = { kty = "oct", digest = "sha384" },
+  HS512 = { kty = "oct", digest = "sha512" },
+}
+
+-- raw JSON of the key sets sent by the controller and the parsed key sets
+local raw_key_sets
+local key_sets = {}
+
+local function load_public_key(pem)
+  local bio = C.BIO_new_mem_buf(pem, #pem)
+  if bio == nil then
+    return nil
+  end
+
+  local pkey = C.PEM_read_bio_PUBKEY(bio, nil, nil, nil)
+  C.BIO_free(bio)
+  if pkey == nil then
+    C.ERR_clear_error()
+    return nil
+  end
+
+  return ffi_gc(pkey, C.EVP_PKEY_free)
+end
+
+local function get_key_set(id)
+  local raw = configuration.get_jwt_keys_data()
+  if raw ~= raw_key_sets then
+    local decoded, err = cjson.decode(raw or "{}")
+    if type(decoded) ~= "table" then
+      ngx.log(ngx.ERR, "could not parse JWT keys: ", tostring(err))
+      decoded = {}
+    end
+
+    key_sets = {}
+    for set_id, keys in pairs(decoded) do
+      local key_set = {}
+      for _, key in ipairs(keys) do
+        if key.kty == "oct" then
+          key.secret = ngx.decode_base64(key.secret or "")
+        end
+        table.insert(key_set, key)
+      end
+      key_sets[set_id] = key_set
+    end
+
+    raw_key_sets = raw
+  end
+
+  return key_sets[id]
+end
+
+local function der_length(n)
+  if n < 0x80 then
+    return string.char(n)
+  end
+  if n < 0x100 then
+    return "\129" .. string.char(n)
+  end
+  return "\130" .. string.char(math.floor(n / 256), n % 256)
+end
+
+local function der_integer(bytes)
+  local i = 1
+  while i < #bytes and bytes:byte(i) == 0 do
+    i = i + 1
+  end
+  bytes = bytes:sub(i)
+
+  if bytes:byte(1) >= 0x80 then
+    bytes = "\0" .. bytes
+  end
+
+  return "\2" .. der_length(#bytes) .. bytes
+end
+
+-- ecdsa_der converts the raw signature used by JSON Web Tokens (the
+-- concatenation of r and s) to the DER encoding expected by OpenSSL
+local function ecdsa_der(signature, size)
+  if #signature ~= size * 2 then
+    return nil
+  end
+
+  local sequence = der_integer(signature:sub(1, size)) .. der_integer(signature:sub(size + 1))
+  return "\48" .. der_length(#sequence) .. sequence
+end
+
+-- constant time comparison of two strings
+local function secure_compare(a, b)
+  if #a ~= #b then
+    return false
+  end
+
+  local result = 0
+  for i = 1, #a do
+    result = bit.bor(result, bit.bxor(a:byte(i), b:byte(i)))
+  end
+
+  return result == 0
+end
+
+local function hmac(md, secret, data)
+  local buf = ffi_new("unsigned char[64]")
+  local len = ffi_new("unsigned int[1]")
+
+  if C.HMAC(md, secret, #secret, data, #data, buf, len) == nil then
+    C.ERR_clear_error()
+    return nil
+  end
+
+  return ffi_string(buf, len[0])
+end
+
+local function verify_hmac(md, secret, data, signature)
+  if not secret or secret == "" then
+    return false
+  end
+
+  local mac = hmac(md, secret, data)
+  return mac ~= nil and secure_compare(mac, signature)
+end
+
+local function verify_public_key(md, pkey, data, signature)
+  local ctx = md_ctx_new()
+  if ctx == nil then
+    return false
+  end
+
+  local ok = C.EVP_DigestVerifyInit(ctx, nil, md, nil, pkey) == 1
+    and C.EVP_DigestUpdate(ctx, data, #data) == 1
+    and C.EVP_DigestVerifyFinal(ctx, signature, #signature) == 1
+
+  md_ctx_free(ctx)
+  if not ok then
+    C.ERR_clear_error()
+  end
+
+  return ok
+end
+
+local function verify_signature(algorithm, key, data, signature)
+  local md = digests[algorithm.digest]()
+
+  if algorithm.kty == "oct" then
+    return verify_hmac(md, key.secret, data, signature)
+  end
+
+  if not key.pkey then
+    key.pkey = load_public_key(key.pem or "")
+    if not key.pkey then
+      ngx.log(ngx.ERR, "invalid public key ", tostring(key.kid))
+      return false
+    end
+  end
+
+  if algorithm.kty == "EC" then
+    signature = ecdsa_der(signature, algorithm.size)
+    if not signature then
+      return false
+    end
+  end
+
+  return verify_public_key(md, key.pkey, data, signature)
+end
+
+-- decode returns the header, the claims and the signature of a token
+local function decode(token)
+  local header_b64, payload_b64, signature_b64 = token:match("^([^.]+)%.([^.]+)%.([^.]+)$")
+  if not header_b64 then
+    return nil, "malformed token"
+  end
+
+  local header = cjson.decode(util.decode_base64url(header_b64) or "")
+  local claims = cjson.decode(util.decode_base64url(payload_b64) or "")
+  local signature = util.decode_base64url(signature_b64)
+  if type(header) ~= "table" or type(claims) ~= "table" or not signature then
+    return nil, "malformed token"
+  end
+
+  return {
+    header = header,
+    claims = claims,
+    signature = signature,
+    signed = header_b64 .. "." .. payload_b64,
+  }
+end
+
+local function verify(jwt, key_set)
+  local algorithm = ALGORITHMS[jwt.header.alg]
+  if not algorithm then
+    return false, "unsupported algorithm"
+  end
+
+  for _, key in ipairs(key_set) do
+    if key.kty == algorithm.kty
+        and (jwt.header.kid == nil or key.kid == jwt.header.kid)
+        and (key.alg == nil or key.alg == jwt.header.alg)
+        and verify_signature(algorithm, key, jwt.signed, jwt.signature) then
+      return true
+    end
+  end
+
+  return false, "invalid signature"
+end
+
+local function contains(value, expected)
+  if type(value) == "table" then
+    for _, v in ipairs(value) do
+      if tostring(v) == expected then
+        return true
+      end
+    end
+    return false
+  end
+
+  return tostring(value) == expected
+end
+
+local function validate_claims(claims, config)
+  local now = ngx.now()
+  local skew = config.clock_skew or 0
+
+  if claims.exp ~= nil and (type(claims.exp) ~= "number" or now >= claims.exp + skew) then
+    return false, "token expired"
+  end
+
+  if claims.nbf ~= nil and (type(claims.nbf) ~= "number" or now + skew < claims.nbf) then
+    return false, "token not valid yet"
+  end
+
+  if config.issuer and config.issuer ~= "" and claims.iss ~= config.issuer then
+    return false, "invalid issuer"
+  end
+
+  if config.audiences and #config.audiences > 0 then
+    local valid = false
+    for _, audience in ipairs(config.audiences) do
+      if claims.aud ~= nil and contains(claims.aud, audience) then
+        valid = true
+        break
+      end
+    end
+    if not valid then
+      return false, "invalid audience"
+    end
+  end
+
+  for name, value in pairs(config.required_claims or {}) do
+    if claims[name] == nil or claims[name] == cjson.null then
+      return false, "missing claim " .. name
+    end
+    if value ~= "" and not contains(claims[name], value) then
+      return false, "invalid claim " .. name
+    end
+  end
+
+  return true
+end
+
+local function header_value(claim)
+  if type(claim) ~= "table" then
+    return tostring(claim)
+  end
+
+  local values = {}
+  for _, v in ipairs(claim) do
+    if type(v) == "table" then
+      return cjson.encode(claim)
+    end
+    table.insert(values, tostring(v))
+  end
+
+  if #values == 0 then
+    return cjson.encode(claim)
+  end
+
+  return table_concat(values, ",")
+end
+
+local function reject(err, description)
+  ngx.log(ngx.INFO, "JWT authentication failed: ", description)
+
+  local challenge = "Bearer"
+  if err then
+    challenge = string_format('Bearer error="%s", error_description="%s"', err, description)
+  end
+  ngx.header["WWW-Authenticate"] = challenge
+
+  return ngx.exit(ngx.HTTP_UNAUTHORIZED)
+end
+
+-- hmac_sha256 returns the HMAC-SHA256 of data, used by other modules to
+-- authenticate their own values
+function _M.hmac_sha256(secret, data)
+  return hmac(C.EVP_sha256(), secret, data)
+end
+
+_M.secure_compare = secure_compare
+
+-- verify_token returns the claims of a token signed with one of the keys
+-- of config.key_set and containing the claims required by config
+function _M.verify_token(token, config)
+  local jwt, err = decode(token)
+  if not jwt then
+    return nil, err
+  end
+
+  local key_set = get_key_set(config.key_set)
+  if not key_set or #key_set == 0 then
+    ngx.log(ngx.WARN, "no keys available to validate JSON Web Tokens of ", config.key_set)
+    return nil, "no keys available"
+  end
+
+  local ok
+  ok, err = verify(jwt, key_set)
+  if not ok then
+    return nil, err
+  end
+
+  ok, err = validate_claims(jwt.claims, config)
+  if not ok then
+    return nil, err
+  end
+
+  return jwt.claims
+end
+
+-- clear_claim_headers removes the headers sent to the upstream to avoid
+-- spoofing
+function _M.clear_claim_headers(claim_headers)
+  for _, header in pairs(claim_headers or {}) do
+    ngx.req.clear_header(header)
+  end
+end
+
+-- set_claim_headers sends the claims to the upstream as headers
+function _M.set_claim_headers(claims, claim_headers)
+  for claim, header in pairs(claim_headers or {}) do
+    local value = claims[claim]
+    if value ~= nil and value ~= cjson.null then
+      ngx.req.set_header(header, header_value(value))
+    end
+  end
+end
+
+-- access validates the bearer token sent by the client, rejecting the
+-- request with a 401 status code when the token is missing or invalid.
+-- The configured claims are sent to the upstream as headers.
+function _M.access(config)
+  _M.clear_claim_headers(config.claim_headers)
+
+  local authorization = ngx.var.http_authorization
+  local token = authorization and authorization:match("^[Bb]earer%s+(%S+)%s*$")
+  if not token then
+    return reject(nil, "missing bearer token")
+  end
+
+  local claims, err = _M.verify_token(token, config)
+  if not claims then
+    return reject("invalid_token", err)
+  end
+
+  -- the verified claims are used by the global rate limit of the location
+  ngx.ctx.jwt_claims = claims
+
+  _M.set_claim_headers(claims, config.claim_headers)
+end
+
+-- validate_claims is also used to validate the claims of the sessions
+-- created by oidc_auth
+_M.validate_claims = validate_claims
+
+if _TEST then
+  _M.decode = decode
+  _M.ecdsa_der = ecdsa_der
+end
+
+return _M
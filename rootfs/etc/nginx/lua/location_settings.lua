@@ -0,0 +1,94 @@
+local cjson = require("cjson.safe")
+local iputils = require("resty.iputils")
+local configuration = require("configuration")
+
+local string_format = string.format
+
+local _M = {}
+
+-- raw JSON of the settings sent by the controller and the parsed settings,
+-- indexed by location key
+local raw_locations
+local locations = {}
+
+local function get_location(key)
+  local raw = configuration.get_locations_data()
+  if raw ~= raw_locations then
+    local decoded, err = cjson.decode(raw or "{}")
+    if type(decoded) ~= "table" then
+      ngx.log(ngx.ERR, "could not parse the settings of the locations: ", tostring(err))
+      decoded = {}
+    end
+
+    for _, location in pairs(decoded) do
+      if location.whitelist then
+        location.whitelist_cidrs = iputils.parse_cidrs(location.whitelist)
+      end
+    end
+
+    locations = decoded
+    raw_locations = raw
+  end
+
+  return locations[key]
+end
+
+local function set_cors_headers(cors)
+  ngx.header["Access-Control-Allow-Origin"] = cors.corsAllowOrigin
+  if cors.corsAllowCredentials then
+    ngx.header["Access-Control-Allow-Credentials"] = "true"
+  end
+  ngx.header["Access-Control-Allow-Methods"] = cors.corsAllowMethods
+  ngx.header["Access-Control-Allow-Headers"] = cors.corsAllowHeaders
+end
+
+-- rewrite answers the CORS preflight requests of the location. It must be
+-- called before the other Lua handlers of the rewrite phase.
+function _M.rewrite(key)
+  local location = get_location(key)
+  if not location or not location.cors or ngx.var.request_method ~= "OPTIONS" then
+    return
+  end
+
+  set_cors_headers(location.cors)
+  ngx.header["Access-Control-Max-Age"] = location.cors.corsMaxAge
+  ngx.header["Content-Type"] = "text/plain charset=UTF-8"
+  ngx.header["Content-Length"] = 0
+
+  return ngx.exit(ngx.HTTP_NO_CONTENT)
+end
+
+-- check_whitelist rejects the requests of the clients outside of the
+-- whitelist of the location. The locations rendered with a whitelist
+-- reject every request until their settings are applied.
+function _M.check_whitelist(key, whitelisted)
+  local location = get_location(key)
+  if not location or not location.whitelist_cidrs then
+    if whitelisted then
+      ngx.log(ngx.ERR, string_format("access forbidden until the whitelist of the location %s is configured", key))
+      return ngx.exit(ngx.HTTP_FORBIDDEN)
+    end
+
+    return
+  end
+
+  local remote_addr = ngx.var.remote_addr
+  if iputils.ip_in_cidrs(remote_addr, location.whitelist_cidrs) then
+    return
+  end
+
+  ngx.log(ngx.ERR, string_format("access forbidden by the whitelist of the location, client: %s", remote_addr))
+  return ngx.exit(ngx.HTTP_FORBIDDEN)
+end
+
+-- header_filter adds the CORS headers of the location to the response
+function _M.header_filter(key)
+  local location = get_location(key)
+  if not location or not location.cors then
+    return
+  end
+
+  set_cors_headers(location.cors)
+end
+
+return _M
@@ -1,4 +1,5 @@
 local ngx_re_split = require("ngx.re").split
+local global_throttle = require("global_throttle")
 
 local original_randomseed = math.randomseed
 local string_format = string.format
@@ -128,6 +129,20 @@ function _M.rewrite(location_config)
 
     ngx_redirect(uri, config.http_redirect_code)
   end
+
+  -- the limits using the claims of the token are enforced by access, once
+  -- the request is authenticated
+  if location_config.global_throttle and not location_config.global_throttle.authenticated then
+    global_throttle.throttle(config.global_throttle, location_config.global_throttle)
+  end
+end
+
+-- access enforces the global rate limit of the location using the claims
+-- of the token verified by jwt_auth
+function _M.access(location_config)
+  if location_config.global_throttle and location_config.global_throttle.authenticated then
+    global_throttle.throttle(config.global_throttle, location_config.global_throttle)
+  end
 end
 
 return _M
@@ -21,7 +21,40 @@ local function send(payload)
   assert(s:close())
 end
 
+-- retries returns the number of attempts of the request sent to the
+-- upstream after the first one, without the custom error pages, or nil
+-- when the request was not retried
+local function retries()
+  local upstream_addr = ngx.var.upstream_addr
+  if not upstream_addr then
+    return nil
+  end
+
+  local attempts = upstream_addr:match("^(.-) : ") or upstream_addr
+  local _, count = attempts:gsub(", ", "")
+  if count == 0 then
+    return nil
+  end
+
+  return count
+end
+
+-- upstream_endpoint returns the address of the endpoint that served the
+-- last attempt of the request, without the custom error pages, or nil
+-- when the request was not sent to an upstream
+local function upstream_endpoint()
+  local upstream_addr = ngx.var.upstream_addr
+  if not upstream_addr then
+    return nil
+  end
+
+  local attempts = upstream_addr:match("^(.-) : ") or upstream_addr
+  return attempts:match("([^, ]+)$")
+end
+
 local function metrics()
+  local external_auth = ngx.ctx.external_auth or {}
+
   return {
     host = ngx.var.host or "-",
     namespace = ngx.var.namespace or "-",
@@ -39,6 +72,14 @@ local function metrics()
     upstreamResponseTime = tonumber(ngx.var.upstream_response_time) or -1,
     upstreamResponseLength = tonumber(ngx.var.upstream_response_length) or -1,
     --upstreamStatus = ngx.var.upstream_status or "-",
+    upstreamRetries = retries(),
+    upstreamAddr = upstream_endpoint(),
+    retryBudgetExhausted = ngx.ctx.retry_budget_exhausted,
+
+    authURL = external_auth.url,
+    authResult = external_auth.result,
+    authResponseTime = external_auth.response_time,
+    authCacheStatus = external_auth.cache_status,
   }
 end
 
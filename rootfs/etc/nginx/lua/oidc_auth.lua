@@ -0,0 +1,477 @@
+local aes = require("resty.aes")
+local cjson = require("cjson.safe")
+local http = require("resty.http")
+local random = require("resty.random")
+local resty_sha256 = require("resty.sha256")
+local configuration = require("configuration")
+local jwt_auth = require("jwt_auth")
+local util = require("util")
+
+local string_format = string.format
+local table_concat = table.concat
+
+local _M = {}
+
+-- seconds the users have to log in with the provider
+local STATE_LIFETIME = 600
+-- seconds of tolerance validating the expiration of the ID tokens
+local CLOCK_SKEW = 60
+-- milliseconds to wait for the token endpoint
+local TOKEN_REQUEST_TIMEOUT = 10000
+-- largest cookie accepted by most browsers
+local MAX_COOKIE_SIZE = 4096
+
+-- raw JSON of the configuration sent by the controller and the parsed
+-- providers and clients
+local raw_config
+local providers = {}
+local clients = {}
+
+local function sha256(data)
+  local hash = resty_sha256:new()
+  hash:update(data)
+  return hash:final()
+end
+
+local function load_configuration()
+  local raw = configuration.get_oidc_data()
+  if raw == raw_config then
+    return
+  end
+
+  local decoded, err = cjson.decode(raw or "{}")
+  if type(decoded) ~= "table" then
+    ngx.log(ngx.ERR, "could not parse OpenID Connect configuration: ", tostring(err))
+    decoded = {}
+  end
+
+  providers = decoded.providers or {}
+  clients = {}
+  for name, client in pairs(decoded.clients or {}) do
+    local secret = ngx.decode_base64(client.cookie_secret or "")
+    if secret then
+      -- different keys are used to encrypt and to authenticate the cookies
+      client.encryption_key = sha256("encryption:" .. secret)
+      client.mac_key = sha256("mac:" .. secret)
+      clients[name] = client
+    end
+  end
+
+  raw_config = raw
+end
+
+local function new_cipher(client, iv)
+  return aes:new(client.encryption_key, nil, aes.cipher(256, "cbc"), { iv = iv })
+end
+
+-- seal encrypts value and authenticates it together with the name of the
+-- cookie, so the value of a cookie cannot be used in another one
+local function seal(client, name, value)
+  local iv = random.bytes(16, true)
+  if not iv then
+    return nil
+  end
+
+  local cipher = new_cipher(client, iv)
+  local encrypted = cipher and cipher:encrypt(cjson.encode(value))
+  if not encrypted then
+    return nil
+  end
+
+  local data = iv .. encrypted
+  local mac = jwt_auth.hmac_sha256(client.mac_key, name .. "|" .. data)
+  if not mac then
+    return nil
+  end
+
+  return util.encode_base64url(data .. mac)
+end
+
+-- unseal returns the value of a cookie created by seal, or nil when the
+-- cookie was modified
+local function unseal(client, name, sealed)
+  local raw = sealed and util.decode_base64url(sealed)
+  -- initialization vector, at least one block and the MAC
+  if not raw or #raw < 16 + 16 + 32 then
+    return nil
+  end
+
+  local data, mac = raw:sub(1, -33), raw:sub(-32)
+  local expected = jwt_auth.hmac_sha256(client.mac_key, name .. "|" .. data)
+  if not expected or not jwt_auth.secure_compare(expected, mac) then
+    return nil
+  end
+
+  local cipher = new_cipher(client, data:sub(1, 16))
+  local decrypted = cipher and cipher:decrypt(data:sub(17))
+  if not decrypted then
+    return nil
+  end
+
+  local value = cjson.decode(decrypted)
+  if type(value) ~= "table" then
+    return nil
+  end
+
+  return value
+end
+
+local function is_https()
+  return ngx.var.https == "on" or ngx.var.pass_access_scheme == "https"
+end
+
+local function set_cookie(name, value, max_age)
+  local cookie = string_format("%s=%s; Path=/; Max-Age=%d; HttpOnly; SameSite=Lax", name, value, max_age)
+  if is_https() then
+    cookie = cookie .. "; Secure"
+  end
+
+  local cookies = ngx.header["Set-Cookie"] or {}
+  if type(cookies) == "string" then
+    cookies = { cookies }
+  end
+  table.insert(cookies, cookie)
+  ngx.header["Set-Cookie"] = cookies
+end
+
+local function get_cookie(name)
+  return ngx.var["cookie_" .. name]
+end
+
+-- save_session stores the session in its cookie, without the refresh token
+-- when the cookie would be too large. Sessions that are still too large,
+-- usually because of the claims of the ID token, are not stored.
+local function save_session(config, client, session)
+  local max_age = session.created + config.session_lifetime - ngx.time()
+
+  local value = seal(client, config.cookie_name, session)
+  if value and #value > MAX_COOKIE_SIZE and session.refresh_token then
+    ngx.log(ngx.WARN, "OpenID Connect session too large, the refresh token is not stored")
+    session.refresh_token = nil
+    value = seal(client, config.cookie_name, session)
+  end
+  if not value then
+    return false, "could not encrypt the OpenID Connect session"
+  end
+
+  if #value > MAX_COOKIE_SIZE then
+    return false, string_format("OpenID Connect session of user %s needs a cookie of %d bytes, "
+      .. "larger than the limit of %d bytes. Reduce the claims of the ID token, "
+      .. "like the groups, requesting fewer scopes", tostring(session.claims.sub), #value, MAX_COOKIE_SIZE)
+  end
+
+  set_cookie(config.cookie_name, value, max_age)
+  return true
+end
+
+local function random_string()
+  local bytes = random.bytes(32, true)
+  return bytes and util.encode_base64url(bytes)
+end
+
+local function add_args(url, args)
+  local separator = url:find("?", 1, true) and "&" or "?"
+  return url .. separator .. ngx.encode_args(args)
+end
+
+local function token_request(provider, client, params)
+  local httpc = http.new()
+  httpc:set_timeout(TOKEN_REQUEST_TIMEOUT)
+
+  -- client_secret_basic authentication (RFC 6749, section 2.3.1)
+  local credentials = ngx.escape_uri(client.client_id) .. ":" .. ngx.escape_uri(client.client_secret)
+
+  local res, err = httpc:request_uri(provider.token_endpoint, {
+    method = "POST",
+    body = ngx.encode_args(params),
+    headers = {
+      ["Accept"] = "application/json",
+      ["Authorization"] = "Basic " .. ngx.encode_base64(credentials),
+      ["Content-Type"] = "application/x-www-form-urlencoded",
+    },
+    ssl_verify = true,
+  })
+  if not res then
+    return nil, err
+  end
+
+  if res.status ~= ngx.HTTP_OK then
+    return nil, "unexpected status code " .. tostring(res.status)
+  end
+
+  local tokens = cjson.decode(res.body)
+  if type(tokens) ~= "table" or type(tokens.id_token) ~= "string" then
+    return nil, "invalid token response"
+  end
+
+  return tokens
+end
+
+local function verify_id_token(provider, client, id_token)
+  local claims, err = jwt_auth.verify_token(id_token, {
+    key_set = "url/" .. provider.jwks_uri,
+    issuer = provider.issuer,
+    audiences = { client.client_id },
+    clock_skew = CLOCK_SKEW,
+  })
+  if not claims then
+    return nil, err
+  end
+
+  if type(claims.exp) ~= "number" or type(claims.sub) ~= "string" then
+    return nil, "missing claims in ID token"
+  end
+
+  return claims
+end
+
+local function login(config, provider, client)
+  local method = ngx.req.get_method()
+  if method ~= "GET" and method ~= "HEAD" then
+    return ngx.exit(ngx.HTTP_UNAUTHORIZED)
+  end
+
+  local state = {
+    state = random_string(),
+    nonce = random_string(),
+    verifier = random_string(),
+    redirect_uri = (is_https() and "https" or "http") .. "://" .. ngx.var.host .. config.redirect_path,
+    original_uri = ngx.var.request_uri,
+    created = ngx.time(),
+  }
+  if not state.state or not state.nonce or not state.verifier then
+    ngx.log(ngx.ERR, "could not generate random values")
+    return ngx.exit(ngx.HTTP_INTERNAL_SERVER_ERROR)
+  end
+
+  local state_cookie = config.cookie_name .. "_state"
+  local value = seal(client, state_cookie, state)
+  if not value then
+    ngx.log(ngx.ERR, "could not encrypt the OpenID Connect state")
+    return ngx.exit(ngx.HTTP_INTERNAL_SERVER_ERROR)
+  end
+  set_cookie(state_cookie, value, STATE_LIFETIME)
+
+  -- authorization code flow with PKCE (RFC 7636)
+  return ngx.redirect(add_args(provider.authorization_endpoint, {
+    response_type = "code",
+    client_id = client.client_id,
+    redirect_uri = state.redirect_uri,
+    scope = table_concat(config.scopes, " "),
+    state = state.state,
+    nonce = state.nonce,
+    code_challenge = util.encode_base64url(sha256(state.verifier)),
+    code_challenge_method = "S256",
+  }))
+end
+
+-- refresh returns a new session using the refresh token of an expired one
+local function refresh(config, provider, client, session)
+  if type(session.refresh_token) ~= "string" then
+    return nil
+  end
+
+  local tokens, err = token_request(provider, client, {
+    grant_type = "refresh_token",
+    refresh_token = session.refresh_token,
+  })
+  if not tokens then
+    ngx.log(ngx.INFO, "could not refresh OpenID Connect session: ", err)
+    return nil
+  end
+
+  local claims
+  claims, err = verify_id_token(provider, client, tokens.id_token)
+  if not claims or claims.sub ~= session.claims.sub then
+    ngx.log(ngx.INFO, "invalid ID token refreshing OpenID Connect session: ", err or "different subject")
+    return nil
+  end
+
+  local refreshed = {
+    claims = claims,
+    refresh_token = tokens.refresh_token or session.refresh_token,
+    created = session.created,
+  }
+  local ok
+  ok, err = save_session(config, client, refreshed)
+  if not ok then
+    ngx.log(ngx.ERR, err)
+    return nil
+  end
+
+  return refreshed
+end
+
+local function in_groups(value, allowed_groups)
+  if type(value) ~= "table" then
+    value = { value }
+  end
+
+  for _, group in ipairs(value) do
+    for _, allowed in ipairs(allowed_groups) do
+      if tostring(group) == allowed then
+        return true
+      end
+    end
+  end
+
+  return false
+end
+
+local function authorize(config, claims)
+  if #config.allowed_groups > 0 and not in_groups(claims[config.groups_claim], config.allowed_groups) then
+    ngx.log(ngx.INFO, "OpenID Connect user ", tostring(claims.sub), " not member of the allowed groups")
+    return false
+  end
+
+  local ok, err = jwt_auth.validate_claims(claims, { required_claims = config.required_claims })
+  if not ok then
+    ngx.log(ngx.INFO, "OpenID Connect user ", tostring(claims.sub), " rejected: ", err)
+    return false
+  end
+
+  return true
+end
+
+local function get_provider_and_client(config)
+  load_configuration()
+
+  local provider, client = providers[config.issuer], clients[config.secret]
+  if not provider or not client then
+    ngx.log(ngx.WARN, "OpenID Connect provider ", config.issuer, " or client ", config.secret, " not available")
+  end
+
+  return provider, client
+end
+
+-- access authenticates the users with the session cookie, redirecting them
+-- to the provider when the session is missing or expired. Users without
+-- the required groups or claims are rejected with a 403 status code.
+function _M.access(config)
+  jwt_auth.clear_claim_headers(config.claim_headers)
+
+  local provider, client = get_provider_and_client(config)
+  if not provider or not client then
+    return ngx.exit(ngx.HTTP_SERVICE_UNAVAILABLE)
+  end
+
+  local now = ngx.time()
+  local session = unseal(client, config.cookie_name, get_cookie(config.cookie_name))
+  if session and (type(session.created) ~= "number" or type(session.claims) ~= "table"
+      or now >= session.created + config.session_lifetime) then
+    session = nil
+  end
+
+  if session and (type(session.claims.exp) ~= "number" or now >= session.claims.exp) then
+    session = refresh(config, provider, client, session)
+  end
+
+  if not session then
+    return login(config, provider, client)
+  end
+
+  if not authorize(config, session.claims) then
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+  end
+
+  jwt_auth.set_claim_headers(session.claims, config.claim_headers)
+end
+
+-- callback exchanges the authorization code returned by the provider,
+-- creating the session and redirecting the users to the original URL
+function _M.callback(config)
+  local provider, client = get_provider_and_client(config)
+  if not provider or not client then
+    return ngx.exit(ngx.HTTP_SERVICE_UNAVAILABLE)
+  end
+
+  local state_cookie = config.cookie_name .. "_state"
+  local state = unseal(client, state_cookie, get_cookie(state_cookie))
+  set_cookie(state_cookie, "", 0)
+
+  local args = ngx.req.get_uri_args()
+  if not state or type(state.created) ~= "number" or ngx.time() >= state.created + STATE_LIFETIME
+      or args.state ~= state.state then
+    ngx.log(ngx.INFO, "invalid OpenID Connect state")
+    return ngx.exit(ngx.HTTP_BAD_REQUEST)
+  end
+
+  if args.error then
+    ngx.log(ngx.INFO, "OpenID Connect authentication failed: ", tostring(args.error))
+    return ngx.exit(ngx.HTTP_UNAUTHORIZED)
+  end
+
+  if type(args.code) ~= "string" then
+    return ngx.exit(ngx.HTTP_BAD_REQUEST)
+  end
+
+  local tokens, err = token_request(provider, client, {
+    grant_type = "authorization_code",
+    code = args.code,
+    redirect_uri = state.redirect_uri,
+    code_verifier = state.verifier,
+  })
+  if not tokens then
+    ngx.log(ngx.ERR, "error requesting OpenID Connect tokens to ", provider.token_endpoint, ": ", err)
+    return ngx.exit(ngx.HTTP_BAD_GATEWAY)
+  end
+
+  local claims
+  claims, err = verify_id_token(provider, client, tokens.id_token)
+  if not claims or claims.nonce ~= state.nonce then
+    ngx.log(ngx.INFO, "invalid OpenID Connect ID token: ", err or "invalid nonce")
+    return ngx.exit(ngx.HTTP_UNAUTHORIZED)
+  end
+
+  local session = {
+    claims = claims,
+    refresh_token = tokens.refresh_token,
+    created = ngx.time(),
+  }
+  local ok
+  ok, err = save_session(config, client, session)
+  if not ok then
+    ngx.log(ngx.ERR, err)
+    return ngx.exit(ngx.HTTP_INTERNAL_SERVER_ERROR)
+  end
+
+  -- only local redirections are allowed
+  local original_uri = state.original_uri
+  if type(original_uri) ~= "string" or original_uri:sub(1, 1) ~= "/" or original_uri:match("^/[/\\]") then
+    original_uri = "/"
+  end
+
+  return ngx.redirect(original_uri)
+end
+
+-- logout removes the session and redirects the users to the end session
+-- endpoint of the provider, when available
+function _M.logout(config)
+  set_cookie(config.cookie_name, "", 0)
+
+  local target = config.post_logout_redirect_uri
+  local provider, client = get_provider_and_client(config)
+  if provider and client and provider.end_session_endpoint then
+    local args = { client_id = client.client_id }
+    if target ~= "" then
+      args.post_logout_redirect_uri = target
+    end
+    target = add_args(provider.end_session_endpoint, args)
+  end
+
+  if target == "" then
+    target = "/"
+  end
+
+  return ngx.redirect(target)
+end
+
+if _TEST then
+  _M.seal = seal
+  _M.unseal = unseal
+  _M.save_session = save_session
+  _M.in_groups = in_groups
+  _M.authorize = authorize
+end
+
+return _M
@@ -0,0 +1,185 @@
+local ngx = ngx
+local tonumber = tonumber
+local string_format = string.format
+local math_floor = math.floor
+local math_max = math.max
+local math_min = math.min
+
+local _M = {}
+
+-- backends with outlier detection, indexed by name
+local backends = {}
+
+-- peer returns the endpoint in the format of $upstream_addr
+local function peer(endpoint)
+  local address = endpoint.address
+  if address:find(":", 1, true) and not address:find("[", 1, true) then
+    address = "[" .. address .. "]"
+  end
+
+  return address .. ":" .. endpoint.port
+end
+
+local function key(name, backend_name, peer_name)
+  return name .. ":" .. backend_name .. "/" .. peer_name
+end
+
+local function is_ejected(backend_name, peer_name)
+  local dict = ngx.shared.outlier_detection
+  if not dict then
+    return false
+  end
+
+  return dict:get(key("ejected", backend_name, peer_name)) ~= nil
+end
+
+-- can_eject returns false when the maximum percentage of the endpoints of
+-- the backend is already ejected. At least one endpoint can be ejected.
+local function can_eject(backend_name, backend)
+  local ejected = 0
+  for _, peer_name in ipairs(backend.peers) do
+    if is_ejected(backend_name, peer_name) then
+      ejected = ejected + 1
+    end
+  end
+
+  local max = math_max(1, math_floor(#backend.peers * backend.config.maxEjectionPercent / 100))
+  return ejected < max
+end
+
+-- eject excludes the endpoint from the load balancing. Every recent
+-- ejection of the endpoint increases the ejection time.
+local function eject(backend_name, backend, peer_name, reason)
+  local dict = ngx.shared.outlier_detection
+  local config = backend.config
+
+  if not can_eject(backend_name, backend) then
+    ngx.log(ngx.INFO, string_format("maximum of ejected endpoints reached for backend %s, not ejecting %s",
+      backend_name, peer_name))
+    return
+  end
+
+  local ejections_key = key("ejections", backend_name, peer_name)
+  local ejections = dict:incr(ejections_key, 1, 0) or 1
+  local duration = math_min(config.baseEjectionTime * ejections, config.maxEjectionTime)
+  -- the number of ejections is reset once the endpoint stops failing
+  dict:expire(ejections_key, duration + config.maxEjectionTime)
+
+  dict:set(key("ejected", backend_name, peer_name), true, duration)
+  dict:delete(key("consecutive", backend_name, peer_name))
+  dict:delete(key("requests", backend_name, peer_name))
+  dict:delete(key("errors", backend_name, peer_name))
+
+  ngx.log(ngx.WARN, string_format("ejecting endpoint %s of backend %s for %s seconds: %s",
+    peer_name, backend_name, duration, reason))
+end
+
+-- record updates the counters of the endpoint with the result of a request
+-- and ejects it when it reaches the thresholds
+local function record(backend_name, peer_name, failed)
+  local dict = ngx.shared.outlier_detection
+  local backend = backends[backend_name]
+  if not dict or not backend or is_ejected(backend_name, peer_name) then
+    return
+  end
+
+  local config = backend.config
+
+  if config.consecutiveErrors and config.consecutiveErrors > 0 then
+    local consecutive_key = key("consecutive", backend_name, peer_name)
+    if not failed then
+      dict:delete(consecutive_key)
+    else
+      local consecutive = dict:incr(consecutive_key, 1, 0)
+      if consecutive and consecutive >= config.consecutiveErrors then
+        eject(backend_name, backend, peer_name, consecutive .. " consecutive errors")
+        return
+      end
+    end
+  end
+
+  if config.errorRate and config.errorRate > 0 then
+    local requests = dict:incr(key("requests", backend_name, peer_name), 1, 0, config.interval) or 0
+    local errors = dict:get(key("errors", backend_name, peer_name)) or 0
+    if failed then
+      errors = dict:incr(key("errors", backend_name, peer_name), 1, 0, config.interval) or 0
+    end
+
+    if requests >= config.minRequests and errors * 100 / requests >= config.errorRate then
+      eject(backend_name, backend, peer_name, string_format("%d errors out of %d requests", errors, requests))
+    end
+  end
+end
+
+local function split(value)
+  local items = {}
+  -- the attempts are separated by commas and the internal redirects by colons
+  for item in ((value or ""):gsub(" : ", ", ") .. ", "):gmatch("(.-), ") do
+    table.insert(items, item)
+  end
+  return items
+end
+
+-- after_request records the result of every attempt of the request sent to
+-- the backend. Server errors, connection errors and timeouts are failures.
+function _M.after_request(backend_name)
+  if not backend_name or not backends[backend_name] then
+    return
+  end
+
+  local addresses = split(ngx.var.upstream_addr)
+  local statuses = split(ngx.var.upstream_status)
+
+  for i, address in ipairs(addresses) do
+    local status = tonumber(statuses[i])
+    -- statuses are missing for the attempts without connection
+    local failed = not status or status >= 500
+    record(backend_name, address, failed)
+  end
+end
+
+-- sync replaces the backends with outlier detection of the worker
+function _M.sync(new_backends)
+  local detected = {}
+
+  for _, backend in ipairs(new_backends) do
+    local config = backend.outlierDetection
+    if config and ((config.consecutiveErrors or 0) > 0 or (config.errorRate or 0) > 0) then
+      local peers = {}
+      for _, endpoint in ipairs(backend.endpoints or {}) do
+        table.insert(peers, peer(endpoint))
+      end
+      detected[backend.name] = { config = config, peers = peers }
+    end
+  end
+
+  backends = detected
+end
+
+-- available_endpoints returns the endpoints of the backend that are not
+-- ejected
+function _M.available_endpoints(backend)
+  if not backends[backend.name] or not backend.endpoints then
+    return backend.endpoints
+  end
+
+  local endpoints = {}
+  for _, endpoint in ipairs(backend.endpoints) do
+    if not is_ejected(backend.name, peer(endpoint)) then
+      table.insert(endpoints, endpoint)
+    end
+  end
+
+  if #endpoints == 0 then
+    return backend.endpoints
+  end
+
+  return endpoints
+end
+
+if _TEST then
+  _M.split = split
+  _M.is_ejected = is_ejected
+end
+
+return _M
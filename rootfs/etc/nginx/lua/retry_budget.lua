@@ -0,0 +1,92 @@
+local ngx = ngx
+local string_format = string.format
+local math_floor = math.floor
+local math_max = math.max
+
+local _M = {}
+
+local function active_key(backend_name)
+  return "active:" .. backend_name
+end
+
+local function retries_key(backend_name)
+  return "retries:" .. backend_name
+end
+
+local function enabled(policy)
+  return policy and (policy.budget_percent or 0) > 0
+end
+
+-- start counts the request as active for the retry budget of the backend.
+-- The request is counted until release is called.
+function _M.start(backend_name, policy)
+  local dict = ngx.shared.retry_budget
+  if not enabled(policy) or not dict or not backend_name then
+    return
+  end
+
+  local _, err = dict:incr(active_key(backend_name), 1, 0)
+  if err then
+    ngx.log(ngx.ERR, string_format("error incrementing active requests of backend %s: %s", backend_name, err))
+    return
+  end
+
+  -- the variables survive the internal redirects to the custom error pages
+  ngx.var.retry_budget_backend = backend_name
+end
+
+-- allow returns whether the request can be retried. A request being
+-- retried counts as one active retry of the backend until it completes.
+function _M.allow(policy)
+  local dict = ngx.shared.retry_budget
+  local backend_name = ngx.var.retry_budget_backend
+  if not enabled(policy) or not dict or not backend_name or backend_name == "" then
+    return true
+  end
+
+  if ngx.var.retry_budget_retrying == "1" then
+    return true
+  end
+
+  local active = dict:get(active_key(backend_name)) or 0
+  local max = math_max(policy.budget_min_retries or 0, math_floor(active * policy.budget_percent / 100))
+
+  local retries, err = dict:incr(retries_key(backend_name), 1, 0)
+  if not retries then
+    ngx.log(ngx.ERR, string_format("error incrementing retries of backend %s: %s", backend_name, err))
+    -- fail open, the budget is not enforced when the dictionary is full
+    return true
+  end
+
+  if retries > max then
+    dict:incr(retries_key(backend_name), -1, 0)
+    ngx.log(ngx.WARN, string_format("retry budget of backend %s exhausted (%d active requests, %d retries)",
+      backend_name, active, retries - 1))
+    return false
+  end
+
+  ngx.var.retry_budget_retrying = "1"
+
+  return true
+end
+
+-- release stops counting the request and its retries, if any
+function _M.release()
+  local backend_name = ngx.var.retry_budget_backend
+  if not backend_name or backend_name == "" then
+    return
+  end
+
+  local dict = ngx.shared.retry_budget
+  if dict then
+    dict:incr(active_key(backend_name), -1, 0)
+    if ngx.var.retry_budget_retrying == "1" then
+      dict:incr(retries_key(backend_name), -1, 0)
+    end
+  end
+
+  ngx.var.retry_budget_backend = ""
+  ngx.var.retry_budget_retrying = ""
+end
+
+return _M
@@ -27,6 +27,15 @@ function _M.call()
     return
   end
 
+  -- the stream subsystem has no HTTP endpoint to read the configuration
+  if backends == "GET" then
+    local _, err_send = sock:send((_M.get_backends_data() or "[]") .. "\r\n")
+    if err_send then
+      ngx.log(ngx.ERR, "failed to send TCP/UDP dynamic-configuration:", err_send)
+    end
+    return
+  end
+
   local success, err_conf = tcp_udp_configuration_data:set("backends", backends)
   if not success then
     ngx.log(ngx.ERR, "dynamic-configuration: error updating configuration: " .. tostring(err_conf))
@@ -0,0 +1,142 @@
+_G._TEST = true
+
+local affinity_hash = require("balancer.affinity_hash")
+local util = require("util")
+
+local original_ngx = ngx
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = original_ngx })
+  _G.ngx = _ngx
+end
+
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function get_test_backend(session_affinity)
+  return {
+    name = "access-router-production-web-80",
+    endpoints = {
+      { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 },
+      { address = "10.184.7.41", port = "8080", maxFails = 0, failTimeout = 0 },
+      { address = "10.184.7.42", port = "8080", maxFails = 0, failTimeout = 0 },
+    },
+    sessionAffinityConfig = session_affinity,
+  }
+end
+
+describe("Balancer affinity_hash", function()
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("ip_prefix()", function()
+    it("masks IPv4 addresses", function()
+      assert.equal("10.184.7.40", affinity_hash.ip_prefix("10.184.7.40", 32, 128))
+      assert.equal("10.184.7.0", affinity_hash.ip_prefix("10.184.7.40", 24, 128))
+      assert.equal("10.184.0.0", affinity_hash.ip_prefix("10.184.7.40", 20, 128))
+      assert.equal("0.0.0.0", affinity_hash.ip_prefix("10.184.7.40", 0, 128))
+    end)
+
+    it("masks IPv6 addresses", function()
+      assert.equal("2001:db8:0:0:0:0:0:1", affinity_hash.ip_prefix("2001:db8::1", 32, 128))
+      assert.equal("2001:db8:85a3:0:0:0:0:0", affinity_hash.ip_prefix("2001:db8:85a3::8a2e:370:7334", 32, 64))
+      assert.equal("2001:d00:0:0:0:0:0:0", affinity_hash.ip_prefix("2001:db8::1", 32, 24))
+    end)
+
+    it("uses the IPv4 prefix length for IPv4-mapped addresses", function()
+      assert.equal("10.184.7.0", affinity_hash.ip_prefix("::ffff:10.184.7.40", 24, 128))
+    end)
+
+    it("returns nil for invalid addresses", function()
+      assert.is_nil(affinity_hash.ip_prefix(nil, 32, 128))
+      assert.is_nil(affinity_hash.ip_prefix("unix:", 32, 128))
+    end)
+  end)
+
+  describe("balance()", function()
+    it("keeps the requests with the same header on the same endpoint", function()
+      local instance = affinity_hash:new(get_test_backend({
+        name = "header", headerSessionAffinity = { name = "X-Session-ID" },
+      }))
+
+      mock_ngx({ var = { http_x_session_id = "session-1" } })
+      local peer = instance:balance()
+      for _ = 1, 100 do
+        assert.equal(peer, instance:balance())
+      end
+    end)
+
+    it("keeps the clients of the same network on the same endpoint", function()
+      local instance = affinity_hash:new(get_test_backend({
+        name = "ip", ipSessionAffinity = { prefixLength = 24 },
+      }))
+
+      mock_ngx({ var = { remote_addr = "192.168.1.10" } })
+      local peer = instance:balance()
+      for i = 1, 100 do
+        ngx.var.remote_addr = "192.168.1." .. i
+        assert.equal(peer, instance:balance())
+      end
+    end)
+
+    it("balances the requests without session", function()
+      local instance = affinity_hash:new(get_test_backend({
+        name = "header", headerSessionAffinity = { name = "X-Session-ID" },
+      }))
+
+      mock_ngx({ var = {} })
+      local peers = {}
+      for _ = 1, 100 do
+        peers[instance:balance()] = true
+      end
+      assert.is_true(util.tablelength(peers) > 1)
+    end)
+
+    it("only moves a part of the sessions when an endpoint is added", function()
+      local backend = get_test_backend({
+        name = "header", headerSessionAffinity = { name = "X-Session-ID" },
+      })
+      local instance = affinity_hash:new(backend)
+
+      mock_ngx({ var = {} })
+      local before = {}
+      for i = 1, 300 do
+        ngx.var.http_x_session_id = "session-" .. i
+        before[i] = instance:balance()
+      end
+
+      backend = util.deepcopy(backend)
+      table.insert(backend.endpoints, { address = "10.184.7.43", port = "8080", maxFails = 0, failTimeout = 0 })
+      instance:sync(backend)
+
+      local moved = 0
+      for i = 1, 300 do
+        ngx.var.http_x_session_id = "session-" .. i
+        local peer = instance:balance()
+        if peer ~= before[i] then
+          assert.equal("10.184.7.43:8080", peer)
+          moved = moved + 1
+        end
+      end
+      assert.is_true(moved < 150)
+    end)
+  end)
+
+  describe("sync()", function()
+    it("updates the session affinity configuration", function()
+      local backend = get_test_backend({
+        name = "header", headerSessionAffinity = { name = "X-Session-ID" },
+      })
+      local instance = affinity_hash:new(backend)
+
+      backend = util.deepcopy(backend)
+      backend.sessionAffinityConfig.headerSessionAffinity.name = "X-Tenant"
+      instance:sync(backend)
+
+      assert.equal("X-Tenant", instance.session_affinity.headerSessionAffinity.name)
+    end)
+  end)
+end)
@@ -262,4 +262,89 @@ describe("Sticky", function()
       end)
     end)
   end)
+
+  describe("balance() in persistent mode", function()
+    local mocked_cookie_new = cookie.new
+
+    before_each(function()
+      package.loaded["balancer.sticky"] = nil
+      sticky = require("balancer.sticky")
+    end)
+
+    after_each(function()
+      cookie.new = mocked_cookie_new
+    end)
+
+    local function get_persistent_backend()
+      local backend = get_several_test_backends(false)
+      backend.sessionAffinityConfig.mode = "persistent"
+      backend.sessionAffinityConfig.cookieSessionAffinity.locations = { ["test.com"] = { "/" } }
+      return backend
+    end
+
+    local function mock_cookie(value)
+      local cookie_instance = {
+        value = value,
+        set = function(self, payload)
+          self.value = payload.value
+          return true, nil
+        end,
+      }
+      cookie_instance.get = function(_, _) return cookie_instance.value end
+      cookie.new = function() return cookie_instance, false end
+      return cookie_instance
+    end
+
+    it("sets a cookie with the identifier of the endpoint", function()
+      local cookie_instance = mock_cookie(nil)
+      local sticky_balancer_instance = sticky:new(get_persistent_backend())
+
+      local peer = sticky_balancer_instance:balance()
+      assert.equal(ngx.md5(peer), cookie_instance.value)
+    end)
+
+    it("keeps the session on its endpoint when endpoints are added", function()
+      local backend = get_persistent_backend()
+      local sticky_balancer_instance = sticky:new(backend)
+
+      for _, endpoint in ipairs(backend.endpoints) do
+        local peer = endpoint.address .. ":" .. endpoint.port
+        mock_cookie(ngx.md5(peer))
+        assert.equal(peer, sticky_balancer_instance:balance())
+      end
+
+      local new_backend = util.deepcopy(backend)
+      for i = 42, 60 do
+        table.insert(new_backend.endpoints, { address = "10.184.7." .. i, port = "8080", maxFails = 0, failTimeout = 0 })
+      end
+      sticky_balancer_instance:sync(new_backend)
+
+      for _, endpoint in ipairs(backend.endpoints) do
+        local peer = endpoint.address .. ":" .. endpoint.port
+        mock_cookie(ngx.md5(peer))
+        assert.equal(peer, sticky_balancer_instance:balance())
+      end
+    end)
+
+    it("remaps the session of a removed endpoint deterministically", function()
+      local backend = get_persistent_backend()
+      table.insert(backend.endpoints, { address = "10.184.7.42", port = "8080", maxFails = 0, failTimeout = 0 })
+      local sticky_balancer_instance = sticky:new(backend)
+
+      local removed = table.remove(backend.endpoints, 1)
+      sticky_balancer_instance:sync(backend)
+
+      local removed_id = ngx.md5(removed.address .. ":" .. removed.port)
+      local cookie_instance = mock_cookie(removed_id)
+      local peer = sticky_balancer_instance:balance()
+
+      assert.not_equal(removed.address .. ":" .. removed.port, peer)
+      assert.equal(ngx.md5(peer), cookie_instance.value)
+
+      for _ = 1, 100 do
+        mock_cookie(removed_id)
+        assert.equal(peer, sticky_balancer_instance:balance())
+      end
+    end)
+  end)
 end)
@@ -1,5 +1,8 @@
 _G._TEST = true
 
+local cjson = require("cjson.safe")
+local util = require("util")
+
 local balancer, expected_implementations, backends
 local original_ngx = ngx
 
@@ -26,6 +29,7 @@ local function reset_expected_implementations()
     ["my-dummy-app-3"] = package.loaded["balancer.sticky"],
     ["my-dummy-app-4"] = package.loaded["balancer.ewma"],
     ["my-dummy-app-5"] = package.loaded["balancer.sticky"],
+    ["my-dummy-app-6"] = package.loaded["balancer.affinity_hash"],
   }
 end
 
@@ -62,6 +66,10 @@ local function reset_backends()
       name = "my-dummy-app-5", ["load-balance"] = "ewma", ["upstream-hash-by"] = "$request_uri",
       sessionAffinityConfig = { name = "cookie", cookieSessionAffinity = { name = "route" } }
     },
+    {
+      name = "my-dummy-app-6", ["load-balance"] = "ewma",
+      sessionAffinityConfig = { name = "header", headerSessionAffinity = { name = "X-Session-ID" } }
+    },
   }
 end
 
@@ -369,6 +377,64 @@ describe("Balancer", function()
       assert.stub(mock_instance.sync).was_called_with(mock_instance, expected_backend)
     end)
 
+    it("excludes the endpoints failing the active health checks", function()
+      local backend = {
+        name = "exmaple-com", healthCheck = { path = "/healthz" },
+        endpoints = {
+          { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 },
+          { address = "10.184.7.41", port = "8080", maxFails = 0, failTimeout = 0 },
+        }
+      }
+      local expected_backend = {
+        name = "exmaple-com", healthCheck = { path = "/healthz" },
+        endpoints = {
+          { address = "10.184.7.41", port = "8080", maxFails = 0, failTimeout = 0 },
+        }
+      }
+
+      ngx.shared.healthcheck:set("state:exmaple-com/10.184.7.40:8080", cjson.encode({ healthy = false }))
+
+      local mock_instance = { sync = function(backend) end }
+      setmetatable(mock_instance, implementation)
+      implementation.new = function(self, backend) return mock_instance end
+      assert.has_no.errors(function() balancer.sync_backend(util.deepcopy(backend)) end)
+      stub(mock_instance, "sync")
+      assert.has_no.errors(function() balancer.sync_backend(util.deepcopy(backend)) end)
+      assert.stub(mock_instance.sync).was_called_with(mock_instance, expected_backend)
+
+      ngx.shared.healthcheck:flush_all()
+    end)
+
+    it("excludes the endpoints ejected by the outlier detection", function()
+      local backend = {
+        name = "exmaple-com", outlierDetection = { consecutiveErrors = 3 },
+        endpoints = {
+          { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 },
+          { address = "10.184.7.41", port = "8080", maxFails = 0, failTimeout = 0 },
+        }
+      }
+      local expected_backend = {
+        name = "exmaple-com", outlierDetection = { consecutiveErrors = 3 },
+        endpoints = {
+          { address = "10.184.7.41", port = "8080", maxFails = 0, failTimeout = 0 },
+        }
+      }
+
+      require("outlier_detection").sync({ backend })
+      ngx.shared.outlier_detection:set("ejected:exmaple-com/10.184.7.40:8080", true)
+
+      local mock_instance = { sync = function(backend) end }
+      setmetatable(mock_instance, implementation)
+      implementation.new = function(self, backend) return mock_instance end
+      assert.has_no.errors(function() balancer.sync_backend(util.deepcopy(backend)) end)
+      stub(mock_instance, "sync")
+      assert.has_no.errors(function() balancer.sync_backend(util.deepcopy(backend)) end)
+      assert.stub(mock_instance.sync).was_called_with(mock_instance, expected_backend)
+
+      ngx.shared.outlier_detection:flush_all()
+      require("outlier_detection").sync({})
+    end)
+
     it("replaces the existing balancer when load balancing config changes for backend", function()
       assert.has_no.errors(function() balancer.sync_backend(backend) end)
 
@@ -400,4 +466,55 @@ describe("Balancer", function()
       assert.stub(mock_instance.sync).was_called_with(mock_instance, backend)
     end)
   end)
+
+  describe("release()", function()
+    local backend, policy
+
+    before_each(function()
+      ngx.shared.circuit_breaker:flush_all()
+      ngx.shared.retry_budget:flush_all()
+
+      backend = {
+        name = "my-dummy-app-1", ["load-balance"] = "round_robin",
+        endpoints = { { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 } },
+        circuitBreaker = { maxRequests = 1, maxPendingRequests = 0, pendingTimeout = 0 },
+      }
+      policy = { per_try_timeout = 0, budget_percent = 20, budget_min_retries = 1 }
+
+      balancer.sync_backend(backend)
+      require("circuit_breaker").sync({ backend })
+    end)
+
+    after_each(function()
+      require("circuit_breaker").sync({})
+    end)
+
+    it("releases a request rejected by limit_req before it is balanced", function()
+      local var = {
+        proxy_upstream_name = backend.name,
+        circuit_breaker_backend = "",
+        retry_budget_backend = "",
+        retry_budget_retrying = "",
+      }
+      mock_ngx({ var = var, ctx = {} })
+
+      balancer.rewrite(policy)
+      assert.are.equal(1, ngx.shared.circuit_breaker:get("active:" .. backend.name))
+      assert.are.equal(1, ngx.shared.retry_budget:get("active:" .. backend.name))
+
+      -- limit_req rejects the request in the preaccess phase, it is never
+      -- balanced and only the log phase of the named location runs
+      balancer.release()
+      balancer.release()
+
+      assert.are.equal(0, ngx.shared.circuit_breaker:get("active:" .. backend.name))
+      assert.are.equal(0, ngx.shared.retry_budget:get("active:" .. backend.name))
+
+      -- the slot of the rejected request is available to the next one
+      mock_ngx({ var = { proxy_upstream_name = backend.name, circuit_breaker_backend = "" }, ctx = {} })
+      local s = spy.on(ngx, "exit")
+      balancer.rewrite(policy)
+      assert.spy(s).was_not_called()
+    end)
+  end)
 end)
@@ -0,0 +1,95 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("circuit_breaker", function()
+  local circuit_breaker
+  local backend
+
+  before_each(function()
+    ngx.shared.circuit_breaker:flush_all()
+    circuit_breaker = require("circuit_breaker")
+    backend = {
+      name = "default-app-80",
+      circuitBreaker = { maxRequests = 2, maxPendingRequests = 0, pendingTimeout = 1 },
+    }
+    circuit_breaker.sync({ backend })
+  end)
+
+  after_each(function()
+    reset_ngx()
+    package.loaded["circuit_breaker"] = nil
+  end)
+
+  -- request returns the variables of a new request
+  local function request()
+    local var = { circuit_breaker_backend = "" }
+    mock_ngx({ var = var })
+    return var
+  end
+
+  it("limits the concurrent requests", function()
+    local first = request()
+    assert.is_true(circuit_breaker.acquire(backend.name))
+    assert.are.equal(backend.name, first.circuit_breaker_backend)
+
+    request()
+    assert.is_true(circuit_breaker.acquire(backend.name))
+
+    request()
+    assert.is_false(circuit_breaker.acquire(backend.name))
+
+    mock_ngx({ var = first })
+    circuit_breaker.release()
+    assert.are.equal("", first.circuit_breaker_backend)
+
+    request()
+    assert.is_true(circuit_breaker.acquire(backend.name))
+  end)
+
+  it("releases a request only once", function()
+    local first = request()
+    assert.is_true(circuit_breaker.acquire(backend.name))
+
+    mock_ngx({ var = first })
+    circuit_breaker.release()
+    circuit_breaker.release()
+
+    assert.are.equal(0, ngx.shared.circuit_breaker:get("active:" .. backend.name))
+  end)
+
+  it("rejects the pending requests after the timeout", function()
+    backend.circuitBreaker.maxRequests = 1
+    backend.circuitBreaker.maxPendingRequests = 1
+    circuit_breaker.sync({ backend })
+
+    request()
+    assert.is_true(circuit_breaker.acquire(backend.name))
+
+    local slept = 0
+    mock_ngx({
+      var = { circuit_breaker_backend = "" },
+      sleep = function(seconds) slept = slept + seconds end,
+      now = function() return slept end,
+      update_time = function() end,
+    })
+    assert.is_false(circuit_breaker.acquire(backend.name))
+    assert.is_true(slept >= 1)
+    assert.are.equal(0, ngx.shared.circuit_breaker:get("pending:" .. backend.name))
+  end)
+
+  it("does not limit the backends without circuit breaking", function()
+    for _ = 1, 5 do
+      local var = request()
+      assert.is_true(circuit_breaker.acquire("default-other-80"))
+      assert.are.equal("", var.circuit_breaker_backend)
+    end
+  end)
+end)
@@ -0,0 +1,163 @@
+_G._TEST = true
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local AUTH_URL = "http://auth.example.com/validate"
+
+local function new_config(overrides)
+  local config = {
+    url = AUTH_URL,
+    circuit_breaker_failures = 2,
+    circuit_breaker_duration = 30,
+  }
+  for k, v in pairs(overrides or {}) do
+    config[k] = v
+  end
+  return config
+end
+
+describe("external_auth", function()
+  local external_auth
+  local ctx
+
+  before_each(function()
+    ngx.shared.external_auth:flush_all()
+    ctx = {}
+    external_auth = require("external_auth")
+  end)
+
+  after_each(function()
+    reset_ngx()
+    package.loaded["external_auth"] = nil
+  end)
+
+  local function mock_request(var)
+    mock_ngx({ var = var, ctx = ctx, exit = function(status) return status end })
+  end
+
+  describe("result()", function()
+    it("returns error when the service fails", function()
+      mock_request({ auth_status = "500", auth_upstream_status = "504" })
+      assert.are.equal("error", external_auth.result())
+
+      mock_request({ auth_status = "500", auth_upstream_status = "502, 503" })
+      assert.are.equal("error", external_auth.result())
+    end)
+
+    it("returns unavailable when the service was not reached", function()
+      mock_request({ auth_status = "503", auth_upstream_status = "", auth_cache_status = "" })
+      assert.are.equal("unavailable", external_auth.result())
+    end)
+
+    it("returns denied on 401 and 403", function()
+      mock_request({ auth_status = "401", auth_upstream_status = "401" })
+      assert.are.equal("denied", external_auth.result())
+
+      mock_request({ auth_status = "403", auth_upstream_status = "", auth_cache_status = "HIT" })
+      assert.are.equal("denied", external_auth.result())
+    end)
+
+    it("returns allowed on success", function()
+      mock_request({ auth_status = "200", auth_upstream_status = "200" })
+      assert.are.equal("allowed", external_auth.result())
+
+      mock_request({ auth_status = "200", auth_upstream_status = "", auth_cache_status = "HIT" })
+      assert.are.equal("allowed", external_auth.result())
+    end)
+  end)
+
+  describe("response_time()", function()
+    it("adds the times of every attempt", function()
+      mock_request({ auth_response_time = "0.100, 0.200 : 0.300" })
+      assert.are.equal(0.6, tonumber(string.format("%.1f", external_auth.response_time())))
+    end)
+
+    it("returns -1 without response", function()
+      mock_request({ auth_response_time = "" })
+      assert.are.equal(-1, external_auth.response_time())
+    end)
+  end)
+
+  describe("log()", function()
+    it("does nothing when the authentication was not requested", function()
+      mock_request({ auth_status = "" })
+      external_auth.log(new_config())
+      assert.is_nil(ctx.external_auth)
+    end)
+
+    it("records the result of the authentication", function()
+      mock_request({ auth_status = "200", auth_upstream_status = "200",
+        auth_response_time = "0.010", auth_cache_status = "MISS" })
+      external_auth.log(new_config())
+
+      assert.are.same({
+        url = AUTH_URL,
+        result = "allowed",
+        response_time = 0.01,
+        cache_status = "MISS",
+      }, ctx.external_auth)
+    end)
+  end)
+
+  describe("circuit breaker", function()
+    local function fail()
+      mock_request({ auth_status = "500", auth_upstream_status = "502" })
+      external_auth.log(new_config())
+    end
+
+    local function succeed()
+      mock_request({ auth_status = "200", auth_upstream_status = "200" })
+      external_auth.log(new_config())
+    end
+
+    local function access()
+      mock_request({})
+      return external_auth.access(new_config())
+    end
+
+    it("opens after consecutive failures", function()
+      fail()
+      assert.is_nil(access())
+
+      fail()
+      assert.are.equal(ngx.HTTP_SERVICE_UNAVAILABLE, access())
+    end)
+
+    it("resets the failures on success", function()
+      fail()
+      succeed()
+      fail()
+      assert.is_nil(access())
+    end)
+
+    it("opens again after a single failure once the circuit closes", function()
+      fail()
+      fail()
+      ngx.shared.external_auth:delete("open:" .. AUTH_URL)
+      assert.is_nil(access())
+
+      fail()
+      assert.are.equal(ngx.HTTP_SERVICE_UNAVAILABLE, access())
+    end)
+
+    it("is disabled without failures threshold", function()
+      local config = new_config({ circuit_breaker_failures = 0 })
+      for _ = 1, 3 do
+        mock_request({ auth_status = "500", auth_upstream_status = "502" })
+        external_auth.log(config)
+      end
+
+      mock_request({})
+      assert.is_nil(external_auth.access(config))
+    end)
+  end)
+end)
@@ -0,0 +1,170 @@
+_G._TEST = true
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local function encode_base64url(s)
+  return (ngx.encode_base64(s):gsub("+", "-"):gsub("/", "_"):gsub("=", ""))
+end
+
+local config = {
+  store = "local",
+  status_code = 429,
+  fallback = "allow",
+}
+
+local function new_location(overrides)
+  local location = {
+    namespace = "default_foo",
+    limit = 2,
+    window_size = 60,
+    key = "$remote_addr",
+    algorithm = "fixed-window",
+    ignored_cidrs = {},
+  }
+  for k, v in pairs(overrides or {}) do
+    location[k] = v
+  end
+  return location
+end
+
+describe("global_throttle", function()
+  local global_throttle
+  local now
+
+  before_each(function()
+    ngx.shared.global_throttle_cache:flush_all()
+    now = 120
+
+    mock_ngx({
+      var = { remote_addr = "10.0.0.1" },
+      header = {},
+      now = function() return now end,
+      exit = function(status) return status end,
+    })
+    global_throttle = require("global_throttle")
+  end)
+
+  after_each(function()
+    reset_ngx()
+    package.loaded["global_throttle"] = nil
+  end)
+
+  describe("resolve_key", function()
+    it("replaces the NGINX variables", function()
+      ngx.var.http_x_api_key = "secret"
+      assert.are.equal("10.0.0.1-secret", global_throttle.resolve_key("$remote_addr-$http_x_api_key"))
+    end)
+
+    it("replaces missing variables with an empty string", function()
+      assert.are.equal("", global_throttle.resolve_key("$http_x_api_key"))
+    end)
+
+    it("uses the claims of the token verified by jwt_auth", function()
+      ngx.ctx = { jwt_claims = { sub = "user-1", tenant = 42 } }
+      assert.are.equal("user-1:42", global_throttle.resolve_key("$jwt_claim_sub:$jwt_claim_tenant"))
+    end)
+
+    it("ignores the claims of a token not verified", function()
+      local payload = encode_base64url('{"sub":"user-1","tenant":42}')
+      ngx.var.http_authorization = "Bearer header." .. payload .. ".signature"
+      ngx.ctx = {}
+      assert.are.equal(":", global_throttle.resolve_key("$jwt_claim_sub:$jwt_claim_tenant"))
+    end)
+  end)
+
+  describe("throttle", function()
+    it("rejects the requests over the limit of a fixed window", function()
+      local s = spy.on(ngx, "exit")
+      local location = new_location()
+
+      global_throttle.throttle(config, location)
+      global_throttle.throttle(config, location)
+      assert.spy(s).was_not_called()
+
+      global_throttle.throttle(config, location)
+      assert.spy(s).was_called_with(429)
+      assert.are.equal(60, ngx.header["Retry-After"])
+    end)
+
+    it("counts the requests of each key separately", function()
+      local s = spy.on(ngx, "exit")
+      local location = new_location({ limit = 1 })
+
+      global_throttle.throttle(config, location)
+      ngx.var.remote_addr = "10.0.0.2"
+      global_throttle.throttle(config, location)
+      assert.spy(s).was_not_called()
+    end)
+
+    it("takes into account the previous window when using the sliding window", function()
+      local s = spy.on(ngx, "exit")
+      local location = new_location({ algorithm = "sliding-window", limit = 3 })
+
+      for _ = 1, 3 do
+        global_throttle.throttle(config, location)
+      end
+      assert.spy(s).was_not_called()
+
+      -- half of the requests of the previous window are counted
+      now = 210
+      global_throttle.throttle(config, location)
+      assert.spy(s).was_not_called()
+      global_throttle.throttle(config, location)
+      assert.spy(s).was_called_with(429)
+    end)
+
+    it("ignores the configured CIDRs", function()
+      local s = spy.on(ngx, "exit")
+      local location = new_location({ limit = 1, ignored_cidrs = { "10.0.0.0/24" } })
+
+      for _ = 1, 3 do
+        global_throttle.throttle(config, location)
+      end
+      assert.spy(s).was_not_called()
+    end)
+
+    describe("when the store is unavailable", function()
+      local unavailable = {
+        store = "memcached",
+        host = "",
+        status_code = 429,
+      }
+
+      it("allows the request", function()
+        local s = spy.on(ngx, "exit")
+        unavailable.fallback = "allow"
+
+        global_throttle.throttle(unavailable, new_location({ limit = 0 }))
+        assert.spy(s).was_not_called()
+      end)
+
+      it("denies the request", function()
+        local s = spy.on(ngx, "exit")
+        unavailable.fallback = "deny"
+
+        global_throttle.throttle(unavailable, new_location())
+        assert.spy(s).was_called_with(429)
+      end)
+
+      it("counts the request locally", function()
+        local s = spy.on(ngx, "exit")
+        unavailable.fallback = "local"
+        local location = new_location({ limit = 1 })
+
+        global_throttle.throttle(unavailable, location)
+        assert.spy(s).was_not_called()
+        global_throttle.throttle(unavailable, location)
+        assert.spy(s).was_called_with(429)
+      end)
+    end)
+  end)
+end)
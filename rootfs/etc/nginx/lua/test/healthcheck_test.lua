@@ -0,0 +1,140 @@
+_G._TEST = true
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local function new_backend()
+  return {
+    name = "default-app-80",
+    healthCheck = {
+      path = "/healthz", scheme = "http", interval = 5, timeout = 2,
+      healthyThreshold = 2, unhealthyThreshold = 3, expectedStatus = "200-399",
+    },
+    endpoints = {
+      { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 },
+      { address = "10.184.7.41", port = "8080", maxFails = 0, failTimeout = 0 },
+    },
+  }
+end
+
+describe("healthcheck", function()
+  local healthcheck
+  local backend
+
+  before_each(function()
+    ngx.shared.healthcheck:flush_all()
+    healthcheck = require("healthcheck")
+    backend = new_backend()
+  end)
+
+  after_each(function()
+    reset_ngx()
+    package.loaded["healthcheck"] = nil
+  end)
+
+  local function fail(endpoint, times)
+    for _ = 1, times do
+      healthcheck.record(backend.name, backend.healthCheck, endpoint, nil, "connection refused")
+    end
+  end
+
+  local function succeed(endpoint, times)
+    for _ = 1, times do
+      healthcheck.record(backend.name, backend.healthCheck, endpoint, 200, nil)
+    end
+  end
+
+  local function healthy_addresses()
+    local addresses = {}
+    for _, endpoint in ipairs(healthcheck.healthy_endpoints(backend)) do
+      table.insert(addresses, endpoint.address)
+    end
+    return addresses
+  end
+
+  describe("expected_status()", function()
+    it("matches codes and ranges", function()
+      assert.is_true(healthcheck.expected_status(200, "200-399"))
+      assert.is_true(healthcheck.expected_status(399, "200-399"))
+      assert.is_false(healthcheck.expected_status(404, "200-399"))
+      assert.is_true(healthcheck.expected_status(204, "200,204,300-302"))
+      assert.is_true(healthcheck.expected_status(301, "200,204,300-302"))
+      assert.is_false(healthcheck.expected_status(201, "200,204,300-302"))
+    end)
+  end)
+
+  describe("healthy_endpoints()", function()
+    it("excludes an endpoint once it reaches the unhealthy threshold", function()
+      fail(backend.endpoints[1], 2)
+      assert.are.same({ "10.184.7.40", "10.184.7.41" }, healthy_addresses())
+
+      fail(backend.endpoints[1], 1)
+      assert.are.same({ "10.184.7.41" }, healthy_addresses())
+    end)
+
+    it("includes an endpoint again once it reaches the healthy threshold", function()
+      fail(backend.endpoints[1], 3)
+      succeed(backend.endpoints[1], 1)
+      assert.are.same({ "10.184.7.41" }, healthy_addresses())
+
+      succeed(backend.endpoints[1], 1)
+      assert.are.same({ "10.184.7.40", "10.184.7.41" }, healthy_addresses())
+    end)
+
+    it("considers unexpected status codes as failures", function()
+      for _ = 1, 3 do
+        healthcheck.record(backend.name, backend.healthCheck, backend.endpoints[2], 503, nil)
+      end
+      assert.are.same({ "10.184.7.40" }, healthy_addresses())
+    end)
+
+    it("returns every endpoint when all of them are unhealthy", function()
+      fail(backend.endpoints[1], 3)
+      fail(backend.endpoints[2], 3)
+      assert.are.same({ "10.184.7.40", "10.184.7.41" }, healthy_addresses())
+    end)
+
+    it("ignores the state of backends without active health checks", function()
+      fail(backend.endpoints[1], 3)
+      backend.healthCheck = nil
+      assert.are.same({ "10.184.7.40", "10.184.7.41" }, healthy_addresses())
+    end)
+  end)
+
+  describe("run_checks()", function()
+    it("checks every endpoint once per interval", function()
+      local checked = {}
+      mock_ngx({ timer = { at = function(_, _, name, _, endpoint)
+        table.insert(checked, name .. "/" .. endpoint.address)
+        return true
+      end } })
+
+      healthcheck.sync({ backend, { name = "default-other-80", endpoints = backend.endpoints } })
+      healthcheck.run_checks(false)
+      healthcheck.run_checks(false)
+
+      assert.are.same({ "default-app-80/10.184.7.40", "default-app-80/10.184.7.41" }, checked)
+    end)
+  end)
+
+  describe("status()", function()
+    it("returns the state of the checked endpoints", function()
+      healthcheck.sync({ backend })
+      fail(backend.endpoints[1], 3)
+
+      local status = healthcheck.status()
+      assert.is_false(status["default-app-80"]["10.184.7.40:8080"].healthy)
+      assert.are.equal(3, status["default-app-80"]["10.184.7.40:8080"].failures)
+      assert.are.equal("connection refused", status["default-app-80"]["10.184.7.40:8080"].error)
+      assert.is_true(status["default-app-80"]["10.184.7.41:8080"].healthy)
+    end)
+  end)
+end)
@@ -0,0 +1,158 @@
+local cjson = require("cjson")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("location_settings", function()
+  local location_settings
+
+  before_each(function()
+    ngx.shared.configuration_data:set("locations", cjson.encode({
+      ["example.com/"] = {
+        whitelist = { "10.0.0.0/8", "192.168.1.1/32" },
+      },
+      ["example.com/api"] = {
+        cors = {
+          corsEnabled = true,
+          corsAllowOrigin = "https://origin.example.com",
+          corsAllowMethods = "GET, POST",
+          corsAllowHeaders = "Authorization",
+          corsAllowCredentials = true,
+          corsMaxAge = 600,
+        },
+      },
+    }))
+
+    mock_ngx({
+      var = { remote_addr = "10.1.2.3", request_method = "GET" },
+      header = {},
+      exit = function(status) return status end,
+    })
+    location_settings = require("location_settings")
+  end)
+
+  after_each(function()
+    reset_ngx()
+    ngx.shared.configuration_data:delete("locations")
+    package.loaded["location_settings"] = nil
+  end)
+
+  describe("check_whitelist", function()
+    it("allows the clients in the whitelist", function()
+      local s = spy.on(ngx, "exit")
+
+      location_settings.check_whitelist("example.com/")
+      assert.spy(s).was_not_called()
+
+      ngx.var.remote_addr = "192.168.1.1"
+      location_settings.check_whitelist("example.com/")
+      assert.spy(s).was_not_called()
+    end)
+
+    it("rejects the clients outside of the whitelist", function()
+      local s = spy.on(ngx, "exit")
+      ngx.var.remote_addr = "192.168.1.2"
+
+      location_settings.check_whitelist("example.com/")
+      assert.spy(s).was_called_with(ngx.HTTP_FORBIDDEN)
+    end)
+
+    it("allows every client of the locations without whitelist", function()
+      local s = spy.on(ngx, "exit")
+      ngx.var.remote_addr = "192.168.1.2"
+
+      location_settings.check_whitelist("example.com/api")
+      location_settings.check_whitelist("example.com/unknown")
+      assert.spy(s).was_not_called()
+    end)
+
+    it("rejects every client of the whitelisted locations without settings", function()
+      local s = spy.on(ngx, "exit")
+
+      location_settings.check_whitelist("example.com/unknown", true)
+      assert.spy(s).was_called_with(ngx.HTTP_FORBIDDEN)
+    end)
+
+    it("rejects every client of the whitelisted locations before the settings are configured", function()
+      ngx.shared.configuration_data:delete("locations")
+      local s = spy.on(ngx, "exit")
+
+      location_settings.check_whitelist("example.com/", true)
+      assert.spy(s).was_called_with(ngx.HTTP_FORBIDDEN)
+    end)
+
+    it("applies the settings of the whitelisted locations", function()
+      local s = spy.on(ngx, "exit")
+
+      location_settings.check_whitelist("example.com/", true)
+      assert.spy(s).was_not_called()
+    end)
+
+    it("applies the new settings without reloading", function()
+      local s = spy.on(ngx, "exit")
+      location_settings.check_whitelist("example.com/")
+      assert.spy(s).was_not_called()
+
+      ngx.shared.configuration_data:set("locations", cjson.encode({
+        ["example.com/"] = { whitelist = { "192.168.1.1/32" } },
+      }))
+
+      location_settings.check_whitelist("example.com/")
+      assert.spy(s).was_called_with(ngx.HTTP_FORBIDDEN)
+    end)
+  end)
+
+  describe("rewrite", function()
+    it("answers the CORS preflight requests", function()
+      local s = spy.on(ngx, "exit")
+      ngx.var.request_method = "OPTIONS"
+
+      location_settings.rewrite("example.com/api")
+      assert.spy(s).was_called_with(ngx.HTTP_NO_CONTENT)
+      assert.are.equal("https://origin.example.com", ngx.header["Access-Control-Allow-Origin"])
+      assert.are.equal("true", ngx.header["Access-Control-Allow-Credentials"])
+      assert.are.equal(600, ngx.header["Access-Control-Max-Age"])
+    end)
+
+    it("ignores the other requests", function()
+      local s = spy.on(ngx, "exit")
+
+      location_settings.rewrite("example.com/api")
+      assert.spy(s).was_not_called()
+      assert.is_nil(ngx.header["Access-Control-Allow-Origin"])
+    end)
+
+    it("ignores the locations without CORS", function()
+      local s = spy.on(ngx, "exit")
+      ngx.var.request_method = "OPTIONS"
+
+      location_settings.rewrite("example.com/")
+      assert.spy(s).was_not_called()
+    end)
+  end)
+
+  describe("header_filter", function()
+    it("adds the CORS headers", function()
+      location_settings.header_filter("example.com/api")
+
+      assert.are.equal("https://origin.example.com", ngx.header["Access-Control-Allow-Origin"])
+      assert.are.equal("GET, POST", ngx.header["Access-Control-Allow-Methods"])
+      assert.are.equal("Authorization", ngx.header["Access-Control-Allow-Headers"])
+      assert.is_nil(ngx.header["Access-Control-Max-Age"])
+    end)
+
+    it("does not add headers to the locations without CORS", function()
+      location_settings.header_filter("example.com/")
+
+      assert.is_nil(ngx.header["Access-Control-Allow-Origin"])
+    end)
+  end)
+end)
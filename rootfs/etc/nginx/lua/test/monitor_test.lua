@@ -41,6 +41,25 @@ describe("Monitor", function()
     assert.equal(10, #monitor.get_metrics_batch())
   end)
 
+  it("counts the retries of the request", function()
+    local monitor = require("monitor")
+
+    mock_ngx({ var = { upstream_addr = "10.10.0.1:8080" } })
+    monitor.call()
+    mock_ngx({ var = { upstream_addr = "10.10.0.1:8080, 10.10.0.2:8080 : 10.10.0.3:8080" } })
+    monitor.call()
+    mock_ngx({ var = { upstream_addr = "10.10.0.1:8080, 10.10.0.2:8080, 10.10.0.3:8080" },
+      ctx = { retry_budget_exhausted = true } })
+    monitor.call()
+
+    local batch = monitor.get_metrics_batch()
+    assert.is_nil(batch[1].upstreamRetries)
+    assert.equal(1, batch[2].upstreamRetries)
+    assert.equal(2, batch[3].upstreamRetries)
+    assert.is_nil(batch[2].retryBudgetExhausted)
+    assert.is_true(batch[3].retryBudgetExhausted)
+  end)
+
   describe("flush", function()
     it("short circuits when premmature is true (when worker is shutting down)", function()
       local tcp_mock = mock_ngx_socket_tcp()
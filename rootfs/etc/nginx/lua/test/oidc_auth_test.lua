@@ -0,0 +1,190 @@
+_G._TEST = true
+
+local cjson = require("cjson")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local ISSUER = "https://auth.example.com"
+
+local function new_config(overrides)
+  local config = {
+    issuer = ISSUER,
+    secret = "default/oidc-client",
+    scopes = { "openid", "email" },
+    redirect_path = "/oauth2/callback",
+    logout_path = "/oauth2/logout",
+    post_logout_redirect_uri = "",
+    groups_claim = "groups",
+    allowed_groups = {},
+    required_claims = {},
+    claim_headers = { email = "X-Email" },
+    session_lifetime = 3600,
+    cookie_name = "_oidc_01234567",
+  }
+  for k, v in pairs(overrides or {}) do
+    config[k] = v
+  end
+  return config
+end
+
+describe("oidc_auth", function()
+  local oidc_auth
+  local headers
+  local redirect
+
+  before_each(function()
+    ngx.shared.configuration_data:set("oidc", cjson.encode({
+      providers = {
+        [ISSUER] = {
+          issuer = ISSUER,
+          authorization_endpoint = ISSUER .. "/authorize",
+          token_endpoint = ISSUER .. "/token",
+          end_session_endpoint = ISSUER .. "/logout",
+          jwks_uri = ISSUER .. "/keys",
+        },
+      },
+      clients = {
+        ["default/oidc-client"] = {
+          client_id = "app",
+          client_secret = "secret",
+          cookie_secret = "MDEyMzQ1Njc4OWFiY2RlZg==",
+        },
+      },
+    }))
+
+    headers = { ["X-Email"] = "spoofed" }
+    redirect = nil
+    mock_ngx({
+      var = { host = "www.example.com", request_uri = "/app?a=b", https = "on" },
+      header = {},
+      time = function() return 1570000000 end,
+      exit = function(status) return status end,
+      redirect = function(url) redirect = url return ngx.HTTP_MOVED_TEMPORARILY end,
+      req = {
+        get_method = function() return "GET" end,
+        clear_header = function(name) headers[name] = nil end,
+        set_header = function(name, value) headers[name] = value end,
+      },
+    })
+    oidc_auth = require("oidc_auth")
+  end)
+
+  after_each(function()
+    reset_ngx()
+    ngx.shared.configuration_data:delete("oidc")
+    package.loaded["oidc_auth"] = nil
+  end)
+
+  describe("access", function()
+    it("redirects the users without session to the provider", function()
+      oidc_auth.access(new_config())
+
+      assert.is_nil(headers["X-Email"])
+      assert.is_truthy(redirect:find(ISSUER .. "/authorize?", 1, true))
+      assert.is_truthy(redirect:find("code_challenge_method=S256", 1, true))
+      assert.is_truthy(redirect:find("client_id=app", 1, true))
+      assert.is_truthy(ngx.header["Set-Cookie"][1]:find("^_oidc_01234567_state=.*; Secure$"))
+    end)
+
+    it("rejects the requests without session that cannot be redirected", function()
+      local s = spy.on(ngx, "exit")
+      ngx.req.get_method = function() return "POST" end
+
+      oidc_auth.access(new_config())
+      assert.spy(s).was_called_with(ngx.HTTP_UNAUTHORIZED)
+      assert.is_nil(redirect)
+    end)
+
+    it("rejects the requests when the provider is not available", function()
+      local s = spy.on(ngx, "exit")
+
+      oidc_auth.access(new_config({ issuer = "https://other.example.com" }))
+      assert.spy(s).was_called_with(ngx.HTTP_SERVICE_UNAVAILABLE)
+    end)
+  end)
+
+  describe("logout", function()
+    it("removes the session and redirects to the provider", function()
+      oidc_auth.logout(new_config({ post_logout_redirect_uri = "https://www.example.com" }))
+
+      assert.is_truthy(ngx.header["Set-Cookie"][1]:find("^_oidc_01234567=; Path=/; Max%-Age=0"))
+      assert.is_truthy(redirect:find(ISSUER .. "/logout?", 1, true))
+      assert.is_truthy(redirect:find("post_logout_redirect_uri=https%3A%2F%2Fwww.example.com", 1, true))
+    end)
+  end)
+
+  describe("seal", function()
+    local client = {
+      encryption_key = string.rep("k", 32),
+      mac_key = string.rep("m", 32),
+    }
+
+    it("encrypts and authenticates the values", function()
+      local sealed = oidc_auth.seal(client, "session", { sub = "user-1" })
+      assert.is_falsy(sealed:find("user-1", 1, true))
+      assert.are.same({ sub = "user-1" }, oidc_auth.unseal(client, "session", sealed))
+    end)
+
+    it("rejects modified values and values of other cookies", function()
+      local sealed = oidc_auth.seal(client, "session", { sub = "user-1" })
+      local modified = sealed:sub(1, 10) .. (sealed:sub(11, 11) == "A" and "B" or "A") .. sealed:sub(12)
+
+      assert.is_nil(oidc_auth.unseal(client, "session", modified))
+      assert.is_nil(oidc_auth.unseal(client, "state", sealed))
+    end)
+  end)
+
+  describe("save_session", function()
+    local client = {
+      encryption_key = string.rep("k", 32),
+      mac_key = string.rep("m", 32),
+    }
+
+    it("stores the session without the refresh token when it is too large", function()
+      local session = {
+        claims = { sub = "user-1" },
+        refresh_token = string.rep("r", 4096),
+        created = ngx.time(),
+      }
+
+      assert.is_true(oidc_auth.save_session(new_config(), client, session))
+      assert.is_nil(session.refresh_token)
+
+      local value = ngx.header["Set-Cookie"][1]:match("^_oidc_01234567=([^;]+);")
+      assert.are.same({ sub = "user-1" }, oidc_auth.unseal(client, "_oidc_01234567", value).claims)
+    end)
+
+    it("fails when the session is too large without the refresh token", function()
+      local session = {
+        claims = { sub = "user-1", groups = { string.rep("g", 4096) } },
+        refresh_token = "token",
+        created = ngx.time(),
+      }
+
+      local ok, err = oidc_auth.save_session(new_config(), client, session)
+      assert.is_false(ok)
+      assert.is_truthy(err:find("larger than the limit of 4096 bytes", 1, true))
+      assert.is_nil(ngx.header["Set-Cookie"])
+    end)
+  end)
+
+  describe("authorize", function()
+    it("validates the groups and the required claims", function()
+      local claims = { sub = "user-1", groups = { "dev", "ops" }, email_verified = true }
+
+      assert.is_true(oidc_auth.authorize(new_config({ allowed_groups = { "ops" } }), claims))
+      assert.is_false(oidc_auth.authorize(new_config({ allowed_groups = { "admins" } }), claims))
+      assert.is_true(oidc_auth.authorize(new_config({ required_claims = { email_verified = "true" } }), claims))
+      assert.is_false(oidc_auth.authorize(new_config({ required_claims = { email = "" } }), claims))
+    end)
+  end)
+end)
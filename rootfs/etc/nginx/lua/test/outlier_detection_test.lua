@@ -0,0 +1,146 @@
+_G._TEST = true
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local function new_backend()
+  local endpoints = {}
+  for i = 1, 10 do
+    table.insert(endpoints, { address = "10.184.7." .. i, port = "8080", maxFails = 0, failTimeout = 0 })
+  end
+
+  return {
+    name = "default-app-80",
+    outlierDetection = {
+      consecutiveErrors = 3, errorRate = 0, minRequests = 10, interval = 10,
+      baseEjectionTime = 30, maxEjectionTime = 300, maxEjectionPercent = 20,
+    },
+    endpoints = endpoints,
+  }
+end
+
+describe("outlier_detection", function()
+  local outlier_detection
+  local backend
+
+  before_each(function()
+    ngx.shared.outlier_detection:flush_all()
+    outlier_detection = require("outlier_detection")
+    backend = new_backend()
+    outlier_detection.sync({ backend })
+  end)
+
+  after_each(function()
+    reset_ngx()
+    package.loaded["outlier_detection"] = nil
+  end)
+
+  local function request(upstream_addr, upstream_status)
+    mock_ngx({ var = { upstream_addr = upstream_addr, upstream_status = upstream_status } })
+    outlier_detection.after_request(backend.name)
+    reset_ngx()
+  end
+
+  local function available_addresses()
+    local addresses = {}
+    for _, endpoint in ipairs(outlier_detection.available_endpoints(backend)) do
+      table.insert(addresses, endpoint.address)
+    end
+    return addresses
+  end
+
+  describe("split()", function()
+    it("splits the attempts and the internal redirects", function()
+      assert.are.same({ "10.184.7.1:8080" }, outlier_detection.split("10.184.7.1:8080"))
+      assert.are.same({ "10.184.7.1:8080", "10.184.7.2:8080", "10.184.7.3:8080" },
+        outlier_detection.split("10.184.7.1:8080, 10.184.7.2:8080 : 10.184.7.3:8080"))
+      assert.are.same({}, outlier_detection.split(nil))
+    end)
+  end)
+
+  describe("after_request()", function()
+    it("ejects an endpoint after the consecutive errors", function()
+      request("10.184.7.1:8080", "502")
+      request("10.184.7.1:8080", "503")
+      assert.is_false(outlier_detection.is_ejected(backend.name, "10.184.7.1:8080"))
+
+      request("10.184.7.1:8080", "504")
+      assert.is_true(outlier_detection.is_ejected(backend.name, "10.184.7.1:8080"))
+      assert.are.equal(9, #available_addresses())
+    end)
+
+    it("resets the consecutive errors after a success", function()
+      request("10.184.7.1:8080", "502")
+      request("10.184.7.1:8080", "502")
+      request("10.184.7.1:8080", "200")
+      request("10.184.7.1:8080", "502")
+      assert.is_false(outlier_detection.is_ejected(backend.name, "10.184.7.1:8080"))
+    end)
+
+    it("counts the attempts without status as errors", function()
+      request("10.184.7.1:8080, 10.184.7.2:8080", "-, 200")
+      request("10.184.7.1:8080, 10.184.7.2:8080", "-, 200")
+      request("10.184.7.1:8080, 10.184.7.2:8080", "-, 200")
+      assert.is_true(outlier_detection.is_ejected(backend.name, "10.184.7.1:8080"))
+      assert.is_false(outlier_detection.is_ejected(backend.name, "10.184.7.2:8080"))
+    end)
+
+    it("ejects an endpoint when the error rate is reached", function()
+      backend.outlierDetection.consecutiveErrors = 0
+      backend.outlierDetection.errorRate = 50
+      outlier_detection.sync({ backend })
+
+      for _ = 1, 4 do
+        request("10.184.7.1:8080", "200")
+        request("10.184.7.1:8080", "500")
+      end
+      assert.is_false(outlier_detection.is_ejected(backend.name, "10.184.7.1:8080"))
+
+      request("10.184.7.1:8080", "200")
+      request("10.184.7.1:8080", "500")
+      assert.is_true(outlier_detection.is_ejected(backend.name, "10.184.7.1:8080"))
+    end)
+
+    it("does not eject more than the maximum percentage of endpoints", function()
+      for i = 1, 3 do
+        for _ = 1, 3 do
+          request("10.184.7." .. i .. ":8080", "500")
+        end
+      end
+
+      assert.is_true(outlier_detection.is_ejected(backend.name, "10.184.7.1:8080"))
+      assert.is_true(outlier_detection.is_ejected(backend.name, "10.184.7.2:8080"))
+      assert.is_false(outlier_detection.is_ejected(backend.name, "10.184.7.3:8080"))
+    end)
+
+    it("ignores the backends without outlier detection", function()
+      for _ = 1, 3 do
+        mock_ngx({ var = { upstream_addr = "10.184.7.1:8080", upstream_status = "500" } })
+        outlier_detection.after_request("default-other-80")
+        reset_ngx()
+      end
+      assert.is_false(outlier_detection.is_ejected("default-other-80", "10.184.7.1:8080"))
+    end)
+  end)
+
+  describe("available_endpoints()", function()
+    it("returns every endpoint when all of them are ejected", function()
+      backend.endpoints = { backend.endpoints[1] }
+      outlier_detection.sync({ backend })
+
+      for _ = 1, 3 do
+        request("10.184.7.1:8080", "500")
+      end
+      assert.is_true(outlier_detection.is_ejected(backend.name, "10.184.7.1:8080"))
+      assert.are.same({ "10.184.7.1" }, available_addresses())
+    end)
+  end)
+end)
@@ -0,0 +1,87 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("retry_budget", function()
+  local retry_budget
+  local backend_name = "default-app-80"
+  local policy = { per_try_timeout = 0, budget_percent = 20, budget_min_retries = 1 }
+
+  before_each(function()
+    ngx.shared.retry_budget:flush_all()
+    retry_budget = require("retry_budget")
+  end)
+
+  after_each(function()
+    reset_ngx()
+    package.loaded["retry_budget"] = nil
+  end)
+
+  -- request starts a new request and returns its variables
+  local function request()
+    local var = { retry_budget_backend = "", retry_budget_retrying = "" }
+    mock_ngx({ var = var })
+    retry_budget.start(backend_name, policy)
+    return var
+  end
+
+  local function with(var, fn)
+    mock_ngx({ var = var })
+    return fn()
+  end
+
+  it("allows the minimum of retries", function()
+    local first = request()
+    local second = request()
+
+    assert.is_true(with(first, function() return retry_budget.allow(policy) end))
+    assert.is_false(with(second, function() return retry_budget.allow(policy) end))
+  end)
+
+  it("allows a percentage of the active requests to be retried", function()
+    local requests = {}
+    for i = 1, 10 do
+      requests[i] = request()
+    end
+
+    assert.is_true(with(requests[1], function() return retry_budget.allow(policy) end))
+    assert.is_true(with(requests[2], function() return retry_budget.allow(policy) end))
+    assert.is_false(with(requests[3], function() return retry_budget.allow(policy) end))
+
+    -- the next attempts of a request being retried are allowed
+    assert.is_true(with(requests[1], function() return retry_budget.allow(policy) end))
+
+    with(requests[1], retry_budget.release)
+    assert.are.equal("", requests[1].retry_budget_backend)
+    request()
+    assert.is_true(with(requests[3], function() return retry_budget.allow(policy) end))
+  end)
+
+  it("releases the active requests and retries", function()
+    local var = request()
+    with(var, function() return retry_budget.allow(policy) end)
+    with(var, retry_budget.release)
+    with(var, retry_budget.release)
+
+    assert.are.equal(0, ngx.shared.retry_budget:get("active:" .. backend_name))
+    assert.are.equal(0, ngx.shared.retry_budget:get("retries:" .. backend_name))
+  end)
+
+  it("allows every retry without budget", function()
+    local no_budget = { per_try_timeout = 2, budget_percent = 0, budget_min_retries = 3 }
+    for _ = 1, 5 do
+      local var = { retry_budget_backend = "", retry_budget_retrying = "" }
+      mock_ngx({ var = var })
+      retry_budget.start(backend_name, no_budget)
+      assert.are.equal("", var.retry_budget_backend)
+      assert.is_true(retry_budget.allow(no_budget))
+    end
+  end)
+end)
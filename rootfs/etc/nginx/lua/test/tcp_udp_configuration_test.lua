@@ -0,0 +1,66 @@
+local tcp_udp_configuration = require("tcp_udp_configuration")
+
+local unmocked_ngx = _G.ngx
+local tcp_udp_configuration_data = ngx.shared.tcp_udp_configuration_data
+
+local function mock_socket(line)
+  local sock = {
+    sent = nil,
+    receiveuntil = function(self, pattern)
+      return function() return line end
+    end,
+    send = function(self, data)
+      self.sent = data
+      return #data
+    end,
+  }
+
+  local _ngx = {
+    req = {
+      socket = function(raw) return sock end,
+    },
+    log = function() end,
+    say = function() end,
+  }
+  setmetatable(_ngx, { __index = unmocked_ngx })
+  _G.ngx = _ngx
+
+  return sock
+end
+
+describe("TCP/UDP configuration", function()
+  before_each(function()
+    tcp_udp_configuration_data:flush_all()
+  end)
+
+  after_each(function()
+    _G.ngx = unmocked_ngx
+  end)
+
+  it("stores the backends sent by the controller", function()
+    local backends = '[{"port":8080}]'
+    local sock = mock_socket(backends)
+
+    assert.has_no.errors(tcp_udp_configuration.call)
+    assert.equal(backends, tcp_udp_configuration.get_backends_data())
+    assert.is_nil(sock.sent)
+  end)
+
+  it("sends the current backends on GET", function()
+    local backends = '[{"port":8080}]'
+    tcp_udp_configuration_data:set("backends", backends)
+    local sock = mock_socket("GET")
+
+    assert.has_no.errors(tcp_udp_configuration.call)
+    assert.equal(backends .. "\r\n", sock.sent)
+    assert.equal(backends, tcp_udp_configuration.get_backends_data())
+  end)
+
+  it("sends an empty list on GET without backends", function()
+    local sock = mock_socket("GET")
+
+    assert.has_no.errors(tcp_udp_configuration.call)
+    assert.equal("[]\r\n", sock.sent)
+    assert.is_nil(tcp_udp_configuration.get_backends_data())
+  end)
+end)
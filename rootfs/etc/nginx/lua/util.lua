@@ -87,4 +87,22 @@ local function replace_special_char(str, a, b)
 end
 _M.replace_special_char = replace_special_char
 
+-- decodes a base64url encoded string (RFC 4648), adding the missing padding
+function _M.decode_base64url(s)
+  s = s:gsub("%-", "+"):gsub("_", "/")
+  local padding = #s % 4
+  if padding == 1 then
+    return nil
+  end
+  if padding > 0 then
+    s = s .. string.rep("=", 4 - padding)
+  end
+  return ngx.decode_base64(s)
+end
+
+-- encodes a string using base64url without padding (RFC 4648)
+function _M.encode_base64url(s)
+  return (ngx.encode_base64(s, true):gsub("%+", "-"):gsub("/", "_"))
+end
+
 return _M
@@ -18,6 +18,8 @@ package annotations
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -36,6 +38,24 @@ var _ = framework.IngressNginxDescribe("Annotations - CORS", func() {
 	AfterEach(func() {
 	})
 
+	// corsRequest sends a request to the host once the CORS settings of the
+	// location are applied by Lua
+	corsRequest := func(host string, method string) gorequest.Response {
+		f.WaitForNginxServer(host,
+			func(server string) bool {
+				return strings.Contains(server, "location_settings.header_filter")
+			})
+		time.Sleep(waitForLuaSync)
+
+		resp, _, errs := gorequest.New().
+			CustomMethod(method, f.GetURL(framework.HTTP)+"/").
+			Set("Host", host).
+			End()
+		Expect(errs).Should(BeEmpty())
+
+		return resp
+	}
+
 	It("should enable cors", func() {
 		host := "cors.foo.com"
 		annotations := map[string]string{
@@ -45,38 +65,16 @@ var _ = framework.IngressNginxDescribe("Annotations - CORS", func() {
 		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, "http-svc", 80, &annotations)
 		f.EnsureIngress(ing)
 
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).Should(ContainSubstring("more_set_headers 'Access-Control-Allow-Methods: GET, PUT, POST, DELETE, PATCH, OPTIONS';"))
-			})
-
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).Should(ContainSubstring("more_set_headers 'Access-Control-Allow-Origin: *';"))
-			})
-
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).Should(ContainSubstring("more_set_headers 'Access-Control-Allow-Headers: DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Authorization';"))
-			})
-
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).Should(ContainSubstring("more_set_headers 'Access-Control-Max-Age: 1728000';"))
-			})
-
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).Should(ContainSubstring("more_set_headers 'Access-Control-Allow-Credentials: true';"))
-			})
+		resp := corsRequest(host, http.MethodGet)
+		Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Access-Control-Allow-Methods")).Should(Equal("GET, PUT, POST, DELETE, PATCH, OPTIONS"))
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).Should(Equal("*"))
+		Expect(resp.Header.Get("Access-Control-Allow-Headers")).Should(Equal("DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Authorization"))
+		Expect(resp.Header.Get("Access-Control-Allow-Credentials")).Should(Equal("true"))
 
-		uri := "/"
-		resp, _, errs := gorequest.New().
-			Options(f.GetURL(framework.HTTP)+uri).
-			Set("Host", host).
-			End()
-		Expect(errs).Should(BeEmpty())
+		resp = corsRequest(host, http.MethodOptions)
 		Expect(resp.StatusCode).Should(Equal(http.StatusNoContent))
+		Expect(resp.Header.Get("Access-Control-Max-Age")).Should(Equal("1728000"))
 	})
 
 	It("should set cors methods to only allow POST, GET", func() {
@@ -89,10 +87,8 @@ var _ = framework.IngressNginxDescribe("Annotations - CORS", func() {
 		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, "http-svc", 80, &annotations)
 		f.EnsureIngress(ing)
 
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).Should(ContainSubstring("more_set_headers 'Access-Control-Allow-Methods: POST, GET';"))
-			})
+		resp := corsRequest(host, http.MethodGet)
+		Expect(resp.Header.Get("Access-Control-Allow-Methods")).Should(Equal("POST, GET"))
 	})
 
 	It("should set cors max-age", func() {
@@ -105,10 +101,8 @@ var _ = framework.IngressNginxDescribe("Annotations - CORS", func() {
 		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, "http-svc", 80, &annotations)
 		f.EnsureIngress(ing)
 
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).Should(ContainSubstring("more_set_headers 'Access-Control-Max-Age: 200';"))
-			})
+		resp := corsRequest(host, http.MethodOptions)
+		Expect(resp.Header.Get("Access-Control-Max-Age")).Should(Equal("200"))
 	})
 
 	It("should disable cors allow credentials", func() {
@@ -121,10 +115,8 @@ var _ = framework.IngressNginxDescribe("Annotations - CORS", func() {
 		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, "http-svc", 80, &annotations)
 		f.EnsureIngress(ing)
 
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).ShouldNot(ContainSubstring("more_set_headers 'Access-Control-Allow-Credentials: true';"))
-			})
+		resp := corsRequest(host, http.MethodGet)
+		Expect(resp.Header.Get("Access-Control-Allow-Credentials")).Should(BeEmpty())
 	})
 
 	It("should allow origin for cors", func() {
@@ -137,10 +129,8 @@ var _ = framework.IngressNginxDescribe("Annotations - CORS", func() {
 		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, "http-svc", 80, &annotations)
 		f.EnsureIngress(ing)
 
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).Should(ContainSubstring("more_set_headers 'Access-Control-Allow-Origin: https://origin.cors.com:8080';"))
-			})
+		resp := corsRequest(host, http.MethodGet)
+		Expect(resp.Header.Get("Access-Control-Allow-Origin")).Should(Equal("https://origin.cors.com:8080"))
 	})
 
 	It("should allow headers for cors", func() {
@@ -153,9 +143,7 @@ var _ = framework.IngressNginxDescribe("Annotations - CORS", func() {
 		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, "http-svc", 80, &annotations)
 		f.EnsureIngress(ing)
 
-		f.WaitForNginxServer(host,
-			func(server string) bool {
-				return Expect(server).Should(ContainSubstring("more_set_headers 'Access-Control-Allow-Headers: DNT, User-Agent';"))
-			})
+		resp := corsRequest(host, http.MethodGet)
+		Expect(resp.Header.Get("Access-Control-Allow-Headers")).Should(Equal("DNT, User-Agent"))
 	})
 })
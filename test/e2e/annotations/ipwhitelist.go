@@ -17,9 +17,13 @@ limitations under the License.
 package annotations
 
 import (
+	"net/http"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/parnurzeal/gorequest"
 
 	"k8s.io/ingress-nginx/test/e2e/framework"
 )
@@ -45,10 +49,36 @@ var _ = framework.IngressNginxDescribe("Annotations - IPWhiteList", func() {
 		ing := framework.NewSingleIngress(host, "/", host, nameSpace, "http-svc", 80, &annotations)
 		f.EnsureIngress(ing)
 
+		f.WaitForNginxServer(host,
+			func(server string) bool {
+				return strings.Contains(server, "location_settings.check_whitelist") &&
+					!strings.Contains(server, "deny all;")
+			})
+		time.Sleep(waitForLuaSync)
+
+		resp, _, errs := gorequest.New().
+			Get(f.GetURL(framework.HTTP)).
+			Set("Host", host).
+			End()
+		Expect(errs).Should(BeEmpty())
+		Expect(resp.StatusCode).Should(Equal(http.StatusForbidden))
+	})
+
+	It("should enforce ip whitelist ranges with IPv6 addresses in NGINX", func() {
+		host := "ipwhitelist.foo.com"
+		nameSpace := f.Namespace
+
+		annotations := map[string]string{
+			"nginx.ingress.kubernetes.io/whitelist-source-range": "18.0.0.0/8, 2001:db8::/32",
+		}
+
+		ing := framework.NewSingleIngress(host, "/", host, nameSpace, "http-svc", 80, &annotations)
+		f.EnsureIngress(ing)
+
 		f.WaitForNginxServer(host,
 			func(server string) bool {
 				return strings.Contains(server, "allow 18.0.0.0/8;") &&
-					strings.Contains(server, "allow 56.0.0.0/8;") &&
+					strings.Contains(server, "allow 2001:db8::/32;") &&
 					strings.Contains(server, "deny all;")
 			})
 	})
@@ -188,6 +188,39 @@ var _ = framework.IngressNginxDescribe("Dynamic Configuration", func() {
 
 			Expect(nginxConfig).Should(Equal(newNginxConfig))
 		})
+
+		It("handles whitelist and CORS changes", func() {
+			var nginxConfig string
+			f.WaitForNginxConfiguration(func(cfg string) bool {
+				nginxConfig = cfg
+				return true
+			})
+
+			ingress, err := f.KubeClientSet.ExtensionsV1beta1().Ingresses(f.Namespace).Get("foo.com", metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			ingress.ObjectMeta.Annotations["nginx.ingress.kubernetes.io/whitelist-source-range"] = "18.0.0.0/8"
+			ingress.ObjectMeta.Annotations["nginx.ingress.kubernetes.io/enable-cors"] = "true"
+			_, err = f.KubeClientSet.ExtensionsV1beta1().Ingresses(f.Namespace).Update(ingress)
+			Expect(err).ToNot(HaveOccurred())
+			time.Sleep(waitForLuaSync)
+
+			resp, _, errs := gorequest.New().
+				Get(f.GetURL(framework.HTTP)).
+				Set("Host", "foo.com").
+				End()
+			Expect(errs).Should(BeEmpty())
+			Expect(resp.StatusCode).Should(Equal(http.StatusForbidden))
+			Expect(resp.Header.Get("Access-Control-Allow-Origin")).Should(Equal("*"))
+
+			var newNginxConfig string
+			f.WaitForNginxConfiguration(func(cfg string) bool {
+				newNginxConfig = cfg
+				return true
+			})
+
+			Expect(nginxConfig).Should(Equal(newNginxConfig))
+		})
 	})
 
 	It("handles a non backend update", func() {
@@ -42,12 +42,9 @@ var _ = framework.IngressNginxDescribe("Configmap change", func() {
 		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, "http-svc", 80, nil)
 		f.EnsureIngress(ing)
 
-		wlKey := "whitelist-source-range"
-		wlValue := "1.1.1.1"
+		By("enabling gzip compression")
 
-		By("adding a whitelist-source-range")
-
-		f.UpdateNginxConfigMapData(wlKey, wlValue)
+		f.UpdateNginxConfigMapData("use-gzip", "true")
 
 		checksumRegex := regexp.MustCompile("Configuration checksum:\\s+(\\d+)")
 		checksum := ""
@@ -60,7 +57,7 @@ var _ = framework.IngressNginxDescribe("Configmap change", func() {
 					checksum = match[1]
 				}
 
-				return strings.Contains(cfg, "allow 1.1.1.1;")
+				return strings.Contains(cfg, "gzip on;")
 			})
 		Expect(checksum).NotTo(BeEmpty())
 